@@ -17,15 +17,23 @@
 package cmd
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/chartutil"
 
 	"github.com/redhat-certification/chart-verifier/pkg/chartverifier"
+	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
 )
 
 var Version = "1.0.0"
@@ -42,12 +50,113 @@ var (
 	enabledChecksFlag []string
 	// disabledChecksFlag are the checks that should not be performed.
 	disabledChecksFlag []string
-	// outputFormatFlag contains the output format the user has specified: default, yaml or json.
+	// outputFormatFlag contains the output formats the user has specified: default, yaml, json, badge or cyclonedx.
 	outputFormatFlag string
+	// outputFilePrefixFlag contains the prefix of the files the report should be written to, one per output format.
+	outputFilePrefixFlag string
 	// setOverridesFlag contains the overrides the user has specified through the --set flag.
 	setOverridesFlag []string
+	// setValuesFlag contains the chart values the user has specified through the --set-value flag.
+	setValuesFlag []string
+	// setStringValuesFlag contains the chart values the user has specified through the --set-string flag.
+	setStringValuesFlag []string
+	// valuesProfilesFlag contains the name=path values profiles the user has specified through the --values-profile flag.
+	valuesProfilesFlag []string
+	// onlyFailuresFlag indicates only the results of failed checks should be present in the output.
+	onlyFailuresFlag bool
+	// notifyUrlFlag contains the webhook url the report should be posted to once the verification has finished.
+	notifyUrlFlag string
+	// notifyRequiredFlag indicates the verification should fail when the report couldn't be posted to the webhook.
+	notifyRequiredFlag bool
+	// failOnFlag contains which check failures turn the outcome negative: optional, mandatory or none.
+	failOnFlag string
+	// openShiftVersionsFlag contains the OpenShift versions the chart should be verified against.
+	openShiftVersionsFlag []string
+	// kubeVersionFlag contains the Kubernetes version the chart should be verified against.
+	kubeVersionFlag string
+	// annotationsFlag contains the key=value annotations to be included in the report.
+	annotationsFlag []string
+	// quietFlag indicates only the report should be written to stdout, and diagnostic messages should be suppressed.
+	quietFlag bool
+	// redactHostsFlag indicates hostnames should be masked in the report, except for the allowlisted domains.
+	redactHostsFlag bool
+	// redactAllowlistFlag contains the domains whose hostnames are kept readable when redacting the report.
+	redactAllowlistFlag []string
+	// usernameFlag contains the username used to authenticate when retrieving charts over HTTP.
+	usernameFlag string
+	// passwordFlag contains the password used to authenticate when retrieving charts over HTTP.
+	passwordFlag string
+	// caFileFlag contains the path of the CA bundle trusted, in addition to the system's, when retrieving charts and
+	// reaching HTTPS services from checks.
+	caFileFlag string
+	// insecureSkipTLSVerifyFlag indicates server certificates should not be verified when reaching HTTPS services.
+	insecureSkipTLSVerifyFlag bool
+	// timestampFlag contains the RFC 3339 time the report is declared to have been generated at, for reproducible reports.
+	timestampFlag string
+	// ledgerFlag contains the path of the ledger a summary of the verification should be appended to.
+	ledgerFlag string
+	// noNetworkFlag indicates checks requiring network access should be skipped.
+	noNetworkFlag bool
+	// noClusterFlag indicates checks requiring cluster access should be skipped.
+	noClusterFlag bool
+	// metadataOnlyFlag indicates only checks neither rendering templates nor reaching the network or the cluster
+	// should be executed.
+	metadataOnlyFlag bool
+	// maxRequestsPerSecondFlag contains the maximum rate of the outbound requests performed by checks.
+	maxRequestsPerSecondFlag float64
+	// failFastFlag indicates the verification should stop at the first failed mandatory check.
+	failFastFlag bool
+	// buildDependenciesFlag indicates the dependencies declared by the chart should be retrieved before verifying it.
+	buildDependenciesFlag bool
+	// suggestFixesFlag indicates checks able to compute a remediation for their findings should suggest it.
+	suggestFixesFlag bool
+	// suggestedFixesFileFlag contains the path of the file the suggested fixes should be written to.
+	suggestedFixesFileFlag string
+	// sinksFlag contains the destinations the report should be written to, instead of stdout.
+	sinksFlag []string
+	// streamFlag indicates each check result should be written to stdout as a JSON line as soon as it completes.
+	streamFlag bool
+	// expectFlag contains the outcomes checks are expected to have, as check=outcome.
+	expectFlag []string
+	// validateSchemaFlag indicates the report should be checked against the report schema before being written.
+	validateSchemaFlag bool
+	// totalTimeoutFlag contains the maximum duration of the verification.
+	totalTimeoutFlag time.Duration
+	// checkpointFlag contains the path of the checkpoint the outcome of each completed check should be persisted to.
+	checkpointFlag string
+	// resumeFlag indicates the outcomes already persisted to the checkpoint should be reused.
+	resumeFlag bool
+	// sbomsFlag contains the SBOMs the cyclonedx output format links to, as path or image=path.
+	sbomsFlag []string
+	// baselineFlag contains the path of the report whose failed checks are accepted as known failures.
+	baselineFlag string
 )
 
+// envBindings maps the flags which can also be informed through environment variables, or keys of the same name in
+// the configuration file, to their environment variables.
+var envBindings = map[string]string{
+	"enable":            "CHART_VERIFIER_ENABLE_CHECKS",
+	"disable":           "CHART_VERIFIER_DISABLE_CHECKS",
+	"output":            "CHART_VERIFIER_OUTPUT",
+	"openshift-version": "CHART_VERIFIER_OPENSHIFT_VERSION",
+	"kube-version":      "CHART_VERIFIER_KUBE_VERSION",
+}
+
+// getStringSliceConfig returns the strings configured for key; comma separated strings, as informed through
+// environment variables, are split.
+func getStringSliceConfig(config *viper.Viper, key string) []string {
+	if s, ok := config.Get(key).(string); ok {
+		values := make([]string, 0)
+		for _, v := range strings.Split(s, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		return values
+	}
+	return config.GetStringSlice(key)
+}
+
 func filterChecks(set []string, subset []string, setEnabled bool, subsetEnabled bool) ([]string, error) {
 	selected := make([]string, 0)
 	seen := map[string]bool{}
@@ -60,14 +169,297 @@ func filterChecks(set []string, subset []string, setEnabled bool, subsetEnabled
 		}
 		seen[v] = subsetEnabled
 	}
-	for k, v := range seen {
-		if v {
-			selected = append(selected, k)
+	// keep the order of set, so checks are executed in a predictable order
+	for _, v := range set {
+		if seen[v] {
+			selected = append(selected, v)
+			seen[v] = false
 		}
 	}
 	return selected, nil
 }
 
+// outputExtensions maps the supported output formats to the extension of the files they're written to.
+var outputExtensions = map[string]string{
+	"default":   "txt",
+	"json":      "json",
+	"yaml":      "yaml",
+	"badge":     "badge.json",
+	"cyclonedx": "cdx.json",
+}
+
+// parseOutputFormats validates the given output formats, defaulting to the default format; several formats can only
+// be written to files.
+func parseOutputFormats(formats []string, filePrefix string) ([]string, error) {
+	if len(formats) == 0 {
+		return []string{"default"}, nil
+	}
+	if len(formats) > 1 && filePrefix == "" {
+		return nil, errors.New("--output-file-prefix is required when several output formats are informed")
+	}
+	for _, format := range formats {
+		if _, ok := outputExtensions[format]; !ok {
+			return nil, errors.Errorf("output format %q is unknown", format)
+		}
+	}
+	return formats, nil
+}
+
+// formatCertificate serializes the certificate in the given output format; bom is the CycloneDX document describing
+// the verification, only required by the cyclonedx format.
+func formatCertificate(result chartverifier.Certificate, format string, bom *chartverifier.CycloneDXDocument) (string, error) {
+	switch format {
+	case "json":
+		b, err := json.Marshal(result)
+		if err != nil {
+			return "", err
+		}
+		return string(b) + "\n", nil
+	case "yaml":
+		b, err := yaml.Marshal(result)
+		if err != nil {
+			return "", err
+		}
+		return string(b) + "\n", nil
+	case "badge":
+		b, err := json.Marshal(chartverifier.NewBadge(result))
+		if err != nil {
+			return "", err
+		}
+		return string(b) + "\n", nil
+	case "cyclonedx":
+		b, err := json.Marshal(bom)
+		if err != nil {
+			return "", err
+		}
+		return string(b) + "\n", nil
+	default:
+		return fmt.Sprint(result), nil
+	}
+}
+
+// validateReportSchema checks the JSON report of result conforms to the report schema.
+func validateReportSchema(result chartverifier.Certificate) error {
+	b, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	report, err := chartverifier.LoadReport(bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	return chartverifier.ValidateReportSchema(report)
+}
+
+// sinkContentTypes maps the output formats to the content type the report is posted to webhook sinks with.
+var sinkContentTypes = map[string]string{
+	"default":   "text/plain",
+	"json":      "application/json",
+	"yaml":      "application/yaml",
+	"badge":     "application/json",
+	"cyclonedx": "application/vnd.cyclonedx+json",
+}
+
+// requiredSinkSuffix marks the sinks the report must be delivered to for the verification to succeed.
+const requiredSinkSuffix = ",required"
+
+// parseSinks parses the given stdout, file=<path> or webhook=<url> sinks, optionally followed by ",required"; the
+// report is written to them in the given output format.
+func parseSinks(cmd *cobra.Command, specs []string, formats []string, filePrefix string) ([]chartverifier.ReportSink, error) {
+	if len(specs) == 0 {
+		return nil, nil
+	}
+	if filePrefix != "" {
+		return nil, errors.New("--sink and --output-file-prefix can't be used at the same time")
+	}
+	if len(formats) > 1 {
+		return nil, errors.New("--sink can only be used with a single output format")
+	}
+
+	var sinks []chartverifier.ReportSink
+	for _, spec := range specs {
+		name := strings.TrimSuffix(spec, requiredSinkSuffix)
+		sink := chartverifier.ReportSink{Name: name, Required: name != spec}
+
+		parts := strings.SplitN(name, "=", 2)
+		switch {
+		case name == "stdout":
+			sink.Writer = cmd.OutOrStdout()
+		case len(parts) == 2 && parts[0] == "file" && parts[1] != "":
+			sink.Writer = chartverifier.NewFileSink(parts[1])
+		case len(parts) == 2 && parts[0] == "webhook" && parts[1] != "":
+			sink.Writer = chartverifier.NewWebhookSink(parts[1], sinkContentTypes[formats[0]])
+		default:
+			return nil, errors.Errorf("sink %q must be one of stdout, file=<path> or webhook=<url>", spec)
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks, nil
+}
+
+// validateStream ensures nothing else is written to stdout along with the stream, so it stays valid newline delimited
+// JSON.
+func validateStream(sinks []string) error {
+	for _, spec := range sinks {
+		if strings.TrimSuffix(spec, requiredSinkSuffix) == "stdout" {
+			return errors.New("--stream and --sink stdout can't be used at the same time")
+		}
+	}
+	return nil
+}
+
+// streamCheckComplete returns the callback writing each completed check to the stream, honoring --only-failures and
+// --redact-hosts.
+func streamCheckComplete(stream *chartverifier.ResultStream, redactor *chartverifier.HostRedactor) chartverifier.CheckCompleteFunc {
+	return func(name string, r checks.Result) {
+		if onlyFailuresFlag && r.Ok {
+			return
+		}
+		if redactor != nil {
+			r = redactor.RedactResult(r)
+		}
+		stream.OnCheckComplete(name, r)
+	}
+}
+
+// parseSBOMs loads the given SBOMs, informed either as path or image=path, ensuring they're only informed along with
+// the cyclonedx output format, which links them.
+func parseSBOMs(specs []string, formats []string) ([]chartverifier.SBOM, error) {
+	cycloneDX := false
+	for _, format := range formats {
+		cycloneDX = cycloneDX || format == "cyclonedx"
+	}
+	if cycloneDX && len(specs) == 0 {
+		return nil, errors.New("the cyclonedx output format requires --sbom")
+	}
+	if !cycloneDX && len(specs) > 0 {
+		return nil, errors.New("--sbom requires the cyclonedx output format")
+	}
+
+	var sboms []chartverifier.SBOM
+	for _, spec := range specs {
+		image, path := "", spec
+		if parts := strings.SplitN(spec, "=", 2); len(parts) == 2 {
+			image, path = parts[0], parts[1]
+		}
+		sbom, err := chartverifier.LoadSBOM(path, image)
+		if err != nil {
+			return nil, err
+		}
+		sboms = append(sboms, sbom)
+	}
+	return sboms, nil
+}
+
+// newCycloneDXDocument describes the verification of the chart found at uri, whose outcome is informed by result,
+// linking the images it references, rendered as certifier's checks render them, to the given SBOMs; image hostnames
+// are masked when a redactor is informed.
+func newCycloneDXDocument(certifier chartverifier.Certifier, result chartverifier.Certificate, uri string, sboms []chartverifier.SBOM, redactor *chartverifier.HostRedactor) (*chartverifier.CycloneDXDocument, error) {
+	chrt, _, err := checks.LoadChartFromURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	images, err := certifier.ImageReferences(uri)
+	if err != nil {
+		return nil, err
+	}
+	if redactor != nil {
+		for i := range images {
+			images[i] = redactor.RedactString(images[i])
+		}
+		for i := range sboms {
+			sboms[i].Image = redactor.RedactString(sboms[i].Image)
+		}
+	}
+	bom, err := chartverifier.NewCycloneDXDocument(result, chrt, images, sboms)
+	if err != nil {
+		return nil, err
+	}
+	return &bom, nil
+}
+
+// parseAnnotations parses the given key=value pairs.
+func parseAnnotations(pairs []string) (map[string]string, error) {
+	annotations := map[string]string{}
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("annotation %q must be in the key=value format", pair)
+		}
+		annotations[parts[0]] = parts[1]
+	}
+	return annotations, nil
+}
+
+// appendToLedger appends the summary of the verification of the chart found at uri to the given ledger.
+func appendToLedger(path string, result chartverifier.Certificate, uri string) error {
+	chrt, _, err := checks.LoadChartFromURI(uri)
+	if err != nil {
+		return err
+	}
+	entry, err := chartverifier.NewLedgerEntry(result, chrt)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(chartverifier.AppendToLedger(path, entry), "failed appending to the ledger")
+}
+
+// parseTimestamp returns a clock fixed at the given RFC 3339 timestamp, or the real clock when none is informed.
+func parseTimestamp(timestamp string) (func() time.Time, error) {
+	if timestamp == "" {
+		return time.Now, nil
+	}
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return nil, errors.Errorf("timestamp %q must be in the RFC 3339 format, e.g: 2021-03-04T05:06:07Z", timestamp)
+	}
+	return func() time.Time { return t }, nil
+}
+
+// parseValuesProfiles reads the values files of the given name=path profiles.
+func parseValuesProfiles(pairs []string) (map[string]chartutil.Values, error) {
+	profiles := map[string]chartutil.Values{}
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.Errorf("values profile %q must be in the name=path format", pair)
+		}
+		if _, ok := profiles[parts[0]]; ok {
+			return nil, errors.Errorf("values profile %q informed more than once", parts[0])
+		}
+		values, err := chartutil.ReadValuesFile(parts[1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed reading values profile %q", parts[0])
+		}
+		profiles[parts[0]] = values
+	}
+	return profiles, nil
+}
+
+// loadBaseline reads the baseline report at the given path, if any.
+func loadBaseline(path string) (*chartverifier.Report, error) {
+	if path == "" {
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed reading baseline")
+	}
+	defer f.Close()
+	baseline, err := chartverifier.LoadReport(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed reading baseline")
+	}
+	return baseline, nil
+}
+
+// printDiagnostic writes msg to stderr, keeping stdout exclusive to the report, unless --quiet has been informed.
+func printDiagnostic(cmd *cobra.Command, msg string) {
+	if !quietFlag {
+		cmd.PrintErrln(msg)
+	}
+}
+
 func buildChecks(all, enabled, disabled []string) ([]string, error) {
 	switch {
 	case len(enabled) > 0 && len(disabled) > 0:
@@ -88,16 +480,123 @@ func NewVerifyCmd(config *viper.Viper) *cobra.Command {
 		Args:  cobra.ExactArgs(1),
 		Short: "Verifies a Helm chart by checking some of its characteristics",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			checks, err := buildChecks(allChecks, enabledChecksFlag, disabledChecksFlag)
+			if quietFlag {
+				// keep usage from being interleaved with the report when the command fails
+				cmd.SilenceUsage = true
+			}
+
+			enabledChecks, err := buildChecks(allChecks, getStringSliceConfig(config, "enable"), getStringSliceConfig(config, "disable"))
+			if err != nil {
+				return err
+			}
+
+			failOn, err := chartverifier.ParseFailOn(failOnFlag)
+			if err != nil {
+				return err
+			}
+
+			outputFormats, err := parseOutputFormats(getStringSliceConfig(config, "output"), outputFilePrefixFlag)
+			if err != nil {
+				return err
+			}
+
+			sinks, err := parseSinks(cmd, sinksFlag, outputFormats, outputFilePrefixFlag)
+			if err != nil {
+				return err
+			}
+
+			sboms, err := parseSBOMs(sbomsFlag, outputFormats)
+			if err != nil {
+				return err
+			}
+
+			annotations, err := parseAnnotations(annotationsFlag)
+			if err != nil {
+				return err
+			}
+
+			expectations, err := chartverifier.ParseExpectations(expectFlag)
+			if err != nil {
+				return err
+			}
+
+			baseline, err := loadBaseline(baselineFlag)
 			if err != nil {
 				return err
 			}
 
+			valuesProfiles, err := parseValuesProfiles(valuesProfilesFlag)
+			if err != nil {
+				return err
+			}
+
+			clock, err := parseTimestamp(timestampFlag)
+			if err != nil {
+				return err
+			}
+
+			if suggestedFixesFileFlag != "" && !suggestFixesFlag {
+				return errors.New("--suggested-fixes-file requires --suggest-fixes")
+			}
+
+			if resumeFlag && checkpointFlag == "" {
+				return errors.New("--resume requires --checkpoint")
+			}
+			var checkpoint *chartverifier.Checkpoint
+			if checkpointFlag != "" {
+				if checkpoint, err = chartverifier.NewCheckpoint(checkpointFlag, resumeFlag); err != nil {
+					return err
+				}
+			}
+
+			if insecureSkipTLSVerifyFlag {
+				printDiagnostic(cmd, "Warning : server certificates will not be verified")
+			}
+
+			var redactor *chartverifier.HostRedactor
+			if redactHostsFlag {
+				redactor = chartverifier.NewHostRedactor(redactAllowlistFlag)
+			}
+
+			var stream *chartverifier.ResultStream
+			var onCheckComplete chartverifier.CheckCompleteFunc
+			if streamFlag {
+				if err := validateStream(sinksFlag); err != nil {
+					return err
+				}
+				stream = chartverifier.NewResultStream(cmd.OutOrStdout())
+				onCheckComplete = streamCheckComplete(stream, redactor)
+			}
+
 			certifier, err := chartverifier.
 				NewCertifierBuilder().
-				SetChecks(checks).
+				SetChecks(enabledChecks).
+				SetFailOn(failOn).
+				SetOpenShiftVersions(getStringSliceConfig(config, "openshift-version")).
+				SetKubeVersion(config.GetString("kube-version")).
+				SetAnnotations(annotations).
 				SetConfig(config).
 				SetOverrides(setOverridesFlag).
+				SetValueOverrides(setValuesFlag).
+				SetStringValueOverrides(setStringValuesFlag).
+				SetValuesProfiles(valuesProfiles).
+				SetCredentials(checks.Credentials{
+					Username:              usernameFlag,
+					Password:              passwordFlag,
+					CAFile:                caFileFlag,
+					InsecureSkipTLSVerify: insecureSkipTLSVerifyFlag,
+				}).
+				SetClock(clock).
+				SetNoNetwork(noNetworkFlag).
+				SetNoCluster(noClusterFlag).
+				SetMetadataOnly(metadataOnlyFlag).
+				SetMaxRequestsPerSecond(maxRequestsPerSecondFlag).
+				SetFailFast(failFastFlag).
+				SetBuildDependencies(buildDependenciesFlag).
+				SetSuggestFixes(suggestFixesFlag).
+				SetCheckpoint(checkpoint).
+				SetTotalTimeout(totalTimeoutFlag).
+				SetOnCheckComplete(onCheckComplete).
 				SetToolVersion(Version).
 				Build()
 
@@ -110,34 +609,143 @@ func NewVerifyCmd(config *viper.Viper) *cobra.Command {
 				return err
 			}
 
-			if outputFormatFlag == "json" {
-				b, err := json.Marshal(result)
-				if err != nil {
+			var comparison chartverifier.BaselineComparison
+			if baseline != nil {
+				result, comparison = chartverifier.ApplyBaseline(result, baseline, failOn)
+				for _, name := range comparison.Known {
+					printDiagnostic(cmd, "Known failure :"+name)
+				}
+				blocking := map[string]bool{}
+				for _, name := range comparison.Blocking {
+					blocking[name] = true
+				}
+				for _, name := range comparison.New {
+					if !blocking[name] {
+						printDiagnostic(cmd, "New non-blocking failure :"+name)
+					}
+				}
+			}
+
+			// expectations are compared before the results are filtered for display
+			mismatches := chartverifier.CompareExpectations(result, expectations)
+
+			if onlyFailuresFlag {
+				result = chartverifier.OnlyFailures(result)
+			}
+
+			if redactor != nil {
+				result = redactor.Redact(result)
+			}
+
+			if validateSchemaFlag {
+				if err := validateReportSchema(result); err != nil {
+					cmd.SilenceUsage = true
 					return err
 				}
+			}
 
-				cmd.Println(string(b))
+			if stream != nil {
+				if err := stream.Close(result); err != nil {
+					return err
+				}
+			}
 
-			} else if outputFormatFlag == "yaml" {
-				b, err := yaml.Marshal(result)
-				if err != nil {
+			var bom *chartverifier.CycloneDXDocument
+			if len(sboms) > 0 {
+				if bom, err = newCycloneDXDocument(certifier, result, args[0], sboms, redactor); err != nil {
 					return err
 				}
+			}
 
-				cmd.Println(string(b))
+			if len(sinks) > 0 {
+				out, err := formatCertificate(result, outputFormats[0], bom)
+				if err != nil {
+					return err
+				}
+				failures, err := chartverifier.EmitReport([]byte(out), sinks)
+				for _, failure := range failures {
+					printDiagnostic(cmd, "Sink failure :"+failure.Error())
+				}
+				if err != nil {
+					return err
+				}
 			} else {
-				cmd.Print(result)
+				for _, format := range outputFormats {
+					out, err := formatCertificate(result, format, bom)
+					if err != nil {
+						return err
+					}
+
+					if outputFilePrefixFlag == "" {
+						// the stream already occupies stdout
+						if stream == nil {
+							cmd.Print(out)
+						}
+						continue
+					}
+
+					if err := ioutil.WriteFile(outputFilePrefixFlag+"."+outputExtensions[format], []byte(out), 0644); err != nil {
+						return err
+					}
+				}
+			}
+
+			if suggestedFixesFileFlag != "" {
+				if err := ioutil.WriteFile(suggestedFixesFileFlag, []byte(chartverifier.FormatSuggestions(result)), 0644); err != nil {
+					return err
+				}
 			}
 
-			reportErr := chartverifier.
+			reportBuilder := chartverifier.
 				NewReportBuilder().
 				SetCertificate(&result).
 				SetChartUri(args[0]).
-				Generate()
+				SetRedactor(redactor)
+
+			reportErr := reportBuilder.Generate()
 
 			if reportErr != nil {
-				cmd.Println("Report failure :" + reportErr.Error())
-				return err
+				printDiagnostic(cmd, "Report failure :"+reportErr.Error())
+				return reportErr
+			}
+
+			if ledgerFlag != "" {
+				if err := appendToLedger(ledgerFlag, result, args[0]); err != nil {
+					return err
+				}
+			}
+
+			if notifyUrlFlag != "" {
+				if notifyErr := reportBuilder.Notify(notifyUrlFlag); notifyErr != nil {
+					if notifyRequiredFlag {
+						return notifyErr
+					}
+					printDiagnostic(cmd, "Notification failure :"+notifyErr.Error())
+				}
+			}
+
+			if len(mismatches) > 0 {
+				cmd.SilenceUsage = true
+				lines := make([]string, len(mismatches))
+				for i, m := range mismatches {
+					lines[i] = m.String()
+				}
+				return errors.New("check outcomes differ from the expected ones:\n\t" + strings.Join(lines, "\n\t"))
+			}
+
+			// with a baseline, only the checks newly failing fail the command
+			if baseline != nil {
+				if !result.IsOk() {
+					cmd.SilenceUsage = true
+					return errors.New("checks newly failing compared to the baseline:\n\t" + strings.Join(comparison.Blocking, "\n\t"))
+				}
+				return nil
+			}
+
+			// the exit code only reflects the outcome when explicitly requested, to keep existing pipelines working
+			if cmd.Flags().Changed("fail-on") && !result.IsOk() {
+				cmd.SilenceUsage = true
+				return errors.New("chart verification failed")
 			}
 
 			return nil
@@ -148,10 +756,90 @@ func NewVerifyCmd(config *viper.Viper) *cobra.Command {
 
 	cmd.Flags().StringSliceVarP(&disabledChecksFlag, "disable", "x", nil, "all checks will be enabled except the informed ones")
 
-	cmd.Flags().StringVarP(&outputFormatFlag, "output", "o", "", "the output format: default, json or yaml")
+	cmd.Flags().StringVarP(&outputFormatFlag, "output", "o", "", "the output formats, comma separated: default, json, yaml, badge or cyclonedx")
+
+	cmd.Flags().StringVar(&outputFilePrefixFlag, "output-file-prefix", "", "the report will be written to a file named after the prefix for each output format, e.g: report.json, instead of stdout")
 
 	cmd.Flags().StringSliceVarP(&setOverridesFlag, "set", "s", []string{}, "overrides a configuration, e.g: dummy.ok=false")
 
+	cmd.Flags().BoolVarP(&quietFlag, "quiet", "q", false, "only the report will be written to stdout, diagnostic messages will be suppressed")
+
+	cmd.Flags().StringArrayVar(&setValuesFlag, "set-value", nil, "sets a chart value for checks rendering the chart's templates, as Helm's --set does, e.g: replicaCount=3")
+
+	cmd.Flags().StringArrayVar(&setStringValuesFlag, "set-string", nil, "sets a chart value kept as a string for checks rendering the chart's templates, as Helm's --set-string does, e.g: port=080")
+
+	cmd.Flags().StringArrayVar(&valuesProfilesFlag, "values-profile", nil, "adds a named values file checks rendering the chart's templates are executed with, once per profile, e.g: ingress=ingress-values.yaml")
+
+	cmd.Flags().BoolVar(&onlyFailuresFlag, "only-failures", false, "only the results of failed checks will be displayed")
+
+	cmd.Flags().StringSliceVar(&annotationsFlag, "annotation", nil, "adds an annotation to the report metadata, e.g: ticket=CERT-123")
+
+	cmd.Flags().StringVar(&notifyUrlFlag, "notify-url", "", "the webhook url the report will be posted to once the verification has finished")
+
+	cmd.Flags().StringVar(&failOnFlag, "fail-on", string(chartverifier.FailOnMandatory), "the check failures turning the outcome negative and, when informed, failing the command: optional, mandatory or none")
+
+	cmd.Flags().StringSliceVar(&openShiftVersionsFlag, "openshift-version", nil, "the OpenShift versions the chart will be verified against, e.g: 4.12,4.13")
+
+	cmd.Flags().StringVar(&kubeVersionFlag, "kube-version", "", "the Kubernetes version the chart will be verified against, independently of the OpenShift versions, e.g: 1.21; templates are rendered with Helm's default Kubernetes version when not informed")
+
+	cmd.Flags().BoolVar(&redactHostsFlag, "redact-hosts", false, "hostnames in the report, such as the ones of the chart uri and image registries, will be masked, except for the allowlisted domains")
+
+	cmd.Flags().StringSliceVar(&redactAllowlistFlag, "redact-allowlist", chartverifier.DefaultRedactionAllowlist, "the public domains whose hostnames are kept readable when option --redact-hosts is given")
+
+	cmd.Flags().StringVar(&usernameFlag, "username", "", "the username used to authenticate when retrieving the chart over HTTP; defaults to the one configured for the chart's Helm repository")
+
+	cmd.Flags().StringVar(&passwordFlag, "password", "", "the password used to authenticate when retrieving the chart over HTTP; defaults to the one configured for the chart's Helm repository")
+
+	cmd.Flags().StringVar(&caFileFlag, "ca-file", "", "the PEM encoded CA bundle trusted, in addition to the system's, when retrieving the chart over HTTPS and by checks reaching HTTPS services; defaults to the one configured for the chart's Helm repository when retrieving the chart")
+
+	cmd.Flags().BoolVar(&insecureSkipTLSVerifyFlag, "insecure-skip-tls-verify", false, "server certificates will not be verified when retrieving the chart over HTTPS and by checks reaching HTTPS services")
+
+	cmd.Flags().StringVar(&timestampFlag, "timestamp", "", "the RFC 3339 time the report is declared to have been generated at, instead of the current time, so reports can be reproduced, e.g: 2021-03-04T05:06:07Z")
+
+	cmd.Flags().StringVar(&ledgerFlag, "ledger", "", "the path of the JSON lines file a summary of the verification, including the chart's digest, will be appended to")
+
+	cmd.Flags().BoolVar(&noNetworkFlag, "no-network", false, "checks requiring network access, such as images-are-certified, will be skipped and reported as such")
+
+	cmd.Flags().BoolVar(&noClusterFlag, "no-cluster", false, "checks requiring cluster access, such as install-succeeds, will be skipped and reported as such")
+
+	cmd.Flags().BoolVar(&metadataOnlyFlag, "metadata-only", false, "only checks neither rendering templates nor requiring network or cluster access, such as has-readme, will be executed, the others being reported as skipped")
+
+	cmd.Flags().Float64Var(&maxRequestsPerSecondFlag, "max-requests-per-second", 0, "the maximum number of outbound requests per second checks perform, e.g. to image registries and icon hosts; unlimited when not informed")
+
+	cmd.Flags().BoolVar(&failFastFlag, "fail-fast", false, "the verification will stop at the first failed mandatory check, the remaining checks being reported as skipped")
+
+	cmd.Flags().BoolVar(&buildDependenciesFlag, "build-dependencies", false, "the dependencies declared by the chart but not bundled in its charts directory will be retrieved from their repositories before verifying it, as helm dependency build does, so checks rendering the chart see its complete dependency tree")
+
+	cmd.Flags().BoolVar(&suggestFixesFlag, "suggest-fixes", false, "checks able to compute a remediation for their findings, such as a patch adding a missing field, will include it in their results as a suggested YAML patch")
+
+	cmd.Flags().StringVar(&suggestedFixesFileFlag, "suggested-fixes-file", "", "the path of the file the fixes suggested with option --suggest-fixes will be written to, as YAML documents, one patch per document")
+
+	cmd.Flags().StringArrayVar(&sinksFlag, "sink", nil, "adds a destination the report will be written to instead of stdout: stdout, file=<path> or webhook=<url>, followed by ,required when failing to write to it should fail the verification")
+
+	cmd.Flags().BoolVar(&streamFlag, "stream", false, "each check result will be written to stdout as a JSON line as soon as the check completes, followed by a summary line, instead of the report")
+
+	cmd.Flags().BoolVar(&validateSchemaFlag, "validate-schema", false, "the report will be checked against the report schema before being written, the command failing with the offending fields otherwise")
+
+	cmd.Flags().StringSliceVar(&expectFlag, "expect", nil, "the outcomes checks are expected to have, pass, fail or skip, the command failing with the differences otherwise, e.g: has-readme=pass,images-are-certified=fail")
+
+	cmd.Flags().DurationVar(&totalTimeoutFlag, "total-timeout", 0, "the maximum duration of the verification, e.g: 10m; once expired, the checks not completed are reported as timed out, or the verification fails if no check has completed")
+
+	cmd.Flags().StringVar(&checkpointFlag, "checkpoint", "", "the path of the file the outcome of each completed check will be persisted to, so an interrupted verification can be resumed with option --resume")
+
+	cmd.Flags().BoolVar(&resumeFlag, "resume", false, "checks whose outcome is persisted to the checkpoint, for the same chart, options and tool version, will not be executed again")
+
+	cmd.Flags().StringArrayVar(&sbomsFlag, "sbom", nil, "adds a CycloneDX SBOM, in the JSON format, the cyclonedx output format links to the image it describes, e.g: app.cdx.json, or to the informed image, e.g: quay.io/team/app:1.0=app.cdx.json; SBOMs not describing one of the chart's images are linked to the chart")
+
+	cmd.Flags().StringVar(&baselineFlag, "baseline", "", "the path of a previous report, in the JSON or YAML format, whose failed checks are reported as known failures; the command only fails when other checks fail")
+
+	cmd.Flags().BoolVar(&notifyRequiredFlag, "notify-required", false, "the verification will fail if the report can't be posted to the webhook")
+
+	// flags take precedence over environment variables, which take precedence over the configuration file
+	for flag, env := range envBindings {
+		_ = config.BindPFlag(flag, cmd.Flags().Lookup(flag))
+		_ = config.BindEnv(flag, env)
+	}
+
 	return cmd
 }
 
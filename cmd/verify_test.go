@@ -19,16 +19,42 @@ package cmd
 import (
 	"bytes"
 	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v3"
 
+	"github.com/redhat-certification/chart-verifier/pkg/chartverifier"
 	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
 )
 
+// checksDir is the directory containing the test charts.
+var checksDir, _ = filepath.Abs(filepath.Join("..", "pkg", "chartverifier", "checks"))
+
+// testChart returns the path of the given test chart.
+func testChart(name string) string {
+	return filepath.Join(checksDir, name)
+}
+
+// useTempWorkDir changes the working directory, where reports are written, to a temporary directory for the duration
+// of the test.
+func useTempWorkDir(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(t.TempDir()))
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+}
+
 func TestCertify(t *testing.T) {
+	useTempWorkDir(t)
 
 	t.Run("Should fail when no argument is given", func(t *testing.T) {
 		cmd := NewVerifyCmd(viper.New())
@@ -47,7 +73,7 @@ func TestCertify(t *testing.T) {
 		errBuf := bytes.NewBufferString("")
 		cmd.SetErr(errBuf)
 
-		cmd.SetArgs([]string{"../pkg/chartverifier/checks/chart-0.1.0-v3.non-existing.tgz"})
+		cmd.SetArgs([]string{testChart("chart-0.1.0-v3.non-existing.tgz")})
 
 		err := cmd.Execute()
 		require.Error(t, err)
@@ -61,7 +87,7 @@ func TestCertify(t *testing.T) {
 		errBuf := bytes.NewBufferString("")
 		cmd.SetErr(errBuf)
 
-		cmd.SetArgs([]string{"../pkg/chartverifier/checks/chart-0.1.0-v3.non-existing.tgz", "-o"})
+		cmd.SetArgs([]string{testChart("chart-0.1.0-v3.non-existing.tgz"), "-o"})
 		err := cmd.Execute()
 		require.Error(t, err)
 		require.False(t, checks.IsChartNotFound(err))
@@ -76,7 +102,7 @@ func TestCertify(t *testing.T) {
 
 		cmd.SetArgs([]string{
 			"-e", "is-helm-vv3",
-			"../pkg/chartverifier/checks/chart-0.1.0-v3.non-existing.tgz",
+			testChart("chart-0.1.0-v3.non-existing.tgz"),
 		})
 		err := cmd.Execute()
 		require.Error(t, err)
@@ -92,7 +118,7 @@ func TestCertify(t *testing.T) {
 
 		cmd.SetArgs([]string{
 			"-e", "is-helm-vv3",
-			"../pkg/chartverifier/checks/chart-0.1.0-v3.valid.tgz",
+			testChart("chart-0.1.0-v3.valid.tgz"),
 		})
 		err := cmd.Execute()
 		require.Error(t, err)
@@ -108,21 +134,25 @@ func TestCertify(t *testing.T) {
 
 		cmd.SetArgs([]string{
 			"-e", "is-helm-v3",
-			"../pkg/chartverifier/checks/chart-0.1.0-v3.valid.tgz",
+			testChart("chart-0.1.0-v3.valid.tgz"),
 		})
 		require.NoError(t, cmd.Execute())
 		require.NotEmpty(t, outBuf.String())
 
 		expected := "Tool:\n" +
 			"  verifier-version: 1.0.0\n" +
-			"  chart-uri: ../pkg/chartverifier/checks/chart-0.1.0-v3.valid.tgz\n" +
+			"  chart-uri: " + testChart("chart-0.1.0-v3.valid.tgz") + "\n" +
 			"Chart:\n" +
 			"  Name: chart\n" +
 			"  version: 1.16.0\n" +
+			"Summary:\n" +
+			"  passed: 1\n" +
+			"  failed: 0\n" +
 			"ok: true\n" +
 			"\n" +
 			"is-helm-v3:\n" +
 			"\tok: true\n" +
+			"\ttype: mandatory\n" +
 			"\treason: " + checks.Helm3Reason + "\n"
 		require.Equal(t, expected, outBuf.String())
 	})
@@ -137,7 +167,8 @@ func TestCertify(t *testing.T) {
 		cmd.SetArgs([]string{
 			"-e", "is-helm-v3", // only consider a single check, perhaps more checks in the future
 			"-o", "json",
-			"../pkg/chartverifier/checks/chart-0.1.0-v3.valid.tgz",
+			"--timestamp", "2021-03-04T05:06:07+01:00",
+			testChart("chart-0.1.0-v3.valid.tgz"),
 		})
 		require.NoError(t, cmd.Execute())
 		require.NotEmpty(t, outBuf.String())
@@ -149,9 +180,11 @@ func TestCertify(t *testing.T) {
 
 		expected := map[string]interface{}{
 			"metadata": map[string]interface{}{
+				"schema-version": chartverifier.ReportSchemaVersion,
 				"tool": map[string]interface{}{
 					"verifier-version": "1.0.0",
-					"chart-uri":        "../pkg/chartverifier/checks/chart-0.1.0-v3.valid.tgz",
+					"chart-uri":        testChart("chart-0.1.0-v3.valid.tgz"),
+					"generated-at":     "2021-03-04T04:06:07Z",
 				},
 				"chart": map[string]interface{}{
 					"name":    "chart",
@@ -159,10 +192,15 @@ func TestCertify(t *testing.T) {
 				},
 			},
 			"ok": true,
+			"summary": map[string]interface{}{
+				"passed": float64(1),
+				"failed": float64(0),
+			},
 			"results": map[string]interface{}{
 				"is-helm-v3": map[string]interface{}{
 					"ok":     true,
 					"reason": checks.Helm3Reason,
+					"type":   "mandatory",
 				},
 			},
 		}
@@ -179,7 +217,8 @@ func TestCertify(t *testing.T) {
 		cmd.SetArgs([]string{
 			"-e", "is-helm-v3", // only consider a single check, perhaps more checks in the future
 			"-o", "yaml",
-			"../pkg/chartverifier/checks/chart-0.1.0-v3.valid.tgz",
+			"--timestamp", "2021-03-04T05:06:07+01:00",
+			testChart("chart-0.1.0-v3.valid.tgz"),
 		})
 		require.NoError(t, cmd.Execute())
 		require.NotEmpty(t, outBuf.String())
@@ -191,9 +230,11 @@ func TestCertify(t *testing.T) {
 
 		expected := map[string]interface{}{
 			"metadata": map[string]interface{}{
+				"schema-version": chartverifier.ReportSchemaVersion,
 				"tool": map[string]interface{}{
 					"verifier-version": "1.0.0",
-					"chart-uri":        "../pkg/chartverifier/checks/chart-0.1.0-v3.valid.tgz",
+					"chart-uri":        testChart("chart-0.1.0-v3.valid.tgz"),
+					"generated-at":     "2021-03-04T04:06:07Z",
 				},
 				"chart": map[string]interface{}{
 					"name":    "chart",
@@ -201,61 +242,1318 @@ func TestCertify(t *testing.T) {
 				},
 			},
 			"ok": true,
+			"summary": map[string]interface{}{
+				"passed": 1,
+				"failed": 0,
+			},
 			"results": map[string]interface{}{
 				"is-helm-v3": map[string]interface{}{
 					"ok":     true,
 					"reason": checks.Helm3Reason,
+					"type":   "mandatory",
 				},
 			},
 		}
 		require.Equal(t, expected, actual)
 	})
 
-}
+	t.Run("Should only display failed checks when option --only-failures is given", func(t *testing.T) {
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
 
-func TestBuildChecks(t *testing.T) {
-	t.Run("Should fail when enabledChecks and disabledChecks have more than one item at the same time", func(t *testing.T) {
-		var (
-			all      = []string{"a", "b", "c"}
-			enabled  = []string{"a"}
-			disabled = []string{"b"}
-		)
-		selected, err := buildChecks(all, enabled, disabled)
-		require.Error(t, err)
-		require.Nil(t, selected)
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3,version-is-semver",
+			"-o", "json",
+			"--only-failures",
+			testChart("chart-0.1.0-v3.version-mismatch.tgz"),
+		})
+		require.NoError(t, cmd.Execute())
+
+		actual := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal([]byte(outBuf.String()), &actual))
+
+		require.Equal(t, false, actual["ok"])
+		require.Equal(t, map[string]interface{}{"passed": float64(1), "failed": float64(1)}, actual["summary"])
+		results, ok := actual["results"].(map[string]interface{})
+		require.True(t, ok)
+		require.Len(t, results, 1)
+		require.Contains(t, results, "version-is-semver")
 	})
 
-	t.Run("Should fail when enabled check is unknown", func(t *testing.T) {
-		var (
-			all      = []string{"a", "b", "c"}
-			disabled = []string{}
-			enabled  = []string{"d"}
-		)
-		selected, err := buildChecks(all, enabled, disabled)
-		require.Error(t, err)
-		require.Nil(t, selected)
+	t.Run("Should display all checks when option --only-failures is not given", func(t *testing.T) {
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3,version-is-semver",
+			"-o", "yaml",
+			testChart("chart-0.1.0-v3.version-mismatch.tgz"),
+		})
+		require.NoError(t, cmd.Execute())
+
+		actual := map[string]interface{}{}
+		require.NoError(t, yaml.Unmarshal([]byte(outBuf.String()), &actual))
+
+		require.Equal(t, map[string]interface{}{"passed": 1, "failed": 1}, actual["summary"])
+		results, ok := actual["results"].(map[string]interface{})
+		require.True(t, ok)
+		require.Len(t, results, 2)
 	})
 
-	t.Run("Should fail when disabled check is unknown", func(t *testing.T) {
-		var (
-			all      = []string{"a", "b", "c"}
-			disabled = []string{"e"}
-			enabled  = []string{}
-		)
-		selected, err := buildChecks(all, enabled, disabled)
-		require.Error(t, err)
-		require.Nil(t, selected)
+	t.Run("Should post the report when option --notify-url is given", func(t *testing.T) {
+		var outcome string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			outcome = r.Header.Get(chartverifier.NotifyOutcomeHeader)
+		}))
+		defer server.Close()
+
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3",
+			"--notify-url", server.URL,
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		require.NoError(t, cmd.Execute())
+		require.Equal(t, chartverifier.NotifyOutcomePassed, outcome)
+		require.Empty(t, errBuf.String())
 	})
 
-	t.Run("Should return all checks when neither enabled or disabled checks have been informed", func(t *testing.T) {
-		var (
-			enabled  = []string{}
-			disabled = []string{}
-			all      = []string{"a", "b", "c"}
-		)
-		selected, err := buildChecks(all, enabled, disabled)
+	t.Run("Should only log notification failures when option --notify-required is not given", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3",
+			"--notify-url", server.URL,
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		require.NoError(t, cmd.Execute())
+		require.Contains(t, errBuf.String(), "Notification failure")
+	})
+
+	t.Run("Should fail on notification failures when option --notify-required is given", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3",
+			"--notify-url", server.URL,
+			"--notify-required",
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		require.Error(t, cmd.Execute())
+	})
+
+	t.Run("Should write the report to all sinks given through option --sink", func(t *testing.T) {
+		var posted []byte
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			posted, _ = ioutil.ReadAll(r.Body)
+		}))
+		defer server.Close()
+
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		path := filepath.Join(t.TempDir(), "report.json")
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3",
+			"-o", "json",
+			"--sink", "stdout",
+			"--sink", "file=" + path,
+			"--sink", "webhook=" + server.URL + ",required",
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		require.NoError(t, cmd.Execute())
+		require.Empty(t, errBuf.String())
+
+		written, err := ioutil.ReadFile(path)
 		require.NoError(t, err)
-		require.Equal(t, selected, all)
+		require.NotEmpty(t, outBuf.String())
+		require.Equal(t, outBuf.String(), string(written))
+		require.Equal(t, outBuf.String(), string(posted))
+	})
+
+	t.Run("Should only log failures of sinks not marked as required", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3",
+			"--sink", "webhook=" + server.URL,
+			"--sink", "stdout",
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		require.NoError(t, cmd.Execute())
+		require.Contains(t, errBuf.String(), "Sink failure")
+		require.Contains(t, outBuf.String(), "is-helm-v3")
+	})
+
+	t.Run("Should fail when a required sink fails", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3",
+			"--sink", "webhook=" + server.URL + ",required",
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		require.Error(t, cmd.Execute())
+	})
+
+	t.Run("Should fail when an unknown sink is given", func(t *testing.T) {
+		cmd := NewVerifyCmd(viper.New())
+		cmd.SetOut(bytes.NewBufferString(""))
+		cmd.SetErr(bytes.NewBufferString(""))
+
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3",
+			"--sink", "s3=bucket",
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		require.EqualError(t, cmd.Execute(), `sink "s3=bucket" must be one of stdout, file=<path> or webhook=<url>`)
+	})
+
+	t.Run("Should skip the checks following the first failed mandatory check when option --fail-fast is given", func(t *testing.T) {
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{
+			"-e", "contains-values,has-readme,is-helm-v3",
+			"-o", "json",
+			"--fail-fast",
+			testChart("chart-0.1.0-v2.invalid.tgz"),
+		})
+		require.NoError(t, cmd.Execute())
+
+		var actual map[string]interface{}
+		require.NoError(t, json.Unmarshal(outBuf.Bytes(), &actual))
+		require.Equal(t, map[string]interface{}{"passed": float64(1), "failed": float64(1), "skipped": float64(1)}, actual["summary"])
+
+		results, ok := actual["results"].(map[string]interface{})
+		require.True(t, ok)
+		result, ok := results["is-helm-v3"].(map[string]interface{})
+		require.True(t, ok)
+		require.Equal(t, true, result["skipped"])
+		require.Equal(t, chartverifier.CheckSkippedFailFast, result["reason"])
+	})
+
+	failOnCases := []struct {
+		failOn string
+		uri    string
+		ok     bool
+	}{
+		{failOn: "optional", uri: testChart("chart-0.1.0-v3.without-icon.tgz"), ok: false},
+		{failOn: "mandatory", uri: testChart("chart-0.1.0-v3.without-icon.tgz"), ok: true},
+		{failOn: "none", uri: testChart("chart-0.1.0-v3.without-icon.tgz"), ok: true},
+		{failOn: "mandatory", uri: testChart("chart-0.1.0-v3.version-mismatch.tgz"), ok: false},
+		{failOn: "none", uri: testChart("chart-0.1.0-v3.version-mismatch.tgz"), ok: true},
+	}
+
+	for _, tc := range failOnCases {
+		t.Run("Should respect option --fail-on "+tc.failOn+" when verifying "+tc.uri, func(t *testing.T) {
+			cmd := NewVerifyCmd(viper.New())
+			outBuf := bytes.NewBufferString("")
+			cmd.SetOut(outBuf)
+			errBuf := bytes.NewBufferString("")
+			cmd.SetErr(errBuf)
+
+			cmd.SetArgs([]string{
+				"-e", "is-helm-v3,has-valid-icon,version-is-semver",
+				"-o", "json",
+				"--fail-on", tc.failOn,
+				tc.uri,
+			})
+			if tc.ok {
+				require.NoError(t, cmd.Execute())
+			} else {
+				require.Error(t, cmd.Execute())
+			}
+
+			actual := map[string]interface{}{}
+			require.NoError(t, json.Unmarshal(outBuf.Bytes(), &actual))
+			require.Equal(t, tc.ok, actual["ok"])
+			results := actual["results"].(map[string]interface{})
+			require.Equal(t, "mandatory", results["is-helm-v3"].(map[string]interface{})["type"])
+			require.Equal(t, "optional", results["has-valid-icon"].(map[string]interface{})["type"])
+		})
+	}
+
+	badgeCases := []struct {
+		uri     string
+		message string
+		color   string
+	}{
+		{uri: testChart("chart-0.1.0-v3.valid.tgz"), message: "passed", color: "green"},
+		{uri: testChart("chart-0.1.0-v3.version-mismatch.tgz"), message: "failed", color: "red"},
+		{uri: testChart("chart-0.1.0-v3.without-icon.tgz"), message: "passed with warnings", color: "yellow"},
+	}
+
+	for _, tc := range badgeCases {
+		t.Run("Should output a badge when option --output badge is given when verifying "+tc.uri, func(t *testing.T) {
+			actual := verifyJSON(t, viper.New(), "-e", "is-helm-v3,has-valid-icon,version-is-semver", "-o", "badge", tc.uri)
+			require.Equal(t, map[string]interface{}{
+				"schemaVersion": float64(1),
+				"label":         "chart-verifier",
+				"message":       tc.message,
+				"color":         tc.color,
+			}, actual)
+		})
+	}
+
+	t.Run("Should link the chart's images to the SBOMs when option --output cyclonedx is given", func(t *testing.T) {
+		dir := t.TempDir()
+		sbom := filepath.Join(dir, "nginx.cdx.json")
+		require.NoError(t, ioutil.WriteFile(sbom, []byte(`{"bomFormat":"CycloneDX","specVersion":"1.4","serialNumber":"urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79","version":1,`+
+			`"metadata":{"component":{"bom-ref":"nginx","type":"container","name":"nginx","version":"1.16.0"}}}`), 0644))
+
+		actual := verifyJSON(t, viper.New(), "-e", "is-helm-v3,has-readme", "-o", "cyclonedx", "--sbom", sbom, "--sbom", "busybox="+sbom,
+			testChart("chart-0.1.0-v3.valid.tgz"))
+		require.Equal(t, "CycloneDX", actual["bomFormat"])
+		require.Equal(t, "1.4", actual["specVersion"])
+
+		component := actual["metadata"].(map[string]interface{})["component"].(map[string]interface{})
+		require.Equal(t, "chart", component["name"])
+		require.Contains(t, component["properties"], map[string]interface{}{"name": "chart-verifier:check:is-helm-v3", "value": "passed"})
+		require.Nil(t, component["externalReferences"])
+
+		components := actual["components"].([]interface{})
+		require.Len(t, components, 2)
+		for _, c := range components {
+			refs := c.(map[string]interface{})["externalReferences"].([]interface{})
+			require.Len(t, refs, 1, c)
+			require.Equal(t, "urn:cdx:3e671687-395b-41f5-a30f-a58921a69b79/1#nginx", refs[0].(map[string]interface{})["url"])
+		}
+	})
+
+	t.Run("Should list the images rendered with the informed values when option --output cyclonedx is given", func(t *testing.T) {
+		dir := t.TempDir()
+		sbom := filepath.Join(dir, "nginx.cdx.json")
+		require.NoError(t, ioutil.WriteFile(sbom, []byte(`{"bomFormat":"CycloneDX","specVersion":"1.4","serialNumber":"urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79","version":1,`+
+			`"metadata":{"component":{"bom-ref":"nginx","type":"container","name":"nginx","version":"1.16.0"}}}`), 0644))
+		profile := filepath.Join(dir, "edge.yaml")
+		require.NoError(t, ioutil.WriteFile(profile, []byte("image:\n  tag: 1.19.0\n"), 0644))
+
+		actual := verifyJSON(t, viper.New(), "-e", "is-helm-v3", "-o", "cyclonedx", "--sbom", sbom,
+			"--set-string", "image.repository=registry.example.com/nginx", "--values-profile", "edge="+profile,
+			testChart("chart-0.1.0-v3.valid.tgz"))
+
+		var names []string
+		for _, c := range actual["components"].([]interface{}) {
+			names = append(names, c.(map[string]interface{})["name"].(string))
+		}
+		require.Contains(t, names, "registry.example.com/nginx:1.19.0")
+		require.NotContains(t, names, "nginx:1.16.0")
+	})
+
+	t.Run("Should fail when options --sbom and --output cyclonedx are not given together", func(t *testing.T) {
+		for _, args := range [][]string{{"-o", "cyclonedx"}, {"--sbom", "nginx.cdx.json"}} {
+			cmd := NewVerifyCmd(viper.New())
+			cmd.SetOut(bytes.NewBufferString(""))
+			cmd.SetErr(bytes.NewBufferString(""))
+			cmd.SetArgs(append(args, testChart("chart-0.1.0-v3.valid.tgz")))
+			err := cmd.Execute()
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "cyclonedx output format")
+		}
+	})
+
+	t.Run("Should list the certified OpenShift versions when option --openshift-version is given", func(t *testing.T) {
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3,has-minkubeversion",
+			"-o", "yaml",
+			"--openshift-version", "4.7,4.8",
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		require.NoError(t, cmd.Execute())
+
+		actual := map[string]interface{}{}
+		require.NoError(t, yaml.Unmarshal(outBuf.Bytes(), &actual))
+		require.Equal(t, false, actual["ok"])
+		tool := actual["metadata"].(map[string]interface{})["tool"].(map[string]interface{})
+		require.Equal(t, []interface{}{"4.7"}, tool["certified-openshift-versions"])
+		versions := actual["results"].(map[string]interface{})["has-minkubeversion"].(map[string]interface{})["openshift-versions"].(map[string]interface{})
+		require.Equal(t, true, versions["4.7"].(map[string]interface{})["ok"])
+		require.Equal(t, false, versions["4.8"].(map[string]interface{})["ok"])
+	})
+
+	t.Run("Should verify against and record the Kubernetes version when option --kube-version is given", func(t *testing.T) {
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3,has-minkubeversion",
+			"-o", "yaml",
+			"--kube-version", "1.19",
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		require.NoError(t, cmd.Execute())
+
+		actual := map[string]interface{}{}
+		require.NoError(t, yaml.Unmarshal(outBuf.Bytes(), &actual))
+		require.Equal(t, false, actual["ok"])
+		tool := actual["metadata"].(map[string]interface{})["tool"].(map[string]interface{})
+		require.Equal(t, "1.19", tool["kube-version"])
+		result := actual["results"].(map[string]interface{})["has-minkubeversion"].(map[string]interface{})
+		require.Equal(t, checks.KubeVersionExcluded+` : "1.20.0" excludes Kubernetes 1.19`, result["reason"])
+	})
+
+	t.Run("Should fail when option --kube-version is not a version", func(t *testing.T) {
+		cmd := NewVerifyCmd(viper.New())
+		cmd.SetOut(bytes.NewBufferString(""))
+		cmd.SetErr(bytes.NewBufferString(""))
+
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3",
+			"--kube-version", "latest",
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		err := cmd.Execute()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), `invalid Kubernetes version "latest"`)
+	})
+
+	t.Run("Should only write the report to stdout when option --quiet is given", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3,has-readme",
+			"-o", "json",
+			"--quiet",
+			"--notify-url", server.URL,
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		require.NoError(t, cmd.Execute())
+		require.Empty(t, errBuf.String())
+
+		lines := strings.Split(strings.TrimSuffix(outBuf.String(), "\n"), "\n")
+		require.Len(t, lines, 1)
+
+		decoder := json.NewDecoder(outBuf)
+		report := map[string]interface{}{}
+		require.NoError(t, decoder.Decode(&report))
+		require.False(t, decoder.More())
+		require.Contains(t, report, "results")
+		require.Len(t, report["results"], 2)
+	})
+
+	t.Run("Should not display usage on failures when option --quiet is given", func(t *testing.T) {
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3",
+			"--quiet",
+			"--fail-on", "unknown",
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		require.Error(t, cmd.Execute())
+		require.Empty(t, outBuf.String())
+	})
+
+	t.Run("Should read checks, output format and OpenShift versions from the environment", func(t *testing.T) {
+		setEnv(t, "CHART_VERIFIER_ENABLE_CHECKS", "is-helm-v3,has-readme")
+		setEnv(t, "CHART_VERIFIER_OUTPUT", "json")
+		setEnv(t, "CHART_VERIFIER_OPENSHIFT_VERSION", "4.12,4.13")
+
+		actual := verifyJSON(t, viper.New(), testChart("chart-0.1.0-v3.valid.tgz"))
+		require.Len(t, actual["results"], 2)
+		require.Contains(t, actual["results"], "is-helm-v3")
+		require.Contains(t, actual["results"], "has-readme")
+		tool := actual["metadata"].(map[string]interface{})["tool"].(map[string]interface{})
+		require.Equal(t, []interface{}{"4.12", "4.13"}, tool["certified-openshift-versions"])
 	})
 
+	t.Run("Should read disabled checks from the environment", func(t *testing.T) {
+		setEnv(t, "CHART_VERIFIER_DISABLE_CHECKS", strings.Join(allChecks[1:], ","))
+		setEnv(t, "CHART_VERIFIER_OUTPUT", "json")
+
+		actual := verifyJSON(t, viper.New(), testChart("chart-0.1.0-v3.valid.tgz"))
+		require.Len(t, actual["results"], 1)
+		require.Contains(t, actual["results"], allChecks[0])
+	})
+
+	t.Run("Should prefer flags over the environment, and the environment over the configuration file", func(t *testing.T) {
+		config := viper.New()
+		config.SetConfigType("yaml")
+		require.NoError(t, config.ReadConfig(strings.NewReader("enable:\n  - has-readme\noutput: yaml\n")))
+
+		setEnv(t, "CHART_VERIFIER_ENABLE_CHECKS", "is-helm-v3")
+		setEnv(t, "CHART_VERIFIER_OUTPUT", "json")
+
+		actual := verifyJSON(t, config, testChart("chart-0.1.0-v3.valid.tgz"))
+		require.Len(t, actual["results"], 1)
+		require.Contains(t, actual["results"], "is-helm-v3")
+
+		actual = verifyJSON(t, config, "-e", "contains-test", testChart("chart-0.1.0-v3.valid.tgz"))
+		require.Len(t, actual["results"], 1)
+		require.Contains(t, actual["results"], "contains-test")
+	})
+
+	t.Run("Should read checks and output format from the configuration file", func(t *testing.T) {
+		config := viper.New()
+		config.SetConfigType("yaml")
+		require.NoError(t, config.ReadConfig(strings.NewReader("enable:\n  - has-readme\noutput: json\n")))
+
+		actual := verifyJSON(t, config, testChart("chart-0.1.0-v3.valid.tgz"))
+		require.Len(t, actual["results"], 1)
+		require.Contains(t, actual["results"], "has-readme")
+	})
+
+	t.Run("Should write the report in all formats when option --output-file-prefix is given", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "chart-verifier")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		prefix := filepath.Join(dir, "report")
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3,has-readme",
+			"-o", "yaml,json,default",
+			"--output-file-prefix", prefix,
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		require.NoError(t, cmd.Execute())
+		require.Empty(t, outBuf.String())
+
+		yamlReport, err := ioutil.ReadFile(prefix + ".yaml")
+		require.NoError(t, err)
+		fromYaml, err := chartverifier.LoadCertificate(yamlReport)
+		require.NoError(t, err)
+
+		jsonReport, err := ioutil.ReadFile(prefix + ".json")
+		require.NoError(t, err)
+		require.True(t, json.Valid(jsonReport))
+		fromJson, err := chartverifier.LoadCertificate(jsonReport)
+		require.NoError(t, err)
+
+		require.Equal(t, fromYaml, fromJson)
+
+		txtReport, err := ioutil.ReadFile(prefix + ".txt")
+		require.NoError(t, err)
+		require.Contains(t, string(txtReport), "Summary:\n  passed: 2\n  failed: 0\nok: true\n")
+		require.Contains(t, string(txtReport), "is-helm-v3:\n\tok: true\n")
+		require.Contains(t, string(txtReport), "has-readme:\n\tok: true\n")
+	})
+
+	t.Run("Should fail when several output formats are informed without option --output-file-prefix", func(t *testing.T) {
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3",
+			"-o", "yaml,json",
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		err := cmd.Execute()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "--output-file-prefix")
+	})
+
+	t.Run("Should fail when the output format is unknown", func(t *testing.T) {
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3",
+			"-o", "xml",
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		err := cmd.Execute()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), `output format "xml" is unknown`)
+	})
+
+	t.Run("Should render the chart with the values informed through options --set-value and --set-string", func(t *testing.T) {
+		actual := verifyJSON(t, viper.New(),
+			"-e", "ha-antiaffinity",
+			"-o", "json",
+			"--set-value", "replicaCount=3",
+			"--set-string", "image.tag=1.16.0",
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		)
+		result := actual["results"].(map[string]interface{})["ha-antiaffinity"].(map[string]interface{})
+		require.Equal(t, false, result["ok"])
+		require.Equal(t, checks.ReplicasNotSpread+" : Deployment/testRelease-chart", result["reason"])
+	})
+
+	t.Run("Should include annotations in the output and the report when option --annotation is given", func(t *testing.T) {
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3",
+			"-o", "json",
+			"--annotation", "ticket=CERT-123",
+			"--annotation", "pipeline-run=42",
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		require.NoError(t, cmd.Execute())
+
+		expected := map[string]interface{}{"ticket": "CERT-123", "pipeline-run": "42"}
+
+		actual := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(outBuf.Bytes(), &actual))
+		require.Equal(t, expected, actual["metadata"].(map[string]interface{})["annotations"])
+
+		b, err := ioutil.ReadFile(filepath.Join("reports", "chart-0.1.0-v3.valid.tgz", "verifier.report.yaml"))
+		require.NoError(t, err)
+		report := map[string]interface{}{}
+		require.NoError(t, yaml.Unmarshal(b, &report))
+		require.Equal(t, expected, report["metadata"].(map[string]interface{})["annotations"])
+	})
+
+	t.Run("Should fail when option --annotation is malformed", func(t *testing.T) {
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3",
+			"--annotation", "ticket",
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		err := cmd.Execute()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "key=value")
+	})
+
+	t.Run("Should append a summary of each verification to the ledger when option --ledger is given", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "chart-verifier")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		ledger := filepath.Join(dir, "ledger.jsonl")
+
+		for _, uri := range []string{
+			testChart("chart-0.1.0-v3.valid.tgz"),
+			testChart("chart-0.1.0-v2.invalid.tgz"),
+		} {
+			verifyJSON(t, viper.New(), "-e", "is-helm-v3", "-o", "json", "--timestamp", "2021-03-04T05:06:07Z", "--ledger", ledger, uri)
+		}
+
+		b, err := ioutil.ReadFile(ledger)
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+		require.Len(t, lines, 2)
+
+		var entries []chartverifier.LedgerEntry
+		for _, line := range lines {
+			var entry chartverifier.LedgerEntry
+			require.NoError(t, json.Unmarshal([]byte(line), &entry))
+			entries = append(entries, entry)
+		}
+		require.Equal(t, "0.1.0-v3.valid", entries[0].Version)
+		require.True(t, entries[0].Ok)
+		require.Equal(t, "2021-03-04T05:06:07Z", entries[0].Timestamp)
+		require.Equal(t, "testchart", entries[1].Name)
+		require.False(t, entries[1].Ok)
+		require.NotEqual(t, entries[0].Digest, entries[1].Digest)
+	})
+
+	t.Run("Should skip checks requiring network access when option --no-network is given", func(t *testing.T) {
+		actual := verifyJSON(t, viper.New(), "-e", "is-helm-v3,images-are-certified", "-o", "json", "--no-network", testChart("chart-0.1.0-v3.valid.tgz"))
+
+		require.Equal(t, true, actual["ok"])
+		require.Equal(t, map[string]interface{}{"passed": float64(1), "failed": float64(0), "skipped": float64(1)}, actual["summary"])
+		require.Equal(t, map[string]interface{}{
+			"ok":      true,
+			"reason":  chartverifier.CheckSkippedNoNetwork,
+			"type":    "mandatory",
+			"skipped": true,
+		}, actual["results"].(map[string]interface{})["images-are-certified"])
+	})
+
+	t.Run("Should only execute metadata checks when option --metadata-only is given", func(t *testing.T) {
+		actual := verifyJSON(t, viper.New(), "-e", "has-readme,version-is-semver,helm-lint,no-nodeport-services,images-are-certified", "-o", "json", "--metadata-only", testChart("chart-0.1.0-v3.valid.tgz"))
+
+		require.Equal(t, true, actual["ok"])
+		require.Equal(t, map[string]interface{}{"passed": float64(2), "failed": float64(0), "skipped": float64(3)}, actual["summary"])
+		results := actual["results"].(map[string]interface{})
+		for _, name := range []string{"has-readme", "version-is-semver"} {
+			require.NotContains(t, results[name], "skipped", name)
+		}
+		for _, name := range []string{"helm-lint", "no-nodeport-services", "images-are-certified"} {
+			require.Equal(t, true, results[name].(map[string]interface{})["skipped"], name)
+			require.Equal(t, chartverifier.CheckSkippedMetadataOnly, results[name].(map[string]interface{})["reason"], name)
+		}
+	})
+
+	t.Run("Should persist the outcome of each check to the checkpoint when option --checkpoint is given", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "chart-verifier")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		checkpoint := filepath.Join(dir, "checkpoint.jsonl")
+		uri := testChart("chart-0.1.0-v3.valid.tgz")
+
+		first := verifyJSON(t, viper.New(), "-e", "is-helm-v3,has-readme", "-o", "json", "--timestamp", "2021-03-04T05:06:07Z", "--checkpoint", checkpoint, uri)
+
+		b, err := ioutil.ReadFile(checkpoint)
+		require.NoError(t, err)
+		require.Len(t, strings.Split(strings.TrimSuffix(string(b), "\n"), "\n"), 2)
+
+		resumed := verifyJSON(t, viper.New(), "-e", "is-helm-v3,has-readme", "-o", "json", "--timestamp", "2021-03-04T05:06:07Z", "--checkpoint", checkpoint, "--resume", uri)
+		require.Equal(t, first["results"], resumed["results"])
+
+		after, err := ioutil.ReadFile(checkpoint)
+		require.NoError(t, err)
+		require.Equal(t, b, after)
+	})
+
+	t.Run("Should fail when option --resume is given without option --checkpoint", func(t *testing.T) {
+		cmd := NewVerifyCmd(viper.New())
+		cmd.SetOut(bytes.NewBufferString(""))
+		cmd.SetErr(bytes.NewBufferString(""))
+		cmd.SetArgs([]string{"-e", "is-helm-v3", "--resume", testChart("chart-0.1.0-v3.valid.tgz")})
+		err := cmd.Execute()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "--checkpoint")
+	})
+
+	t.Run("Should fail without notifying when the report can't be written", func(t *testing.T) {
+		useTempWorkDir(t)
+		// a file in place of the reports directory keeps the report from being written
+		require.NoError(t, ioutil.WriteFile("reports", nil, 0644))
+
+		notified := false
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			notified = true
+		}))
+		defer srv.Close()
+		ledger := filepath.Join(t.TempDir(), "ledger.jsonl")
+
+		cmd := NewVerifyCmd(viper.New())
+		cmd.SetOut(bytes.NewBufferString(""))
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3",
+			"--notify-url", srv.URL,
+			"--notify-required",
+			"--ledger", ledger,
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		err := cmd.Execute()
+		require.Error(t, err)
+		require.Contains(t, errBuf.String(), "Report failure :")
+		require.False(t, notified)
+		_, err = os.Stat(ledger)
+		require.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("Should succeed when the check outcomes match option --expect", func(t *testing.T) {
+		actual := verifyJSON(t, viper.New(), "-e", "is-helm-v3,has-readme,images-are-certified", "-o", "json", "--no-network",
+			"--expect", "is-helm-v3=pass,has-readme=pass,images-are-certified=skip", testChart("chart-0.1.0-v3.valid.tgz"))
+		require.Equal(t, true, actual["ok"])
+	})
+
+	t.Run("Should fail with the differences when the check outcomes don't match option --expect", func(t *testing.T) {
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		cmd.SetErr(bytes.NewBufferString(""))
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3,has-readme",
+			"-o", "json",
+			"--expect", "is-helm-v3=fail,has-readme=pass,has-minkubeversion=pass",
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		err := cmd.Execute()
+		require.Error(t, err)
+		require.Equal(t, "check outcomes differ from the expected ones:"+
+			"\n\thas-minkubeversion: expected pass, got not run"+
+			"\n\tis-helm-v3: expected fail, got pass", err.Error())
+
+		// the report is still written
+		var report map[string]interface{}
+		require.NoError(t, json.Unmarshal(outBuf.Bytes(), &report))
+		require.Equal(t, true, report["ok"])
+	})
+
+	t.Run("Should fail when option --expect is malformed", func(t *testing.T) {
+		cmd := NewVerifyCmd(viper.New())
+		cmd.SetOut(bytes.NewBufferString(""))
+		cmd.SetErr(bytes.NewBufferString(""))
+		cmd.SetArgs([]string{"-e", "is-helm-v3", "--expect", "is-helm-v3=ok", testChart("chart-0.1.0-v3.valid.tgz")})
+		err := cmd.Execute()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid expectation")
+	})
+
+	t.Run("Should succeed when only the checks failing in option --baseline fail", func(t *testing.T) {
+		uri := testChart("chart-0.1.0-v3.valid.tgz")
+		baseline := writeBaseline(t, "has-readme,has-license,revision-history-bounded", uri)
+
+		actual := verifyJSON(t, viper.New(), "-e", "has-readme,has-license,revision-history-bounded", "-o", "json", "--validate-schema",
+			"--fail-on", "optional", "--baseline", baseline, uri)
+		require.Equal(t, true, actual["ok"])
+		results := actual["results"].(map[string]interface{})
+		require.Equal(t, true, results["has-license"].(map[string]interface{})["known"])
+		require.Equal(t, true, results["revision-history-bounded"].(map[string]interface{})["known"])
+		require.NotContains(t, results["has-readme"], "known")
+	})
+
+	t.Run("Should fail with the checks newly failing compared to option --baseline", func(t *testing.T) {
+		uri := testChart("chart-0.1.0-v3.valid.tgz")
+		baseline := writeBaseline(t, "has-readme,revision-history-bounded", uri)
+
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+		cmd.SetArgs([]string{
+			"-e", "has-readme,has-license,revision-history-bounded,update-strategy-declared",
+			"-o", "json",
+			"--fail-on", "optional",
+			"--baseline", baseline,
+			uri,
+		})
+		err := cmd.Execute()
+		require.Error(t, err)
+		require.Equal(t, "checks newly failing compared to the baseline:"+
+			"\n\thas-license"+
+			"\n\tupdate-strategy-declared", err.Error())
+		require.Contains(t, errBuf.String(), "Known failure :revision-history-bounded")
+
+		// the report is still written
+		var report map[string]interface{}
+		require.NoError(t, json.Unmarshal(outBuf.Bytes(), &report))
+		require.Equal(t, false, report["ok"])
+		results := report["results"].(map[string]interface{})
+		require.Equal(t, true, results["revision-history-bounded"].(map[string]interface{})["known"])
+		require.NotContains(t, results["has-license"], "known")
+	})
+
+	t.Run("Should only fail with the blocking checks newly failing compared to option --baseline", func(t *testing.T) {
+		baseline := writeBaseline(t, "contains-test,has-license", testChart("chart-0.1.0-v3.license-file.tgz"))
+
+		cmd := NewVerifyCmd(viper.New())
+		cmd.SetOut(bytes.NewBufferString(""))
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+		cmd.SetArgs([]string{
+			"-e", "contains-test,has-license",
+			"--baseline", baseline,
+			testChart("chart-0.1.0-v3.valid.notest.tgz"),
+		})
+		err := cmd.Execute()
+		require.Error(t, err)
+		require.Equal(t, "checks newly failing compared to the baseline:"+
+			"\n\tcontains-test", err.Error())
+		require.Contains(t, errBuf.String(), "New non-blocking failure :has-license")
+	})
+
+	t.Run("Should fail when option --baseline is not a report", func(t *testing.T) {
+		baseline := filepath.Join(t.TempDir(), "baseline.json")
+		require.NoError(t, ioutil.WriteFile(baseline, []byte("{}"), 0644))
+
+		cmd := NewVerifyCmd(viper.New())
+		cmd.SetOut(bytes.NewBufferString(""))
+		cmd.SetErr(bytes.NewBufferString(""))
+		cmd.SetArgs([]string{"-e", "is-helm-v3", "--baseline", baseline, testChart("chart-0.1.0-v3.valid.tgz")})
+		err := cmd.Execute()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed reading baseline")
+	})
+
+	t.Run("Should write the report when it conforms to the report schema and option --validate-schema is given", func(t *testing.T) {
+		actual := verifyJSON(t, viper.New(), "-e", "is-helm-v3,has-readme,has-minkubeversion", "-o", "json", "--validate-schema",
+			"--openshift-version", "4.7,4.8", "--annotation", "ticket=CERT-123", testChart("chart-0.1.0-v3.valid.tgz"))
+		require.Contains(t, actual, "results")
+	})
+
+	t.Run("Should report and write the suggested fixes when option --suggest-fixes is given", func(t *testing.T) {
+		fixes := filepath.Join(t.TempDir(), "fixes.yaml")
+		actual := verifyJSON(t, viper.New(), "-e", "revision-history-bounded", "-o", "json", "--validate-schema",
+			"--suggest-fixes", "--suggested-fixes-file", fixes, testChart("chart-0.1.0-v3.valid.tgz"))
+
+		result := actual["results"].(map[string]interface{})["revision-history-bounded"].(map[string]interface{})
+		require.Equal(t, []interface{}{map[string]interface{}{
+			"resource":    "Deployment/testRelease-chart",
+			"description": "Keep at most 10 old ReplicaSets",
+			"patch":       "spec:\n  revisionHistoryLimit: 10\n",
+		}}, result["suggestions"])
+
+		b, err := ioutil.ReadFile(fixes)
+		require.NoError(t, err)
+		require.Equal(t, "# revision-history-bounded : Deployment/testRelease-chart\n"+
+			"# Keep at most 10 old ReplicaSets\n"+
+			"spec:\n  revisionHistoryLimit: 10\n", string(b))
+	})
+
+	t.Run("Should fail when option --suggested-fixes-file is given without --suggest-fixes", func(t *testing.T) {
+		cmd := NewVerifyCmd(viper.New())
+		cmd.SetOut(bytes.NewBufferString(""))
+		cmd.SetErr(bytes.NewBufferString(""))
+
+		cmd.SetArgs([]string{"-e", "revision-history-bounded", "--suggested-fixes-file", "fixes.yaml", testChart("chart-0.1.0-v3.valid.tgz")})
+		err := cmd.Execute()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "--suggested-fixes-file requires --suggest-fixes")
+	})
+
+	t.Run("Should fail when option --timestamp is malformed", func(t *testing.T) {
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3",
+			"--timestamp", "2021-03-04",
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		err := cmd.Execute()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "RFC 3339")
+	})
+
+	t.Run("Should authenticate with the informed --username and --password without reporting them", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if u, p, ok := r.BasicAuth(); !ok || u != "admin" || p != "s3cr3t" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			http.ServeFile(w, r, testChart("chart-0.1.0-v3.valid.tgz"))
+		}))
+		defer srv.Close()
+		uri := srv.URL + "/charts/chart-0.1.0-v3.authenticated.tgz"
+
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{"-e", "is-helm-v3", "-o", "json", "--username", "admin", "--password", "s3cr3t", uri})
+		require.NoError(t, cmd.Execute())
+		require.NotContains(t, outBuf.String(), "s3cr3t")
+		require.NotContains(t, errBuf.String(), "s3cr3t")
+
+		b, err := ioutil.ReadFile(filepath.Join("reports", "chart-0.1.0-v3.authenticated.tgz", "verifier.report.yaml"))
+		require.NoError(t, err)
+		require.NotContains(t, string(b), "s3cr3t")
+	})
+
+	t.Run("Should authenticate with the credentials informed in the chart uri without reporting them", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if u, p, ok := r.BasicAuth(); !ok || u != "admin" || p != "s3cr3t" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			http.ServeFile(w, r, testChart("chart-0.1.0-v3.valid.tgz"))
+		}))
+		defer srv.Close()
+		uri := strings.Replace(srv.URL, "http://", "http://admin:s3cr3t@", 1) + "/charts/chart-0.1.0-v3.userinfo.tgz"
+
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{"-e", "is-helm-v3", "-o", "json", uri})
+		require.NoError(t, cmd.Execute())
+		require.NotContains(t, outBuf.String(), "s3cr3t")
+		require.NotContains(t, errBuf.String(), "s3cr3t")
+
+		var report map[string]interface{}
+		require.NoError(t, json.Unmarshal(outBuf.Bytes(), &report))
+		tool := report["metadata"].(map[string]interface{})["tool"].(map[string]interface{})
+		require.Equal(t, srv.URL+"/charts/chart-0.1.0-v3.userinfo.tgz", tool["chart-uri"])
+
+		b, err := ioutil.ReadFile(filepath.Join("reports", "chart-0.1.0-v3.userinfo.tgz", "verifier.report.yaml"))
+		require.NoError(t, err)
+		require.NotContains(t, string(b), "s3cr3t")
+	})
+
+	t.Run("Should trust the CA informed through option --ca-file when retrieving the chart over HTTPS", func(t *testing.T) {
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.ServeFile(w, r, testChart("chart-0.1.0-v3.valid.tgz"))
+		}))
+		defer srv.Close()
+
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+		require.NoError(t, ioutil.WriteFile(caFile, ca, 0644))
+
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{"-e", "is-helm-v3", "--ca-file", caFile, srv.URL + "/charts/chart-0.1.0-v3.private-ca.tgz"})
+		require.NoError(t, cmd.Execute())
+
+		cmd = NewVerifyCmd(viper.New())
+		cmd.SetOut(bytes.NewBufferString(""))
+		cmd.SetErr(bytes.NewBufferString(""))
+
+		cmd.SetArgs([]string{"-e", "is-helm-v3", srv.URL + "/charts/chart-0.1.0-v3.untrusted-ca.tgz"})
+		err := cmd.Execute()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "certificate")
+	})
+
+	t.Run("Should warn when option --insecure-skip-tls-verify is given", func(t *testing.T) {
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.ServeFile(w, r, testChart("chart-0.1.0-v3.valid.tgz"))
+		}))
+		defer srv.Close()
+
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{"-e", "is-helm-v3", "--insecure-skip-tls-verify", srv.URL + "/charts/chart-0.1.0-v3.insecure.tgz"})
+		require.NoError(t, cmd.Execute())
+		require.Contains(t, errBuf.String(), "server certificates will not be verified")
+	})
+
+	t.Run("Should render templates once per profile when option --values-profile is given", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "chart-verifier-profiles")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+		defaultProfile := filepath.Join(dir, "default.yaml")
+		require.NoError(t, ioutil.WriteFile(defaultProfile, []byte("replicaCount: 1\n"), 0644))
+		ingressProfile := filepath.Join(dir, "ingress.yaml")
+		require.NoError(t, ioutil.WriteFile(ingressProfile, []byte("ingress:\n  enabled: true\n"), 0644))
+
+		actual := verifyJSON(t, viper.New(),
+			"-e", "no-nodeport-services",
+			"-o", "json",
+			"--values-profile", "default="+defaultProfile,
+			"--values-profile", "ingress="+ingressProfile,
+			testChart("chart-0.1.0-v3.ingress-nodeport.tgz"),
+		)
+
+		result := actual["results"].(map[string]interface{})["no-nodeport-services"].(map[string]interface{})
+		require.Equal(t, false, result["ok"])
+		profiles := result["values-profiles"].(map[string]interface{})
+		require.Equal(t, true, profiles["default"].(map[string]interface{})["ok"])
+		require.Equal(t, false, profiles["ingress"].(map[string]interface{})["ok"])
+		require.Len(t, profiles["ingress"].(map[string]interface{})["findings"], 1)
+	})
+
+	t.Run("Should fail when option --values-profile is malformed", func(t *testing.T) {
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{
+			"-e", "no-nodeport-services",
+			"--values-profile", "ingress.yaml",
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		err := cmd.Execute()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "name=path")
+	})
+
+	t.Run("Should mask hostnames in the output and the report when option --redact-hosts is given", func(t *testing.T) {
+		srv := httptest.NewServer(http.FileServer(http.Dir(checksDir)))
+		defer srv.Close()
+		uri := srv.URL + "/chart-0.1.0-v3.valid.tgz"
+		host := strings.TrimPrefix(srv.URL, "http://")
+		host = host[:strings.LastIndex(host, ":")]
+
+		for _, format := range []string{"default", "json", "yaml"} {
+			cmd := NewVerifyCmd(viper.New())
+			outBuf := bytes.NewBufferString("")
+			cmd.SetOut(outBuf)
+			errBuf := bytes.NewBufferString("")
+			cmd.SetErr(errBuf)
+
+			cmd.SetArgs([]string{"-e", "is-helm-v3,has-valid-icon", "-o", format, "--redact-hosts", uri})
+			require.NoError(t, cmd.Execute())
+			require.NotContains(t, outBuf.String(), host)
+			require.Contains(t, outBuf.String(), "http://"+chartverifier.RedactedHost+":")
+
+			b, err := ioutil.ReadFile(filepath.Join("reports", "chart-0.1.0-v3.valid.tgz", "verifier.report.yaml"))
+			require.NoError(t, err)
+			require.NotContains(t, string(b), host)
+			require.Contains(t, string(b), "chart-uri: http://"+chartverifier.RedactedHost+":")
+		}
+	})
+
+	t.Run("Should write each check result as a JSON line followed by a summary when option --stream is given", func(t *testing.T) {
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3,has-readme,contains-values-schema",
+			"--stream",
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		require.NoError(t, cmd.Execute())
+
+		lines := strings.Split(strings.TrimSuffix(outBuf.String(), "\n"), "\n")
+		require.Len(t, lines, 4)
+
+		streamed := map[string]bool{}
+		for _, line := range lines[:3] {
+			var r chartverifier.StreamedResult
+			require.NoError(t, json.Unmarshal([]byte(line), &r), line)
+			require.Equal(t, chartverifier.StreamEventResult, r.Event)
+			require.NotEmpty(t, r.Reason)
+			streamed[r.Check] = r.Ok
+		}
+		require.Equal(t, map[string]bool{"is-helm-v3": true, "has-readme": true, "contains-values-schema": true}, streamed)
+
+		var summary map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(lines[3]), &summary), lines[3])
+		require.Equal(t, chartverifier.StreamEventSummary, summary["event"])
+		require.Equal(t, true, summary["ok"])
+		require.Equal(t, map[string]interface{}{"passed": float64(3), "failed": float64(0)}, summary["summary"])
+
+		// the report is still generated
+		_, err := os.Stat(filepath.Join("reports", "chart-0.1.0-v3.valid.tgz", "verifier.report.yaml"))
+		require.NoError(t, err)
+	})
+
+	t.Run("Should only stream failed checks when options --stream and --only-failures are given", func(t *testing.T) {
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3,has-minkubeversion",
+			"--stream", "--only-failures", "--openshift-version", "4.7,4.8",
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		require.NoError(t, cmd.Execute())
+
+		lines := strings.Split(strings.TrimSuffix(outBuf.String(), "\n"), "\n")
+		require.Len(t, lines, 2)
+
+		var r chartverifier.StreamedResult
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &r), lines[0])
+		require.Equal(t, "has-minkubeversion", r.Check)
+		require.False(t, r.Ok)
+
+		var summary chartverifier.StreamedSummary
+		require.NoError(t, json.Unmarshal([]byte(lines[1]), &summary), lines[1])
+		require.Equal(t, chartverifier.StreamEventSummary, summary.Event)
+		require.False(t, summary.Ok)
+	})
+
+	t.Run("Should fail when options --stream and --sink stdout are given", func(t *testing.T) {
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3",
+			"--stream", "--sink", "stdout",
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		err := cmd.Execute()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "--stream and --sink stdout")
+	})
+
+	t.Run("Should fail when option --fail-on is unknown", func(t *testing.T) {
+		cmd := NewVerifyCmd(viper.New())
+		outBuf := bytes.NewBufferString("")
+		cmd.SetOut(outBuf)
+		errBuf := bytes.NewBufferString("")
+		cmd.SetErr(errBuf)
+
+		cmd.SetArgs([]string{
+			"-e", "is-helm-v3",
+			"--fail-on", "everything",
+			testChart("chart-0.1.0-v3.valid.tgz"),
+		})
+		err := cmd.Execute()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unknown fail-on mode")
+	})
+
+}
+
+func TestBuildChecks(t *testing.T) {
+	t.Run("Should fail when enabledChecks and disabledChecks have more than one item at the same time", func(t *testing.T) {
+		var (
+			all      = []string{"a", "b", "c"}
+			enabled  = []string{"a"}
+			disabled = []string{"b"}
+		)
+		selected, err := buildChecks(all, enabled, disabled)
+		require.Error(t, err)
+		require.Nil(t, selected)
+	})
+
+	t.Run("Should fail when enabled check is unknown", func(t *testing.T) {
+		var (
+			all      = []string{"a", "b", "c"}
+			disabled = []string{}
+			enabled  = []string{"d"}
+		)
+		selected, err := buildChecks(all, enabled, disabled)
+		require.Error(t, err)
+		require.Nil(t, selected)
+	})
+
+	t.Run("Should fail when disabled check is unknown", func(t *testing.T) {
+		var (
+			all      = []string{"a", "b", "c"}
+			disabled = []string{"e"}
+			enabled  = []string{}
+		)
+		selected, err := buildChecks(all, enabled, disabled)
+		require.Error(t, err)
+		require.Nil(t, selected)
+	})
+
+	t.Run("Should return all checks when neither enabled or disabled checks have been informed", func(t *testing.T) {
+		var (
+			enabled  = []string{}
+			disabled = []string{}
+			all      = []string{"a", "b", "c"}
+		)
+		selected, err := buildChecks(all, enabled, disabled)
+		require.NoError(t, err)
+		require.Equal(t, selected, all)
+	})
+
+}
+
+// setEnv sets the environment variable key for the duration of the test.
+func setEnv(t *testing.T, key, value string) {
+	original, found := os.LookupEnv(key)
+	require.NoError(t, os.Setenv(key, value))
+	t.Cleanup(func() {
+		if found {
+			os.Setenv(key, original)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+// verifyJSON executes the verify command with the given configuration and arguments, returning its JSON output.
+// writeBaseline writes the JSON report of the given checks, to be informed as baseline.
+func writeBaseline(t *testing.T, enabled, uri string) string {
+	baseline := filepath.Join(t.TempDir(), "baseline.json")
+	cmd := NewVerifyCmd(viper.New())
+	outBuf := bytes.NewBufferString("")
+	cmd.SetOut(outBuf)
+	cmd.SetErr(bytes.NewBufferString(""))
+	cmd.SetArgs([]string{"-e", enabled, "-o", "json", uri})
+	require.NoError(t, cmd.Execute())
+	require.NoError(t, ioutil.WriteFile(baseline, outBuf.Bytes(), 0644))
+	return baseline
+}
+
+func verifyJSON(t *testing.T, config *viper.Viper, args ...string) map[string]interface{} {
+	cmd := NewVerifyCmd(config)
+	outBuf := bytes.NewBufferString("")
+	cmd.SetOut(outBuf)
+	errBuf := bytes.NewBufferString("")
+	cmd.SetErr(errBuf)
+
+	cmd.SetArgs(args)
+	require.NoError(t, cmd.Execute())
+
+	actual := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(outBuf.Bytes(), &actual))
+	return actual
 }
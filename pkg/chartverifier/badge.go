@@ -0,0 +1,47 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+const (
+	badgeLabel = "chart-verifier"
+
+	BadgePassed             = "passed"
+	BadgePassedWithWarnings = "passed with warnings"
+	BadgeFailed             = "failed"
+)
+
+// Badge is a status summary compatible with shields.io endpoints, suitable for embedding in a README.
+type Badge struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Label         string `json:"label"`
+	Message       string `json:"message"`
+	Color         string `json:"color"`
+}
+
+// NewBadge summarizes the outcome of the given certificate; certificates whose outcome is positive despite failed
+// checks, such as optional ones, are reported as passed with warnings.
+func NewBadge(c Certificate) Badge {
+	b := Badge{SchemaVersion: 1, Label: badgeLabel, Message: BadgePassed, Color: "green"}
+
+	if !c.IsOk() {
+		b.Message, b.Color = BadgeFailed, "red"
+	} else if cert, ok := c.(*certificate); ok && cert.Summary.Failed > 0 {
+		b.Message, b.Color = BadgePassedWithWarnings, "yellow"
+	}
+
+	return b
+}
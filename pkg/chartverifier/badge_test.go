@@ -0,0 +1,73 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
+)
+
+func TestNewBadge(t *testing.T) {
+	type testCase struct {
+		description string
+		mandatoryOk bool
+		optionalOk  bool
+		expected    string
+	}
+
+	testCases := []testCase{
+		{
+			description: "all checks passed",
+			mandatoryOk: true,
+			optionalOk:  true,
+			expected:    `{"schemaVersion":1,"label":"chart-verifier","message":"passed","color":"green"}`,
+		},
+		{
+			description: "mandatory check failed",
+			mandatoryOk: false,
+			optionalOk:  true,
+			expected:    `{"schemaVersion":1,"label":"chart-verifier","message":"failed","color":"red"}`,
+		},
+		{
+			description: "only optional checks failed",
+			mandatoryOk: true,
+			optionalOk:  false,
+			expected:    `{"schemaVersion":1,"label":"chart-verifier","message":"passed with warnings","color":"yellow"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			cert, err := NewCertificateBuilder().
+				SetChartName("chart").
+				SetChartVersion("0.1.0").
+				SetFailOn(FailOnMandatory).
+				AddCheckResult("mandatory-check", checks.MandatoryCheckType, checks.NewResult(tc.mandatoryOk, "mandatory")).
+				AddCheckResult("optional-check", checks.OptionalCheckType, checks.NewResult(tc.optionalOk, "optional")).
+				Build()
+			require.NoError(t, err)
+
+			b, err := json.Marshal(NewBadge(cert))
+			require.NoError(t, err)
+			require.JSONEq(t, tc.expected, string(b))
+		})
+	}
+}
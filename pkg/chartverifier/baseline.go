@@ -0,0 +1,71 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"sort"
+)
+
+// BaselineComparison describes the failed checks of a certificate compared to the ones of a baseline report.
+type BaselineComparison struct {
+	// New contains the checks which have failed, while they haven't in the baseline, sorted by name.
+	New []string
+	// Blocking contains the checks of New failing the overall outcome according to FailOn, sorted by name.
+	Blocking []string
+	// Known contains the checks which have failed in the baseline as well, sorted by name.
+	Known []string
+}
+
+// failed informs whether the given check result is a failure; skipped checks don't fail.
+func failed(r checkResult) bool {
+	return !r.Ok && !r.Skipped
+}
+
+// ApplyBaseline returns a copy of the given certificate whose failed checks also failing in the baseline are marked as
+// known, and whose overall outcome, according to failOn, only accounts for the checks newly failing. The comparison
+// of the failed checks is returned along.
+func ApplyBaseline(c Certificate, baseline *Report, failOn FailOn) (Certificate, BaselineComparison) {
+	var comparison BaselineComparison
+	cert, ok := c.(*certificate)
+	if !ok {
+		return c, comparison
+	}
+
+	applied := *cert
+	applied.Ok = true
+	applied.CheckResultMap = checkResultMap{}
+	for name, r := range cert.CheckResultMap {
+		if failed(r) {
+			if b, ok := baseline.CheckResultMap[name]; ok && failed(b) {
+				r.Known = true
+				comparison.Known = append(comparison.Known, name)
+			} else {
+				comparison.New = append(comparison.New, name)
+				if failOn.blocks(r.Type) {
+					comparison.Blocking = append(comparison.Blocking, name)
+					applied.Ok = false
+				}
+			}
+		}
+		applied.CheckResultMap[name] = r
+	}
+	sort.Strings(comparison.New)
+	sort.Strings(comparison.Blocking)
+	sort.Strings(comparison.Known)
+
+	return &applied, comparison
+}
@@ -0,0 +1,99 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
+)
+
+func TestApplyBaseline(t *testing.T) {
+	b, err := NewCertificateBuilder().
+		SetChartName("chart").
+		SetChartVersion("0.1.0").
+		AddCheckResult("known-check", checks.MandatoryCheckType, checks.NewResult(false, "failed")).
+		AddCheckResult("fixed-check", checks.MandatoryCheckType, checks.NewResult(false, "failed")).
+		AddCheckResult("regressed-check", checks.MandatoryCheckType, checks.NewResult(true, "passed")).
+		Build()
+	require.NoError(t, err)
+	baseline := &Report{certificate: *b.(*certificate)}
+
+	t.Run("Should succeed when only known checks fail", func(t *testing.T) {
+		cert, err := NewCertificateBuilder().
+			SetChartName("chart").
+			SetChartVersion("0.1.0").
+			AddCheckResult("known-check", checks.MandatoryCheckType, checks.NewResult(false, "failed")).
+			AddCheckResult("fixed-check", checks.MandatoryCheckType, checks.NewResult(true, "passed")).
+			AddCheckResult("regressed-check", checks.MandatoryCheckType, checks.NewResult(true, "passed")).
+			AddSkippedCheck("skipped-check", checks.MandatoryCheckType, CheckSkippedNoNetwork).
+			Build()
+		require.NoError(t, err)
+		require.False(t, cert.IsOk())
+
+		applied, comparison := ApplyBaseline(cert, baseline, FailOnMandatory)
+		require.True(t, applied.IsOk())
+		require.Equal(t, BaselineComparison{Known: []string{"known-check"}}, comparison)
+
+		results := applied.(*certificate).CheckResultMap
+		require.True(t, results["known-check"].Known)
+		require.False(t, results["fixed-check"].Known)
+
+		// the certificate itself is left untouched
+		require.False(t, cert.IsOk())
+		require.False(t, cert.(*certificate).CheckResultMap["known-check"].Known)
+	})
+
+	t.Run("Should fail when a check newly fails", func(t *testing.T) {
+		cert, err := NewCertificateBuilder().
+			SetChartName("chart").
+			SetChartVersion("0.1.0").
+			AddCheckResult("known-check", checks.MandatoryCheckType, checks.NewResult(false, "failed")).
+			AddCheckResult("regressed-check", checks.MandatoryCheckType, checks.NewResult(false, "failed")).
+			AddCheckResult("new-check", checks.MandatoryCheckType, checks.NewResult(false, "failed")).
+			Build()
+		require.NoError(t, err)
+
+		applied, comparison := ApplyBaseline(cert, baseline, FailOnMandatory)
+		require.False(t, applied.IsOk())
+		require.Equal(t, BaselineComparison{
+			New:      []string{"new-check", "regressed-check"},
+			Blocking: []string{"new-check", "regressed-check"},
+			Known:    []string{"known-check"},
+		}, comparison)
+	})
+
+	t.Run("Should succeed when an optional check newly fails unless failing on optional checks", func(t *testing.T) {
+		cert, err := NewCertificateBuilder().
+			SetChartName("chart").
+			SetChartVersion("0.1.0").
+			AddCheckResult("new-check", checks.OptionalCheckType, checks.NewResult(false, "failed")).
+			Build()
+		require.NoError(t, err)
+
+		applied, comparison := ApplyBaseline(cert, baseline, FailOnMandatory)
+		require.True(t, applied.IsOk())
+		require.Equal(t, []string{"new-check"}, comparison.New)
+		require.Empty(t, comparison.Blocking)
+
+		applied, comparison = ApplyBaseline(cert, baseline, FailOnOptional)
+		require.False(t, applied.IsOk())
+		require.Equal(t, []string{"new-check"}, comparison.Blocking)
+	})
+}
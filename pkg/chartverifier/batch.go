@@ -0,0 +1,83 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// BatchResult is the outcome of the verification of one of the charts informed to VerifyBatch: either the report of
+// the chart found at URI, or the error verifying it.
+type BatchResult struct {
+	URI    string
+	Report *Report
+	Err    error
+}
+
+// maxConcurrentVerifications returns the number of verifications VerifyBatch runs at once.
+func (c *certifier) maxConcurrentVerifications() int {
+	if c.maxConcurrent > 0 {
+		return c.maxConcurrent
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+func (c *certifier) VerifyBatch(ctx context.Context, uris []string) <-chan BatchResult {
+	results := make(chan BatchResult)
+
+	workers := c.maxConcurrentVerifications()
+	if workers > len(uris) {
+		workers = len(uris)
+	}
+
+	pending := make(chan string)
+	go func() {
+		defer close(pending)
+		for _, uri := range uris {
+			select {
+			case pending <- uri:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for uri := range pending {
+				report, err := c.Verify(ctx, uri)
+				select {
+				case results <- BatchResult{URI: uri, Report: report, Err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
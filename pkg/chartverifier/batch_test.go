@@ -0,0 +1,116 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
+)
+
+func TestCertifier_VerifyBatch(t *testing.T) {
+	t.Run("Should emit the outcome of every verification", func(t *testing.T) {
+		registry := checks.NewRegistry().Add("positive-check", func(uri string, _ *viper.Viper) (checks.Result, error) {
+			return checks.NewResult(true, "positive"), nil
+		})
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			SetChecks([]string{"positive-check"}).
+			SetMaxConcurrentVerifications(2).
+			Build()
+		require.NoError(t, err)
+
+		uris := []string{
+			"./checks/chart-0.1.0-v3.valid.tgz",
+			"./checks/chart-0.1.0-v3.non-existing.tgz",
+			"./checks/chart-0.1.0-v3.jobs.tgz",
+			"./checks/chart-0.1.0-v3.without-icon.tgz",
+		}
+
+		results := map[string]BatchResult{}
+		for result := range c.VerifyBatch(context.Background(), uris) {
+			require.NotContains(t, results, result.URI)
+			results[result.URI] = result
+		}
+		require.Len(t, results, len(uris))
+
+		for _, uri := range uris {
+			result := results[uri]
+			if uri == "./checks/chart-0.1.0-v3.non-existing.tgz" {
+				require.Error(t, result.Err)
+				require.True(t, checks.IsChartNotFound(result.Err))
+				require.Nil(t, result.Report)
+				continue
+			}
+			require.NoError(t, result.Err, uri)
+			require.True(t, result.Report.IsOk(), uri)
+		}
+	})
+
+	t.Run("Should close the channel when no uri is informed", func(t *testing.T) {
+		registry := checks.NewRegistry().Add("positive-check", func(uri string, _ *viper.Viper) (checks.Result, error) {
+			return checks.NewResult(true, "positive"), nil
+		})
+		c, err := NewCertifierBuilder().SetRegistry(registry).SetChecks([]string{"positive-check"}).Build()
+		require.NoError(t, err)
+
+		_, open := <-c.VerifyBatch(context.Background(), nil)
+		require.False(t, open)
+	})
+
+	t.Run("Should stop starting verifications once the context is cancelled", func(t *testing.T) {
+		started := make(chan struct{}, 10)
+		release := make(chan struct{})
+		defer close(release)
+
+		var calls int32
+		registry := checks.NewRegistry().Add("hanging-check", func(uri string, _ *viper.Viper) (checks.Result, error) {
+			atomic.AddInt32(&calls, 1)
+			started <- struct{}{}
+			<-release
+			return checks.NewResult(true, "finally"), nil
+		})
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			SetChecks([]string{"hanging-check"}).
+			SetMaxConcurrentVerifications(1).
+			Build()
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		results := c.VerifyBatch(ctx, []string{
+			"./checks/chart-0.1.0-v3.valid.tgz",
+			"./checks/chart-0.1.0-v3.jobs.tgz",
+			"./checks/chart-0.1.0-v3.without-icon.tgz",
+		})
+
+		<-started
+		cancel()
+
+		for result := range results {
+			require.Error(t, result.Err)
+			require.True(t, errors.Is(result.Err, context.Canceled))
+		}
+		require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+}
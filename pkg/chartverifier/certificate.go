@@ -16,7 +16,30 @@
 
 package chartverifier
 
-import "strconv"
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"gopkg.in/yaml.v3"
+
+	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
+)
+
+// ReportSchemaVersion is the version of the report schema produced by this package; it must be bumped whenever the
+// report fields change.
+const ReportSchemaVersion = "1.7"
+
+// UnsupportedSchemaVersionErr is returned when loading a report produced with a newer, unknown, schema version.
+type UnsupportedSchemaVersionErr struct {
+	Version string
+}
+
+func (e UnsupportedSchemaVersionErr) Error() string {
+	return fmt.Sprintf("report schema version %q is not supported, the latest supported version is %q", e.Version, ReportSchemaVersion)
+}
 
 type chartMetadata struct {
 	Name    string `json:"name" yaml:"name"`
@@ -26,15 +49,29 @@ type chartMetadata struct {
 type runMetadata struct {
 	Version  string `json:"verifier-version" yaml:"verifier-version"`
 	ChartUri string `json:"chart-uri" yaml:"chart-uri"`
+	// GeneratedAt is the time the certificate has been generated, formatted as RFC 3339 in UTC.
+	GeneratedAt string `json:"generated-at,omitempty" yaml:"generated-at,omitempty"`
+	// CertifiedOpenShiftVersions contains the informed OpenShift versions the chart has passed the verification for.
+	CertifiedOpenShiftVersions []string `json:"certified-openshift-versions,omitempty" yaml:"certified-openshift-versions,omitempty"`
+	// KubeVersion is the Kubernetes version the chart has been verified against, if informed.
+	KubeVersion string `json:"kube-version,omitempty" yaml:"kube-version,omitempty"`
+	// SkippedDependencies contains the dependencies which haven't been retrieved when building the chart's
+	// dependencies, since they're hosted in OCI registries, so checks haven't rendered them.
+	SkippedDependencies []string `json:"skipped-dependencies,omitempty" yaml:"skipped-dependencies,omitempty"`
 }
 
 type metadata struct {
+	// SchemaVersion is the version of the report schema, informing consumers how the report should be parsed.
+	SchemaVersion string        `json:"schema-version" yaml:"schema-version"`
 	RunMetadata   runMetadata   `json:"tool" yaml:"tool"`
 	ChartMetadata chartMetadata `json:"chart" yaml:"chart"`
+	// Annotations contains arbitrary metadata informed by the caller, such as tracking information.
+	Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
 }
 
 func newMetadata(name, version, chartUri, toolVersion string) *metadata {
 	return &metadata{
+		SchemaVersion: ReportSchemaVersion,
 		RunMetadata: runMetadata{
 			ChartUri: chartUri,
 			Version:  toolVersion,
@@ -46,27 +83,131 @@ func newMetadata(name, version, chartUri, toolVersion string) *metadata {
 	}
 }
 
+// summary accounts for all checks executed within a certification, regardless of which results are present in the
+// certificate.
+type summary struct {
+	Passed int `json:"passed" yaml:"passed"`
+	Failed int `json:"failed" yaml:"failed"`
+	// Skipped accounts for the checks which couldn't be executed in the current mode, such as without network access.
+	Skipped int `json:"skipped,omitempty" yaml:"skipped,omitempty"`
+}
+
+func newSummary(resultMap checkResultMap) summary {
+	s := summary{}
+	for _, v := range resultMap {
+		if v.Skipped {
+			s.Skipped++
+		} else if v.Ok {
+			s.Passed++
+		} else {
+			s.Failed++
+		}
+	}
+	return s
+}
+
 type certificate struct {
 	Ok             bool           `json:"ok" yaml:"ok"`
 	Metadata       *metadata      `json:"metadata" yaml:"metadata"`
+	Summary        summary        `json:"summary" yaml:"summary"`
 	CheckResultMap checkResultMap `json:"results" yaml:"results"`
 }
 
 type checkResultMap map[string]checkResult
 
 type checkResult struct {
+	Ok       bool             `json:"ok" yaml:"ok"`
+	Reason   string           `json:"reason" yaml:"reason"`
+	Type     checks.CheckType `json:"type" yaml:"type"`
+	Findings []checks.Finding `json:"findings,omitempty" yaml:"findings,omitempty"`
+	// Suggestions contains the fixes proposed by the check, when requested.
+	Suggestions []checks.Suggestion `json:"suggestions,omitempty" yaml:"suggestions,omitempty"`
+	// Skipped indicates the check hasn't been executed, since it requires capabilities disabled for the certification.
+	Skipped bool `json:"skipped,omitempty" yaml:"skipped,omitempty"`
+	// Known indicates the check has failed in the baseline report as well, so its failure is accepted.
+	Known bool `json:"known,omitempty" yaml:"known,omitempty"`
+	// OpenShiftVersions contains the results per OpenShift version of checks requiring an OpenShift version.
+	OpenShiftVersions map[string]versionCheckResult `json:"openshift-versions,omitempty" yaml:"openshift-versions,omitempty"`
+	// ValuesProfiles contains the results per values profile of checks rendering the chart's templates.
+	ValuesProfiles map[string]profileCheckResult `json:"values-profiles,omitempty" yaml:"values-profiles,omitempty"`
+}
+
+type versionCheckResult struct {
 	Ok     bool   `json:"ok" yaml:"ok"`
 	Reason string `json:"reason" yaml:"reason"`
 }
 
-func newCertificate(name, version, chartUri, toolVersion string, ok bool, resultMap checkResultMap) Certificate {
+type profileCheckResult struct {
+	Ok       bool             `json:"ok" yaml:"ok"`
+	Reason   string           `json:"reason" yaml:"reason"`
+	Findings []checks.Finding `json:"findings,omitempty" yaml:"findings,omitempty"`
+}
+
+func newCertificate(name, version, chartUri, toolVersion string, ok bool, resultMap checkResultMap) *certificate {
 	return &certificate{
 		Metadata:       newMetadata(name, version, chartUri, toolVersion),
 		Ok:             ok,
+		Summary:        newSummary(resultMap),
 		CheckResultMap: resultMap,
 	}
 }
 
+// LoadCertificate parses a report serialized either as YAML or JSON; reports produced before the schema version was
+// introduced are accepted, while reports of a newer schema version are rejected.
+func LoadCertificate(b []byte) (Certificate, error) {
+	cert := &certificate{}
+	if err := yaml.Unmarshal(b, cert); err != nil {
+		return nil, err
+	}
+
+	if cert.Metadata == nil {
+		return cert, nil
+	}
+
+	if err := checkSchemaVersion(cert.Metadata.SchemaVersion); err != nil {
+		return nil, err
+	}
+
+	return cert, nil
+}
+
+// checkSchemaVersion returns an error unless the given report schema version is supported; an empty version, as
+// found in reports produced before the schema version was introduced, is supported.
+func checkSchemaVersion(schemaVersion string) error {
+	if schemaVersion == "" {
+		return nil
+	}
+
+	version, err := semver.NewVersion(schemaVersion)
+	if err != nil {
+		return fmt.Errorf("invalid report schema version %q: %v", schemaVersion, err)
+	}
+	if version.GreaterThan(semver.MustParse(ReportSchemaVersion)) {
+		return UnsupportedSchemaVersionErr{Version: schemaVersion}
+	}
+
+	return nil
+}
+
+// OnlyFailures returns a copy of the given certificate containing only the results of failed checks; both the
+// overall outcome and the summary still account for all executed checks.
+func OnlyFailures(c Certificate) Certificate {
+	cert, ok := c.(*certificate)
+	if !ok {
+		return c
+	}
+
+	filtered := *cert
+	filtered.CheckResultMap = checkResultMap{}
+	for k, v := range cert.CheckResultMap {
+		if !v.Ok {
+			filtered.CheckResultMap[k] = v
+		}
+	}
+
+	return &filtered
+}
+
 func (c *certificate) IsOk() bool {
 	return c.Ok
 }
@@ -74,17 +215,53 @@ func (c *certificate) IsOk() bool {
 func (c *certificate) String() string {
 	report := "Tool:\n" +
 		"  verifier-version: " + c.Metadata.RunMetadata.Version + "\n" +
-		"  chart-uri: " + c.Metadata.RunMetadata.ChartUri + "\n" +
-		"Chart:\n" +
+		"  chart-uri: " + c.Metadata.RunMetadata.ChartUri + "\n"
+
+	if len(c.Metadata.RunMetadata.CertifiedOpenShiftVersions) > 0 {
+		report += "  certified-openshift-versions: " + strings.Join(c.Metadata.RunMetadata.CertifiedOpenShiftVersions, ", ") + "\n"
+	}
+
+	if c.Metadata.RunMetadata.KubeVersion != "" {
+		report += "  kube-version: " + c.Metadata.RunMetadata.KubeVersion + "\n"
+	}
+
+	if len(c.Metadata.RunMetadata.SkippedDependencies) > 0 {
+		report += "  skipped-dependencies: " + strings.Join(c.Metadata.RunMetadata.SkippedDependencies, ", ") + "\n"
+	}
+
+	report += "Chart:\n" +
 		"  Name: " + c.Metadata.ChartMetadata.Name + "\n" +
-		"  version: " + c.Metadata.ChartMetadata.Version + "\n" +
-		"ok: " + strconv.FormatBool(c.Ok) + "\n" +
+		"  version: " + c.Metadata.ChartMetadata.Version + "\n"
+
+	if len(c.Metadata.Annotations) > 0 {
+		keys := make([]string, 0, len(c.Metadata.Annotations))
+		for k := range c.Metadata.Annotations {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		report += "Annotations:\n"
+		for _, k := range keys {
+			report += "  " + k + ": " + c.Metadata.Annotations[k] + "\n"
+		}
+	}
+
+	report += "Summary:\n" +
+		"  passed: " + strconv.Itoa(c.Summary.Passed) + "\n" +
+		"  failed: " + strconv.Itoa(c.Summary.Failed) + "\n"
+	if c.Summary.Skipped > 0 {
+		report += "  skipped: " + strconv.Itoa(c.Summary.Skipped) + "\n"
+	}
+	report += "ok: " + strconv.FormatBool(c.Ok) + "\n" +
 		"\n"
 
 	for k, v := range c.CheckResultMap {
 		report += k + ":\n" +
 			"\tok: " + strconv.FormatBool(v.Ok) + "\n" +
+			"\ttype: " + string(v.Type) + "\n" +
 			"\treason: " + v.Reason + "\n"
+		if v.Known {
+			report += "\tknown: true\n"
+		}
 	}
 
 	return report
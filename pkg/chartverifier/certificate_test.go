@@ -0,0 +1,216 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
+)
+
+func TestOnlyFailures(t *testing.T) {
+
+	c, err := NewCertificateBuilder().
+		SetChartName("chart").
+		SetChartVersion("0.1.0").
+		AddCheckResult("passed-check", checks.MandatoryCheckType, checks.NewResult(true, "passed")).
+		AddCheckResult("failed-check", checks.MandatoryCheckType, checks.NewResult(false, "failed")).
+		Build()
+	require.NoError(t, err)
+
+	t.Run("Should only contain failed checks while keeping the summary", func(t *testing.T) {
+		filtered := OnlyFailures(c).(*certificate)
+		require.False(t, filtered.Ok)
+		require.Equal(t, summary{Passed: 1, Failed: 1}, filtered.Summary)
+		require.Equal(t, checkResultMap{"failed-check": {Ok: false, Reason: "failed", Type: checks.MandatoryCheckType}}, filtered.CheckResultMap)
+	})
+
+	t.Run("Should not modify the original certificate", func(t *testing.T) {
+		_ = OnlyFailures(c)
+		require.Len(t, c.(*certificate).CheckResultMap, 2)
+	})
+}
+
+func TestCertificateFindings(t *testing.T) {
+
+	finding := checks.Finding{
+		Resource: "Deployment/chart",
+		Field:    "env",
+		Message:  "Secret \"chart-secret\" is not created by the chart",
+		Severity: checks.ErrorSeverity,
+	}
+
+	failed := checks.NewResult(false, "failed")
+	failed.AddFinding(finding)
+
+	c, err := NewCertificateBuilder().
+		SetChartName("chart").
+		SetChartVersion("0.1.0").
+		AddCheckResult("legacy-check", checks.MandatoryCheckType, checks.NewResult(true, "passed")).
+		AddCheckResult("findings-check", checks.MandatoryCheckType, failed).
+		Build()
+	require.NoError(t, err)
+
+	t.Run("Should serialize findings to JSON alongside the reason", func(t *testing.T) {
+		b, err := json.Marshal(c)
+		require.NoError(t, err)
+
+		actual := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(b, &actual))
+
+		results := actual["results"].(map[string]interface{})
+		require.Equal(t, map[string]interface{}{
+			"ok":     false,
+			"reason": "failed",
+			"type":   "mandatory",
+			"findings": []interface{}{
+				map[string]interface{}{
+					"resource": finding.Resource,
+					"field":    finding.Field,
+					"message":  finding.Message,
+					"severity": finding.Severity,
+				},
+			},
+		}, results["findings-check"])
+		require.Equal(t, map[string]interface{}{"ok": true, "reason": "passed", "type": "mandatory"}, results["legacy-check"])
+	})
+
+	t.Run("Should serialize findings to YAML alongside the reason", func(t *testing.T) {
+		b, err := yaml.Marshal(c)
+		require.NoError(t, err)
+
+		actual := certificate{}
+		require.NoError(t, yaml.Unmarshal(b, &actual))
+		require.Equal(t, []checks.Finding{finding}, actual.CheckResultMap["findings-check"].Findings)
+		require.Empty(t, actual.CheckResultMap["legacy-check"].Findings)
+		require.Equal(t, "passed", actual.CheckResultMap["legacy-check"].Reason)
+	})
+}
+
+func TestCertificateAnnotations(t *testing.T) {
+
+	annotations := map[string]string{"ticket": "CERT-123", "pipeline-run": "https://ci.example.com/runs/42"}
+
+	c, err := NewCertificateBuilder().
+		SetChartName("chart").
+		SetChartVersion("0.1.0").
+		SetAnnotations(annotations).
+		AddCheckResult("passed-check", checks.MandatoryCheckType, checks.NewResult(true, "passed")).
+		Build()
+	require.NoError(t, err)
+
+	t.Run("Should round trip annotations through JSON", func(t *testing.T) {
+		b, err := json.Marshal(c)
+		require.NoError(t, err)
+
+		actual := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(b, &actual))
+		require.Equal(t, map[string]interface{}{
+			"ticket":       "CERT-123",
+			"pipeline-run": "https://ci.example.com/runs/42",
+		}, actual["metadata"].(map[string]interface{})["annotations"])
+
+		loaded := certificate{}
+		require.NoError(t, json.Unmarshal(b, &loaded))
+		require.Equal(t, annotations, loaded.Metadata.Annotations)
+	})
+
+	t.Run("Should round trip annotations through YAML", func(t *testing.T) {
+		b, err := yaml.Marshal(c)
+		require.NoError(t, err)
+
+		loaded := certificate{}
+		require.NoError(t, yaml.Unmarshal(b, &loaded))
+		require.Equal(t, annotations, loaded.Metadata.Annotations)
+	})
+
+	t.Run("Should display annotations", func(t *testing.T) {
+		require.Contains(t, c.(*certificate).String(), "Annotations:\n  pipeline-run: https://ci.example.com/runs/42\n  ticket: CERT-123\n")
+	})
+
+	t.Run("Should not be affected by later changes to the informed annotations", func(t *testing.T) {
+		informed := map[string]string{"ticket": "CERT-123"}
+		c, err := NewCertificateBuilder().SetChartName("chart").SetChartVersion("0.1.0").SetAnnotations(informed).Build()
+		require.NoError(t, err)
+		informed["ticket"] = "CERT-456"
+		require.Equal(t, "CERT-123", c.(*certificate).Metadata.Annotations["ticket"])
+	})
+
+	t.Run("Should omit annotations when none have been informed", func(t *testing.T) {
+		c, err := NewCertificateBuilder().SetChartName("chart").SetChartVersion("0.1.0").Build()
+		require.NoError(t, err)
+		b, err := json.Marshal(c)
+		require.NoError(t, err)
+		require.NotContains(t, string(b), "annotations")
+	})
+}
+
+func TestCertificateSchemaVersion(t *testing.T) {
+
+	c, err := NewCertificateBuilder().
+		SetChartName("chart").
+		SetChartVersion("0.1.0").
+		AddCheckResult("passed-check", checks.MandatoryCheckType, checks.NewResult(true, "passed")).
+		Build()
+	require.NoError(t, err)
+
+	t.Run("Should populate the schema version when serializing as JSON", func(t *testing.T) {
+		b, err := json.Marshal(c)
+		require.NoError(t, err)
+
+		actual := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(b, &actual))
+		require.Equal(t, ReportSchemaVersion, actual["metadata"].(map[string]interface{})["schema-version"])
+
+		loaded, err := LoadCertificate(b)
+		require.NoError(t, err)
+		require.Equal(t, c, loaded)
+	})
+
+	t.Run("Should populate the schema version when serializing as YAML", func(t *testing.T) {
+		b, err := yaml.Marshal(c)
+		require.NoError(t, err)
+		require.Contains(t, string(b), "schema-version: \""+ReportSchemaVersion+"\"")
+
+		loaded, err := LoadCertificate(b)
+		require.NoError(t, err)
+		require.Equal(t, c, loaded)
+	})
+
+	t.Run("Should load reports produced before the schema version was introduced", func(t *testing.T) {
+		loaded, err := LoadCertificate([]byte("ok: true\nmetadata:\n  tool:\n    verifier-version: 1.0.0\n"))
+		require.NoError(t, err)
+		require.True(t, loaded.IsOk())
+	})
+
+	t.Run("Should reject reports of a newer schema version", func(t *testing.T) {
+		_, err := LoadCertificate([]byte("ok: true\nmetadata:\n  schema-version: \"99.0\"\n"))
+		require.Error(t, err)
+		require.IsType(t, UnsupportedSchemaVersionErr{}, err)
+		require.Equal(t, `report schema version "99.0" is not supported, the latest supported version is "`+ReportSchemaVersion+`"`, err.Error())
+	})
+
+	t.Run("Should reject reports with an invalid schema version", func(t *testing.T) {
+		_, err := LoadCertificate([]byte("ok: true\nmetadata:\n  schema-version: latest\n"))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid report schema version")
+	})
+}
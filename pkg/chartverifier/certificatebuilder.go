@@ -18,6 +18,7 @@ package chartverifier
 
 import (
 	"errors"
+	"time"
 
 	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
 )
@@ -27,7 +28,25 @@ type CertificateBuilder interface {
 	SetChartUri(name string) CertificateBuilder
 	SetChartName(name string) CertificateBuilder
 	SetChartVersion(version string) CertificateBuilder
-	AddCheckResult(name string, result checks.Result) CertificateBuilder
+	AddCheckResult(name string, checkType checks.CheckType, result checks.Result) CertificateBuilder
+	SetFailOn(failOn FailOn) CertificateBuilder
+	// SetAnnotations informs arbitrary metadata to be included in the certificate.
+	SetAnnotations(annotations map[string]string) CertificateBuilder
+	// SetOpenShiftVersions informs the OpenShift versions the chart has been verified against.
+	SetOpenShiftVersions(versions []string) CertificateBuilder
+	// SetKubeVersion informs the Kubernetes version the chart has been verified against.
+	SetKubeVersion(version string) CertificateBuilder
+	// SetSkippedDependencies informs the dependencies which haven't been retrieved before verifying the chart.
+	SetSkippedDependencies(names []string) CertificateBuilder
+	// AddOpenShiftVersionResult records the result of a previously added check for a single OpenShift version.
+	AddOpenShiftVersionResult(name string, version string, result checks.Result) CertificateBuilder
+	// AddValuesProfileResult records the result of a previously added check for a single values profile.
+	AddValuesProfileResult(name string, profile string, result checks.Result) CertificateBuilder
+	// AddSkippedCheck records a check which hasn't been executed, explained by reason; skipped checks never fail the
+	// certification.
+	AddSkippedCheck(name string, checkType checks.CheckType, reason string) CertificateBuilder
+	// SetGeneratedAt informs the time the certificate has been generated; it is omitted when unset.
+	SetGeneratedAt(t time.Time) CertificateBuilder
 	Build() (Certificate, error)
 }
 
@@ -37,11 +56,17 @@ type CheckResult struct {
 }
 
 type certificateBuilder struct {
-	ToolVersion    string
-	ChartUri       string
-	ChartName      string
-	ChartVersion   string
-	CheckResultMap checkResultMap
+	ToolVersion         string
+	ChartUri            string
+	ChartName           string
+	ChartVersion        string
+	CheckResultMap      checkResultMap
+	FailOn              FailOn
+	OpenShiftVersions   []string
+	KubeVersion         string
+	SkippedDependencies []string
+	Annotations         map[string]string
+	GeneratedAt         time.Time
 }
 
 func NewCertificateBuilder() CertificateBuilder {
@@ -70,11 +95,89 @@ func (r *certificateBuilder) SetChartVersion(version string) CertificateBuilder
 	return r
 }
 
-func (r *certificateBuilder) AddCheckResult(name string, result checks.Result) CertificateBuilder {
-	r.CheckResultMap[name] = checkResult{Ok: result.Ok, Reason: result.Reason}
+func (r *certificateBuilder) AddCheckResult(name string, checkType checks.CheckType, result checks.Result) CertificateBuilder {
+	r.CheckResultMap[name] = checkResult{Ok: result.Ok, Reason: result.Reason, Type: checkType, Findings: result.Findings, Suggestions: result.Suggestions}
 	return r
 }
 
+func (r *certificateBuilder) AddSkippedCheck(name string, checkType checks.CheckType, reason string) CertificateBuilder {
+	r.CheckResultMap[name] = checkResult{Ok: true, Reason: reason, Type: checkType, Skipped: true}
+	return r
+}
+
+func (r *certificateBuilder) SetFailOn(failOn FailOn) CertificateBuilder {
+	r.FailOn = failOn
+	return r
+}
+
+func (r *certificateBuilder) SetAnnotations(annotations map[string]string) CertificateBuilder {
+	r.Annotations = annotations
+	return r
+}
+
+func (r *certificateBuilder) SetOpenShiftVersions(versions []string) CertificateBuilder {
+	r.OpenShiftVersions = versions
+	return r
+}
+
+func (r *certificateBuilder) SetKubeVersion(version string) CertificateBuilder {
+	r.KubeVersion = version
+	return r
+}
+
+func (r *certificateBuilder) SetSkippedDependencies(names []string) CertificateBuilder {
+	r.SkippedDependencies = names
+	return r
+}
+
+func (r *certificateBuilder) AddOpenShiftVersionResult(name string, version string, result checks.Result) CertificateBuilder {
+	cr := r.CheckResultMap[name]
+	if cr.OpenShiftVersions == nil {
+		cr.OpenShiftVersions = map[string]versionCheckResult{}
+	}
+	cr.OpenShiftVersions[version] = versionCheckResult{Ok: result.Ok, Reason: result.Reason}
+	r.CheckResultMap[name] = cr
+	return r
+}
+
+func (r *certificateBuilder) AddValuesProfileResult(name string, profile string, result checks.Result) CertificateBuilder {
+	cr := r.CheckResultMap[name]
+	if cr.ValuesProfiles == nil {
+		cr.ValuesProfiles = map[string]profileCheckResult{}
+	}
+	cr.ValuesProfiles[profile] = profileCheckResult{Ok: result.Ok, Reason: result.Reason, Findings: result.Findings}
+	r.CheckResultMap[name] = cr
+	return r
+}
+
+func (r *certificateBuilder) SetGeneratedAt(t time.Time) CertificateBuilder {
+	r.GeneratedAt = t
+	return r
+}
+
+// certifiedOpenShiftVersions returns the OpenShift versions for which no check has failed, according to the FailOn
+// mode; checks executed once account for all versions.
+func (r *certificateBuilder) certifiedOpenShiftVersions() []string {
+	var certified []string
+	for _, version := range r.OpenShiftVersions {
+		ok := true
+		for _, v := range r.CheckResultMap {
+			versionOk := v.Ok
+			if vr, found := v.OpenShiftVersions[version]; found {
+				versionOk = vr.Ok
+			}
+			if !versionOk && r.FailOn.blocks(v.Type) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			certified = append(certified, version)
+		}
+	}
+	return certified
+}
+
 func (r *certificateBuilder) Build() (Certificate, error) {
 
 	if r.ChartName == "" {
@@ -88,11 +191,25 @@ func (r *certificateBuilder) Build() (Certificate, error) {
 	ok := true
 
 	for _, v := range r.CheckResultMap {
-		if !v.Ok {
+		if !v.Ok && r.FailOn.blocks(v.Type) {
 			ok = false
 			break
 		}
 	}
 
-	return newCertificate(r.ChartName, r.ChartVersion, r.ChartUri, r.ToolVersion, ok, r.CheckResultMap), nil
+	cert := newCertificate(r.ChartName, r.ChartVersion, r.ChartUri, r.ToolVersion, ok, r.CheckResultMap)
+	cert.Metadata.RunMetadata.CertifiedOpenShiftVersions = r.certifiedOpenShiftVersions()
+	cert.Metadata.RunMetadata.KubeVersion = r.KubeVersion
+	cert.Metadata.RunMetadata.SkippedDependencies = r.SkippedDependencies
+	if !r.GeneratedAt.IsZero() {
+		cert.Metadata.RunMetadata.GeneratedAt = r.GeneratedAt.UTC().Format(time.RFC3339)
+	}
+	if len(r.Annotations) > 0 {
+		cert.Metadata.Annotations = map[string]string{}
+		for k, v := range r.Annotations {
+			cert.Metadata.Annotations[k] = v
+		}
+	}
+
+	return cert, nil
 }
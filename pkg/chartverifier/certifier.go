@@ -17,8 +17,26 @@
 package chartverifier
 
 import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
 	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
 	"github.com/spf13/viper"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+const (
+	CheckSkippedNoNetwork    = "Check skipped: network access is disabled"
+	CheckSkippedNoCluster    = "Check skipped: cluster access is disabled"
+	CheckSkippedFailFast     = "Check not run: a previous mandatory check has failed"
+	CheckSkippedMetadataOnly = "Check skipped: only metadata checks are executed"
+	CheckTimedOut            = "Check timed out: the verification has exceeded its total timeout"
 )
 
 type CheckNotFoundErr string
@@ -37,47 +55,615 @@ func NewCheckErr(err error) error {
 	return CheckErr(err.Error())
 }
 
+// checkErrResult records the error returned by a check as a failed result.
+func checkErrResult(err error) checks.Result {
+	return checks.NewResult(false, NewCheckErr(err).Error())
+}
+
+// valuesProfile is a named set of chart values the checks rendering the chart's templates are executed with.
+type valuesProfile struct {
+	name   string
+	values chartutil.Values
+}
+
 type certifier struct {
-	config         *viper.Viper
-	registry       checks.Registry
-	requiredChecks []string
-	toolVersion    string
+	config            *viper.Viper
+	registry          checks.Registry
+	requiredChecks    []string
+	toolVersion       string
+	onCheckComplete   CheckCompleteFunc
+	failOn            FailOn
+	openShiftVersions []string
+	kubeVersion       string
+	annotations       map[string]string
+	continueOnError   bool
+	credentials       checks.Credentials
+	values            chartutil.Values
+	valuesProfiles    []valuesProfile
+	clock             func() time.Time
+	noNetwork         bool
+	noCluster         bool
+	metadataOnly      bool
+	failFast          bool
+	// buildDependencies informs whether the dependencies the chart declares but doesn't bundle are retrieved before
+	// executing checks.
+	buildDependencies bool
+	// suggestFixes informs whether checks able to compute a remediation for their findings should suggest it.
+	suggestFixes bool
+	// totalTimeout bounds the duration of each verification, including the chart retrieval, when positive.
+	totalTimeout time.Duration
+	// maxConcurrent is the maximum number of verifications VerifyBatch runs at once, GOMAXPROCS if not positive.
+	maxConcurrent int
+	// rateLimiter spaces the outbound requests of all checks, across every verification performed by the certifier.
+	rateLimiter *checks.RateLimiter
+	// tlsConfig contains the TLS settings of the HTTPS requests performed by checks, or nil for Go's defaults.
+	tlsConfig *tls.Config
+	// checkpoint persists the outcome of completed checks, if set, and checkpointFingerprint identifies the options
+	// they're produced with.
+	checkpoint            *Checkpoint
+	checkpointFingerprint string
+	// metrics instruments the verifications performed by the certifier, if set.
+	metrics *verifierMetrics
+	// callbackMutex serializes onCheckComplete invocations, so callers don't need to synchronize their callbacks
+	// when a certifier is shared among goroutines.
+	callbackMutex sync.Mutex
+}
+
+func (c *certifier) notifyCheckComplete(name string, r checks.Result) {
+	if c.onCheckComplete == nil {
+		return
+	}
+	c.callbackMutex.Lock()
+	defer c.callbackMutex.Unlock()
+	c.onCheckComplete(name, r)
 }
 
+const (
+	// checksConfigKey is the configuration key containing the settings of each check, keyed by check name.
+	checksConfigKey = "checks"
+	// optionsConfigKey is the key, relative to a check's settings, containing the options informed to the check.
+	optionsConfigKey = "options"
+	// typeConfigKey is the key, relative to a check's settings, overriding the check's registered type.
+	typeConfigKey = "type"
+)
+
+// checkType returns the type configured for the given check in checks.<name>.type, if any, otherwise registered.
+func (c *certifier) checkType(name string, registered checks.CheckType) checks.CheckType {
+	if c.config == nil {
+		return registered
+	}
+	if t := c.config.GetString(checksConfigKey + "." + name + "." + typeConfigKey); t != "" {
+		return checks.CheckType(t)
+	}
+	return registered
+}
+
+// subConfig returns the options scoped to the given check; options informed in checks.<name>.options take precedence
+// over the ones informed in the <name> subtree. The chart values overrides are informed to every check.
 func (c *certifier) subConfig(name string) *viper.Viper {
-	if sub := c.config.Sub(name); sub == nil {
-		return viper.New()
-	} else {
-		return sub
+	sub := viper.New()
+	for _, key := range []string{name, checksConfigKey + "." + name + "." + optionsConfigKey} {
+		if settings := c.config.Sub(key); settings != nil {
+			for k, v := range settings.AllSettings() {
+				sub.Set(k, v)
+			}
+		}
+	}
+	if len(c.values) > 0 {
+		sub.Set(checks.ValuesConfigKey, c.values)
+	}
+	// checks reaching the network or the cluster only when allowed to are kept from doing so in the matching modes
+	if c.noNetwork || c.metadataOnly {
+		sub.Set(checks.AllowNetworkConfigKey, false)
+	}
+	if c.noCluster || c.metadataOnly {
+		sub.Set(checks.AllowClusterConfigKey, false)
+	}
+	if c.rateLimiter != nil {
+		sub.Set(checks.RateLimiterConfigKey, c.rateLimiter)
+	}
+	if c.kubeVersion != "" {
+		sub.Set(checks.KubeVersionConfigKey, c.kubeVersion)
+	}
+	if c.tlsConfig != nil {
+		sub.Set(checks.TLSConfigKey, c.tlsConfig)
+	}
+	if c.suggestFixes {
+		sub.Set(checks.SuggestFixesConfigKey, true)
+	}
+	if c.credentials.Transport != nil {
+		sub.Set(checks.HTTPTransportConfigKey, c.credentials.Transport)
+	}
+	return sub
+}
+
+// skipReason returns why the given check can't be executed in the certifier's mode, if that's the case.
+func (c *certifier) skipReason(check checks.Check) (string, bool) {
+	switch {
+	case c.metadataOnly && (check.RendersTemplates || check.RequiresNetwork || check.RequiresCluster):
+		return CheckSkippedMetadataOnly, true
+	case check.RequiresNetwork && c.noNetwork:
+		return CheckSkippedNoNetwork, true
+	case check.RequiresCluster && c.noCluster:
+		return CheckSkippedNoCluster, true
+	default:
+		return "", false
+	}
+}
+
+// stopsVerification informs whether the given result of check keeps the remaining checks from being executed.
+func (c *certifier) stopsVerification(check checks.Check, r checks.Result) bool {
+	return c.failFast && check.Type == checks.MandatoryCheckType && !r.Ok
+}
+
+// checkOutcome holds the values returned by a check function, so they can be transferred through a channel.
+type checkOutcome struct {
+	result checks.Result
+	err    error
+}
+
+// inFlightChecks counts the check goroutines of a verification still running, including the ones abandoned when the
+// verification is cancelled, so resources they use are only released once they have returned.
+type inFlightChecks struct {
+	mu     sync.Mutex
+	count  int
+	onIdle []func()
+}
+
+func (f *inFlightChecks) start() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.count++
+}
+
+func (f *inFlightChecks) done() {
+	f.mu.Lock()
+	f.count--
+	var onIdle []func()
+	if f.count == 0 {
+		onIdle, f.onIdle = f.onIdle, nil
+	}
+	f.mu.Unlock()
+	for _, fn := range onIdle {
+		fn()
+	}
+}
+
+// whenIdle calls fn once no check goroutine is running anymore, immediately if none is; functions are called in the
+// order they have been informed.
+func (f *inFlightChecks) whenIdle(fn func()) {
+	f.mu.Lock()
+	if f.count > 0 {
+		f.onIdle = append(f.onIdle, fn)
+		f.mu.Unlock()
+		return
+	}
+	f.mu.Unlock()
+	fn()
+}
+
+// runCheck executes checkFunc in its own goroutine and waits for either its outcome or the cancellation of ctx,
+// whatever happens first. ctx is informed to the check through its configuration, so its requests are aborted once
+// ctx is cancelled, but the check itself can't be interrupted: its goroutine keeps running until checkFunc returns,
+// tracked by inFlight. The outcome channel is buffered so the goroutine is able to finish even when nobody is waiting
+// for it anymore.
+func runCheck(ctx context.Context, inFlight *inFlightChecks, checkFunc checks.CheckFunc, uri string, config *viper.Viper) (checks.Result, error) {
+	if ctx.Done() != nil {
+		config.Set(checks.ContextConfigKey, ctx)
+	}
+	outcomeCh := make(chan checkOutcome, 1)
+	inFlight.start()
+	go func() {
+		defer inFlight.done()
+		r, err := checkFunc(uri, config)
+		outcomeCh <- checkOutcome{result: r, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return checks.Result{}, ctx.Err()
+	case o := <-outcomeCh:
+		return o.result, o.err
+	}
+}
+
+// runMatrixCheck executes checkFunc concurrently once per given configuration; outcomes are returned in the same order
+// as the configurations.
+func runMatrixCheck(ctx context.Context, inFlight *inFlightChecks, checkFunc checks.CheckFunc, uri string, configs []*viper.Viper) []checkOutcome {
+	outcomes := make([]checkOutcome, len(configs))
+
+	var wg sync.WaitGroup
+	for i, config := range configs {
+		wg.Add(1)
+		go func(i int, config *viper.Viper) {
+			defer wg.Done()
+			r, err := runCheck(ctx, inFlight, checkFunc, uri, config)
+			outcomes[i] = checkOutcome{result: r, err: err}
+		}(i, config)
+	}
+	wg.Wait()
+
+	return outcomes
+}
+
+// runVersionedCheck executes checkFunc concurrently once per OpenShift version, informing each version through the
+// check's configuration; outcomes are returned in the same order as the versions.
+func (c *certifier) runVersionedCheck(ctx context.Context, inFlight *inFlightChecks, name string, checkFunc checks.CheckFunc, uri string) []checkOutcome {
+	configs := make([]*viper.Viper, len(c.openShiftVersions))
+	for i, version := range c.openShiftVersions {
+		configs[i] = c.subConfig(name)
+		configs[i].Set(checks.OpenShiftVersionConfigKey, version)
+	}
+	return runMatrixCheck(ctx, inFlight, checkFunc, uri, configs)
+}
+
+// runProfileCheck executes checkFunc concurrently once per values profile, informing each profile's values through
+// the check's configuration; outcomes are returned in the same order as the profiles.
+func (c *certifier) runProfileCheck(ctx context.Context, inFlight *inFlightChecks, name string, checkFunc checks.CheckFunc, uri string) []checkOutcome {
+	configs := make([]*viper.Viper, len(c.valuesProfiles))
+	for i, profile := range c.valuesProfiles {
+		configs[i] = c.subConfig(name)
+		configs[i].Set(checks.ValuesConfigKey, profile.values)
+	}
+	return runMatrixCheck(ctx, inFlight, checkFunc, uri, configs)
+}
+
+// aggregateOutcomes combines the outcomes of a check executed once per matrix entry into a single result, failed if any
+// entry failed; each entry's reason is prefixed by its label. Errors are recorded as failed results when the
+// certifier continues on check errors, and returned otherwise.
+func (c *certifier) aggregateOutcomes(outcomes []checkOutcome, labels []string) (checks.Result, error) {
+	r := checks.NewResult(true, "")
+	for i, o := range outcomes {
+		if o.err != nil {
+			if !c.continueOnError {
+				return checks.Result{}, NewCheckErr(o.err)
+			}
+			outcomes[i].result = checkErrResult(o.err)
+			o = outcomes[i]
+		}
+		r.AddResult(o.result.Ok, fmt.Sprintf("%s : %s", labels[i], o.result.Reason))
+		r.Findings = append(r.Findings, o.result.Findings...)
+		for _, s := range o.result.Suggestions {
+			// entries often render the same resources, so their suggestions are only reported once
+			if !containsSuggestion(r.Suggestions, s) {
+				r.AddSuggestion(s)
+			}
+		}
+	}
+	return r, nil
+}
+
+// containsSuggestion informs whether the given suggestions contain s.
+func containsSuggestion(suggestions []checks.Suggestion, s checks.Suggestion) bool {
+	for _, suggestion := range suggestions {
+		if suggestion == s {
+			return true
+		}
+	}
+	return false
+}
+
+// outcomesErrored informs whether the check has returned an error in any of the given outcomes.
+func outcomesErrored(outcomes []checkOutcome) bool {
+	for _, o := range outcomes {
+		if o.err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// recordCheckpoint persists the given outcome of a check of the chart identified by digest, unless the check has
+// returned an error in any of its outcomes, so the check is executed again when resuming.
+func (c *certifier) recordCheckpoint(digest string, entry checkpointEntry, outcomes []checkOutcome) error {
+	if c.checkpoint == nil || outcomesErrored(outcomes) {
+		return nil
+	}
+	entry.Fingerprint, entry.Digest = c.checkpointFingerprint, digest
+	return c.checkpoint.record(entry)
+}
+
+// withTotalTimeout returns ctx bounded by the certifier's total timeout, if any.
+func (c *certifier) withTotalTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.totalTimeout <= 0 {
+		return ctx, func() {}
 	}
+	return context.WithTimeout(ctx, c.totalTimeout)
+}
+
+// recordTimeout reports the given check as timed out when ctxErr results from the expiry of the total timeout and
+// at least one check has completed; otherwise, the error the verification fails with is returned.
+func (c *certifier) recordTimeout(result CertificateBuilder, name string, check checks.Check, ctxErr error, completed int) error {
+	if c.totalTimeout <= 0 || !errors.Is(ctxErr, context.DeadlineExceeded) {
+		return ctxErr
+	}
+	if completed == 0 {
+		return errors.Wrapf(ctxErr, "verification timed out after %s", c.totalTimeout)
+	}
+	r := checks.NewResult(false, CheckTimedOut)
+	_ = result.AddCheckResult(name, check.Type, r)
+	c.notifyCheckComplete(name, r)
+	return nil
+}
+
+// now returns the current time as informed by the configured clock.
+func (c *certifier) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock()
 }
 
 func (c *certifier) Certify(uri string) (Certificate, error) {
+	return c.CertifyContext(context.Background(), uri)
+}
+
+func (c *certifier) CertifyContext(ctx context.Context, uri string) (cert Certificate, err error) {
+	defer func() { c.metrics.observeVerification(cert, err) }()
+
+	ctx, cancel := c.withTotalTimeout(ctx)
+	defer cancel()
 
-	chrt, _, err := checks.LoadChartFromURI(uri)
+	if err := checks.ValidateChartURI(ctx, uri, c.credentials); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	chrt, _, err := checks.LoadChartFromURIWithCredentials(ctx, uri, c.credentials)
 	if err != nil {
 		return nil, err
 	}
+	deps, err := c.buildChartDependencies(ctx, uri)
+	if err != nil {
+		return nil, err
+	}
+	inFlight := &inFlightChecks{}
+	defer inFlight.whenIdle(deps.Release)
+	c.metrics.observeDownload(start)
+
+	return c.certifyChart(ctx, inFlight, uri, chrt, deps)
+}
+
+func (c *certifier) Verify(ctx context.Context, uri string) (*Report, error) {
+	ctx, cancel := c.withTotalTimeout(ctx)
+	defer cancel()
+
+	if err := checks.ValidateChartURI(ctx, uri, c.credentials); err != nil {
+		c.metrics.observeVerification(nil, err)
+		return nil, err
+	}
+
+	// the chart is retained rather than loaded, so it isn't kept cached once verified
+	start := time.Now()
+	chrt, release, err := checks.RetainChartFromURI(ctx, uri, c.credentials)
+	if err != nil {
+		c.metrics.observeVerification(nil, err)
+		return nil, err
+	}
+	// checks abandoned on cancellation may still load the chart, so it is only released once they have returned
+	inFlight := &inFlightChecks{}
+	defer inFlight.whenIdle(release)
+	deps, err := c.buildChartDependencies(ctx, uri)
+	if err != nil {
+		c.metrics.observeVerification(nil, err)
+		return nil, err
+	}
+	defer inFlight.whenIdle(deps.Release)
+	c.metrics.observeDownload(start)
 
+	result, err := c.certifyChart(ctx, inFlight, uri, chrt, deps)
+	c.metrics.observeVerification(result, err)
+	if err != nil {
+		return nil, err
+	}
+
+	cert, ok := result.(*certificate)
+	if !ok {
+		return nil, fmt.Errorf("unsupported certificate type %T", result)
+	}
+	return &Report{certificate: *cert, ChartMetadata: chrt.Metadata}, nil
+}
+
+func (c *certifier) ImageReferences(uri string) ([]string, error) {
+	valuesSets := []chartutil.Values{c.values}
+	if len(c.valuesProfiles) > 0 {
+		valuesSets = make([]chartutil.Values, len(c.valuesProfiles))
+		for i, profile := range c.valuesProfiles {
+			valuesSets[i] = profile.values
+		}
+	}
+
+	seen := map[string]bool{}
+	var images []string
+	for _, vals := range valuesSets {
+		refs, err := checks.GetImageReferencesWithValues(uri, vals, c.kubeVersion)
+		if err != nil {
+			return nil, err
+		}
+		for _, image := range refs {
+			if !seen[image] {
+				seen[image] = true
+				images = append(images, image)
+			}
+		}
+	}
+	sort.Strings(images)
+	return images, nil
+}
+
+// buildChartDependencies retrieves the dependencies of the chart found at uri, when requested, so checks loading the
+// chart from the returned URI see them; the chart cached for uri is left as retrieved, so its digest remains the
+// published one.
+func (c *certifier) buildChartDependencies(ctx context.Context, uri string) (checks.BuiltDependencies, error) {
+	if !c.buildDependencies {
+		return checks.BuiltDependencies{URI: uri}, nil
+	}
+	return checks.BuildChartDependencies(ctx, uri, c.credentials)
+}
+
+// certifyChart executes the required checks against chrt, found at uri, tracking their goroutines in inFlight; checks
+// load the chart along with the dependencies built for it from deps.URI.
+func (c *certifier) certifyChart(ctx context.Context, inFlight *inFlightChecks, uri string, chrt *chart.Chart, deps checks.BuiltDependencies) (Certificate, error) {
 	result := NewCertificateBuilder().
 		SetChartName(chrt.Name()).
 		SetChartVersion(chrt.AppVersion()).
 		SetToolVersion(c.toolVersion).
-		SetChartUri(uri)
+		SetChartUri(stripUserinfo(uri)).
+		SetFailOn(c.failOn).
+		SetOpenShiftVersions(c.openShiftVersions).
+		SetKubeVersion(c.kubeVersion).
+		SetAnnotations(c.annotations).
+		SetSkippedDependencies(deps.Skipped)
+
+	digest := ""
+	if c.checkpoint != nil {
+		digest = chartDigest(chrt)
+	}
 
+	stopped := false
+	// completed counts the checks whose result is reported, so verifications timing out before any check completes
+	// fail instead of producing an empty report
+	completed := 0
 	for _, name := range c.requiredChecks {
-		checkFunc, ok := c.registry.Get(name)
+		check, ok := c.registry.Get(name)
 		if !ok {
 			return nil, CheckNotFoundErr(name)
 		}
+		check.Type = c.checkType(name, check.Type)
 
-		r, err := checkFunc(uri, c.subConfig(name))
+		if stopped {
+			_ = result.AddSkippedCheck(name, check.Type, CheckSkippedFailFast)
+			c.metrics.observeSkippedCheck(name)
+			c.notifyCheckComplete(name, checks.NewResult(true, CheckSkippedFailFast))
+			continue
+		}
+
+		if reason, skip := c.skipReason(check); skip {
+			_ = result.AddSkippedCheck(name, check.Type, reason)
+			c.metrics.observeSkippedCheck(name)
+			c.notifyCheckComplete(name, checks.NewResult(true, reason))
+			continue
+		}
+
+		if entry, ok := c.checkpoint.get(c.checkpointFingerprint, digest, name); ok {
+			_ = result.AddCheckResult(name, check.Type, entry.Result)
+			for _, version := range c.openShiftVersions {
+				if r, ok := entry.OpenShiftVersions[version]; ok {
+					_ = result.AddOpenShiftVersionResult(name, version, r)
+				}
+			}
+			for _, profile := range c.valuesProfiles {
+				if r, ok := entry.ValuesProfiles[profile.name]; ok {
+					_ = result.AddValuesProfileResult(name, profile.name, r)
+				}
+			}
+			stopped = c.stopsVerification(check, entry.Result)
+			completed++
+			c.notifyCheckComplete(name, entry.Result)
+			continue
+		}
+
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			if err := c.recordTimeout(result, name, check, ctxErr, completed); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		start := time.Now()
+		if check.RequiresOpenShiftVersion && len(c.openShiftVersions) > 0 {
+			outcomes := c.runVersionedCheck(ctx, inFlight, name, check.Func, deps.URI)
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				if err := c.recordTimeout(result, name, check, ctxErr, completed); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			labels := make([]string, len(c.openShiftVersions))
+			for i, version := range c.openShiftVersions {
+				labels[i] = "OpenShift " + version
+			}
+			errored := outcomesErrored(outcomes)
+			r, err := c.aggregateOutcomes(outcomes, labels)
+			c.metrics.observeCheck(name, r, errored, start)
+			if err != nil {
+				return nil, err
+			}
+
+			_ = result.AddCheckResult(name, check.Type, r)
+			stopped = c.stopsVerification(check, r)
+			entry := checkpointEntry{Check: name, Result: r, OpenShiftVersions: map[string]checks.Result{}}
+			for i, o := range outcomes {
+				_ = result.AddOpenShiftVersionResult(name, c.openShiftVersions[i], o.result)
+				entry.OpenShiftVersions[c.openShiftVersions[i]] = o.result
+			}
+			if err := c.recordCheckpoint(digest, entry, outcomes); err != nil {
+				return nil, err
+			}
+			completed++
+			c.notifyCheckComplete(name, r)
+			continue
+		}
+
+		if check.RendersTemplates && len(c.valuesProfiles) > 0 {
+			outcomes := c.runProfileCheck(ctx, inFlight, name, check.Func, deps.URI)
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				if err := c.recordTimeout(result, name, check, ctxErr, completed); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			labels := make([]string, len(c.valuesProfiles))
+			for i, profile := range c.valuesProfiles {
+				labels[i] = "Values profile " + profile.name
+			}
+			errored := outcomesErrored(outcomes)
+			r, err := c.aggregateOutcomes(outcomes, labels)
+			c.metrics.observeCheck(name, r, errored, start)
+			if err != nil {
+				return nil, err
+			}
+
+			_ = result.AddCheckResult(name, check.Type, r)
+			stopped = c.stopsVerification(check, r)
+			entry := checkpointEntry{Check: name, Result: r, ValuesProfiles: map[string]checks.Result{}}
+			for i, o := range outcomes {
+				_ = result.AddValuesProfileResult(name, c.valuesProfiles[i].name, o.result)
+				entry.ValuesProfiles[c.valuesProfiles[i].name] = o.result
+			}
+			if err := c.recordCheckpoint(digest, entry, outcomes); err != nil {
+				return nil, err
+			}
+			completed++
+			c.notifyCheckComplete(name, r)
+			continue
+		}
+
+		r, err := runCheck(ctx, inFlight, check.Func, deps.URI, c.subConfig(name))
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			if err := c.recordTimeout(result, name, check, ctxErr, completed); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		c.metrics.observeCheck(name, r, err != nil, start)
 		if err != nil {
-			return nil, NewCheckErr(err)
+			if !c.continueOnError {
+				return nil, NewCheckErr(err)
+			}
+			r = checkErrResult(err)
+		}
+		_ = result.AddCheckResult(name, check.Type, r)
+		stopped = c.stopsVerification(check, r)
+		if err := c.recordCheckpoint(digest, checkpointEntry{Check: name, Result: r}, []checkOutcome{{result: r, err: err}}); err != nil {
+			return nil, err
 		}
-		_ = result.AddCheckResult(name, r)
+		completed++
+		c.notifyCheckComplete(name, r)
 
 	}
 
-	return result.Build()
+	return result.SetGeneratedAt(c.now()).Build()
 }
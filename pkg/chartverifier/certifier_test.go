@@ -17,12 +17,26 @@
 package chartverifier
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
 
 	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
 	"github.com/redhat-certification/chart-verifier/pkg/testutil"
@@ -104,3 +118,1377 @@ func TestCertifier_Certify(t *testing.T) {
 
 	cancel()
 }
+
+func TestCertifier_CertifyContext(t *testing.T) {
+
+	dummyCheckName := "dummy-check"
+	otherCheckName := "other-check"
+	validChartUri := "./checks/chart-0.1.0-v3.valid.tgz"
+
+	t.Run("Should return cancellation error if context is cancelled before the run", func(t *testing.T) {
+		called := false
+		c := &certifier{
+			config: viper.New(),
+			registry: checks.NewRegistry().Add(dummyCheckName, func(uri string, _ *viper.Viper) (checks.Result, error) {
+				called = true
+				return checks.Result{Ok: true}, nil
+			}),
+			requiredChecks: []string{dummyCheckName},
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		r, err := c.CertifyContext(ctx, validChartUri)
+		require.Error(t, err)
+		require.True(t, errors.Is(err, context.Canceled))
+		require.Nil(t, r)
+		require.False(t, called)
+	})
+
+	t.Run("Should not execute remaining checks once context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		otherCalled := false
+		c := &certifier{
+			config: viper.New(),
+			registry: checks.NewRegistry().
+				Add(dummyCheckName, func(uri string, _ *viper.Viper) (checks.Result, error) {
+					cancel()
+					return checks.Result{Ok: true}, nil
+				}).
+				Add(otherCheckName, func(uri string, _ *viper.Viper) (checks.Result, error) {
+					otherCalled = true
+					return checks.Result{Ok: true}, nil
+				}),
+			requiredChecks: []string{dummyCheckName, otherCheckName},
+		}
+
+		r, err := c.CertifyContext(ctx, validChartUri)
+		require.Error(t, err)
+		require.True(t, errors.Is(err, context.Canceled))
+		require.Nil(t, r)
+		require.False(t, otherCalled)
+	})
+
+	t.Run("Should return promptly when context is cancelled while a check is running", func(t *testing.T) {
+		// load the chart beforehand, so goroutines eventually spawned by the chart loader aren't accounted for.
+		_, _, err := checks.LoadChartFromURI(validChartUri)
+		require.NoError(t, err)
+
+		baseline := runtime.NumGoroutine()
+
+		started := make(chan struct{})
+		stopped := make(chan struct{})
+		c := &certifier{
+			config: viper.New(),
+			registry: checks.NewRegistry().Add(dummyCheckName, func(uri string, config *viper.Viper) (checks.Result, error) {
+				// the check stops once the verification's context is cancelled
+				checkCtx := config.Get(checks.ContextConfigKey).(context.Context)
+				close(started)
+				<-checkCtx.Done()
+				close(stopped)
+				return checks.Result{}, checkCtx.Err()
+			}),
+			requiredChecks: []string{dummyCheckName},
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			<-started
+			cancel()
+		}()
+
+		begin := time.Now()
+		r, err := c.CertifyContext(ctx, validChartUri)
+		require.Error(t, err)
+		require.True(t, errors.Is(err, context.Canceled))
+		require.Nil(t, r)
+		require.Less(t, int64(time.Since(begin)), int64(time.Second))
+
+		// the check has been informed of the cancellation, so its goroutine should not linger.
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			require.Fail(t, "the check has not been informed of the cancellation")
+		}
+		require.Eventually(t, func() bool {
+			return runtime.NumGoroutine() <= baseline
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestCertifier_OnCheckComplete(t *testing.T) {
+
+	validChartUri := "./checks/chart-0.1.0-v3.valid.tgz"
+
+	registry := checks.NewRegistry().
+		Add("positive-check", func(uri string, _ *viper.Viper) (checks.Result, error) {
+			return checks.NewResult(true, "positive"), nil
+		}).
+		Add("negative-check", func(uri string, _ *viper.Viper) (checks.Result, error) {
+			return checks.NewResult(false, "negative"), nil
+		})
+
+	t.Run("Should invoke the callback once per check", func(t *testing.T) {
+		completed := map[string][]checks.Result{}
+
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			SetChecks([]string{"positive-check", "negative-check"}).
+			SetOnCheckComplete(func(name string, r checks.Result) {
+				completed[name] = append(completed[name], r)
+			}).
+			Build()
+		require.NoError(t, err)
+
+		r, err := c.Certify(validChartUri)
+		require.NoError(t, err)
+		require.NotNil(t, r)
+
+		require.Equal(t, map[string][]checks.Result{
+			"positive-check": {checks.NewResult(true, "positive")},
+			"negative-check": {checks.NewResult(false, "negative")},
+		}, completed)
+	})
+
+	t.Run("Should not invoke the callback for checks that did not complete", func(t *testing.T) {
+		var completed []string
+
+		c, err := NewCertifierBuilder().
+			SetRegistry(checks.NewRegistry().Add("errored-check", func(uri string, _ *viper.Viper) (checks.Result, error) {
+				return checks.Result{}, errors.New("artificial error")
+			})).
+			SetOnCheckComplete(func(name string, r checks.Result) {
+				completed = append(completed, name)
+			}).
+			Build()
+		require.NoError(t, err)
+
+		_, err = c.Certify(validChartUri)
+		require.Error(t, err)
+		require.Empty(t, completed)
+	})
+}
+
+func TestCertifier_CheckOptions(t *testing.T) {
+
+	validChartUri := "./checks/chart-0.1.0-v3.valid.tgz"
+
+	received := map[string]map[string]interface{}{}
+	recordingCheck := func(name string) checks.CheckFunc {
+		return func(uri string, config *viper.Viper) (checks.Result, error) {
+			received[name] = config.AllSettings()
+			return checks.NewResult(true, name), nil
+		}
+	}
+
+	registry := checks.NewRegistry().
+		Add("size-check", recordingCheck("size-check")).
+		Add("access-check", recordingCheck("access-check")).
+		Add("default-check", recordingCheck("default-check"))
+
+	certify := func(t *testing.T, config *viper.Viper, overrides []string) {
+		received = map[string]map[string]interface{}{}
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			SetConfig(config).
+			SetOverrides(overrides).
+			Build()
+		require.NoError(t, err)
+
+		_, err = c.Certify(validChartUri)
+		require.NoError(t, err)
+	}
+
+	t.Run("Each check should only receive its own options", func(t *testing.T) {
+		config := viper.New()
+		config.Set("checks", map[string]interface{}{
+			"size-check":   map[string]interface{}{"options": map[string]interface{}{"maxSize": 1024}},
+			"access-check": map[string]interface{}{"options": map[string]interface{}{"allowlist": []string{"sa"}}},
+		})
+
+		certify(t, config, nil)
+
+		require.Equal(t, map[string]map[string]interface{}{
+			"size-check":    {"maxsize": 1024},
+			"access-check":  {"allowlist": []string{"sa"}},
+			"default-check": {},
+		}, received)
+	})
+
+	t.Run("Scoped options should take precedence over the check subtree", func(t *testing.T) {
+		config := viper.New()
+		config.Set("size-check", map[string]interface{}{"maxSize": 1, "allowedPaths": "charts/"})
+
+		certify(t, config, []string{"checks.size-check.options.maxSize=2048"})
+
+		require.Equal(t, map[string]interface{}{"maxsize": "2048", "allowedpaths": "charts/"}, received["size-check"])
+		require.Empty(t, received["access-check"])
+	})
+	t.Run("Checks should apply their defaults when options are absent", func(t *testing.T) {
+		for overrides, ok := range map[string]bool{
+			"": true,
+			"checks.chart-size-reasonable.options.maxSize=1024": false,
+		} {
+			var overridesList []string
+			if overrides != "" {
+				overridesList = []string{overrides}
+			}
+			c, err := NewCertifierBuilder().
+				SetChecks([]string{"chart-size-reasonable"}).
+				SetOverrides(overridesList).
+				Build()
+			require.NoError(t, err)
+
+			r, err := c.Certify(validChartUri)
+			require.NoError(t, err)
+			require.Equal(t, ok, r.(*certificate).CheckResultMap["chart-size-reasonable"].Ok, overrides)
+		}
+	})
+}
+
+func TestCertifier_ChartDirectory(t *testing.T) {
+
+	packagedChartUri := "./checks/chart-0.1.0-v3.valid.tgz"
+
+	// images-are-certified and install-succeeds require external services and are not exercised here.
+	requiredChecks := []string{
+		"has-readme", "is-helm-v3", "contains-test", "contains-values", "contains-values-schema", "has-minkubeversion",
+		"not-contains-crds", "helm-lint", "not-contain-csi-objects", "version-is-semver", "has-valid-icon",
+		"referenced-configmaps-exist", "chart-size-reasonable", "readme-documents-values",
+	}
+
+	certify := func(t *testing.T, uri string) checkResultMap {
+		c, err := NewCertifierBuilder().SetChecks(requiredChecks).Build()
+		require.NoError(t, err)
+
+		r, err := c.Certify(uri)
+		require.NoError(t, err)
+		require.NotNil(t, r)
+		return r.(*certificate).CheckResultMap
+	}
+
+	expected := certify(t, packagedChartUri)
+	require.Len(t, expected, len(requiredChecks))
+
+	dir := t.TempDir()
+	require.NoError(t, chartutil.ExpandFile(dir, packagedChartUri))
+	absChartPath := filepath.Join(dir, "chart")
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	relChartPath, err := filepath.Rel(wd, absChartPath)
+	require.NoError(t, err)
+
+	for description, uri := range map[string]string{
+		"absolute path":     absChartPath,
+		"relative path":     relChartPath,
+		"absolute file uri": "file://" + absChartPath,
+		"relative file uri": "file://" + relChartPath,
+	} {
+		t.Run("Chart directory should produce the same results as its archive when informed as "+description, func(t *testing.T) {
+			require.Equal(t, expected, certify(t, uri))
+		})
+	}
+}
+
+func TestCertifier_FailOn(t *testing.T) {
+
+	validChartUri := "./checks/chart-0.1.0-v3.valid.tgz"
+
+	registry := checks.NewRegistry().
+		Add("passing-mandatory-check", func(uri string, _ *viper.Viper) (checks.Result, error) {
+			return checks.NewResult(true, "passing"), nil
+		}).
+		AddCheck("failing-optional-check", checks.Check{
+			Func: func(uri string, _ *viper.Viper) (checks.Result, error) {
+				return checks.NewResult(false, "failing"), nil
+			},
+			Type: checks.OptionalCheckType,
+		})
+
+	for failOn, ok := range map[FailOn]bool{
+		"":              true,
+		FailOnMandatory: true,
+		FailOnOptional:  false,
+		FailOnNone:      true,
+	} {
+		t.Run("Outcome should respect fail-on mode "+string(failOn), func(t *testing.T) {
+			c, err := NewCertifierBuilder().
+				SetRegistry(registry).
+				SetFailOn(failOn).
+				Build()
+			require.NoError(t, err)
+
+			r, err := c.Certify(validChartUri)
+			require.NoError(t, err)
+			require.Equal(t, ok, r.IsOk())
+
+			// the classification is recorded regardless of the mode
+			results := r.(*certificate).CheckResultMap
+			require.Equal(t, checks.MandatoryCheckType, results["passing-mandatory-check"].Type)
+			require.Equal(t, checks.OptionalCheckType, results["failing-optional-check"].Type)
+			require.False(t, results["failing-optional-check"].Ok)
+		})
+	}
+
+	t.Run("Mandatory failures should only be ignored in fail-on mode none", func(t *testing.T) {
+		failingRegistry := checks.NewRegistry().Add("failing-mandatory-check", func(uri string, _ *viper.Viper) (checks.Result, error) {
+			return checks.NewResult(false, "failing"), nil
+		})
+		for failOn, ok := range map[FailOn]bool{FailOnMandatory: false, FailOnOptional: false, FailOnNone: true} {
+			c, err := NewCertifierBuilder().SetRegistry(failingRegistry).SetFailOn(failOn).Build()
+			require.NoError(t, err)
+
+			r, err := c.Certify(validChartUri)
+			require.NoError(t, err)
+			require.Equal(t, ok, r.IsOk(), failOn)
+		}
+	})
+
+	t.Run("Should fail when fail-on mode is unknown", func(t *testing.T) {
+		_, err := NewCertifierBuilder().SetRegistry(registry).SetFailOn("everything").Build()
+		require.Error(t, err)
+	})
+}
+
+func TestCertifier_OpenShiftVersions(t *testing.T) {
+
+	validChartUri := "./checks/chart-0.1.0-v3.valid.tgz"
+
+	var mutex sync.Mutex
+	calls := map[string][]string{}
+	record := func(name string, config *viper.Viper) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		calls[name] = append(calls[name], config.GetString(checks.OpenShiftVersionConfigKey))
+	}
+
+	registry := checks.NewRegistry().
+		Add("version-insensitive-check", func(uri string, config *viper.Viper) (checks.Result, error) {
+			record("version-insensitive-check", config)
+			return checks.NewResult(true, "insensitive"), nil
+		}).
+		AddCheck("version-sensitive-check", checks.Check{
+			Func: func(uri string, config *viper.Viper) (checks.Result, error) {
+				record("version-sensitive-check", config)
+				if config.GetString(checks.OpenShiftVersionConfigKey) == "4.13" {
+					return checks.NewResult(false, "unsupported"), nil
+				}
+				return checks.NewResult(true, "supported"), nil
+			},
+			Type:                     checks.MandatoryCheckType,
+			RequiresOpenShiftVersion: true,
+		})
+
+	certify := func(t *testing.T, versions []string) *certificate {
+		calls = map[string][]string{}
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			SetOpenShiftVersions(versions).
+			Build()
+		require.NoError(t, err)
+
+		r, err := c.Certify(validChartUri)
+		require.NoError(t, err)
+		return r.(*certificate)
+	}
+
+	t.Run("Should list all versions that passed", func(t *testing.T) {
+		r := certify(t, []string{"4.12", "4.14"})
+
+		require.True(t, r.Ok)
+		require.Equal(t, []string{"4.12", "4.14"}, r.Metadata.RunMetadata.CertifiedOpenShiftVersions)
+		require.ElementsMatch(t, []string{"4.12", "4.14"}, calls["version-sensitive-check"])
+		require.Equal(t, []string{""}, calls["version-insensitive-check"])
+	})
+
+	t.Run("Should report failures per version", func(t *testing.T) {
+		r := certify(t, []string{"4.12", "4.13", "4.14"})
+
+		require.False(t, r.Ok)
+		require.Equal(t, []string{"4.12", "4.14"}, r.Metadata.RunMetadata.CertifiedOpenShiftVersions)
+		require.Equal(t, []string{""}, calls["version-insensitive-check"])
+
+		result := r.CheckResultMap["version-sensitive-check"]
+		require.False(t, result.Ok)
+		require.Equal(t, map[string]versionCheckResult{
+			"4.12": {Ok: true, Reason: "supported"},
+			"4.13": {Ok: false, Reason: "unsupported"},
+			"4.14": {Ok: true, Reason: "supported"},
+		}, result.OpenShiftVersions)
+		require.Equal(t, "OpenShift 4.12 : supported\n\t\tOpenShift 4.13 : unsupported\n\t\tOpenShift 4.14 : supported", result.Reason)
+		require.Empty(t, r.CheckResultMap["version-insensitive-check"].OpenShiftVersions)
+	})
+
+	t.Run("Should run version sensitive checks once when no versions are informed", func(t *testing.T) {
+		r := certify(t, nil)
+
+		require.True(t, r.Ok)
+		require.Empty(t, r.Metadata.RunMetadata.CertifiedOpenShiftVersions)
+		require.Equal(t, []string{""}, calls["version-sensitive-check"])
+		require.Empty(t, r.CheckResultMap["version-sensitive-check"].OpenShiftVersions)
+	})
+
+	t.Run("Should fail when an OpenShift version is invalid", func(t *testing.T) {
+		_, err := NewCertifierBuilder().SetRegistry(registry).SetOpenShiftVersions([]string{"3.11"}).Build()
+		require.Error(t, err)
+	})
+}
+
+func TestCertifier_ContinueOnCheckError(t *testing.T) {
+
+	validChartUri := "./checks/chart-0.1.0-v3.valid.tgz"
+
+	var executed []string
+	registry := checks.NewRegistry().
+		Add("erroring-check", func(uri string, _ *viper.Viper) (checks.Result, error) {
+			executed = append(executed, "erroring-check")
+			return checks.Result{}, errors.New("artificial error")
+		}).
+		Add("passing-check", func(uri string, _ *viper.Viper) (checks.Result, error) {
+			executed = append(executed, "passing-check")
+			return checks.NewResult(true, "passing"), nil
+		}).
+		AddCheck("erroring-versioned-check", checks.Check{
+			Func: func(uri string, config *viper.Viper) (checks.Result, error) {
+				if config.GetString(checks.OpenShiftVersionConfigKey) == "4.13" {
+					return checks.Result{}, errors.New("artificial versioned error")
+				}
+				return checks.NewResult(true, "passing"), nil
+			},
+			Type:                     checks.MandatoryCheckType,
+			RequiresOpenShiftVersion: true,
+		})
+
+	t.Run("Should abort on check errors by default", func(t *testing.T) {
+		executed = nil
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			SetChecks([]string{"erroring-check", "passing-check"}).
+			Build()
+		require.NoError(t, err)
+
+		_, err = c.Certify(validChartUri)
+		require.Error(t, err)
+		require.Equal(t, CheckErr("artificial error"), err)
+		require.Equal(t, []string{"erroring-check"}, executed)
+	})
+
+	t.Run("Should record check errors as failures and execute remaining checks", func(t *testing.T) {
+		executed = nil
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			SetChecks([]string{"erroring-check", "passing-check"}).
+			SetContinueOnCheckError(true).
+			Build()
+		require.NoError(t, err)
+
+		r, err := c.Certify(validChartUri)
+		require.NoError(t, err)
+		require.False(t, r.IsOk())
+		require.Equal(t, []string{"erroring-check", "passing-check"}, executed)
+
+		results := r.(*certificate).CheckResultMap
+		require.False(t, results["erroring-check"].Ok)
+		require.Equal(t, "check error: artificial error", results["erroring-check"].Reason)
+		require.True(t, results["passing-check"].Ok)
+		require.Equal(t, summary{Passed: 1, Failed: 1}, r.(*certificate).Summary)
+	})
+
+	t.Run("Should record check errors per OpenShift version", func(t *testing.T) {
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			SetChecks([]string{"erroring-versioned-check"}).
+			SetOpenShiftVersions([]string{"4.12", "4.13"}).
+			SetContinueOnCheckError(true).
+			Build()
+		require.NoError(t, err)
+
+		r, err := c.Certify(validChartUri)
+		require.NoError(t, err)
+
+		result := r.(*certificate).CheckResultMap["erroring-versioned-check"]
+		require.False(t, result.Ok)
+		require.Equal(t, versionCheckResult{Ok: true, Reason: "passing"}, result.OpenShiftVersions["4.12"])
+		require.Equal(t, versionCheckResult{Ok: false, Reason: "check error: artificial versioned error"}, result.OpenShiftVersions["4.13"])
+	})
+}
+
+func TestCertifier_ValueOverrides(t *testing.T) {
+
+	validChartUri := "./checks/chart-0.1.0-v3.valid.tgz"
+
+	var values interface{}
+	registry := checks.NewRegistry().Add("values-check", func(uri string, config *viper.Viper) (checks.Result, error) {
+		values = config.Get(checks.ValuesConfigKey)
+		return checks.NewResult(true, "passing"), nil
+	})
+
+	t.Run("Should inform typed and string values to checks", func(t *testing.T) {
+		values = nil
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			SetValueOverrides([]string{"replicaCount=3", "image.tag=1.16.0", "zipCode=8080"}).
+			SetStringValueOverrides([]string{"zipCode=08080"}).
+			Build()
+		require.NoError(t, err)
+
+		_, err = c.Certify(validChartUri)
+		require.NoError(t, err)
+		require.Equal(t, chartutil.Values{
+			"replicaCount": int64(3),
+			"image":        map[string]interface{}{"tag": "1.16.0"},
+			"zipCode":      "08080",
+		}, values)
+	})
+
+	t.Run("Should not inform values to checks when none have been given", func(t *testing.T) {
+		values = nil
+		c, err := NewCertifierBuilder().SetRegistry(registry).Build()
+		require.NoError(t, err)
+
+		_, err = c.Certify(validChartUri)
+		require.NoError(t, err)
+		require.Nil(t, values)
+	})
+
+	t.Run("Should fail when a value override is malformed", func(t *testing.T) {
+		_, err := NewCertifierBuilder().SetRegistry(registry).SetValueOverrides([]string{"replicaCount"}).Build()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed parsing value override")
+	})
+}
+
+func TestCertifier_ValuesProfiles(t *testing.T) {
+
+	chartUri := "./checks/chart-0.1.0-v3.ingress-nodeport.tgz"
+
+	registry := checks.NewRegistry().
+		Add("is-helm-v3", checks.IsHelmV3).
+		AddCheck("no-nodeport-services", checks.Check{
+			Func:             checks.NoNodePortServices,
+			Type:             checks.MandatoryCheckType,
+			RendersTemplates: true,
+		})
+
+	profiles := map[string]chartutil.Values{
+		"default": {},
+		"ingress": {"ingress": map[string]interface{}{"enabled": true}},
+	}
+
+	certify := func(t *testing.T, builder CertifierBuilder) *certificate {
+		c, err := builder.SetRegistry(registry).Build()
+		require.NoError(t, err)
+
+		r, err := c.Certify(chartUri)
+		require.NoError(t, err)
+		return r.(*certificate)
+	}
+
+	t.Run("Should fail when any profile fails and report results per profile", func(t *testing.T) {
+		r := certify(t, NewCertifierBuilder().SetValuesProfiles(profiles))
+
+		require.False(t, r.Ok)
+
+		result := r.CheckResultMap["no-nodeport-services"]
+		require.False(t, result.Ok)
+		require.Equal(t, "Values profile default : "+checks.NodePortServicesAbsent+
+			"\n\t\tValues profile ingress : "+checks.NodePortServiceFound+" : Service/testRelease-chart", result.Reason)
+
+		finding := checks.Finding{
+			Resource: "Service/testRelease-chart",
+			Field:    "spec.type",
+			Message:  "Service of type NodePort should be replaced by a Route or an Ingress",
+			Severity: checks.ErrorSeverity,
+		}
+		require.Equal(t, []checks.Finding{finding}, result.Findings)
+		require.Equal(t, map[string]profileCheckResult{
+			"default": {Ok: true, Reason: checks.NodePortServicesAbsent},
+			"ingress": {Ok: false, Reason: checks.NodePortServiceFound + " : Service/testRelease-chart", Findings: []checks.Finding{finding}},
+		}, result.ValuesProfiles)
+
+		require.True(t, r.CheckResultMap["is-helm-v3"].Ok)
+		require.Empty(t, r.CheckResultMap["is-helm-v3"].ValuesProfiles)
+	})
+
+	t.Run("Should apply value overrides on top of each profile", func(t *testing.T) {
+		r := certify(t, NewCertifierBuilder().SetValuesProfiles(profiles).SetValueOverrides([]string{"ingress.enabled=false"}))
+
+		require.True(t, r.Ok)
+		require.Equal(t, map[string]profileCheckResult{
+			"default": {Ok: true, Reason: checks.NodePortServicesAbsent},
+			"ingress": {Ok: true, Reason: checks.NodePortServicesAbsent},
+		}, r.CheckResultMap["no-nodeport-services"].ValuesProfiles)
+	})
+
+	t.Run("Should render templates once when no profiles are informed", func(t *testing.T) {
+		r := certify(t, NewCertifierBuilder())
+
+		require.True(t, r.Ok)
+		require.Equal(t, checks.NodePortServicesAbsent, r.CheckResultMap["no-nodeport-services"].Reason)
+		require.Empty(t, r.CheckResultMap["no-nodeport-services"].ValuesProfiles)
+	})
+}
+
+func TestCertifier_Clock(t *testing.T) {
+	registry := checks.NewRegistry().Add("is-helm-v3", checks.IsHelmV3)
+	uri := "./checks/chart-0.1.0-v3.valid.tgz"
+
+	t.Run("Should report the time informed by the configured clock", func(t *testing.T) {
+		generatedAt := time.Date(2021, 3, 4, 5, 6, 7, 0, time.FixedZone("CET", 3600))
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			SetChecks([]string{"is-helm-v3"}).
+			SetClock(func() time.Time { return generatedAt }).
+			Build()
+		require.NoError(t, err)
+
+		r, err := c.Certify(uri)
+		require.NoError(t, err)
+		require.Equal(t, "2021-03-04T04:06:07Z", r.(*certificate).Metadata.RunMetadata.GeneratedAt)
+
+		b, err := yaml.Marshal(r)
+		require.NoError(t, err)
+		require.Contains(t, string(b), "generated-at: \"2021-03-04T04:06:07Z\"")
+	})
+
+	t.Run("Should report the current time by default", func(t *testing.T) {
+		c, err := NewCertifierBuilder().SetRegistry(registry).SetChecks([]string{"is-helm-v3"}).Build()
+		require.NoError(t, err)
+
+		before := time.Now().Truncate(time.Second)
+		r, err := c.Certify(uri)
+		require.NoError(t, err)
+
+		generatedAt, err := time.Parse(time.RFC3339, r.(*certificate).Metadata.RunMetadata.GeneratedAt)
+		require.NoError(t, err)
+		require.False(t, generatedAt.Before(before))
+		require.False(t, generatedAt.After(time.Now()))
+	})
+}
+
+func TestCertifier_InvalidChartURI(t *testing.T) {
+	c, err := NewCertifierBuilder().SetChecks([]string{"is-helm-v3"}).Build()
+	require.NoError(t, err)
+
+	r, err := c.Certify("oci://registry.example.com/charts/chart:0.1.0")
+	require.Error(t, err)
+	require.True(t, checks.IsInvalidChartURI(err), "unexpected error %v", err)
+	require.Nil(t, r)
+}
+
+func TestCertifier_Capabilities(t *testing.T) {
+	uri := "./checks/chart-0.1.0-v3.valid.tgz"
+
+	var mutex sync.Mutex
+	executed := map[string]*viper.Viper{}
+	recordingCheck := func(name string) checks.CheckFunc {
+		return func(uri string, config *viper.Viper) (checks.Result, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			executed[name] = config
+			return checks.NewResult(true, name), nil
+		}
+	}
+
+	registry := checks.NewRegistry().
+		Add("local-check", recordingCheck("local-check")).
+		AddCheck("network-check", checks.Check{Func: recordingCheck("network-check"), Type: checks.MandatoryCheckType, RequiresNetwork: true}).
+		AddCheck("cluster-check", checks.Check{Func: recordingCheck("cluster-check"), Type: checks.OptionalCheckType, RequiresCluster: true})
+
+	type testCase struct {
+		description string
+		noNetwork   bool
+		noCluster   bool
+		skipped     map[string]string
+	}
+
+	testCases := []testCase{
+		{description: "Should execute all checks by default", skipped: map[string]string{}},
+		{description: "Should skip checks requiring network access when network is disabled", noNetwork: true, skipped: map[string]string{"network-check": CheckSkippedNoNetwork}},
+		{description: "Should skip checks requiring cluster access when cluster is disabled", noCluster: true, skipped: map[string]string{"cluster-check": CheckSkippedNoCluster}},
+		{
+			description: "Should skip checks requiring either capability when both are disabled",
+			noNetwork:   true,
+			noCluster:   true,
+			skipped:     map[string]string{"network-check": CheckSkippedNoNetwork, "cluster-check": CheckSkippedNoCluster},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			executed = map[string]*viper.Viper{}
+			completed := map[string]checks.Result{}
+
+			c, err := NewCertifierBuilder().
+				SetRegistry(registry).
+				SetChecks([]string{"local-check", "network-check", "cluster-check"}).
+				SetNoNetwork(tc.noNetwork).
+				SetNoCluster(tc.noCluster).
+				SetOnCheckComplete(func(name string, r checks.Result) { completed[name] = r }).
+				Build()
+			require.NoError(t, err)
+
+			r, err := c.Certify(uri)
+			require.NoError(t, err)
+			cert := r.(*certificate)
+
+			require.True(t, cert.Ok)
+			require.Equal(t, summary{Passed: 3 - len(tc.skipped), Skipped: len(tc.skipped)}, cert.Summary)
+			for _, name := range []string{"local-check", "network-check", "cluster-check"} {
+				result := cert.CheckResultMap[name]
+				require.True(t, result.Ok, name)
+				require.Contains(t, completed, name)
+				if reason, ok := tc.skipped[name]; ok {
+					require.NotContains(t, executed, name)
+					require.True(t, result.Skipped, name)
+					require.Equal(t, reason, result.Reason)
+				} else {
+					require.Contains(t, executed, name)
+					require.False(t, result.Skipped, name)
+					require.Equal(t, name, result.Reason)
+				}
+			}
+
+			require.Equal(t, tc.noNetwork, executed["local-check"].IsSet(checks.AllowNetworkConfigKey))
+			require.False(t, executed["local-check"].GetBool(checks.AllowNetworkConfigKey))
+		})
+	}
+}
+
+func TestCertifier_MetadataOnly(t *testing.T) {
+	uri := "./checks/chart-0.1.0-v3.valid.tgz"
+	metadataChecks := []string{"has-readme", "version-is-semver", "has-valid-icon"}
+	skippedChecks := []string{"helm-lint", "pdb-configured", "images-are-certified", "install-succeeds"}
+
+	completed := map[string]checks.Result{}
+	c, err := NewCertifierBuilder().
+		SetChecks(append(append([]string{}, metadataChecks...), skippedChecks...)).
+		SetMetadataOnly(true).
+		SetOnCheckComplete(func(name string, r checks.Result) { completed[name] = r }).
+		Build()
+	require.NoError(t, err)
+
+	r, err := c.Certify(uri)
+	require.NoError(t, err)
+	cert := r.(*certificate)
+
+	require.True(t, cert.Ok)
+	require.Equal(t, summary{Passed: len(metadataChecks), Skipped: len(skippedChecks)}, cert.Summary)
+	for _, name := range metadataChecks {
+		require.False(t, cert.CheckResultMap[name].Skipped, name)
+		require.NotEqual(t, CheckSkippedMetadataOnly, completed[name].Reason, name)
+	}
+	for _, name := range skippedChecks {
+		require.True(t, cert.CheckResultMap[name].Skipped, name)
+		require.Equal(t, CheckSkippedMetadataOnly, cert.CheckResultMap[name].Reason, name)
+		require.Equal(t, CheckSkippedMetadataOnly, completed[name].Reason, name)
+	}
+}
+
+func TestCertifier_MaxRequestsPerSecond(t *testing.T) {
+	uri := "./checks/chart-0.1.0-v3.valid.tgz"
+
+	var mutex sync.Mutex
+	limiters := map[string]interface{}{}
+	recordingCheck := func(name string) checks.CheckFunc {
+		return func(uri string, config *viper.Viper) (checks.Result, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			limiters[name] = config.Get(checks.RateLimiterConfigKey)
+			return checks.NewResult(true, name), nil
+		}
+	}
+
+	registry := checks.NewRegistry().
+		Add("icon-check", recordingCheck("icon-check")).
+		Add("image-check", recordingCheck("image-check"))
+
+	t.Run("Should share a single rate limiter among all checks", func(t *testing.T) {
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			SetChecks([]string{"icon-check", "image-check"}).
+			SetMaxRequestsPerSecond(5).
+			Build()
+		require.NoError(t, err)
+
+		_, err = c.Certify(uri)
+		require.NoError(t, err)
+
+		limiter, ok := limiters["icon-check"].(*checks.RateLimiter)
+		require.True(t, ok)
+		require.NotNil(t, limiter)
+		require.Same(t, limiter, limiters["image-check"])
+	})
+
+	t.Run("Should not limit requests by default", func(t *testing.T) {
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			SetChecks([]string{"icon-check", "image-check"}).
+			Build()
+		require.NoError(t, err)
+
+		_, err = c.Certify(uri)
+		require.NoError(t, err)
+
+		require.Nil(t, limiters["icon-check"])
+		require.Nil(t, limiters["image-check"])
+	})
+}
+
+func TestCertifier_FailFast(t *testing.T) {
+	uri := "./checks/chart-0.1.0-v3.valid.tgz"
+
+	var mutex sync.Mutex
+	var executed []string
+	recordingCheck := func(name string, ok bool) checks.CheckFunc {
+		return func(uri string, config *viper.Viper) (checks.Result, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			executed = append(executed, name)
+			return checks.NewResult(ok, name), nil
+		}
+	}
+
+	registry := checks.NewRegistry().
+		AddCheck("passing-check", checks.Check{Func: recordingCheck("passing-check", true), Type: checks.MandatoryCheckType}).
+		AddCheck("failing-optional-check", checks.Check{Func: recordingCheck("failing-optional-check", false), Type: checks.OptionalCheckType}).
+		AddCheck("failing-mandatory-check", checks.Check{Func: recordingCheck("failing-mandatory-check", false), Type: checks.MandatoryCheckType}).
+		AddCheck("remaining-mandatory-check", checks.Check{Func: recordingCheck("remaining-mandatory-check", true), Type: checks.MandatoryCheckType}).
+		AddCheck("remaining-optional-check", checks.Check{Func: recordingCheck("remaining-optional-check", true), Type: checks.OptionalCheckType})
+	names := []string{"passing-check", "failing-optional-check", "failing-mandatory-check", "remaining-mandatory-check", "remaining-optional-check"}
+
+	t.Run("Should not run the checks following the first failed mandatory check", func(t *testing.T) {
+		executed = nil
+		completed := map[string]checks.Result{}
+
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			SetChecks(names).
+			SetFailFast(true).
+			SetOnCheckComplete(func(name string, r checks.Result) { completed[name] = r }).
+			Build()
+		require.NoError(t, err)
+
+		r, err := c.Certify(uri)
+		require.NoError(t, err)
+		cert := r.(*certificate)
+
+		require.Equal(t, []string{"passing-check", "failing-optional-check", "failing-mandatory-check"}, executed)
+		require.False(t, cert.Ok)
+		require.Equal(t, summary{Passed: 1, Failed: 2, Skipped: 2}, cert.Summary)
+		for _, name := range []string{"remaining-mandatory-check", "remaining-optional-check"} {
+			result := cert.CheckResultMap[name]
+			require.True(t, result.Skipped, name)
+			require.Equal(t, CheckSkippedFailFast, result.Reason)
+			require.Equal(t, CheckSkippedFailFast, completed[name].Reason)
+		}
+		require.False(t, cert.CheckResultMap["failing-mandatory-check"].Skipped)
+	})
+
+	t.Run("Should run all checks when not failing fast", func(t *testing.T) {
+		executed = nil
+
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			SetChecks(names).
+			Build()
+		require.NoError(t, err)
+
+		r, err := c.Certify(uri)
+		require.NoError(t, err)
+		cert := r.(*certificate)
+
+		require.Equal(t, names, executed)
+		require.Equal(t, summary{Passed: 3, Failed: 2}, cert.Summary)
+	})
+}
+
+func TestCertifier_Verify(t *testing.T) {
+	// images-are-certified and install-succeeds require external services and are not exercised here.
+	requiredChecks := []string{
+		"has-readme", "is-helm-v3", "contains-values", "has-minkubeversion", "helm-lint", "version-is-semver",
+		"rbac-least-privilege", "helm-hooks-valid", "no-podsecuritypolicy", "secrets-are-external",
+	}
+
+	newCertifier := func(t *testing.T) Certifier {
+		c, err := NewCertifierBuilder().
+			SetChecks(requiredChecks).
+			SetClock(func() time.Time { return time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC) }).
+			SetValuesProfiles(map[string]chartutil.Values{
+				"default": {},
+				"scaled":  {"replicaCount": 3},
+			}).
+			Build()
+		require.NoError(t, err)
+		return c
+	}
+
+	// readDirNames returns the names of the entries of dir, which must exist.
+	readDirNames := func(t *testing.T, dir string) []string {
+		f, err := os.Open(dir)
+		require.NoError(t, err)
+		defer f.Close()
+		names, err := f.Readdirnames(-1)
+		require.NoError(t, err)
+		return names
+	}
+
+	t.Run("Should return independent reports for concurrent verifications without side effects", func(t *testing.T) {
+		uris := []string{
+			"./checks/chart-0.1.0-v3.valid.tgz",
+			"./checks/chart-0.1.0-v3.psp.tgz",
+			"./checks/chart-0.1.0-v3.hooks.tgz",
+			"./checks/chart-0.1.0-v3.rbac-wildcard.tgz",
+		}
+
+		c := newCertifier(t)
+		expected := map[string]*Report{}
+		for _, uri := range uris {
+			report, err := c.Verify(context.Background(), uri)
+			require.NoError(t, err)
+			expected[uri] = report
+		}
+
+		// temporary files and cached charts would be written to these directories
+		tmpDir, homeDir := t.TempDir(), t.TempDir()
+		for key, value := range map[string]string{"TMPDIR": tmpDir, "HOME": homeDir, "XDG_CACHE_HOME": homeDir} {
+			original, found := os.LookupEnv(key)
+			require.NoError(t, os.Setenv(key, value))
+			key := key
+			t.Cleanup(func() {
+				if found {
+					_ = os.Setenv(key, original)
+				} else {
+					_ = os.Unsetenv(key)
+				}
+			})
+		}
+
+		stdout := os.Stdout
+		r, w, err := os.Pipe()
+		require.NoError(t, err)
+		os.Stdout = w
+		defer func() { os.Stdout = stdout }()
+
+		const verifications = 16
+		reports := make([]*Report, verifications)
+		errs := make([]error, verifications)
+		var wg sync.WaitGroup
+		for i := 0; i < verifications; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				reports[i], errs[i] = c.Verify(context.Background(), uris[i%len(uris)])
+			}(i)
+		}
+		wg.Wait()
+
+		os.Stdout = stdout
+		require.NoError(t, w.Close())
+		written, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.Empty(t, string(written))
+
+		for i, report := range reports {
+			require.NoError(t, errs[i])
+			require.Equal(t, expected[uris[i%len(uris)]], report)
+		}
+		require.Equal(t, "0.1.0-v3.psp", reports[1].ChartMetadata.Version)
+		require.False(t, reports[1].CheckResultMap["no-podsecuritypolicy"].Ok)
+		require.True(t, reports[0].CheckResultMap["no-podsecuritypolicy"].Ok)
+
+		require.Empty(t, readDirNames(t, tmpDir))
+		require.Empty(t, readDirNames(t, homeDir))
+	})
+
+	t.Run("Should not keep the chart cached once verified", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, chartutil.ExpandFile(dir, "./checks/chart-0.1.0-v3.valid.tgz"))
+		uri := filepath.Join(dir, "chart")
+
+		c := newCertifier(t)
+		report, err := c.Verify(context.Background(), uri)
+		require.NoError(t, err)
+		require.Equal(t, "0.1.0-v3.valid", report.ChartMetadata.Version)
+
+		chartYaml := filepath.Join(uri, "Chart.yaml")
+		b, err := ioutil.ReadFile(chartYaml)
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(chartYaml, []byte(strings.Replace(string(b), "0.1.0-v3.valid", "0.2.0", 1)), 0644))
+
+		report, err = c.Verify(context.Background(), uri)
+		require.NoError(t, err)
+		require.Equal(t, "0.2.0", report.ChartMetadata.Version)
+	})
+}
+
+func TestCertifier_TotalTimeout(t *testing.T) {
+	uri := "./checks/chart-0.1.0-v3.valid.tgz"
+
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+
+	remainingCalled := false
+	registry := checks.NewRegistry().
+		Add("fast-check", func(uri string, _ *viper.Viper) (checks.Result, error) {
+			return checks.NewResult(true, "fast"), nil
+		}).
+		Add("hanging-check", func(uri string, _ *viper.Viper) (checks.Result, error) {
+			<-release
+			return checks.NewResult(true, "finally"), nil
+		}).
+		Add("remaining-check", func(uri string, _ *viper.Viper) (checks.Result, error) {
+			remainingCalled = true
+			return checks.NewResult(true, "remaining"), nil
+		})
+
+	build := func(t *testing.T, requiredChecks ...string) Certifier {
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			SetChecks(requiredChecks).
+			SetTotalTimeout(100 * time.Millisecond).
+			Build()
+		require.NoError(t, err)
+		return c
+	}
+
+	t.Run("Should report the checks not completed in time as timed out", func(t *testing.T) {
+		remainingCalled = false
+		completed := map[string]checks.Result{}
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			SetChecks([]string{"fast-check", "hanging-check", "remaining-check"}).
+			SetTotalTimeout(100 * time.Millisecond).
+			SetOnCheckComplete(func(name string, r checks.Result) { completed[name] = r }).
+			Build()
+		require.NoError(t, err)
+
+		begin := time.Now()
+		r, err := c.Certify(uri)
+		require.NoError(t, err)
+		require.Less(t, int64(time.Since(begin)), int64(time.Second))
+		require.False(t, remainingCalled)
+
+		cert := r.(*certificate)
+		require.False(t, cert.Ok)
+		require.Equal(t, summary{Passed: 1, Failed: 2}, cert.Summary)
+		require.True(t, cert.CheckResultMap["fast-check"].Ok)
+		for _, name := range []string{"hanging-check", "remaining-check"} {
+			require.False(t, cert.CheckResultMap[name].Ok, name)
+			require.Equal(t, CheckTimedOut, cert.CheckResultMap[name].Reason, name)
+			require.Equal(t, CheckTimedOut, completed[name].Reason, name)
+		}
+	})
+
+	t.Run("Should return the partial report when verifying", func(t *testing.T) {
+		report, err := build(t, "fast-check", "hanging-check").Verify(context.Background(), uri)
+		require.NoError(t, err)
+		require.False(t, report.Ok)
+		require.Equal(t, CheckTimedOut, report.CheckResultMap["hanging-check"].Reason)
+	})
+
+	t.Run("Should fail when no check completes in time", func(t *testing.T) {
+		remainingCalled = false
+		r, err := build(t, "hanging-check", "remaining-check").Certify(uri)
+		require.Error(t, err)
+		require.True(t, errors.Is(err, context.DeadlineExceeded))
+		require.Contains(t, err.Error(), "verification timed out after 100ms")
+		require.Nil(t, r)
+		require.False(t, remainingCalled)
+	})
+
+	t.Run("Should not bound verifications completing in time", func(t *testing.T) {
+		r, err := build(t, "fast-check", "remaining-check").Certify(uri)
+		require.NoError(t, err)
+		require.True(t, r.IsOk())
+	})
+}
+
+func TestCertifier_TotalTimeoutRetainsChart(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, chartutil.ExpandFile(dir, "./checks/chart-0.1.0-v3.valid.tgz"))
+	uri := filepath.Join(dir, "chart")
+
+	release := make(chan struct{})
+	loaded := make(chan string, 1)
+	registry := checks.NewRegistry().
+		Add("fast-check", func(uri string, _ *viper.Viper) (checks.Result, error) {
+			return checks.NewResult(true, "fast"), nil
+		}).
+		Add("late-check", func(uri string, _ *viper.Viper) (checks.Result, error) {
+			<-release
+			c, _, err := checks.LoadChartFromURI(uri)
+			if err != nil {
+				return checks.Result{}, err
+			}
+			loaded <- c.Metadata.Version
+			return checks.NewResult(true, "late"), nil
+		})
+
+	c, err := NewCertifierBuilder().
+		SetRegistry(registry).
+		SetChecks([]string{"fast-check", "late-check"}).
+		SetTotalTimeout(100 * time.Millisecond).
+		Build()
+	require.NoError(t, err)
+
+	report, err := c.Verify(context.Background(), uri)
+	require.NoError(t, err)
+	require.Equal(t, CheckTimedOut, report.CheckResultMap["late-check"].Reason)
+
+	// the abandoned check still sees the chart retained by the verification, rather than loading it again
+	chartYaml := filepath.Join(uri, "Chart.yaml")
+	b, err := ioutil.ReadFile(chartYaml)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(chartYaml, []byte(strings.Replace(string(b), "0.1.0-v3.valid", "0.2.0", 1)), 0644))
+	close(release)
+	require.Equal(t, "0.1.0-v3.valid", <-loaded)
+
+	// and the chart is released once the check has returned
+	c, err = NewCertifierBuilder().
+		SetRegistry(registry).
+		SetChecks([]string{"fast-check"}).
+		Build()
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		report, err := c.Verify(context.Background(), uri)
+		return err == nil && report.ChartMetadata.Version == "0.2.0"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestCertifier_BuildDependencies(t *testing.T) {
+	addr := "127.0.0.1:9877"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	repositoryDir := t.TempDir()
+	subchart := &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "subchart", Version: "0.1.0"},
+		Templates: []*chart.File{{
+			Name: "templates/pod.yaml",
+			Data: []byte("apiVersion: v1\nkind: Pod\nmetadata:\n  name: {{ .Release.Name }}-subchart\nspec:\n  containers:\n    - name: subchart\n      image: registry.example.com/subchart:0.1.0\n"),
+		}},
+	}
+	require.NoError(t, testutil.WriteChartRepository(repositoryDir, subchart))
+	require.NoError(t, testutil.ServeCharts(ctx, addr, repositoryDir))
+
+	chartDir := t.TempDir()
+	require.NoError(t, chartutil.SaveDir(&chart.Chart{
+		Metadata: &chart.Metadata{
+			APIVersion: chart.APIVersionV2,
+			Name:       "parent",
+			Version:    "0.1.0",
+			AppVersion: "1.0.0",
+			Dependencies: []*chart.Dependency{
+				{Name: "subchart", Version: "0.1.0", Repository: "http://" + addr + "/charts"},
+				{Name: "ocichart", Version: "0.1.0", Repository: "oci://registry.example.com/charts"},
+			},
+		},
+	}, chartDir))
+	uri := filepath.Join(chartDir, "parent")
+
+	// the check passes when the subchart's pod is rendered
+	registry := checks.NewRegistry().AddCheck("renders-subchart", checks.Check{
+		Func: func(uri string, _ *viper.Viper) (checks.Result, error) {
+			images, err := checks.GetImageReferences(uri, "")
+			if err != nil {
+				return checks.Result{}, err
+			}
+			return checks.NewResult(len(images) == 1 && images[0] == "registry.example.com/subchart:0.1.0", "subchart rendered"), nil
+		},
+		Type:             checks.MandatoryCheckType,
+		RendersTemplates: true,
+	})
+
+	for _, buildDependencies := range []bool{false, true} {
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			SetChecks([]string{"renders-subchart"}).
+			SetBuildDependencies(buildDependencies).
+			Build()
+		require.NoError(t, err)
+
+		r, err := c.Verify(context.Background(), uri)
+		require.NoError(t, err)
+		require.Equal(t, buildDependencies, r.IsOk(), "build dependencies: %v", buildDependencies)
+		if buildDependencies {
+			require.Equal(t, []string{"ocichart"}, r.Metadata.RunMetadata.SkippedDependencies)
+		} else {
+			require.Empty(t, r.Metadata.RunMetadata.SkippedDependencies)
+		}
+	}
+
+	t.Run("Should leave the chart cached for the uri as retrieved", func(t *testing.T) {
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			SetChecks([]string{"renders-subchart"}).
+			SetBuildDependencies(true).
+			Build()
+		require.NoError(t, err)
+
+		cert, err := c.Certify(uri)
+		require.NoError(t, err)
+		require.True(t, cert.IsOk())
+
+		retrieved, err := loader.Load(uri)
+		require.NoError(t, err)
+		cached, _, err := checks.LoadChartFromURI(uri)
+		require.NoError(t, err)
+		require.Empty(t, cached.Dependencies())
+		require.Equal(t, chartDigest(retrieved), chartDigest(cached))
+	})
+}
+
+// replayingTransport answers requests with the files found in dir, by base name, or with an empty image when the
+// request is not for a chart repository file, recording every request it receives.
+type replayingTransport struct {
+	dir      string
+	mutex    sync.Mutex
+	requests []string
+}
+
+func (rt *replayingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.mutex.Lock()
+	rt.requests = append(rt.requests, req.Method+" "+req.URL.String())
+	rt.mutex.Unlock()
+
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(&bytes.Buffer{}), Request: req}
+	switch path.Ext(req.URL.Path) {
+	case ".tgz", ".yaml":
+		data, err := ioutil.ReadFile(filepath.Join(rt.dir, path.Base(req.URL.Path)))
+		if err != nil {
+			resp.StatusCode = http.StatusNotFound
+		} else {
+			resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+		}
+	default:
+		resp.Header.Set("Content-Type", "image/png")
+	}
+	return resp, nil
+}
+
+func TestCertifier_HTTPTransport(t *testing.T) {
+	repositoryDir := t.TempDir()
+	subchart := &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "subchart", Version: "0.1.0"},
+	}
+	parent := &chart.Chart{
+		Metadata: &chart.Metadata{
+			APIVersion:   chart.APIVersionV2,
+			Name:         "parent",
+			Version:      "0.1.0",
+			AppVersion:   "1.0.0",
+			Icon:         "https://icons.example.com/parent.png",
+			Dependencies: []*chart.Dependency{{Name: "subchart", Version: "0.1.0", Repository: "http://charts.example.com/charts"}},
+		},
+	}
+	require.NoError(t, testutil.WriteChartRepository(repositoryDir, subchart, parent))
+
+	registry := checks.NewRegistry().AddCheck("has-valid-icon", checks.Check{Func: checks.HasValidIcon, Type: checks.MandatoryCheckType})
+	config := viper.New()
+	config.Set("has-valid-icon."+checks.AllowNetworkConfigKey, true)
+
+	t.Run("Should perform every request with the informed transport", func(t *testing.T) {
+		transport := &replayingTransport{dir: repositoryDir}
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			SetChecks([]string{"has-valid-icon"}).
+			SetConfig(config).
+			SetBuildDependencies(true).
+			SetHTTPTransport(transport).
+			Build()
+		require.NoError(t, err)
+
+		r, err := c.Verify(context.Background(), "http://charts.example.com/charts/parent-0.1.0.tgz")
+		require.NoError(t, err)
+		require.True(t, r.IsOk())
+		require.ElementsMatch(t, []string{
+			"HEAD http://charts.example.com/charts/parent-0.1.0.tgz",
+			"GET http://charts.example.com/charts/parent-0.1.0.tgz",
+			"GET http://charts.example.com/charts/index.yaml",
+			"GET http://charts.example.com/charts/subchart-0.1.0.tgz",
+			"GET https://icons.example.com/parent.png",
+		}, transport.requests)
+	})
+
+	t.Run("Should not inform a transport to checks by default", func(t *testing.T) {
+		var informed interface{}
+		c, err := NewCertifierBuilder().
+			SetRegistry(checks.NewRegistry().Add("recording-check", func(uri string, config *viper.Viper) (checks.Result, error) {
+				informed = config.Get(checks.HTTPTransportConfigKey)
+				return checks.NewResult(true, "recorded"), nil
+			})).
+			SetChecks([]string{"recording-check"}).
+			Build()
+		require.NoError(t, err)
+
+		_, err = c.Certify("./checks/chart-0.1.0-v3.valid.tgz")
+		require.NoError(t, err)
+		require.Nil(t, informed)
+	})
+}
+
+func TestCertifier_SuggestFixes(t *testing.T) {
+	uri := "./checks/chart-0.1.0-v3.valid.tgz"
+
+	for _, suggestFixes := range []bool{false, true} {
+		c, err := NewCertifierBuilder().
+			SetChecks([]string{"revision-history-bounded"}).
+			SetSuggestFixes(suggestFixes).
+			Build()
+		require.NoError(t, err)
+
+		r, err := c.Verify(context.Background(), uri)
+		require.NoError(t, err)
+		cr := r.CheckResultMap["revision-history-bounded"]
+		require.False(t, cr.Ok)
+		if suggestFixes {
+			require.Equal(t, []checks.Suggestion{{
+				Resource:    "Deployment/testRelease-chart",
+				Description: "Keep at most 10 old ReplicaSets",
+				Patch:       "spec:\n  revisionHistoryLimit: 10\n",
+			}}, cr.Suggestions)
+		} else {
+			require.Empty(t, cr.Suggestions)
+		}
+	}
+}
+
+func TestCertifier_CheckType(t *testing.T) {
+	uri := "./checks/chart-0.1.0-v3.valid.tgz"
+
+	t.Run("Should report the registered type by default", func(t *testing.T) {
+		c, err := NewCertifierBuilder().
+			SetChecks([]string{"token-automount-disabled"}).
+			Build()
+		require.NoError(t, err)
+
+		r, err := c.Verify(context.Background(), uri)
+		require.NoError(t, err)
+		require.Equal(t, checks.OptionalCheckType, r.CheckResultMap["token-automount-disabled"].Type)
+	})
+
+	t.Run("Should report the configured type", func(t *testing.T) {
+		c, err := NewCertifierBuilder().
+			SetChecks([]string{"token-automount-disabled"}).
+			SetOverrides([]string{"checks.token-automount-disabled.type=mandatory"}).
+			Build()
+		require.NoError(t, err)
+
+		r, err := c.Verify(context.Background(), uri)
+		require.NoError(t, err)
+		require.Equal(t, checks.MandatoryCheckType, r.CheckResultMap["token-automount-disabled"].Type)
+	})
+
+	t.Run("Should fail building when the configured type is unknown", func(t *testing.T) {
+		_, err := NewCertifierBuilder().
+			SetChecks([]string{"token-automount-disabled"}).
+			SetOverrides([]string{"checks.token-automount-disabled.type=required"}).
+			Build()
+		require.EqualError(t, err, `type "required" of check token-automount-disabled must be either mandatory or optional`)
+	})
+}
@@ -17,10 +17,16 @@
 package chartverifier
 
 import (
-	"errors"
+	"net/http"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/viper"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/strvals"
 
 	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
 )
@@ -34,11 +40,55 @@ func init() {
 	defaultRegistry.Add("contains-test", checks.ContainsTest)
 	defaultRegistry.Add("contains-values", checks.ContainsValues)
 	defaultRegistry.Add("contains-values-schema", checks.ContainsValuesSchema)
-	defaultRegistry.Add("has-minkubeversion", checks.HasMinKubeVersion)
+	defaultRegistry.AddCheck("has-minkubeversion", checks.Check{Func: checks.HasMinKubeVersion, Type: checks.MandatoryCheckType, RequiresOpenShiftVersion: true})
 	defaultRegistry.Add("not-contains-crds", checks.NotContainCRDs)
-	defaultRegistry.Add("helm-lint", checks.HelmLint)
+	defaultRegistry.AddCheck("helm-lint", checks.Check{Func: checks.HelmLint, Type: checks.MandatoryCheckType, RendersTemplates: true})
 	defaultRegistry.Add("not-contain-csi-objects", checks.NotContainCSIObjects)
-	defaultRegistry.Add("images-are-certified", checks.ImagesAreCertified)
+	defaultRegistry.AddCheck("images-are-certified", checks.Check{Func: checks.ImagesAreCertified, Type: checks.MandatoryCheckType, RequiresNetwork: true})
+	defaultRegistry.Add("version-is-semver", checks.VersionIsSemver)
+	defaultRegistry.AddCheck("has-valid-icon", checks.Check{Func: checks.HasValidIcon, Type: checks.OptionalCheckType})
+	defaultRegistry.AddCheck("install-succeeds", checks.Check{Func: checks.InstallSucceeds, Type: checks.OptionalCheckType, RequiresCluster: true, RequiresOpenShiftVersion: true})
+	defaultRegistry.AddCheck("referenced-configmaps-exist", checks.Check{Func: checks.ReferencedConfigMapsExist, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("chart-size-reasonable", checks.Check{Func: checks.ChartSizeReasonable, Type: checks.OptionalCheckType})
+	defaultRegistry.AddCheck("readme-documents-values", checks.Check{Func: checks.ReadmeDocumentsValues, Type: checks.OptionalCheckType})
+	defaultRegistry.AddCheck("no-nodeport-services", checks.Check{Func: checks.NoNodePortServices, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("images-airgap-ready", checks.Check{Func: checks.ImagesAirgapReady, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("pdb-configured", checks.Check{Func: checks.PdbConfigured, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("no-secrets-in-values", checks.Check{Func: checks.NoSecretsInValues, Type: checks.OptionalCheckType})
+	defaultRegistry.AddCheck("ha-antiaffinity", checks.Check{Func: checks.HaAntiAffinity, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("rbac-least-privilege", checks.Check{Func: checks.RbacLeastPrivilege, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("helm-tests-pass", checks.Check{Func: checks.HelmTestsPass, Type: checks.OptionalCheckType, RequiresCluster: true})
+	defaultRegistry.AddCheck("dependencies-from-trusted-repos", checks.Check{Func: checks.DependenciesFromTrustedRepos, Type: checks.OptionalCheckType})
+	defaultRegistry.AddCheck("pvc-storage-declared", checks.Check{Func: checks.PvcStorageDeclared, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("pods-run-as-nonroot", checks.Check{Func: checks.PodsRunAsNonroot, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("has-project-metadata", checks.Check{Func: checks.HasProjectMetadata, Type: checks.OptionalCheckType})
+	defaultRegistry.AddCheck("crds-have-structural-schema", checks.Check{Func: checks.CrdsHaveStructuralSchema, Type: checks.OptionalCheckType})
+	defaultRegistry.AddCheck("olm-annotations-valid", checks.Check{Func: checks.OlmAnnotationsValid, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("workloads-use-serviceaccount", checks.Check{Func: checks.WorkloadsUseServiceaccount, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("ingress-hosts-valid", checks.Check{Func: checks.IngressHostsValid, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("resource-scope-correct", checks.Check{Func: checks.ResourceScopeCorrect, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("images-from-approved-registries", checks.Check{Func: checks.ImagesFromApprovedRegistries, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("update-strategy-declared", checks.Check{Func: checks.UpdateStrategyDeclared, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("helm-hooks-valid", checks.Check{Func: checks.HelmHooksValid, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("has-license", checks.Check{Func: checks.HasLicense, Type: checks.OptionalCheckType})
+	defaultRegistry.AddCheck("secrets-are-external", checks.Check{Func: checks.SecretsAreExternal, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("no-podsecuritypolicy", checks.Check{Func: checks.NoPodSecurityPolicy, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("no-plaintext-env-secrets", checks.Check{Func: checks.NoPlaintextEnvSecrets, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("statefulset-topology-valid", checks.Check{Func: checks.StatefulSetTopologyIsValid, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("values-types-consistent", checks.Check{Func: checks.ValuesTypesConsistent, Type: checks.OptionalCheckType})
+	defaultRegistry.AddCheck("chart-repackages-cleanly", checks.Check{Func: checks.ChartRepackagesWithoutChanges, Type: checks.OptionalCheckType})
+	defaultRegistry.AddCheck("images-declare-nonroot-user", checks.Check{Func: checks.ImagesDeclareNonRootUsers, Type: checks.OptionalCheckType, RendersTemplates: true, RequiresNetwork: true})
+	defaultRegistry.AddCheck("replica-count-sane", checks.Check{Func: checks.ReplicaCountSane, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("annotation-format-valid", checks.Check{Func: checks.AnnotationFormatValid, Type: checks.OptionalCheckType})
+	defaultRegistry.AddCheck("jobs-configured", checks.Check{Func: checks.JobsConfigured, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("scc-references-valid", checks.Check{Func: checks.SCCReferencesValid, Type: checks.OptionalCheckType, RendersTemplates: true, RequiresOpenShiftVersion: true})
+	defaultRegistry.AddCheck("appversion-matches-image-tag", checks.Check{Func: checks.AppVersionMatchesImageTag, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("values-consistent-casing", checks.Check{Func: checks.ValuesConsistentCasing, Type: checks.OptionalCheckType})
+	defaultRegistry.AddCheck("probe-parameters-sane", checks.Check{Func: checks.ProbeParametersSane, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("no-duplicate-resources", checks.Check{Func: checks.NoDuplicateResources, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("revision-history-bounded", checks.Check{Func: checks.RevisionHistoryBounded, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("statefulset-servicename-valid", checks.Check{Func: checks.StatefulsetServicenameValid, Type: checks.OptionalCheckType, RendersTemplates: true})
+	defaultRegistry.AddCheck("token-automount-disabled", checks.Check{Func: checks.TokenAutomountDisabled, Type: checks.OptionalCheckType, RendersTemplates: true})
 }
 
 func DefaultRegistry() checks.Registry {
@@ -46,11 +96,34 @@ func DefaultRegistry() checks.Registry {
 }
 
 type certifierBuilder struct {
-	checks      []string
-	config      *viper.Viper
-	overrides   []string
-	registry    checks.Registry
-	toolVersion string
+	checks            []string
+	config            *viper.Viper
+	overrides         []string
+	valueOverrides    []string
+	stringOverrides   []string
+	registry          checks.Registry
+	toolVersion       string
+	onCheckComplete   CheckCompleteFunc
+	failOn            FailOn
+	openShiftVersions []string
+	kubeVersion       string
+	annotations       map[string]string
+	continueOnError   bool
+	credentials       checks.Credentials
+	httpTransport     http.RoundTripper
+	valuesProfiles    map[string]chartutil.Values
+	clock             func() time.Time
+	noNetwork         bool
+	noCluster         bool
+	metadataOnly      bool
+	maxRequestsPerSec float64
+	failFast          bool
+	buildDependencies bool
+	suggestFixes      bool
+	checkpoint        *Checkpoint
+	metricsRegisterer prometheus.Registerer
+	totalTimeout      time.Duration
+	maxConcurrent     int
 }
 
 func (b *certifierBuilder) SetRegistry(registry checks.Registry) CertifierBuilder {
@@ -73,12 +146,133 @@ func (b *certifierBuilder) SetOverrides(overrides []string) CertifierBuilder {
 	return b
 }
 
+func (b *certifierBuilder) SetValueOverrides(overrides []string) CertifierBuilder {
+	b.valueOverrides = overrides
+	return b
+}
+
+func (b *certifierBuilder) SetStringValueOverrides(overrides []string) CertifierBuilder {
+	b.stringOverrides = overrides
+	return b
+}
+
 func (b *certifierBuilder) SetToolVersion(version string) CertifierBuilder {
 	b.toolVersion = version
 	return b
 }
 
+func (b *certifierBuilder) SetOnCheckComplete(onCheckComplete CheckCompleteFunc) CertifierBuilder {
+	b.onCheckComplete = onCheckComplete
+	return b
+}
+
+func (b *certifierBuilder) SetFailOn(failOn FailOn) CertifierBuilder {
+	b.failOn = failOn
+	return b
+}
+
+func (b *certifierBuilder) SetAnnotations(annotations map[string]string) CertifierBuilder {
+	b.annotations = annotations
+	return b
+}
+
+func (b *certifierBuilder) SetContinueOnCheckError(continueOnError bool) CertifierBuilder {
+	b.continueOnError = continueOnError
+	return b
+}
+
+func (b *certifierBuilder) SetCredentials(credentials checks.Credentials) CertifierBuilder {
+	b.credentials = credentials
+	return b
+}
+
+func (b *certifierBuilder) SetHTTPTransport(transport http.RoundTripper) CertifierBuilder {
+	b.httpTransport = transport
+	return b
+}
+
+func (b *certifierBuilder) SetValuesProfiles(profiles map[string]chartutil.Values) CertifierBuilder {
+	b.valuesProfiles = profiles
+	return b
+}
+
+func (b *certifierBuilder) SetNoNetwork(noNetwork bool) CertifierBuilder {
+	b.noNetwork = noNetwork
+	return b
+}
+
+func (b *certifierBuilder) SetNoCluster(noCluster bool) CertifierBuilder {
+	b.noCluster = noCluster
+	return b
+}
+
+func (b *certifierBuilder) SetMetadataOnly(metadataOnly bool) CertifierBuilder {
+	b.metadataOnly = metadataOnly
+	return b
+}
+
+func (b *certifierBuilder) SetCheckpoint(checkpoint *Checkpoint) CertifierBuilder {
+	b.checkpoint = checkpoint
+	return b
+}
+
+func (b *certifierBuilder) SetMaxConcurrentVerifications(maxConcurrent int) CertifierBuilder {
+	b.maxConcurrent = maxConcurrent
+	return b
+}
+
+func (b *certifierBuilder) SetTotalTimeout(timeout time.Duration) CertifierBuilder {
+	b.totalTimeout = timeout
+	return b
+}
+
+func (b *certifierBuilder) SetMetricsRegisterer(registerer prometheus.Registerer) CertifierBuilder {
+	b.metricsRegisterer = registerer
+	return b
+}
+
+func (b *certifierBuilder) SetFailFast(failFast bool) CertifierBuilder {
+	b.failFast = failFast
+	return b
+}
+
+func (b *certifierBuilder) SetBuildDependencies(buildDependencies bool) CertifierBuilder {
+	b.buildDependencies = buildDependencies
+	return b
+}
+
+func (b *certifierBuilder) SetSuggestFixes(suggestFixes bool) CertifierBuilder {
+	b.suggestFixes = suggestFixes
+	return b
+}
+
+func (b *certifierBuilder) SetMaxRequestsPerSecond(maxRequestsPerSecond float64) CertifierBuilder {
+	b.maxRequestsPerSec = maxRequestsPerSecond
+	return b
+}
+
+func (b *certifierBuilder) SetClock(clock func() time.Time) CertifierBuilder {
+	b.clock = clock
+	return b
+}
+
+func (b *certifierBuilder) SetOpenShiftVersions(versions []string) CertifierBuilder {
+	b.openShiftVersions = versions
+	return b
+}
+
+func (b *certifierBuilder) SetKubeVersion(version string) CertifierBuilder {
+	b.kubeVersion = version
+	return b
+}
+
+// Build creates a Certifier using the informed configuration. When a custom registry has been set without requiring
+// any checks, all checks contained in the custom registry are required.
 func (b *certifierBuilder) Build() (Certifier, error) {
+	if len(b.checks) == 0 && b.registry != nil {
+		b.checks = b.registry.AllChecks()
+	}
+
 	if len(b.checks) == 0 {
 		return nil, errors.New("no checks have been required")
 	}
@@ -91,18 +285,127 @@ func (b *certifierBuilder) Build() (Certifier, error) {
 		b.config = viper.New()
 	}
 
+	if b.clock == nil {
+		b.clock = time.Now
+	}
+
+	if b.failOn == "" {
+		b.failOn = FailOnMandatory
+	} else if _, err := ParseFailOn(string(b.failOn)); err != nil {
+		return nil, err
+	}
+
+	for _, v := range b.openShiftVersions {
+		if _, err := checks.KubeVersionForOpenShift(v); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := checks.CapabilitiesForKubeVersion(b.kubeVersion); err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := checks.NewTLSConfig(b.credentials.CAFile, b.credentials.InsecureSkipTLSVerify)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := b.parseValueOverrides()
+	if err != nil {
+		return nil, err
+	}
+
+	// profiles are sorted by name, so results are reported in a stable order
+	profileNames := make([]string, 0, len(b.valuesProfiles))
+	for name := range b.valuesProfiles {
+		profileNames = append(profileNames, name)
+	}
+	sort.Strings(profileNames)
+
+	profiles := make([]valuesProfile, 0, len(profileNames))
+	for _, name := range profileNames {
+		// value overrides take precedence over the profile's values, as Helm's --set does over --values
+		profileValues, err := b.parseValueOverrides()
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, valuesProfile{name: name, values: chartutil.CoalesceTables(profileValues, b.valuesProfiles[name])})
+	}
+
 	// naively override values from the configuration
 	for _, val := range b.overrides {
-		parts := strings.Split(val, "=")
+		parts := strings.SplitN(val, "=", 2)
 		b.config.Set(parts[0], parts[1])
 	}
 
-	return &certifier{
-		registry:       b.registry,
-		requiredChecks: b.checks,
-		config:         b.config,
-		toolVersion:    b.toolVersion,
-	}, nil
+	for _, name := range b.checks {
+		t := b.config.GetString(checksConfigKey + "." + name + "." + typeConfigKey)
+		if t != "" && t != string(checks.MandatoryCheckType) && t != string(checks.OptionalCheckType) {
+			return nil, errors.Errorf("type %q of check %s must be either %s or %s", t, name, checks.MandatoryCheckType, checks.OptionalCheckType)
+		}
+	}
+
+	var metrics *verifierMetrics
+	if b.metricsRegisterer != nil {
+		if metrics, err = newVerifierMetrics(b.metricsRegisterer); err != nil {
+			return nil, errors.Wrap(err, "failed registering metrics")
+		}
+	}
+
+	credentials := b.credentials
+	if b.httpTransport != nil {
+		credentials.Transport = b.httpTransport
+	}
+
+	c := &certifier{
+		registry:          b.registry,
+		requiredChecks:    b.checks,
+		config:            b.config,
+		toolVersion:       b.toolVersion,
+		onCheckComplete:   b.onCheckComplete,
+		failOn:            b.failOn,
+		openShiftVersions: b.openShiftVersions,
+		kubeVersion:       b.kubeVersion,
+		annotations:       b.annotations,
+		continueOnError:   b.continueOnError,
+		credentials:       credentials,
+		values:            values,
+		valuesProfiles:    profiles,
+		clock:             b.clock,
+		noNetwork:         b.noNetwork,
+		noCluster:         b.noCluster,
+		metadataOnly:      b.metadataOnly,
+		failFast:          b.failFast,
+		buildDependencies: b.buildDependencies,
+		suggestFixes:      b.suggestFixes,
+		totalTimeout:      b.totalTimeout,
+		maxConcurrent:     b.maxConcurrent,
+		rateLimiter:       checks.NewRateLimiter(b.maxRequestsPerSec),
+		tlsConfig:         tlsConfig,
+		checkpoint:        b.checkpoint,
+		metrics:           metrics,
+	}
+	if c.checkpoint != nil {
+		c.checkpointFingerprint = c.fingerprint()
+	}
+	return c, nil
+}
+
+// parseValueOverrides parses the informed value overrides; chart values are parsed as Helm does, so types are
+// preserved.
+func (b *certifierBuilder) parseValueOverrides() (chartutil.Values, error) {
+	values := chartutil.Values{}
+	for _, val := range b.valueOverrides {
+		if err := strvals.ParseInto(val, values); err != nil {
+			return nil, errors.Wrap(err, "failed parsing value override")
+		}
+	}
+	for _, val := range b.stringOverrides {
+		if err := strvals.ParseIntoString(val, values); err != nil {
+			return nil, errors.Wrap(err, "failed parsing string value override")
+		}
+	}
+	return values, nil
 }
 
 func NewCertifierBuilder() CertifierBuilder {
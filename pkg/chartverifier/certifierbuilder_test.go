@@ -17,9 +17,13 @@
 package chartverifier
 
 import (
+	"crypto/tls"
 	"testing"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
+
+	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
 )
 
 func TestCertificationBuilder(t *testing.T) {
@@ -42,4 +46,91 @@ func TestCertificationBuilder(t *testing.T) {
 		require.NoError(t, err)
 		require.NotNil(t, c)
 	})
+
+	t.Run("Should fail building certifier when custom registry is empty", func(t *testing.T) {
+		b := NewCertifierBuilder()
+
+		c, err := b.
+			SetRegistry(checks.NewRegistry()).
+			Build()
+
+		require.Error(t, err)
+		require.Nil(t, c)
+	})
+
+	t.Run("Should only execute checks from the custom registry", func(t *testing.T) {
+		executed := map[string]bool{}
+		newCheck := func(name string) checks.CheckFunc {
+			return func(uri string, _ *viper.Viper) (checks.Result, error) {
+				executed[name] = true
+				return checks.NewResult(true, name), nil
+			}
+		}
+
+		registry := checks.NewRegistry().
+			Add("first-check", newCheck("first-check")).
+			Add("second-check", newCheck("second-check"))
+
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			Build()
+		require.NoError(t, err)
+		require.NotNil(t, c)
+
+		r, err := c.Certify("./checks/chart-0.1.0-v3.valid.tgz")
+		require.NoError(t, err)
+		require.NotNil(t, r)
+		require.True(t, r.IsOk())
+
+		require.Equal(t, map[string]bool{"first-check": true, "second-check": true}, executed)
+		require.Len(t, r.(*certificate).CheckResultMap, 2)
+		require.Contains(t, r.(*certificate).CheckResultMap, "first-check")
+		require.Contains(t, r.(*certificate).CheckResultMap, "second-check")
+	})
+
+	t.Run("Should fail certifying when a required check is not in the custom registry", func(t *testing.T) {
+		registry := checks.NewRegistry().
+			Add("first-check", func(uri string, _ *viper.Viper) (checks.Result, error) {
+				return checks.NewResult(true, ""), nil
+			})
+
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			SetChecks([]string{"has-readme"}).
+			Build()
+		require.NoError(t, err)
+		require.NotNil(t, c)
+
+		r, err := c.Certify("./checks/chart-0.1.0-v3.valid.tgz")
+		require.Error(t, err)
+		require.Equal(t, CheckNotFoundErr("has-readme"), err)
+		require.Nil(t, r)
+	})
+
+	t.Run("Should fail building certifier when the CA file can't be read", func(t *testing.T) {
+		c, err := NewCertifierBuilder().
+			SetChecks([]string{"has-readme"}).
+			SetCredentials(checks.Credentials{CAFile: "./checks/missing-ca.pem"}).
+			Build()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed reading CA file")
+		require.Nil(t, c)
+	})
+
+	t.Run("Should inform the TLS settings to checks", func(t *testing.T) {
+		var tlsConfig interface{}
+		c, err := NewCertifierBuilder().
+			SetRegistry(checks.NewRegistry().Add("tls-check", func(uri string, config *viper.Viper) (checks.Result, error) {
+				tlsConfig = config.Get(checks.TLSConfigKey)
+				return checks.NewResult(true, ""), nil
+			})).
+			SetCredentials(checks.Credentials{InsecureSkipTLSVerify: true}).
+			Build()
+		require.NoError(t, err)
+
+		_, err = c.Certify("./checks/chart-0.1.0-v3.valid.tgz")
+		require.NoError(t, err)
+		require.IsType(t, &tls.Config{}, tlsConfig)
+		require.True(t, tlsConfig.(*tls.Config).InsecureSkipVerify)
+	})
 }
@@ -0,0 +1,153 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
+)
+
+// checkpointEntry is the outcome of a check completed within a verification, along with what identifies the
+// verification: the digest of the verified chart and the fingerprint of the certifier's options.
+type checkpointEntry struct {
+	Fingerprint string        `json:"fingerprint"`
+	Digest      string        `json:"digest"`
+	Check       string        `json:"check"`
+	Result      checks.Result `json:"result"`
+	// OpenShiftVersions contains the results per OpenShift version of checks requiring an OpenShift version.
+	OpenShiftVersions map[string]checks.Result `json:"openshift-versions,omitempty"`
+	// ValuesProfiles contains the results per values profile of checks rendering the chart's templates.
+	ValuesProfiles map[string]checks.Result `json:"values-profiles,omitempty"`
+}
+
+// Checkpoint persists the outcome of each completed check as a JSON line, so a verification interrupted mid-way can
+// be resumed without executing the completed checks again. Outcomes are keyed by chart digest and check name, and
+// only reused by certifiers whose options and tool version match the ones the outcomes were produced with.
+type Checkpoint struct {
+	path    string
+	mutex   sync.Mutex
+	entries map[string]checkpointEntry
+}
+
+// NewCheckpoint returns the checkpoint persisted at path; when resume is set, the outcomes already persisted are
+// reused, otherwise the checkpoint is reset. Lines which can't be parsed, such as a line only partially written when
+// the verification was interrupted, are ignored.
+func NewCheckpoint(path string, resume bool) (*Checkpoint, error) {
+	cp := &Checkpoint{path: path, entries: map[string]checkpointEntry{}}
+
+	if !resume {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed resetting checkpoint %s: %v", path, err)
+		}
+		return cp, nil
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry checkpointEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		cp.entries[checkpointKey(entry.Fingerprint, entry.Digest, entry.Check)] = entry
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed reading checkpoint %s: %v", path, err)
+	}
+	return cp, nil
+}
+
+func checkpointKey(fingerprint, digest, check string) string {
+	return fingerprint + "/" + digest + "/" + check
+}
+
+// get returns the persisted outcome of the given check, if any; a nil checkpoint contains no outcome.
+func (cp *Checkpoint) get(fingerprint, digest, check string) (checkpointEntry, bool) {
+	if cp == nil {
+		return checkpointEntry{}, false
+	}
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+	entry, ok := cp.entries[checkpointKey(fingerprint, digest, check)]
+	return entry, ok
+}
+
+// record persists the given outcome, synced to disk before returning; recording to a nil checkpoint does nothing.
+func (cp *Checkpoint) record(entry checkpointEntry) error {
+	if cp == nil {
+		return nil
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+
+	f, err := os.OpenFile(cp.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("failed writing checkpoint %s: %v", cp.path, err)
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	cp.entries[checkpointKey(entry.Fingerprint, entry.Digest, entry.Check)] = entry
+	return nil
+}
+
+// fingerprint identifies the options and tool version affecting the outcome of the certifier's checks, so outcomes
+// persisted by a differently configured certifier aren't reused.
+func (c *certifier) fingerprint() string {
+	var settings map[string]interface{}
+	if c.config != nil {
+		settings = c.config.AllSettings()
+	}
+	profiles := make([]string, len(c.valuesProfiles))
+	for i, p := range c.valuesProfiles {
+		profiles[i] = fmt.Sprintf("%s=%v", p.name, p.values)
+	}
+
+	// maps are printed sorted by key, so equal options are printed the same way
+	options := fmt.Sprintf("%v|%v|%v|%v|%v|%v|%v|%v|%v|%v|%v|%v|%v|%v",
+		c.toolVersion, settings, c.values, profiles, c.openShiftVersions, c.kubeVersion, c.continueOnError,
+		c.noNetwork, c.noCluster, c.metadataOnly, c.credentials.CAFile, c.credentials.InsecureSkipTLSVerify,
+		c.buildDependencies, c.suggestFixes)
+	h := sha256.Sum256([]byte(options))
+	return hex.EncodeToString(h[:])
+}
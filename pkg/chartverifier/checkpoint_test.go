@@ -0,0 +1,177 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
+)
+
+func TestCertifier_Checkpoint(t *testing.T) {
+	uri := "./checks/chart-0.1.0-v3.valid.tgz"
+	requiredChecks := []string{"first-check", "versioned-check", "interrupted-check", "last-check"}
+
+	calls := map[string]int{}
+	interrupt := true
+	counting := func(name string) checks.CheckFunc {
+		return func(uri string, config *viper.Viper) (checks.Result, error) {
+			calls[name]++
+			if name == "interrupted-check" && interrupt {
+				return checks.Result{}, errors.New("interrupted")
+			}
+			return checks.NewResult(true, name+" "+config.GetString(checks.OpenShiftVersionConfigKey)), nil
+		}
+	}
+	registry := checks.NewRegistry().
+		Add("first-check", counting("first-check")).
+		AddCheck("versioned-check", checks.Check{
+			Func:                     counting("versioned-check"),
+			Type:                     checks.MandatoryCheckType,
+			RequiresOpenShiftVersion: true,
+		}).
+		Add("interrupted-check", counting("interrupted-check")).
+		Add("last-check", counting("last-check"))
+
+	certify := func(t *testing.T, path string, resume bool, toolVersion string) (*certificate, error) {
+		calls = map[string]int{}
+		checkpoint, err := NewCheckpoint(path, resume)
+		require.NoError(t, err)
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			SetChecks(requiredChecks).
+			SetOpenShiftVersions([]string{"4.12", "4.13"}).
+			SetToolVersion(toolVersion).
+			SetCheckpoint(checkpoint).
+			Build()
+		require.NoError(t, err)
+		r, err := c.Certify(uri)
+		if err != nil {
+			return nil, err
+		}
+		return r.(*certificate), nil
+	}
+
+	interruptedRun := func(t *testing.T) string {
+		path := filepath.Join(t.TempDir(), "checkpoint.jsonl")
+		interrupt = true
+		_, err := certify(t, path, false, "1.0.0")
+		require.Error(t, err)
+		require.Equal(t, map[string]int{"first-check": 1, "versioned-check": 2, "interrupted-check": 1}, calls)
+		interrupt = false
+		return path
+	}
+
+	t.Run("Should only execute the checks not completed before the interruption when resuming", func(t *testing.T) {
+		path := interruptedRun(t)
+
+		r, err := certify(t, path, true, "1.0.0")
+		require.NoError(t, err)
+		require.Equal(t, map[string]int{"interrupted-check": 1, "last-check": 1}, calls)
+
+		require.True(t, r.Ok)
+		require.Equal(t, summary{Passed: len(requiredChecks)}, r.Summary)
+		require.Equal(t, "first-check ", r.CheckResultMap["first-check"].Reason)
+		require.Equal(t, map[string]versionCheckResult{
+			"4.12": {Ok: true, Reason: "versioned-check 4.12"},
+			"4.13": {Ok: true, Reason: "versioned-check 4.13"},
+		}, r.CheckResultMap["versioned-check"].OpenShiftVersions)
+		require.Equal(t, []string{"4.12", "4.13"}, r.Metadata.RunMetadata.CertifiedOpenShiftVersions)
+
+		_, err = certify(t, path, true, "1.0.0")
+		require.NoError(t, err)
+		require.Empty(t, calls)
+	})
+
+	t.Run("Should execute all checks when the tool version has changed", func(t *testing.T) {
+		path := interruptedRun(t)
+
+		_, err := certify(t, path, true, "1.1.0")
+		require.NoError(t, err)
+		require.Equal(t, map[string]int{"first-check": 1, "versioned-check": 2, "interrupted-check": 1, "last-check": 1}, calls)
+	})
+
+	t.Run("Should execute all checks when the options have changed", func(t *testing.T) {
+		path := interruptedRun(t)
+
+		calls = map[string]int{}
+		checkpoint, err := NewCheckpoint(path, true)
+		require.NoError(t, err)
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			SetChecks(requiredChecks).
+			SetOpenShiftVersions([]string{"4.12", "4.13"}).
+			SetToolVersion("1.0.0").
+			SetOverrides([]string{"first-check.allowNetwork=true"}).
+			SetCheckpoint(checkpoint).
+			Build()
+		require.NoError(t, err)
+		_, err = c.Certify(uri)
+		require.NoError(t, err)
+		require.Equal(t, map[string]int{"first-check": 1, "versioned-check": 2, "interrupted-check": 1, "last-check": 1}, calls)
+	})
+
+	t.Run("Should execute all checks when not resuming", func(t *testing.T) {
+		path := interruptedRun(t)
+
+		_, err := certify(t, path, false, "1.0.0")
+		require.NoError(t, err)
+		require.Equal(t, map[string]int{"first-check": 1, "versioned-check": 2, "interrupted-check": 1, "last-check": 1}, calls)
+	})
+
+	t.Run("Should ignore a partially written outcome when resuming", func(t *testing.T) {
+		path := interruptedRun(t)
+
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		require.NoError(t, err)
+		_, err = f.WriteString(`{"fingerprint":"`)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		_, err = certify(t, path, true, "1.0.0")
+		require.NoError(t, err)
+		require.Equal(t, map[string]int{"interrupted-check": 1, "last-check": 1}, calls)
+	})
+}
+
+func TestNewCheckpoint(t *testing.T) {
+	t.Run("Should reset an existing checkpoint when not resuming", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "checkpoint.jsonl")
+		require.NoError(t, ioutil.WriteFile(path, []byte(`{"fingerprint":"f","digest":"d","check":"c","result":{"Ok":true}}`+"\n"), 0644))
+
+		cp, err := NewCheckpoint(path, false)
+		require.NoError(t, err)
+		_, ok := cp.get("f", "d", "c")
+		require.False(t, ok)
+		_, err = os.Stat(path)
+		require.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("Should start empty when resuming without a checkpoint", func(t *testing.T) {
+		cp, err := NewCheckpoint(filepath.Join(t.TempDir(), "checkpoint.jsonl"), true)
+		require.NoError(t, err)
+		_, ok := cp.get("f", "d", "c")
+		require.False(t, ok)
+	})
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/spf13/viper"
+)
+
+const (
+	CertificationAnnotationsValid    = "Certification annotations are well formed"
+	CertificationAnnotationsSkipped  = "Chart does not declare certification annotations"
+	CertificationAnnotationMalformed = "Certification annotation is malformed"
+)
+
+// certificationAnnotationPrefix is the prefix of the Chart.yaml annotations carrying certification metadata.
+const certificationAnnotationPrefix = "charts.openshift.io/"
+
+// supportedArchitectures are the architectures OpenShift runs on.
+var supportedArchitectures = []string{"amd64", "arm64", "ppc64le", "s390x"}
+
+// annotationFormat validates an annotation value, returning why it is malformed or an empty string when it isn't.
+type annotationFormat func(value string) string
+
+// certificationAnnotationFormats maps the known certification annotations, without their prefix, to their format.
+var certificationAnnotationFormats = map[string]annotationFormat{
+	"name":                       textFormat,
+	"provider":                   textFormat,
+	"supportURL":                 urlFormat,
+	"supportedArchitectures":     architecturesFormat,
+	"supportedOpenShiftVersions": constraintFormat,
+	"testedOpenShiftVersion":     versionFormat,
+	"requiresClusterAdmin":       booleanFormat,
+	"disconnectedSupported":      booleanFormat,
+}
+
+func textFormat(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return "should not be empty"
+	}
+	return ""
+}
+
+func urlFormat(value string) string {
+	if u, err := url.Parse(value); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Sprintf("%q should be an absolute http or https URL", value)
+	}
+	return ""
+}
+
+func architecturesFormat(value string) string {
+	var unknown []string
+	for _, arch := range strings.Split(value, ",") {
+		if arch = strings.TrimSpace(arch); !containsString(supportedArchitectures, arch) {
+			unknown = append(unknown, fmt.Sprintf("%q", arch))
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Sprintf("%q should be a comma separated list of: %s, but contains %s", value,
+			strings.Join(supportedArchitectures, ", "), strings.Join(unknown, ", "))
+	}
+	return ""
+}
+
+func constraintFormat(value string) string {
+	if _, err := semver.NewConstraint(value); err != nil {
+		return fmt.Sprintf("%q should be a version range, e.g. >=4.7: %v", value, err)
+	}
+	return ""
+}
+
+func versionFormat(value string) string {
+	if _, err := semver.NewVersion(value); err != nil {
+		return fmt.Sprintf("%q should be a version, e.g. 4.8: %v", value, err)
+	}
+	return ""
+}
+
+func booleanFormat(value string) string {
+	if value != "true" && value != "false" {
+		return fmt.Sprintf("%q should be either \"true\" or \"false\"", value)
+	}
+	return ""
+}
+
+// AnnotationFormatValid checks the certification annotations declared in the chart's Chart.yaml follow their
+// expected formats; annotations using the certification prefix but not known are reported as warnings.
+func AnnotationFormatValid(uri string, _ *viper.Viper) (Result, error) {
+	c, _, err := LoadChartFromURI(uri)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var keys []string
+	for k := range c.Metadata.Annotations {
+		if strings.HasPrefix(k, certificationAnnotationPrefix) {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return NewResult(true, CertificationAnnotationsSkipped), nil
+	}
+	sort.Strings(keys)
+
+	r := NewResult(true, CertificationAnnotationsValid)
+	for _, k := range keys {
+		format, ok := certificationAnnotationFormats[strings.TrimPrefix(k, certificationAnnotationPrefix)]
+		if !ok {
+			r.AddFinding(Finding{
+				Resource: "Chart.yaml",
+				Field:    "annotations." + k,
+				Message:  fmt.Sprintf("Annotation %s is not a known certification annotation", k),
+				Severity: WarningSeverity,
+			})
+			continue
+		}
+
+		if message := format(c.Metadata.Annotations[k]); message != "" {
+			addFailure(&r, fmt.Sprintf("%s : %s %s", CertificationAnnotationMalformed, k, message))
+			r.AddFinding(Finding{
+				Resource: "Chart.yaml",
+				Field:    "annotations." + k,
+				Message:  fmt.Sprintf("Annotation %s %s", k, message),
+				Severity: ErrorSeverity,
+			})
+		}
+	}
+
+	return r, nil
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotationFormatValid(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		reason      string
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart with well formed certification annotations", uri: "chart-0.1.0-v3.certification-annotations.tgz", reason: CertificationAnnotationsValid},
+		{description: "chart without certification annotations", uri: "chart-0.1.0-v3.valid.tgz", reason: CertificationAnnotationsSkipped},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			r, err := AnnotationFormatValid(tc.uri, viper.New())
+			require.NoError(t, err)
+			require.True(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	t.Run("chart with malformed certification annotations", func(t *testing.T) {
+		r, err := AnnotationFormatValid("chart-0.1.0-v3.certification-annotations-invalid.tgz", viper.New())
+		require.NoError(t, err)
+		require.False(t, r.Ok)
+
+		require.Contains(t, r.Reason, CertificationAnnotationMalformed+` : charts.openshift.io/requiresClusterAdmin "yes" should be either "true" or "false"`)
+		require.Contains(t, r.Reason, CertificationAnnotationMalformed+` : charts.openshift.io/supportedArchitectures "amd64,x86" should be a comma separated list of: amd64, arm64, ppc64le, s390x, but contains "x86"`)
+		require.Contains(t, r.Reason, CertificationAnnotationMalformed+" : charts.openshift.io/provider should not be empty")
+		require.Contains(t, r.Reason, CertificationAnnotationMalformed+` : charts.openshift.io/supportURL "www.example.com/support" should be an absolute http or https URL`)
+		require.Contains(t, r.Reason, CertificationAnnotationMalformed+` : charts.openshift.io/supportedOpenShiftVersions ">=four" should be a version range`)
+		require.NotContains(t, r.Reason, "charts.openshift.io/name")
+		require.NotContains(t, r.Reason, "charts.openshift.io/testedOpenShiftVersion")
+
+		fields := map[string]string{}
+		for _, f := range r.Findings {
+			require.Equal(t, "Chart.yaml", f.Resource)
+			fields[f.Field] = f.Severity
+		}
+		require.Equal(t, map[string]string{
+			"annotations.charts.openshift.io/provider":                   ErrorSeverity,
+			"annotations.charts.openshift.io/requiresClusterAdmin":       ErrorSeverity,
+			"annotations.charts.openshift.io/supportURL":                 ErrorSeverity,
+			"annotations.charts.openshift.io/supportedArchitectures":     ErrorSeverity,
+			"annotations.charts.openshift.io/supportedOpenShiftVersions": ErrorSeverity,
+			"annotations.charts.openshift.io/disconectedSupported":       WarningSeverity,
+		}, fields)
+	})
+}
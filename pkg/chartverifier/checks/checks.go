@@ -17,14 +17,26 @@
 package checks
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
 	"path"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/Masterminds/semver/v3"
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/lint"
 	"helm.sh/helm/v3/pkg/lint/support"
+	corev1 "k8s.io/api/core/v1"
 
 	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/pyxis"
 )
@@ -35,11 +47,15 @@ const (
 	ReadmeDoesNotExist           = "Chart does not have a README"
 	NotHelm3Reason               = "API version is not V2, used in Helm 3"
 	Helm3Reason                  = "API version is V2, used in Helm 3"
+	LegacyDependencyFileFound    = "API version is V2, but legacy dependency file found; dependencies should be declared in Chart.yaml"
+	InvalidDependency            = "Dependency declared in Chart.yaml is invalid"
 	TestTemplatePrefix           = "templates/tests/"
 	ChartTestFilesExist          = "Chart test files exist"
 	ChartTestFilesDoesNotExist   = "Chart test files do not exist"
 	MinKuberVersionSpecified     = "Minimum Kubernetes version specified"
 	MinKuberVersionNotSpecified  = "Minimum Kubernetes version is not specified"
+	KubeVersionNotSupported      = "Kubernetes version constraint excludes the target OpenShift version"
+	KubeVersionExcluded          = "Kubernetes version constraint excludes the target Kubernetes version"
 	ValuesSchemaFileExist        = "Values schema file exist"
 	ValuesSchemaFileDoesNotExist = "Values schema file does not exist"
 	ValuesFileExist              = "Values file exist"
@@ -54,24 +70,134 @@ const (
 	ImageCertifyFailed           = "Failed to certify images"
 	ImageCertified               = "Image is Red Hat certified"
 	ImageNotCertified            = "Image is not Red Hat certified"
+	ChartVersionIsSemver         = "Chart version is valid semver"
+	ChartVersionNotSemver        = "Chart version is not valid semver"
+	ChartVersionMismatch         = "Chart version does not match the version encoded in the chart URI"
+	IconIsValid                  = "Chart icon is valid"
+	IconNotSpecified             = "Chart icon is not specified"
+	IconInvalidScheme            = "Chart icon must be an http, https or data URI"
+	IconNotReachable             = "Chart icon is not reachable"
+	IconNotAnImage               = "Chart icon is not an image"
+	ReferencedObjectsExist       = "Referenced ConfigMaps and Secrets exist"
+	ReferencedConfigMapMissing   = "Referenced ConfigMap does not exist"
+	ReferencedSecretMissing      = "Referenced Secret does not exist"
+	RenderFailed                 = "Failed to render chart templates"
+	ChartSizeIsReasonable        = "Chart size is reasonable"
+	ChartSizeExceeded            = "Chart uncompressed size exceeds the maximum"
+	ChartContainsBinaries        = "Chart contains binary files outside the allowed paths"
+	ReadmeValuesDocumented       = "README documents the chart values"
+	ReadmeMissingValuesSection   = "README does not have a configuration section with a table"
+	ReadmeOmitsValues            = "README omits most of the chart values"
+	ReadmeValuesSkipped          = "README does not exist; see has-readme"
+	ProjectMetadataComplete      = "Chart project metadata is complete"
+	MaintainersMissing           = "Chart does not declare any maintainer with a name"
+	HomeNotSpecified             = "Chart home URL is not specified"
+	HomeInvalid                  = "Chart home must be an http or https URL"
+	DescriptionNotSpecified      = "Chart description is not specified"
+	LicenseIncluded              = "Chart includes license information"
+	LicenseMissing               = "Chart neither includes a LICENSE file nor declares a license"
+	LicenseUnknown               = "Chart declares a license that is not a known SPDX identifier"
 )
 
+const (
+	// AllowNetworkConfigKey is the check configuration key enabling checks to perform network requests.
+	AllowNetworkConfigKey = "allowNetwork"
+	// AllowlistConfigKey is the check configuration key containing the names a check should accept regardless of its
+	// findings.
+	AllowlistConfigKey = "allowlist"
+	// MaxSizeConfigKey is the check configuration key informing the maximum uncompressed chart size, in bytes.
+	MaxSizeConfigKey = "maxSize"
+	// AllowedPathsConfigKey is the check configuration key containing the path prefixes where binary files are
+	// expected.
+	AllowedPathsConfigKey = "allowedPaths"
+	// MinCoverageConfigKey is the check configuration key informing the minimum fraction, between 0 and 1, of top-level
+	// values the README should document.
+	MinCoverageConfigKey = "minCoverage"
+	// FailOnSeverityConfigKey is the check configuration key informing the lowest severity of the lint messages
+	// failing helm-lint; either ErrorSeverity (the default) or WarningSeverity.
+	FailOnSeverityConfigKey = "failOnSeverity"
+)
+
+const (
+	// defaultMaxChartSize is the maximum uncompressed chart size used when none is configured.
+	defaultMaxChartSize = 1024 * 1024
+	// binarySniffLength is the number of leading bytes inspected when looking for binary content.
+	binarySniffLength = 8000
+	// largestFilesReported is the number of largest files included in chart-size-reasonable reasons.
+	largestFilesReported = 3
+	// defaultMinCoverage is the minimum fraction of top-level values the README should document when none is
+	// configured.
+	defaultMinCoverage = 0.5
+)
+
+// configurationHeadingRegexp matches the Markdown headings usually introducing the documentation of the chart values.
+var configurationHeadingRegexp = regexp.MustCompile(`(?i)^#{1,6}\s+.*\b(values|configuration|parameters)\b`)
+
+// binaryMagics are the leading bytes of executables and archives commonly vendored into charts.
+var binaryMagics = [][]byte{
+	[]byte("\x7fELF"),        // ELF
+	{0xfe, 0xed, 0xfa, 0xce}, // Mach-O 32-bit
+	{0xfe, 0xed, 0xfa, 0xcf}, // Mach-O 64-bit
+	{0xcf, 0xfa, 0xed, 0xfe}, // Mach-O 64-bit, little endian
+	{0xca, 0xfe, 0xba, 0xbe}, // Mach-O universal
+	{0x1f, 0x8b},             // gzip
+	[]byte("PK\x03\x04"),     // zip
+}
+
+// iconRequestTimeout is the maximum amount of time to wait for the chart icon to be retrieved.
+var iconRequestTimeout = 10 * time.Second
+
 func notImplemented() (Result, error) {
 	return Result{Ok: false}, errors.New("not implemented")
 }
 
+// getStringSliceConfig returns the list stored in config under key; comma separated strings, such as the ones informed
+// through the --set flag, are split into multiple values.
+func getStringSliceConfig(config *viper.Viper, key string) []string {
+	if s, ok := config.Get(key).(string); ok {
+		values := make([]string, 0)
+		for _, v := range strings.Split(s, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		return values
+	}
+	return config.GetStringSlice(key)
+}
+
+// getStringSetConfig is like getStringSliceConfig, but returns a set to simplify lookups.
+func getStringSetConfig(config *viper.Viper, key string) map[string]bool {
+	set := map[string]bool{}
+	for _, v := range getStringSliceConfig(config, key) {
+		set[v] = true
+	}
+	return set
+}
+
 func IsHelmV3(uri string, _ *viper.Viper) (Result, error) {
 	c, _, err := LoadChartFromURI(uri)
 	if err != nil {
 		return Result{}, err
 	}
-	isHelmV3 := c.Metadata.APIVersion == APIVersion2
 
-	reason := NotHelm3Reason
-	if isHelmV3 {
-		reason = Helm3Reason
+	if c.Metadata.APIVersion != APIVersion2 {
+		return NewResult(false, fmt.Sprintf("%s : detected apiVersion %q", NotHelm3Reason, c.Metadata.APIVersion)), nil
 	}
-	return NewResult(isHelmV3, reason), nil
+
+	r := NewResult(true, Helm3Reason)
+	for _, f := range c.Raw {
+		if f.Name == "requirements.yaml" || f.Name == "requirements.lock" {
+			addFailure(&r, fmt.Sprintf("%s : %s", LegacyDependencyFileFound, f.Name))
+		}
+	}
+	for _, dep := range c.Metadata.Dependencies {
+		if dep.Name == "" || dep.Version == "" {
+			addFailure(&r, fmt.Sprintf("%s : %q must declare both name and version", InvalidDependency, dep.Name))
+		}
+	}
+
+	return r, nil
 }
 
 func HasReadme(uri string, _ *viper.Viper) (Result, error) {
@@ -139,6 +265,580 @@ func ContainsValuesSchema(uri string, _ *viper.Viper) (Result, error) {
 	return r, nil
 }
 
+// VersionIsSemver checks whether the chart's version is a semver version, as Helm parses it, allowing a "v" prefix and
+// omitted minor and patch versions; when StrictConfigKey is set, the version must follow semver 2.0.0 exactly.
+func VersionIsSemver(uri string, config *viper.Viper) (Result, error) {
+	c, _, err := LoadChartFromURI(uri)
+	if err != nil {
+		return Result{}, err
+	}
+
+	parseVersion := semver.NewVersion
+	if config.GetBool(StrictConfigKey) {
+		parseVersion = semver.StrictNewVersion
+	}
+
+	chartVersion, err := parseVersion(c.Metadata.Version)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %s", ChartVersionNotSemver, c.Metadata.Version)), nil
+	}
+
+	r := NewResult(true, ChartVersionIsSemver)
+
+	if uriVersion, ok := getURIVersion(uri, c.Name()); ok {
+		matches := uriVersion == c.Metadata.Version
+		if v, err := parseVersion(uriVersion); err == nil {
+			matches = v.Equal(chartVersion)
+		}
+		if !matches {
+			r.SetResult(false, fmt.Sprintf("%s : %s != %s", ChartVersionMismatch, c.Metadata.Version, uriVersion))
+		}
+	}
+
+	return r, nil
+}
+
+// getURIVersion extracts the chart version encoded in the given uri, either from a "<name>-<version>.tgz" tarball
+// name or from the tag of an "oci://" reference. Returns false when the uri doesn't encode a version.
+func getURIVersion(uri string, name string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", false
+	}
+
+	base := path.Base(u.Path)
+
+	if u.Scheme == "oci" {
+		if strings.Contains(base, "@") {
+			return "", false
+		}
+		if i := strings.LastIndex(base, ":"); i > 0 && i < len(base)-1 {
+			return base[i+1:], true
+		}
+		return "", false
+	}
+
+	for _, ext := range []string{".tgz", ".tar.gz"} {
+		if !strings.HasSuffix(base, ext) {
+			continue
+		}
+		prefix := name + "-"
+		if trimmed := strings.TrimSuffix(base, ext); strings.HasPrefix(trimmed, prefix) && len(trimmed) > len(prefix) {
+			return strings.TrimPrefix(trimmed, prefix), true
+		}
+	}
+
+	return "", false
+}
+
+func HasValidIcon(uri string, config *viper.Viper) (Result, error) {
+	c, _, err := LoadChartFromURI(uri)
+	if err != nil {
+		return Result{}, err
+	}
+
+	icon := c.Metadata.Icon
+	if icon == "" {
+		return NewResult(false, IconNotSpecified), nil
+	}
+
+	u, err := url.Parse(icon)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %s : %v", IconInvalidScheme, icon, err)), nil
+	}
+
+	switch u.Scheme {
+	case "data":
+		if !strings.HasPrefix(u.Opaque, "image/") {
+			return NewResult(false, fmt.Sprintf("%s : %s", IconNotAnImage, icon)), nil
+		}
+	case "http", "https":
+		if config.GetBool(AllowNetworkConfigKey) {
+			return checkIconURL(icon, getRateLimiter(config), newConfiguredHTTPClient(config, iconRequestTimeout)), nil
+		}
+	default:
+		return NewResult(false, fmt.Sprintf("%s : %s", IconInvalidScheme, icon)), nil
+	}
+
+	return NewResult(true, IconIsValid), nil
+}
+
+// checkIconURL retrieves the given icon url once allowed by limiter, with the given client, expecting a successful
+// response containing an image.
+func checkIconURL(icon string, limiter *RateLimiter, client *http.Client) Result {
+	limiter.Wait()
+	resp, err := client.Get(icon)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %s : %v", IconNotReachable, icon, err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return NewResult(false, fmt.Sprintf("%s : %s : %s", IconNotReachable, icon, resp.Status))
+	}
+
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "image/") {
+		return NewResult(false, fmt.Sprintf("%s : %s : %s", IconNotAnImage, icon, contentType))
+	}
+
+	return NewResult(true, IconIsValid)
+}
+
+func HasProjectMetadata(uri string, _ *viper.Viper) (Result, error) {
+	c, _, err := LoadChartFromURI(uri)
+	if err != nil {
+		return Result{}, err
+	}
+
+	r := NewResult(true, ProjectMetadataComplete)
+
+	hasNamedMaintainer := false
+	for _, m := range c.Metadata.Maintainers {
+		if m != nil && strings.TrimSpace(m.Name) != "" {
+			hasNamedMaintainer = true
+			break
+		}
+	}
+	if !hasNamedMaintainer {
+		addFailure(&r, MaintainersMissing)
+		r.AddFinding(Finding{
+			Resource: "Chart.yaml",
+			Field:    "maintainers",
+			Message:  "At least one maintainer with a name should be declared",
+			Severity: ErrorSeverity,
+		})
+	}
+
+	if home := c.Metadata.Home; home == "" {
+		addFailure(&r, HomeNotSpecified)
+		r.AddFinding(Finding{
+			Resource: "Chart.yaml",
+			Field:    "home",
+			Message:  "The project home URL should be declared",
+			Severity: ErrorSeverity,
+		})
+	} else if u, err := url.Parse(home); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		addFailure(&r, fmt.Sprintf("%s : %s", HomeInvalid, home))
+		r.AddFinding(Finding{
+			Resource: "Chart.yaml",
+			Field:    "home",
+			Message:  fmt.Sprintf("The project home %q is not an http or https URL", home),
+			Severity: ErrorSeverity,
+		})
+	}
+
+	if strings.TrimSpace(c.Metadata.Description) == "" {
+		addFailure(&r, DescriptionNotSpecified)
+		r.AddFinding(Finding{
+			Resource: "Chart.yaml",
+			Field:    "description",
+			Message:  "The chart description should be declared",
+			Severity: ErrorSeverity,
+		})
+	}
+
+	return r, nil
+}
+
+// LicenseAnnotation is the Chart.yaml annotation declaring the SPDX license expression of the chart, as used by
+// Artifact Hub.
+const LicenseAnnotation = "artifacthub.io/license"
+
+// licenseFiles are the names of the files at the root of the chart holding its license.
+var licenseFiles = map[string]bool{
+	"LICENSE":     true,
+	"LICENSE.txt": true,
+	"LICENSE.md":  true,
+}
+
+// spdxLicenses are the SPDX identifiers of the licenses commonly used by charts, deprecated ones included.
+var spdxLicenses = map[string]bool{
+	"0BSD":              true,
+	"AGPL-3.0":          true,
+	"AGPL-3.0-only":     true,
+	"AGPL-3.0-or-later": true,
+	"Apache-2.0":        true,
+	"Artistic-2.0":      true,
+	"BSD-2-Clause":      true,
+	"BSD-3-Clause":      true,
+	"BSL-1.0":           true,
+	"CC0-1.0":           true,
+	"EPL-1.0":           true,
+	"EPL-2.0":           true,
+	"GPL-2.0":           true,
+	"GPL-2.0-only":      true,
+	"GPL-2.0-or-later":  true,
+	"GPL-3.0":           true,
+	"GPL-3.0-only":      true,
+	"GPL-3.0-or-later":  true,
+	"ISC":               true,
+	"LGPL-2.1":          true,
+	"LGPL-2.1-only":     true,
+	"LGPL-2.1-or-later": true,
+	"LGPL-3.0":          true,
+	"LGPL-3.0-only":     true,
+	"LGPL-3.0-or-later": true,
+	"MIT":               true,
+	"MPL-2.0":           true,
+	"PostgreSQL":        true,
+	"Unlicense":         true,
+	"Zlib":              true,
+}
+
+// getLicenseIdentifiers returns the license identifiers of the given SPDX license expression, e.g. Apache-2.0 and MIT
+// for "(Apache-2.0 OR MIT)", leaving out the exceptions following WITH.
+func getLicenseIdentifiers(expression string) []string {
+	var ids []string
+	tokens := strings.Fields(strings.NewReplacer("(", " ", ")", " ").Replace(expression))
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "AND", "OR":
+		case "WITH":
+			i++
+		default:
+			ids = append(ids, tokens[i])
+		}
+	}
+	return ids
+}
+
+// HasLicense checks the chart includes a LICENSE file or declares its license through LicenseAnnotation; when
+// StrictConfigKey is set, the declared license identifiers must be known SPDX identifiers, or be allowlisted.
+func HasLicense(uri string, config *viper.Viper) (Result, error) {
+	c, _, err := LoadChartFromURI(uri)
+	if err != nil {
+		return Result{}, err
+	}
+
+	hasFile := false
+	for _, f := range c.Files {
+		if licenseFiles[f.Name] {
+			hasFile = true
+			break
+		}
+	}
+
+	expression := strings.TrimSpace(c.Metadata.Annotations[LicenseAnnotation])
+	if !hasFile && expression == "" {
+		r := NewResult(false, LicenseMissing)
+		r.AddFinding(Finding{
+			Resource: "Chart.yaml",
+			Field:    "annotations." + LicenseAnnotation,
+			Message:  "A LICENSE file should be included or the SPDX identifier of the license should be declared",
+			Severity: ErrorSeverity,
+		})
+		return r, nil
+	}
+
+	r := NewResult(true, LicenseIncluded)
+	if expression == "" || !config.GetBool(StrictConfigKey) {
+		return r, nil
+	}
+
+	allowlist := getStringSetConfig(config, AllowlistConfigKey)
+	for _, id := range getLicenseIdentifiers(expression) {
+		// LicenseRef- identifiers refer to licenses defined by the chart itself
+		if spdxLicenses[id] || allowlist[id] || strings.HasPrefix(id, "LicenseRef-") {
+			continue
+		}
+		addFailure(&r, fmt.Sprintf("%s : %s", LicenseUnknown, id))
+		r.AddFinding(Finding{
+			Resource: "Chart.yaml",
+			Field:    "annotations." + LicenseAnnotation,
+			Message:  fmt.Sprintf("License %q is not a known SPDX identifier", id),
+			Severity: ErrorSeverity,
+		})
+	}
+
+	return r, nil
+}
+
+func ReferencedConfigMapsExist(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	defined := map[string]map[string]bool{"ConfigMap": {}, "Secret": {}}
+	for _, res := range resources {
+		if names, ok := defined[res.GetKind()]; ok {
+			names[res.GetName()] = true
+		}
+	}
+
+	allowlist := getStringSetConfig(config, AllowlistConfigKey)
+
+	r := NewResult(true, ReferencedObjectsExist)
+	for _, res := range resources {
+		podSpec, ok := getPodSpec(res)
+		if !ok {
+			continue
+		}
+
+		for _, ref := range getConfigMapAndSecretReferences(podSpec) {
+			if defined[ref.kind][ref.name] || allowlist[ref.name] {
+				continue
+			}
+			reason := ReferencedConfigMapMissing
+			if ref.kind == "Secret" {
+				reason = ReferencedSecretMissing
+			}
+			addFailure(&r, fmt.Sprintf("%s : %s referenced by %s", reason, ref.name, res))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    ref.field,
+				Message:  fmt.Sprintf("%s %q is not created by the chart", ref.kind, ref.name),
+				Severity: ErrorSeverity,
+			})
+		}
+	}
+
+	return r, nil
+}
+
+// addFailure adds a failed outcome to r, replacing the reason if r hasn't failed before.
+func addFailure(r *Result, reason string) {
+	if r.Ok {
+		r.SetResult(false, reason)
+	} else {
+		r.AddResult(false, reason)
+	}
+}
+
+// objectReference is a reference from a pod spec to either a ConfigMap or a Secret.
+type objectReference struct {
+	kind  string
+	name  string
+	field string
+}
+
+// getConfigMapAndSecretReferences returns all ConfigMaps and Secrets the given pod spec requires; optional references
+// are ignored.
+func getConfigMapAndSecretReferences(podSpec *corev1.PodSpec) []objectReference {
+	var refs []objectReference
+	seen := map[objectReference]bool{}
+
+	add := func(kind, name, field string, optional *bool) {
+		ref := objectReference{kind: kind, name: name, field: field}
+		if name == "" || (optional != nil && *optional) || seen[ref] {
+			return
+		}
+		seen[ref] = true
+		refs = append(refs, ref)
+	}
+
+	for _, c := range getAllContainers(podSpec) {
+		for _, e := range c.EnvFrom {
+			if e.ConfigMapRef != nil {
+				add("ConfigMap", e.ConfigMapRef.Name, "envFrom", e.ConfigMapRef.Optional)
+			}
+			if e.SecretRef != nil {
+				add("Secret", e.SecretRef.Name, "envFrom", e.SecretRef.Optional)
+			}
+		}
+		for _, e := range c.Env {
+			if e.ValueFrom == nil {
+				continue
+			}
+			if ref := e.ValueFrom.ConfigMapKeyRef; ref != nil {
+				add("ConfigMap", ref.Name, "env", ref.Optional)
+			}
+			if ref := e.ValueFrom.SecretKeyRef; ref != nil {
+				add("Secret", ref.Name, "env", ref.Optional)
+			}
+		}
+	}
+
+	for _, v := range podSpec.Volumes {
+		if v.ConfigMap != nil {
+			add("ConfigMap", v.ConfigMap.Name, "volumes", v.ConfigMap.Optional)
+		}
+		if v.Secret != nil {
+			add("Secret", v.Secret.SecretName, "volumes", v.Secret.Optional)
+		}
+		if v.Projected != nil {
+			for _, source := range v.Projected.Sources {
+				if source.ConfigMap != nil {
+					add("ConfigMap", source.ConfigMap.Name, "volumes", source.ConfigMap.Optional)
+				}
+				if source.Secret != nil {
+					add("Secret", source.Secret.Name, "volumes", source.Secret.Optional)
+				}
+			}
+		}
+	}
+
+	return refs
+}
+
+func ChartSizeReasonable(uri string, config *viper.Viper) (Result, error) {
+	c, _, err := LoadChartFromURI(uri)
+	if err != nil {
+		return Result{}, err
+	}
+
+	maxSize := int64(defaultMaxChartSize)
+	if config.IsSet(MaxSizeConfigKey) {
+		maxSize = config.GetInt64(MaxSizeConfigKey)
+	}
+
+	allowedPaths := []string{"charts/"}
+	if config.IsSet(AllowedPathsConfigKey) {
+		allowedPaths = getStringSliceConfig(config, AllowedPathsConfigKey)
+	}
+
+	var total int64
+	var binaries []*chart.File
+	files := make([]*chart.File, len(c.Raw))
+	copy(files, c.Raw)
+	for _, f := range files {
+		total += int64(len(f.Data))
+		if isBinary(f.Data) && !hasAnyPrefix(f.Name, allowedPaths) {
+			binaries = append(binaries, f)
+		}
+	}
+
+	r := NewResult(true, fmt.Sprintf("%s : %d bytes", ChartSizeIsReasonable, total))
+
+	if total > maxSize {
+		addFailure(&r, fmt.Sprintf("%s : %d bytes exceeds %d bytes; largest files: %s",
+			ChartSizeExceeded, total, maxSize, describeLargestFiles(files)))
+	}
+
+	if len(binaries) > 0 {
+		addFailure(&r, fmt.Sprintf("%s : %s", ChartContainsBinaries, describeLargestFiles(binaries)))
+		for _, f := range binaries {
+			r.AddFinding(Finding{
+				Resource: f.Name,
+				Message:  fmt.Sprintf("binary file of %d bytes is not expected outside %s", len(f.Data), strings.Join(allowedPaths, ", ")),
+				Severity: ErrorSeverity,
+			})
+		}
+	}
+
+	return r, nil
+}
+
+// isBinary reports whether data looks like binary rather than text content.
+func isBinary(data []byte) bool {
+	for _, magic := range binaryMagics {
+		if bytes.HasPrefix(data, magic) {
+			return true
+		}
+	}
+	sniff := data
+	if len(sniff) > binarySniffLength {
+		sniff = sniff[:binarySniffLength]
+	}
+	return bytes.IndexByte(sniff, 0) != -1
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// describeLargestFiles sorts files by decreasing size and describes the largest ones.
+func describeLargestFiles(files []*chart.File) string {
+	sort.SliceStable(files, func(i, j int) bool { return len(files[i].Data) > len(files[j].Data) })
+	var descriptions []string
+	for i, f := range files {
+		if i == largestFilesReported {
+			break
+		}
+		descriptions = append(descriptions, fmt.Sprintf("%s (%d bytes)", f.Name, len(f.Data)))
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+func ReadmeDocumentsValues(uri string, config *viper.Viper) (Result, error) {
+	c, _, err := LoadChartFromURI(uri)
+	if err != nil {
+		return Result{}, err
+	}
+
+	var readme string
+	found := false
+	for _, f := range c.Files {
+		if f.Name == "README.md" {
+			readme = string(f.Data)
+			found = true
+		}
+	}
+	if !found {
+		return NewResult(true, ReadmeValuesSkipped), nil
+	}
+
+	section, ok := getConfigurationSection(readme)
+	if !ok {
+		return NewResult(false, ReadmeMissingValuesSection), nil
+	}
+
+	minCoverage := defaultMinCoverage
+	if config.IsSet(MinCoverageConfigKey) {
+		minCoverage = config.GetFloat64(MinCoverageConfigKey)
+	}
+
+	keys := make([]string, 0, len(c.Values))
+	for k := range c.Values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var missing []string
+	for _, k := range keys {
+		if !regexp.MustCompile(`\b` + regexp.QuoteMeta(k) + `\b`).MatchString(section) {
+			missing = append(missing, k)
+		}
+	}
+
+	documented := len(keys) - len(missing)
+	if len(keys) > 0 && float64(documented)/float64(len(keys)) < minCoverage {
+		return NewResult(false, fmt.Sprintf("%s : %d of %d top-level values documented; missing: %s",
+			ReadmeOmitsValues, documented, len(keys), strings.Join(missing, ", "))), nil
+	}
+
+	return NewResult(true, ReadmeValuesDocumented), nil
+}
+
+// getConfigurationSection returns the README section documenting the chart values, from its heading up to the next
+// heading of the same or upper level outside code blocks; the section is only considered when it contains a Markdown table.
+func getConfigurationSection(readme string) (string, bool) {
+	lines := strings.Split(readme, "\n")
+	for i, line := range lines {
+		if !configurationHeadingRegexp.MatchString(line) {
+			continue
+		}
+		level := len(line) - len(strings.TrimLeft(line, "#"))
+		end := len(lines)
+		hasTable := false
+		inCodeBlock := false
+		for j := i + 1; j < len(lines); j++ {
+			if strings.HasPrefix(strings.TrimSpace(lines[j]), "```") {
+				inCodeBlock = !inCodeBlock
+			}
+			if inCodeBlock {
+				continue
+			}
+			if l := len(lines[j]) - len(strings.TrimLeft(lines[j], "#")); l > 0 && l <= level {
+				end = j
+				break
+			}
+			if strings.HasPrefix(strings.TrimSpace(lines[j]), "|") {
+				hasTable = true
+			}
+		}
+		if hasTable {
+			return strings.Join(lines[i:end], "\n"), true
+		}
+	}
+	return "", false
+}
+
 func KeywordsAreOpenshiftCategories(uri string, _ *viper.Viper) (Result, error) {
 	return notImplemented()
 }
@@ -151,7 +851,7 @@ func IsCommunityChart(uri string, _ *viper.Viper) (Result, error) {
 	return notImplemented()
 }
 
-func HasMinKubeVersion(uri string, _ *viper.Viper) (Result, error) {
+func HasMinKubeVersion(uri string, config *viper.Viper) (Result, error) {
 	c, _, err := LoadChartFromURI(uri)
 	if err != nil {
 		return NewResult(false, err.Error()), err
@@ -163,6 +863,24 @@ func HasMinKubeVersion(uri string, _ *viper.Viper) (Result, error) {
 		r.SetResult(true, MinKuberVersionSpecified)
 	}
 
+	if openShiftVersion := config.GetString(OpenShiftVersionConfigKey); r.Ok && openShiftVersion != "" {
+		kubeVersion, err := KubeVersionForOpenShift(openShiftVersion)
+		if err != nil {
+			return Result{}, err
+		}
+		if !chartutil.IsCompatibleRange(c.Metadata.KubeVersion, kubeVersion) {
+			r.SetResult(false, fmt.Sprintf("%s : %q excludes Kubernetes %s, shipped with OpenShift %s",
+				KubeVersionNotSupported, c.Metadata.KubeVersion, kubeVersion, openShiftVersion))
+		}
+	}
+
+	if kubeVersion := config.GetString(KubeVersionConfigKey); r.Ok && kubeVersion != "" {
+		if !chartutil.IsCompatibleRange(c.Metadata.KubeVersion, kubeVersion) {
+			r.SetResult(false, fmt.Sprintf("%s : %q excludes Kubernetes %s",
+				KubeVersionExcluded, c.Metadata.KubeVersion, kubeVersion))
+		}
+	}
+
 	return r, nil
 }
 
@@ -182,20 +900,66 @@ func NotContainCRDs(uri string, _ *viper.Viper) (Result, error) {
 	return r, nil
 }
 
-func HelmLint(uri string, _ *viper.Viper) (Result, error) {
-	c, p, err := LoadChartFromURI(uri)
+// lintSeverities maps the severities of Helm's lint messages reported as findings to the ones of the findings.
+var lintSeverities = map[int]string{
+	support.ErrorSev:   ErrorSeverity,
+	support.WarningSev: WarningSeverity,
+}
+
+func HelmLint(uri string, config *viper.Viper) (Result, error) {
+	c, _, err := LoadChartFromURI(uri)
 	if err != nil {
 		return NewResult(false, err.Error()), err
 	}
+
+	threshold := support.ErrorSev
+	switch severity := config.GetString(FailOnSeverityConfigKey); severity {
+	case "", ErrorSeverity:
+	case WarningSeverity:
+		threshold = support.WarningSev
+	default:
+		return Result{}, errors.Errorf("%s must be either %q or %q, but got %q", FailOnSeverityConfigKey, ErrorSeverity, WarningSeverity, severity)
+	}
+
+	// Helm only lints charts saved as directories, so the chart is saved to a directory removed once linted
+	dir, err := ioutil.TempDir("", "chart-verifier-lint-")
+	if err != nil {
+		return Result{}, err
+	}
+	defer os.RemoveAll(dir)
+	if err := chartutil.SaveDir(c, dir); err != nil {
+		return Result{}, err
+	}
+
+	// templates are linted with the informed values, so each values profile is linted
+	vals, _ := config.Get(ValuesConfigKey).(chartutil.Values)
+	if vals == nil {
+		vals = chartutil.Values{}
+	}
+
 	r := NewResult(true, HelmLintSuccessful)
-	p = path.Join(p, c.Name())
-	linter := lint.All(p, map[string]interface{}{}, "default", false)
-	if linter.HighestSeverity > support.WarningSev {
-		reason := ""
+	linter := lint.All(path.Join(dir, c.Name()), vals, "default", false)
+
+	// errors are reported before warnings, so the reason leads with the messages more likely to be blocking
+	var failures []string
+	for _, sev := range []int{support.ErrorSev, support.WarningSev} {
 		for _, m := range linter.Messages {
-			reason = reason + m.Error() + "\n"
+			if m.Severity != sev {
+				continue
+			}
+			r.AddFinding(Finding{
+				Resource: m.Path,
+				Message:  m.Err.Error(),
+				Severity: lintSeverities[sev],
+			})
+			if sev >= threshold {
+				failures = append(failures, m.Error())
+			}
 		}
-		r.SetResult(false, fmt.Sprintf("%s %s", HelmLintHasFailedPrefix, reason))
+	}
+
+	if len(failures) > 0 {
+		r.SetResult(false, HelmLintHasFailedPrefix+strings.Join(failures, "\n\t\t"))
 	}
 	return r, nil
 }
@@ -234,11 +998,13 @@ func CanBeInstalledWithoutClusterAdminPrivileges(uri string, _ *viper.Viper) (Re
 	return notImplemented()
 }
 
-func ImagesAreCertified(uri string, _ *viper.Viper) (Result, error) {
+func ImagesAreCertified(uri string, config *viper.Viper) (Result, error) {
 
 	r := NewResult(false, "")
+	limiter := getRateLimiter(config)
+	client := newConfiguredHTTPClient(config, 0)
 
-	images, err := getImageReferences(uri)
+	images, err := GetImageReferences(uri, config.GetString(KubeVersionConfigKey))
 
 	if err != nil {
 		r.SetResult(false, fmt.Sprintf("%s : Failed to get images : %v", ImageCertifyFailed, err))
@@ -250,17 +1016,21 @@ func ImagesAreCertified(uri string, _ *viper.Viper) (Result, error) {
 			registries, repository, version := getImageParts(image)
 
 			if len(registries) == 0 {
-				registries, err = pyxis.GetImageRegistries(repository)
+				limiter.Wait()
+				registries, err = pyxis.GetImageRegistriesWithClient(client, repository)
 			}
 
 			if err != nil {
 				r.AddResult(false, fmt.Sprintf("%s : %s : %v", ImageNotCertified, image, err))
+				r.AddFinding(Finding{Resource: image, Message: ImageNotCertified, Severity: ErrorSeverity})
 			} else if len(registries) == 0 {
 				r.AddResult(false, fmt.Sprintf("%s : %s", ImageNotCertified, image))
+				r.AddFinding(Finding{Resource: image, Message: ImageNotCertified, Severity: ErrorSeverity})
 			} else {
 				certified := false
 				for _, registry := range registries {
-					found, checkImageErr := pyxis.IsImageInRegistry(repository, version, registry)
+					limiter.Wait()
+					found, checkImageErr := pyxis.IsImageInRegistryWithClient(client, repository, version, registry)
 					if found {
 						err = nil
 						certified = true
@@ -275,6 +1045,7 @@ func ImagesAreCertified(uri string, _ *viper.Viper) (Result, error) {
 					} else {
 						r.AddResult(false, fmt.Sprintf("%s : %s", ImageNotCertified, image))
 					}
+					r.AddFinding(Finding{Resource: image, Message: ImageNotCertified, Severity: ErrorSeverity})
 				} else {
 					r.AddResult(true, fmt.Sprintf("%s : %s", ImageCertified, image))
 				}
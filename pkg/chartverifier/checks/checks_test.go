@@ -17,17 +17,23 @@
 package checks
 
 import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/spf13/viper"
 	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chartutil"
 )
 
 func TestIsHelmV3(t *testing.T) {
 	type testCase struct {
 		description string
 		uri         string
+		reason      string
 	}
 
 	positiveTestCases := []testCase{
@@ -46,7 +52,8 @@ func TestIsHelmV3(t *testing.T) {
 	}
 
 	negativeTestCases := []testCase{
-		{description: "invalid tarball", uri: "chart-0.1.0-v2.invalid.tgz"},
+		{description: "invalid tarball", uri: "chart-0.1.0-v2.invalid.tgz", reason: NotHelm3Reason + ` : detected apiVersion "v1"`},
+		{description: "tarball with requirements.yaml", uri: "chart-0.1.0-v3.with-requirements.tgz", reason: LegacyDependencyFileFound + " : requirements.yaml"},
 	}
 
 	for _, tc := range negativeTestCases {
@@ -56,7 +63,7 @@ func TestIsHelmV3(t *testing.T) {
 			require.NoError(t, err)
 			require.NotNil(t, r)
 			require.False(t, r.Ok)
-			require.Equal(t, NotHelm3Reason, r.Reason)
+			require.Equal(t, tc.reason, r.Reason)
 		})
 	}
 }
@@ -245,6 +252,41 @@ func TestHasMinKubeVersion(t *testing.T) {
 		})
 	}
 
+	t.Run("minimum Kubernetes version allowing the OpenShift version", func(t *testing.T) {
+		config := viper.New()
+		config.Set(OpenShiftVersionConfigKey, "4.7")
+		r, err := HasMinKubeVersion("chart-0.1.0-v3.valid.tgz", config)
+		require.NoError(t, err)
+		require.True(t, r.Ok)
+		require.Equal(t, MinKuberVersionSpecified, r.Reason)
+	})
+
+	t.Run("minimum Kubernetes version excluding the OpenShift version", func(t *testing.T) {
+		config := viper.New()
+		config.Set(OpenShiftVersionConfigKey, "4.8")
+		r, err := HasMinKubeVersion("chart-0.1.0-v3.valid.tgz", config)
+		require.NoError(t, err)
+		require.False(t, r.Ok)
+		require.Equal(t, KubeVersionNotSupported+` : "1.20.0" excludes Kubernetes 1.21.0, shipped with OpenShift 4.8`, r.Reason)
+	})
+
+	t.Run("minimum Kubernetes version allowing the Kubernetes version", func(t *testing.T) {
+		config := viper.New()
+		config.Set(KubeVersionConfigKey, "1.20.0")
+		r, err := HasMinKubeVersion("chart-0.1.0-v3.valid.tgz", config)
+		require.NoError(t, err)
+		require.True(t, r.Ok)
+		require.Equal(t, MinKuberVersionSpecified, r.Reason)
+	})
+
+	t.Run("minimum Kubernetes version excluding the Kubernetes version", func(t *testing.T) {
+		config := viper.New()
+		config.Set(KubeVersionConfigKey, "1.19.3")
+		r, err := HasMinKubeVersion("chart-0.1.0-v3.valid.tgz", config)
+		require.NoError(t, err)
+		require.False(t, r.Ok)
+		require.Equal(t, KubeVersionExcluded+` : "1.20.0" excludes Kubernetes 1.19.3`, r.Reason)
+	})
 }
 
 func TestNotContainCRDs(t *testing.T) {
@@ -325,40 +367,409 @@ func TestHelmLint(t *testing.T) {
 	type testCase struct {
 		description string
 		uri         string
+		severity    string
+		warnings    int
+		errors      int
 	}
 
 	positiveTestCases := []testCase{
 		{description: "Helm lint works for valid chart", uri: "chart-0.1.0-v3.valid.tgz"},
 		{description: "Helm lint works for chart with lint INFO message", uri: "chart-0.1.0-v2.lint-info.tgz"},
-		{description: "Helm lint works for chart with lint WARNING message", uri: "chart-0.1.0-v2.lint-warning.tgz"},
+		{description: "Helm lint works for chart with lint INFO message when failing on warnings", uri: "chart-0.1.0-v2.lint-info.tgz", severity: WarningSeverity},
+		{description: "Helm lint works for chart with lint WARNING message", uri: "chart-0.1.0-v2.lint-warning.tgz", warnings: 4},
+		{description: "Helm lint works for chart with lint WARNING message when failing on errors", uri: "chart-0.1.0-v2.lint-warning.tgz", severity: ErrorSeverity, warnings: 4},
 	}
 
 	for _, tc := range positiveTestCases {
 		t.Run(tc.description, func(t *testing.T) {
 			config := viper.New()
+			config.Set(FailOnSeverityConfigKey, tc.severity)
 			r, err := HelmLint(tc.uri, config)
 			require.NoError(t, err)
 			require.NotNil(t, r)
 			require.True(t, r.Ok)
 			require.Equal(t, HelmLintSuccessful, r.Reason)
+			require.Len(t, r.Findings, tc.warnings)
+			for _, f := range r.Findings {
+				require.Equal(t, WarningSeverity, f.Severity)
+			}
 		})
 	}
 
 	negativeTestCases := []testCase{
-		{description: "Helm lint fails for chart with lint error", uri: "chart-0.1.0-v2.lint-error.tgz"},
+		{description: "Helm lint fails for chart with lint error", uri: "chart-0.1.0-v2.lint-error.tgz", errors: 1},
+		{description: "Helm lint fails for chart with lint WARNING message when failing on warnings", uri: "chart-0.1.0-v2.lint-warning.tgz", severity: WarningSeverity, warnings: 4},
 	}
 
 	for _, tc := range negativeTestCases {
 		t.Run(tc.description, func(t *testing.T) {
 			config := viper.New()
+			config.Set(FailOnSeverityConfigKey, tc.severity)
 			r, err := HelmLint(tc.uri, config)
 			require.NoError(t, err)
 			require.NotNil(t, r)
 			require.False(t, r.Ok)
-			require.Contains(t, r.Reason, HelmLintHasFailedPrefix)
+			require.True(t, strings.HasPrefix(r.Reason, HelmLintHasFailedPrefix))
+			require.Len(t, strings.Split(r.Reason, "\n\t\t"), tc.errors+tc.warnings)
+
+			severities := map[string]int{}
+			for _, f := range r.Findings {
+				require.NotEmpty(t, f.Resource)
+				require.NotEmpty(t, f.Message)
+				severities[f.Severity]++
+			}
+			require.Equal(t, tc.errors, severities[ErrorSeverity])
+			require.Equal(t, tc.warnings, severities[WarningSeverity])
+		})
+	}
+
+	t.Run("Helm lint reports the path and message of each lint message", func(t *testing.T) {
+		r, err := HelmLint("chart-0.1.0-v2.lint-warning.tgz", viper.New())
+		require.NoError(t, err)
+		require.Equal(t, "templates/deployment.yaml", r.Findings[0].Resource)
+		require.Contains(t, r.Findings[0].Message, `object name does not conform to Kubernetes naming requirements: "Fred"`)
+	})
+
+	t.Run("Helm lint lints the templates with the informed values", func(t *testing.T) {
+		config := viper.New()
+		config.Set(ValuesConfigKey, chartutil.Values{"port": "eighty"})
+		r, err := HelmLint("chart-0.1.0-v3.valid.tgz", config)
+		require.NoError(t, err)
+		require.False(t, r.Ok)
+		require.Contains(t, r.Reason, "port")
+	})
+
+	t.Run("Helm lint fails for an unknown severity", func(t *testing.T) {
+		config := viper.New()
+		config.Set(FailOnSeverityConfigKey, "info")
+		_, err := HelmLint("chart-0.1.0-v3.valid.tgz", config)
+		require.Error(t, err)
+	})
+}
+
+func TestVersionIsSemver(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		reason      string
+	}
+
+	positiveTestCases := []testCase{
+		{description: "semver version matching the tarball name", uri: "chart-0.1.0-v3.valid.tgz", reason: ChartVersionIsSemver},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			r, err := VersionIsSemver(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.True(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{description: "version is not semver", uri: "chart-0.1.0-v3.invalid-version.tgz", reason: ChartVersionNotSemver},
+		{description: "version does not match the tarball name", uri: "chart-0.1.0-v3.version-mismatch.tgz", reason: ChartVersionMismatch},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			r, err := VersionIsSemver(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.False(t, r.Ok)
+			require.Contains(t, r.Reason, tc.reason)
+		})
+	}
+
+	t.Run("Should accept versions Helm accepts, unless strict", func(t *testing.T) {
+		// the chart is expanded, so the version encoded in the tarball name isn't compared
+		dir := t.TempDir()
+		require.NoError(t, chartutil.ExpandFile(dir, "chart-0.1.0-v3.non-semver.tgz"))
+		uri := filepath.Join(dir, "chart")
+		r, err := VersionIsSemver(uri, viper.New())
+		require.NoError(t, err)
+		require.True(t, r.Ok, r.Reason)
+
+		config := viper.New()
+		config.Set(StrictConfigKey, true)
+		r, err = VersionIsSemver(uri, config)
+		require.NoError(t, err)
+		require.False(t, r.Ok)
+		require.Contains(t, r.Reason, ChartVersionNotSemver)
+	})
+}
+
+func TestURIVersion(t *testing.T) {
+
+	type testCase struct {
+		description     string
+		uri             string
+		expectedVersion string
+		expectedOk      bool
+	}
+
+	testCases := []testCase{
+		{"Tarball name", "chart-0.1.0.tgz", "0.1.0", true},
+		{"Tarball name with pre-release and build metadata", "https://example.com/chart-1.0.0-rc.1+build.5.tgz", "1.0.0-rc.1+build.5", true},
+		{"Tarball name of another chart", "other-0.1.0.tgz", "", false},
+		{"OCI tag", "oci://registry.example.com/charts/chart:1.2.3", "1.2.3", true},
+		{"OCI digest", "oci://registry.example.com/charts/chart@sha256:abcdef", "", false},
+		{"Directory", "./charts/chart", "", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			version, ok := getURIVersion(tc.uri, "chart")
+			require.Equal(t, tc.expectedOk, ok)
+			require.Equal(t, tc.expectedVersion, version)
+		})
+	}
+}
+
+func TestHasValidIcon(t *testing.T) {
+	type testCase struct {
+		description  string
+		uri          string
+		allowNetwork bool
+		reason       string
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:9879")
+	require.NoError(t, err)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = w.Write([]byte{0x89, 'P', 'N', 'G'})
+	}))
+	_ = srv.Listener.Close()
+	srv.Listener = ln
+	srv.Start()
+	defer srv.Close()
+
+	positiveTestCases := []testCase{
+		{description: "icon is not retrieved when network is not allowed", uri: "chart-0.1.0-v3.valid.tgz"},
+		{description: "unreachable icon when network is not allowed", uri: "chart-0.1.0-v3.unreachable-icon.tgz"},
+		{description: "reachable icon when network is allowed", uri: "chart-0.1.0-v3.local-icon.tgz", allowNetwork: true},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(AllowNetworkConfigKey, tc.allowNetwork)
+			r, err := HasValidIcon(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.True(t, r.Ok)
+			require.Equal(t, IconIsValid, r.Reason)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{description: "missing icon", uri: "chart-0.1.0-v3.without-icon.tgz", reason: IconNotSpecified},
+		{description: "icon with unsupported scheme", uri: "chart-0.1.0-v3.invalid-icon.tgz", reason: IconInvalidScheme},
+		{description: "unreachable icon when network is allowed", uri: "chart-0.1.0-v3.unreachable-icon.tgz", allowNetwork: true, reason: IconNotReachable},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(AllowNetworkConfigKey, tc.allowNetwork)
+			r, err := HasValidIcon(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.False(t, r.Ok)
+			require.Contains(t, r.Reason, tc.reason)
+		})
+	}
+}
+
+func TestHasProjectMetadata(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		reason      string
+		findings    []Finding
+	}
+
+	t.Run("complete project metadata", func(t *testing.T) {
+		r, err := HasProjectMetadata("chart-0.1.0-v3.project-metadata.tgz", viper.New())
+		require.NoError(t, err)
+		require.True(t, r.Ok)
+		require.Equal(t, ProjectMetadataComplete, r.Reason)
+		require.Empty(t, r.Findings)
+	})
+
+	negativeTestCases := []testCase{
+		{
+			description: "missing maintainers",
+			uri:         "chart-0.1.0-v3.no-maintainers.tgz",
+			reason:      MaintainersMissing,
+			findings: []Finding{
+				{Resource: "Chart.yaml", Field: "maintainers", Message: "At least one maintainer with a name should be declared", Severity: ErrorSeverity},
+			},
+		},
+		{
+			description: "invalid home URL",
+			uri:         "chart-0.1.0-v3.invalid-home.tgz",
+			reason:      HomeInvalid + " : www.example.com/chart",
+			findings: []Finding{
+				{Resource: "Chart.yaml", Field: "home", Message: `The project home "www.example.com/chart" is not an http or https URL`, Severity: ErrorSeverity},
+			},
+		},
+		{
+			description: "missing maintainers and home URL",
+			uri:         "chart-0.1.0-v3.valid.tgz",
+			reason:      MaintainersMissing + "\n\t\t" + HomeNotSpecified,
+			findings: []Finding{
+				{Resource: "Chart.yaml", Field: "maintainers", Message: "At least one maintainer with a name should be declared", Severity: ErrorSeverity},
+				{Resource: "Chart.yaml", Field: "home", Message: "The project home URL should be declared", Severity: ErrorSeverity},
+			},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			r, err := HasProjectMetadata(tc.uri, viper.New())
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, tc.findings, r.Findings)
+		})
+	}
+}
+
+func TestHasLicense(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		strict      bool
+		allowlist   []string
+		reason      string
+		findings    []Finding
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart with a LICENSE file", uri: "chart-0.1.0-v3.license-file.tgz", strict: true},
+		{description: "chart declaring SPDX identifiers", uri: "chart-0.1.0-v3.license-annotation.tgz", strict: true},
+		{description: "chart declaring an unknown license when not strict", uri: "chart-0.1.0-v3.license-unknown.tgz"},
+		{description: "chart declaring an allowlisted license", uri: "chart-0.1.0-v3.license-unknown.tgz", strict: true, allowlist: []string{"Apache", "2"}},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(StrictConfigKey, tc.strict)
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			r, err := HasLicense(tc.uri, config)
+			require.NoError(t, err)
+			require.True(t, r.Ok)
+			require.Equal(t, LicenseIncluded, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart with neither a LICENSE file nor a license annotation",
+			uri:         "chart-0.1.0-v3.valid.tgz",
+			reason:      LicenseMissing,
+			findings: []Finding{
+				{Resource: "Chart.yaml", Field: "annotations.artifacthub.io/license", Message: "A LICENSE file should be included or the SPDX identifier of the license should be declared", Severity: ErrorSeverity},
+			},
+		},
+		{
+			description: "chart declaring a license that is not an SPDX identifier",
+			uri:         "chart-0.1.0-v3.license-unknown.tgz",
+			strict:      true,
+			reason:      LicenseUnknown + " : Apache" + "\n\t\t" + LicenseUnknown + " : 2",
+			findings: []Finding{
+				{Resource: "Chart.yaml", Field: "annotations.artifacthub.io/license", Message: `License "Apache" is not a known SPDX identifier`, Severity: ErrorSeverity},
+				{Resource: "Chart.yaml", Field: "annotations.artifacthub.io/license", Message: `License "2" is not a known SPDX identifier`, Severity: ErrorSeverity},
+			},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(StrictConfigKey, tc.strict)
+			r, err := HasLicense(tc.uri, config)
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, tc.findings, r.Findings)
+		})
+	}
+}
+
+func TestGetLicenseIdentifiers(t *testing.T) {
+	require.Equal(t, []string{"MIT"}, getLicenseIdentifiers("MIT"))
+	require.Equal(t, []string{"Apache-2.0", "MIT"}, getLicenseIdentifiers("(Apache-2.0 OR MIT)"))
+	require.Equal(t, []string{"GPL-2.0-only", "BSD-3-Clause"}, getLicenseIdentifiers("GPL-2.0-only WITH Classpath-exception-2.0 AND BSD-3-Clause"))
+}
+
+func TestReferencedConfigMapsExist(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		allowlist   []string
+		reasons     []string
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart without references", uri: "chart-0.1.0-v3.valid.tgz"},
+		{description: "chart creating all referenced objects", uri: "chart-0.1.0-v3.with-references.tgz"},
+		{description: "chart referencing an allowlisted secret", uri: "chart-0.1.0-v3.dangling-reference.tgz", allowlist: []string{"chart-secret"}},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			r, err := ReferencedConfigMapsExist(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.True(t, r.Ok)
+			require.Equal(t, ReferencedObjectsExist, r.Reason)
 		})
 	}
 
+	negativeTestCases := []testCase{
+		{
+			description: "chart with a dangling secret reference",
+			uri:         "chart-0.1.0-v3.dangling-reference.tgz",
+			reasons:     []string{ReferencedSecretMissing + " : chart-secret referenced by Deployment/testRelease-chart"},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			r, err := ReferencedConfigMapsExist(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.False(t, r.Ok)
+			for _, reason := range tc.reasons {
+				require.Contains(t, r.Reason, reason)
+			}
+			require.NotContains(t, r.Reason, ReferencedConfigMapMissing)
+			require.Equal(t, []Finding{{
+				Resource: "Deployment/testRelease-chart",
+				Field:    "env",
+				Message:  `Secret "chart-secret" is not created by the chart`,
+				Severity: ErrorSeverity,
+			}, {
+				Resource: "Deployment/testRelease-chart",
+				Field:    "volumes",
+				Message:  `Secret "chart-secret" is not created by the chart`,
+				Severity: ErrorSeverity,
+			}}, r.Findings)
+		})
+	}
 }
 
 func TestImageCertify(t *testing.T) {
@@ -422,3 +833,124 @@ func TestImageParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestChartSizeReasonable(t *testing.T) {
+	type testCase struct {
+		description  string
+		uri          string
+		maxSize      int64
+		allowedPaths []string
+		reasons      []string
+	}
+
+	positiveTestCases := []testCase{
+		{description: "small chart without binaries", uri: "chart-0.1.0-v3.valid.tgz"},
+		{description: "chart with a binary in an allowed path", uri: "chart-0.1.0-v3.with-binary.tgz", allowedPaths: []string{"files/"}},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			if tc.maxSize != 0 {
+				config.Set(MaxSizeConfigKey, tc.maxSize)
+			}
+			if tc.allowedPaths != nil {
+				config.Set(AllowedPathsConfigKey, tc.allowedPaths)
+			}
+			r, err := ChartSizeReasonable(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.True(t, r.Ok)
+			require.Contains(t, r.Reason, ChartSizeIsReasonable)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart exceeding the maximum size",
+			uri:         "chart-0.1.0-v3.valid.tgz",
+			maxSize:     1024,
+			reasons:     []string{ChartSizeExceeded, "exceeds 1024 bytes", "templates/"},
+		},
+		{
+			description: "chart with a binary blob",
+			uri:         "chart-0.1.0-v3.with-binary.tgz",
+			reasons:     []string{ChartContainsBinaries + " : files/tool.bin (16392 bytes)"},
+		},
+		{
+			description: "oversized chart with a binary blob",
+			uri:         "chart-0.1.0-v3.with-binary.tgz",
+			maxSize:     4096,
+			reasons:     []string{ChartSizeExceeded, "largest files: files/tool.bin (16392 bytes)", ChartContainsBinaries},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			if tc.maxSize != 0 {
+				config.Set(MaxSizeConfigKey, tc.maxSize)
+			}
+			if tc.allowedPaths != nil {
+				config.Set(AllowedPathsConfigKey, tc.allowedPaths)
+			}
+			r, err := ChartSizeReasonable(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.False(t, r.Ok)
+			for _, reason := range tc.reasons {
+				require.Contains(t, r.Reason, reason)
+			}
+		})
+	}
+}
+
+func TestReadmeDocumentsValues(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		minCoverage float64
+		reason      string
+	}
+
+	positiveTestCases := []testCase{
+		{description: "README documenting all values", uri: "chart-0.1.0-v3.documented-values.tgz", reason: ReadmeValuesDocumented},
+		{description: "README documenting few values with a lower threshold", uri: "chart-0.1.0-v3.undocumented-values.tgz", minCoverage: 0.1, reason: ReadmeValuesDocumented},
+		{description: "chart without README", uri: "chart-0.1.0-v3.without-readme.tgz", reason: ReadmeValuesSkipped},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			if tc.minCoverage != 0 {
+				config.Set(MinCoverageConfigKey, tc.minCoverage)
+			}
+			r, err := ReadmeDocumentsValues(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.True(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{description: "README without configuration section", uri: "chart-0.1.0-v3.valid.tgz", reason: ReadmeMissingValuesSection},
+		{description: "README documenting few values", uri: "chart-0.1.0-v3.undocumented-values.tgz", reason: ReadmeOmitsValues + " : 2 of 18 top-level values documented"},
+		{description: "README not documenting all values with a full threshold", uri: "chart-0.1.0-v3.undocumented-values.tgz", minCoverage: 1, reason: "missing: affinity, autoscaling"},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			if tc.minCoverage != 0 {
+				config.Set(MinCoverageConfigKey, tc.minCoverage)
+			}
+			r, err := ReadmeDocumentsValues(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.False(t, r.Ok)
+			require.Contains(t, r.Reason, tc.reason)
+		})
+	}
+}
@@ -0,0 +1,390 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+const (
+	// AllowClusterConfigKey is the check configuration key enabling checks to interact with the cluster configured
+	// in the current Kubernetes context.
+	AllowClusterConfigKey = "allowCluster"
+	// InstallModeConfigKey is the check configuration key selecting how install-succeeds installs the chart; either
+	// InstallModeDryRun (the default), submitting the rendered objects to the API server with server-side dry run, or
+	// InstallModeFull.
+	InstallModeConfigKey = "mode"
+	// NamespaceConfigKey is the check configuration key informing the namespace used for dry run installations.
+	NamespaceConfigKey = "namespace"
+
+	InstallModeDryRun = "dry-run"
+	InstallModeFull   = "install"
+)
+
+const (
+	InstallSucceeded             = "Chart installation succeeded"
+	InstallFailed                = "Chart installation failed"
+	InstallSkipped               = "Chart installation skipped: cluster access is not allowed"
+	InstallCleanup               = "Failed to clean up the chart installation"
+	InstallOtherOpenShiftVersion = "Chart installation skipped: the cluster does not run the OpenShift version"
+	HelmTestsPassed              = "Chart tests passed"
+	HelmTestsFailed              = "Chart tests failed"
+	HelmTestsSkipped             = "Chart tests skipped: cluster access is not allowed"
+	HelmTestsAbsent              = "Chart does not contain tests"
+)
+
+// testLogLinesReported is the number of trailing test pod log lines included in the reason of failed chart tests.
+const testLogLinesReported = 10
+
+// HelmActionRunner performs the Helm actions requiring a live cluster.
+type HelmActionRunner interface {
+	// Install installs chrt, with the given values, as releaseName in the given namespace; when dryRun is set, the rendered objects are
+	// submitted to the API server with server-side dry run, so they go through admission, but nothing is created.
+	Install(chrt *chart.Chart, releaseName, namespace string, values map[string]interface{}, dryRun bool) error
+	// Test runs the test hooks of releaseName in the given namespace, returning the logs of the test pods.
+	Test(releaseName, namespace string) (string, error)
+	// Uninstall removes releaseName from the given namespace.
+	Uninstall(releaseName, namespace string) error
+	// CreateNamespace creates the given namespace.
+	CreateNamespace(namespace string) error
+	// DeleteNamespace deletes the given namespace.
+	DeleteNamespace(namespace string) error
+	// KubeVersion returns the Kubernetes version of the cluster, e.g. "v1.21.1+051ac4f".
+	KubeVersion() (string, error)
+}
+
+// newHelmActionRunner creates the HelmActionRunner used by the cluster checks, whose requests to the cluster are
+// aborted once ctx is cancelled; replaced in tests.
+var newHelmActionRunner = func(ctx context.Context) HelmActionRunner {
+	return &helmActionRunner{settings: cli.New(), ctx: ctx}
+}
+
+type helmActionRunner struct {
+	settings *cli.EnvSettings
+	ctx      context.Context
+}
+
+// configuration returns the Helm configuration for namespace; bound configurations abort their requests once the
+// runner's context is cancelled, while unbound ones are used to clean up, so cancelled checks still remove what they
+// installed.
+func (r *helmActionRunner) configuration(namespace string, bound bool) (*action.Configuration, error) {
+	getter := r.settings.RESTClientGetter()
+	if bound {
+		getter = &contextRESTClientGetter{RESTClientGetter: getter, ctx: r.ctx}
+	}
+	cfg := new(action.Configuration)
+	if err := cfg.Init(getter, namespace, os.Getenv("HELM_DRIVER"), func(string, ...interface{}) {}); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (r *helmActionRunner) Install(chrt *chart.Chart, releaseName, namespace string, values map[string]interface{}, dryRun bool) error {
+	cfg, err := r.configuration(namespace, true)
+	if err != nil {
+		return err
+	}
+	client := action.NewInstall(cfg)
+	client.ReleaseName = releaseName
+	client.Namespace = namespace
+	client.DryRun = dryRun
+	rel, err := client.Run(chrt, values)
+	if err != nil || !dryRun {
+		return err
+	}
+
+	// Helm's dry run only renders the chart on the client, so the rendered objects are submitted with a server-side
+	// dry run for admission webhooks, quotas and SecurityContextConstraints to reject them
+	resources, err := cfg.KubeClient.Build(bytes.NewBufferString(rel.Manifest), false)
+	if err != nil {
+		return err
+	}
+	return resources.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+		ns := ""
+		if info.Namespaced() {
+			ns = namespace
+		}
+		if _, err := resource.NewHelper(info.Client, info.Mapping).DryRun(true).Create(ns, true, info.Object); err != nil {
+			return errors.Wrapf(err, "%s %s", info.Mapping.GroupVersionKind.Kind, info.Name)
+		}
+		return nil
+	})
+}
+
+func (r *helmActionRunner) Test(releaseName, namespace string) (string, error) {
+	cfg, err := r.configuration(namespace, true)
+	if err != nil {
+		return "", err
+	}
+	client := action.NewReleaseTesting(cfg)
+	client.Namespace = namespace
+	rel, err := client.Run(releaseName)
+	if rel == nil {
+		return "", err
+	}
+
+	var logs bytes.Buffer
+	if logsErr := client.GetPodLogs(&logs, rel); logsErr != nil && err == nil {
+		err = logsErr
+	}
+	return logs.String(), err
+}
+
+func (r *helmActionRunner) Uninstall(releaseName, namespace string) error {
+	cfg, err := r.configuration(namespace, false)
+	if err != nil {
+		return err
+	}
+	_, err = action.NewUninstall(cfg).Run(releaseName)
+	return err
+}
+
+func (r *helmActionRunner) CreateNamespace(namespace string) error {
+	cfg, err := r.configuration(namespace, true)
+	if err != nil {
+		return err
+	}
+	clientset, err := cfg.KubernetesClientSet()
+	if err != nil {
+		return err
+	}
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	_, err = clientset.CoreV1().Namespaces().Create(r.ctx, ns, metav1.CreateOptions{})
+	return err
+}
+
+func (r *helmActionRunner) DeleteNamespace(namespace string) error {
+	cfg, err := r.configuration(namespace, false)
+	if err != nil {
+		return err
+	}
+	clientset, err := cfg.KubernetesClientSet()
+	if err != nil {
+		return err
+	}
+	return clientset.CoreV1().Namespaces().Delete(context.Background(), namespace, metav1.DeleteOptions{})
+}
+
+func (r *helmActionRunner) KubeVersion() (string, error) {
+	cfg, err := r.configuration("default", true)
+	if err != nil {
+		return "", err
+	}
+	clientset, err := cfg.KubernetesClientSet()
+	if err != nil {
+		return "", err
+	}
+	info, err := clientset.Discovery().ServerVersion()
+	if err != nil {
+		return "", err
+	}
+	return info.GitVersion, nil
+}
+
+// installValues returns the chart values informed through ValuesConfigKey, the chart's defaults being used otherwise.
+func installValues(config *viper.Viper) map[string]interface{} {
+	vals, _ := config.Get(ValuesConfigKey).(chartutil.Values)
+	if vals == nil {
+		return map[string]interface{}{}
+	}
+	return vals
+}
+
+// runsOpenShiftVersion returns an empty string when the cluster runs the Kubernetes version shipped with the given
+// OpenShift version, otherwise the reason the installation isn't checked against that OpenShift version.
+func runsOpenShiftVersion(runner HelmActionRunner, openShiftVersion string) (string, error) {
+	expected, err := KubeVersionForOpenShift(openShiftVersion)
+	if err != nil {
+		return "", err
+	}
+	kubeVersion, err := runner.KubeVersion()
+	if err != nil {
+		return "", err
+	}
+	actual, err := semver.NewVersion(kubeVersion)
+	if err != nil {
+		return "", errors.Errorf("invalid cluster Kubernetes version %q: %v", kubeVersion, err)
+	}
+	if v := semver.MustParse(expected); v.Major() == actual.Major() && v.Minor() == actual.Minor() {
+		return "", nil
+	}
+	return fmt.Sprintf("%s : the cluster runs Kubernetes %d.%d, while OpenShift %s ships Kubernetes %s",
+		InstallOtherOpenShiftVersion, actual.Major(), actual.Minor(), openShiftVersion, strings.TrimSuffix(expected, ".0")), nil
+}
+
+// maxReleaseNameLength is the maximum length of Helm release names.
+const maxReleaseNameLength = 53
+
+// invalidNameCharsRegexp matches the characters not allowed in release and namespace names.
+var invalidNameCharsRegexp = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// newThrowawayName returns a name suitable for both releases and namespaces created only for the duration of a check;
+// the chart name is sanitized and truncated so the name fits in a release name.
+func newThrowawayName(chartName string) string {
+	const prefix, suffixLength = "chart-verifier-", len("-00000")
+	name := invalidNameCharsRegexp.ReplaceAllString(strings.ToLower(chartName), "-")
+	if max := maxReleaseNameLength - len(prefix) - suffixLength; len(name) > max {
+		name = name[:max]
+	}
+	name = strings.Trim(name, "-")
+	return fmt.Sprintf("%s%s-%05d", prefix, name, rand.New(rand.NewSource(time.Now().UnixNano())).Intn(100000))
+}
+
+func InstallSucceeds(uri string, config *viper.Viper) (Result, error) {
+	if !config.GetBool(AllowClusterConfigKey) {
+		return NewResult(true, InstallSkipped), nil
+	}
+
+	c, _, err := LoadChartFromURI(uri)
+	if err != nil {
+		return Result{}, err
+	}
+
+	runner := newHelmActionRunner(getContext(config))
+	releaseName := newThrowawayName(c.Name())
+	values := installValues(config)
+
+	// the installation is only checked against the OpenShift version the cluster runs
+	if openShiftVersion := config.GetString(OpenShiftVersionConfigKey); openShiftVersion != "" {
+		reason, err := runsOpenShiftVersion(runner, openShiftVersion)
+		if err != nil {
+			return Result{}, err
+		}
+		if reason != "" {
+			return NewResult(true, reason), nil
+		}
+	}
+
+	if config.GetString(InstallModeConfigKey) != InstallModeFull {
+		namespace := config.GetString(NamespaceConfigKey)
+		if namespace == "" {
+			namespace = "default"
+		}
+		if err := runner.Install(c, releaseName, namespace, values, true); err != nil {
+			return NewResult(false, fmt.Sprintf("%s : %v", InstallFailed, err)), nil
+		}
+		return NewResult(true, InstallSucceeded), nil
+	}
+
+	namespace := releaseName
+	if err := runner.CreateNamespace(namespace); err != nil {
+		return Result{}, err
+	}
+
+	r := NewResult(true, InstallSucceeded)
+	if err := runner.Install(c, releaseName, namespace, values, false); err != nil {
+		r.SetResult(false, fmt.Sprintf("%s : %v", InstallFailed, err))
+	} else if err := runner.Uninstall(releaseName, namespace); err != nil {
+		r.AddResult(true, fmt.Sprintf("%s : %v", InstallCleanup, err))
+	}
+
+	if err := runner.DeleteNamespace(namespace); err != nil {
+		r.AddResult(true, fmt.Sprintf("%s : %v", InstallCleanup, err))
+	}
+
+	return r, nil
+}
+
+// hasTests informs whether the chart contains test hooks.
+func hasTests(chrt *chart.Chart) bool {
+	for _, t := range chrt.Templates {
+		if strings.HasPrefix(t.Name, "templates/tests/") {
+			return true
+		}
+	}
+	return false
+}
+
+// summarizeLogs returns the trailing non-empty lines of the given logs, suitable for a check reason.
+func summarizeLogs(logs string) string {
+	var lines []string
+	for _, line := range strings.Split(logs, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > testLogLinesReported {
+		lines = lines[len(lines)-testLogLinesReported:]
+	}
+	return strings.Join(lines, "\n\t\t")
+}
+
+func HelmTestsPass(uri string, config *viper.Viper) (Result, error) {
+	if !config.GetBool(AllowClusterConfigKey) {
+		return NewResult(true, HelmTestsSkipped), nil
+	}
+
+	c, _, err := LoadChartFromURI(uri)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if !hasTests(c) {
+		return NewResult(true, HelmTestsAbsent), nil
+	}
+
+	runner := newHelmActionRunner(getContext(config))
+	releaseName := newThrowawayName(c.Name())
+
+	namespace := releaseName
+	if err := runner.CreateNamespace(namespace); err != nil {
+		return Result{}, err
+	}
+
+	r := NewResult(true, HelmTestsPassed)
+	if err := runner.Install(c, releaseName, namespace, installValues(config), false); err != nil {
+		r.SetResult(false, fmt.Sprintf("%s : %v", InstallFailed, err))
+	} else {
+		if logs, err := runner.Test(releaseName, namespace); err != nil {
+			reason := fmt.Sprintf("%s : %v", HelmTestsFailed, err)
+			if summary := summarizeLogs(logs); summary != "" {
+				reason += "\n\t\t" + summary
+			}
+			r.SetResult(false, reason)
+		}
+		if err := runner.Uninstall(releaseName, namespace); err != nil {
+			r.AddResult(true, fmt.Sprintf("%s : %v", InstallCleanup, err))
+		}
+	}
+
+	if err := runner.DeleteNamespace(namespace); err != nil {
+		r.AddResult(true, fmt.Sprintf("%s : %v", InstallCleanup, err))
+	}
+
+	return r, nil
+}
@@ -0,0 +1,281 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// fakeHelmActionRunner records the Helm actions it has been asked to perform.
+type fakeHelmActionRunner struct {
+	// ctx is the context the runner has been created with.
+	ctx         context.Context
+	installErr  error
+	testErr     error
+	testLogs    string
+	kubeVersion string
+	calls       []string
+	values      map[string]interface{}
+	namespaces  map[string]bool
+}
+
+func (r *fakeHelmActionRunner) Install(chrt *chart.Chart, releaseName, namespace string, values map[string]interface{}, dryRun bool) error {
+	r.values = values
+	if dryRun {
+		r.calls = append(r.calls, "install --dry-run")
+	} else {
+		r.calls = append(r.calls, "install")
+	}
+	return r.installErr
+}
+
+func (r *fakeHelmActionRunner) Test(releaseName, namespace string) (string, error) {
+	r.calls = append(r.calls, "test")
+	return r.testLogs, r.testErr
+}
+
+func (r *fakeHelmActionRunner) Uninstall(releaseName, namespace string) error {
+	r.calls = append(r.calls, "uninstall")
+	return nil
+}
+
+func (r *fakeHelmActionRunner) CreateNamespace(namespace string) error {
+	r.calls = append(r.calls, "create namespace")
+	r.namespaces[namespace] = true
+	return nil
+}
+
+func (r *fakeHelmActionRunner) DeleteNamespace(namespace string) error {
+	r.calls = append(r.calls, "delete namespace")
+	delete(r.namespaces, namespace)
+	return nil
+}
+
+func (r *fakeHelmActionRunner) KubeVersion() (string, error) {
+	return r.kubeVersion, nil
+}
+
+func useFakeHelmActionRunner(t *testing.T, installErr error) *fakeHelmActionRunner {
+	runner := &fakeHelmActionRunner{installErr: installErr, namespaces: map[string]bool{}}
+	original := newHelmActionRunner
+	newHelmActionRunner = func(ctx context.Context) HelmActionRunner {
+		runner.ctx = ctx
+		return runner
+	}
+	t.Cleanup(func() { newHelmActionRunner = original })
+	return runner
+}
+
+func TestNewThrowawayName(t *testing.T) {
+	nameRegexp := regexp.MustCompile(`^chart-verifier-[a-z0-9]([-a-z0-9]*[a-z0-9])?-[0-9]{5}$`)
+	for _, chartName := range []string{
+		"chart",
+		strings.Repeat("very-long-chart-name-", 5),
+		"Chart_With.Invalid-Characters",
+	} {
+		t.Run("Should fit a release name for chart "+chartName, func(t *testing.T) {
+			name := newThrowawayName(chartName)
+			require.LessOrEqual(t, len(name), maxReleaseNameLength)
+			require.Regexp(t, nameRegexp, name)
+		})
+	}
+}
+
+func TestInstallSucceeds(t *testing.T) {
+	uri := "chart-0.1.0-v3.valid.tgz"
+
+	t.Run("Should skip installation when cluster access is not allowed", func(t *testing.T) {
+		runner := useFakeHelmActionRunner(t, nil)
+		r, err := InstallSucceeds(uri, viper.New())
+		require.NoError(t, err)
+		require.True(t, r.Ok)
+		require.Equal(t, InstallSkipped, r.Reason)
+		require.Empty(t, runner.calls)
+	})
+
+	t.Run("Should succeed when dry run installation succeeds", func(t *testing.T) {
+		runner := useFakeHelmActionRunner(t, nil)
+		config := viper.New()
+		config.Set(AllowClusterConfigKey, true)
+		r, err := InstallSucceeds(uri, config)
+		require.NoError(t, err)
+		require.True(t, r.Ok)
+		require.Equal(t, InstallSucceeded, r.Reason)
+		require.Equal(t, []string{"install --dry-run"}, runner.calls)
+	})
+
+	t.Run("Should reach the cluster within the verification's context", func(t *testing.T) {
+		runner := useFakeHelmActionRunner(t, nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		config := viper.New()
+		config.Set(AllowClusterConfigKey, true)
+		config.Set(ContextConfigKey, ctx)
+		_, err := InstallSucceeds(uri, config)
+		require.NoError(t, err)
+		require.Equal(t, ctx, runner.ctx)
+	})
+
+	t.Run("Should fail with the server error when dry run installation is rejected", func(t *testing.T) {
+		runner := useFakeHelmActionRunner(t, errors.New("admission webhook denied the request"))
+		config := viper.New()
+		config.Set(AllowClusterConfigKey, true)
+		r, err := InstallSucceeds(uri, config)
+		require.NoError(t, err)
+		require.False(t, r.Ok)
+		require.Contains(t, r.Reason, InstallFailed)
+		require.Contains(t, r.Reason, "admission webhook denied the request")
+		require.Equal(t, []string{"install --dry-run"}, runner.calls)
+	})
+
+	t.Run("Should install with the informed values", func(t *testing.T) {
+		runner := useFakeHelmActionRunner(t, nil)
+		config := viper.New()
+		config.Set(AllowClusterConfigKey, true)
+		config.Set(ValuesConfigKey, chartutil.Values{"replicaCount": 3})
+		r, err := InstallSucceeds(uri, config)
+		require.NoError(t, err)
+		require.True(t, r.Ok)
+		require.Equal(t, map[string]interface{}{"replicaCount": 3}, runner.values)
+	})
+
+	t.Run("Should install when the cluster runs the OpenShift version", func(t *testing.T) {
+		runner := useFakeHelmActionRunner(t, nil)
+		runner.kubeVersion = "v1.21.1+051ac4f"
+		config := viper.New()
+		config.Set(AllowClusterConfigKey, true)
+		config.Set(OpenShiftVersionConfigKey, "4.8")
+		r, err := InstallSucceeds(uri, config)
+		require.NoError(t, err)
+		require.True(t, r.Ok)
+		require.Equal(t, InstallSucceeded, r.Reason)
+		require.Equal(t, []string{"install --dry-run"}, runner.calls)
+	})
+
+	t.Run("Should skip installation when the cluster runs another OpenShift version", func(t *testing.T) {
+		runner := useFakeHelmActionRunner(t, nil)
+		runner.kubeVersion = "v1.20.0+558d959"
+		config := viper.New()
+		config.Set(AllowClusterConfigKey, true)
+		config.Set(OpenShiftVersionConfigKey, "4.8")
+		r, err := InstallSucceeds(uri, config)
+		require.NoError(t, err)
+		require.True(t, r.Ok)
+		require.Equal(t, InstallOtherOpenShiftVersion+" : the cluster runs Kubernetes 1.20, while OpenShift 4.8 ships Kubernetes 1.21", r.Reason)
+		require.Empty(t, runner.calls)
+	})
+
+	t.Run("Should install in a throwaway namespace and clean up", func(t *testing.T) {
+		runner := useFakeHelmActionRunner(t, nil)
+		config := viper.New()
+		config.Set(AllowClusterConfigKey, true)
+		config.Set(InstallModeConfigKey, InstallModeFull)
+		r, err := InstallSucceeds(uri, config)
+		require.NoError(t, err)
+		require.True(t, r.Ok)
+		require.Equal(t, InstallSucceeded, r.Reason)
+		require.Equal(t, []string{"create namespace", "install", "uninstall", "delete namespace"}, runner.calls)
+		require.Empty(t, runner.namespaces)
+	})
+
+	t.Run("Should clean up the throwaway namespace when installation fails", func(t *testing.T) {
+		runner := useFakeHelmActionRunner(t, errors.New("forbidden"))
+		config := viper.New()
+		config.Set(AllowClusterConfigKey, true)
+		config.Set(InstallModeConfigKey, InstallModeFull)
+		r, err := InstallSucceeds(uri, config)
+		require.NoError(t, err)
+		require.False(t, r.Ok)
+		require.Contains(t, r.Reason, "forbidden")
+		require.Equal(t, []string{"create namespace", "install", "delete namespace"}, runner.calls)
+		require.Empty(t, runner.namespaces)
+	})
+}
+
+func TestHelmTestsPass(t *testing.T) {
+	uri := "chart-0.1.0-v3.valid.tgz"
+
+	t.Run("Should skip tests when cluster access is not allowed", func(t *testing.T) {
+		runner := useFakeHelmActionRunner(t, nil)
+		r, err := HelmTestsPass(uri, viper.New())
+		require.NoError(t, err)
+		require.True(t, r.Ok)
+		require.Equal(t, HelmTestsSkipped, r.Reason)
+		require.Empty(t, runner.calls)
+	})
+
+	t.Run("Should succeed without installing when the chart does not contain tests", func(t *testing.T) {
+		runner := useFakeHelmActionRunner(t, nil)
+		config := viper.New()
+		config.Set(AllowClusterConfigKey, true)
+		r, err := HelmTestsPass("chart-0.1.0-v3.valid.notest.tgz", config)
+		require.NoError(t, err)
+		require.True(t, r.Ok)
+		require.Equal(t, HelmTestsAbsent, r.Reason)
+		require.Empty(t, runner.calls)
+	})
+
+	t.Run("Should succeed when tests pass and clean up", func(t *testing.T) {
+		runner := useFakeHelmActionRunner(t, nil)
+		config := viper.New()
+		config.Set(AllowClusterConfigKey, true)
+		r, err := HelmTestsPass(uri, config)
+		require.NoError(t, err)
+		require.True(t, r.Ok)
+		require.Equal(t, HelmTestsPassed, r.Reason)
+		require.Equal(t, []string{"create namespace", "install", "test", "uninstall", "delete namespace"}, runner.calls)
+		require.Empty(t, runner.namespaces)
+	})
+
+	t.Run("Should fail with the test pod logs when tests fail and clean up", func(t *testing.T) {
+		runner := useFakeHelmActionRunner(t, nil)
+		runner.testErr = errors.New("pod testRelease-chart-test-connection failed")
+		runner.testLogs = "POD LOGS: testRelease-chart-test-connection\nConnecting to testRelease-chart:80\n\nwget: can't connect to remote host: Connection refused\n"
+		config := viper.New()
+		config.Set(AllowClusterConfigKey, true)
+		r, err := HelmTestsPass(uri, config)
+		require.NoError(t, err)
+		require.False(t, r.Ok)
+		require.Equal(t, HelmTestsFailed+" : pod testRelease-chart-test-connection failed"+
+			"\n\t\tPOD LOGS: testRelease-chart-test-connection"+
+			"\n\t\tConnecting to testRelease-chart:80"+
+			"\n\t\twget: can't connect to remote host: Connection refused", r.Reason)
+		require.Equal(t, []string{"create namespace", "install", "test", "uninstall", "delete namespace"}, runner.calls)
+		require.Empty(t, runner.namespaces)
+	})
+
+	t.Run("Should fail without testing when installation fails", func(t *testing.T) {
+		runner := useFakeHelmActionRunner(t, errors.New("forbidden"))
+		config := viper.New()
+		config.Set(AllowClusterConfigKey, true)
+		r, err := HelmTestsPass(uri, config)
+		require.NoError(t, err)
+		require.False(t, r.Ok)
+		require.Contains(t, r.Reason, "forbidden")
+		require.Equal(t, []string{"create namespace", "install", "delete namespace"}, runner.calls)
+		require.Empty(t, runner.namespaces)
+	})
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+// ContextConfigKey is the check configuration key containing the context.Context of the verification executing the
+// check; the requests checks send to image registries, icon hosts or the cluster are aborted once it is cancelled.
+const ContextConfigKey = "context"
+
+// getContext returns the context informed in config, or the background context when none is informed.
+func getContext(config *viper.Viper) context.Context {
+	if config == nil {
+		return context.Background()
+	}
+	if ctx, ok := config.Get(ContextConfigKey).(context.Context); ok && ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
+
+// contextTransport performs requests with next, aborting them once ctx is cancelled, in addition to when their own
+// context is.
+type contextTransport struct {
+	ctx  context.Context
+	next http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-t.ctx.Done():
+			cancel()
+		case <-stop:
+		}
+	}()
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			close(stop)
+			cancel()
+		})
+	}
+
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		release()
+		return nil, err
+	}
+	// the request's context must outlive the response, whose body is read afterwards
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releasingBody calls release once the response body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+// withContext returns transport, or Go's default transport when nil, aborting requests once ctx is cancelled; transport
+// is returned as is for contexts which are never cancelled.
+func withContext(ctx context.Context, transport http.RoundTripper) http.RoundTripper {
+	if ctx.Done() == nil {
+		return transport
+	}
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &contextTransport{ctx: ctx, next: transport}
+}
+
+// contextRESTClientGetter is a genericclioptions.RESTClientGetter whose clients abort their requests to the cluster
+// once ctx is cancelled.
+type contextRESTClientGetter struct {
+	genericclioptions.RESTClientGetter
+	ctx context.Context
+}
+
+func (g *contextRESTClientGetter) ToRESTConfig() (*rest.Config, error) {
+	config, err := g.RESTClientGetter.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	wrap := config.WrapTransport
+	config.WrapTransport = func(rt http.RoundTripper) http.RoundTripper {
+		if wrap != nil {
+			rt = wrap(rt)
+		}
+		return withContext(g.ctx, rt)
+	}
+	return config, nil
+}
+
+func (g *contextRESTClientGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	config, err := g.ToRESTConfig()
+	if err != nil {
+		return nil, err
+	}
+	// discovery issues a request per API group version, as genericclioptions allows for
+	config.Burst = 100
+	client, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return memory.NewMemCacheClient(client), nil
+}
+
+func (g *contextRESTClientGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	client, err := g.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	return restmapper.NewShortcutExpander(restmapper.NewDeferredDiscoveryRESTMapper(client), client), nil
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfiguredHTTPClient_Context(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	t.Run("Should abort requests once the verification's context is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		config := viper.New()
+		config.Set(ContextConfigKey, ctx)
+		client := newConfiguredHTTPClient(config, 0)
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			cancel()
+		}()
+		begin := time.Now()
+		_, err := client.Get(srv.URL)
+		require.Error(t, err)
+		require.True(t, errors.Is(err, context.Canceled))
+		require.Less(t, int64(time.Since(begin)), int64(time.Second))
+	})
+
+	t.Run("Should read responses until the body is closed", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		config := viper.New()
+		config.Set(ContextConfigKey, ctx)
+
+		ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("icon"))
+		}))
+		defer ok.Close()
+
+		resp, err := newConfiguredHTTPClient(config, 0).Get(ok.URL)
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, "icon", string(body))
+		require.NoError(t, resp.Body.Close())
+	})
+
+	t.Run("Should keep the transport when no context is informed", func(t *testing.T) {
+		require.Nil(t, newConfiguredHTTPClient(viper.New(), 0).Transport)
+	})
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	CRDSchemasStructural   = "CRDs have structural schemas"
+	CRDSchemaMissing       = "CRD does not declare an OpenAPI v3 schema"
+	CRDSchemaNotStructural = "CRD schema does not have type object at its root"
+	CRDSchemasSkipped      = "Chart does not contain CRDs"
+)
+
+// crdSchema is an OpenAPI v3 schema declared by a CRD, and the path of the field declaring it.
+type crdSchema struct {
+	schema map[string]interface{}
+	field  string
+}
+
+// getCRDSchemas returns the schemas declared by the given CRD; CRDs declare either a single schema, in
+// spec.validation, or one schema per version. A nil schema is returned for each version lacking one.
+func getCRDSchemas(crd renderedResource) ([]crdSchema, error) {
+	if schema, found, err := unstructured.NestedMap(crd.Object, "spec", "validation", "openAPIV3Schema"); err != nil {
+		return nil, err
+	} else if found {
+		return []crdSchema{{schema: schema, field: "spec.validation.openAPIV3Schema"}}, nil
+	}
+
+	versions, _, err := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(versions) == 0 {
+		return []crdSchema{{field: "spec.validation.openAPIV3Schema"}}, nil
+	}
+
+	schemas := make([]crdSchema, 0, len(versions))
+	for i, v := range versions {
+		field := fmt.Sprintf("spec.versions[%d].schema.openAPIV3Schema", i)
+		version, ok := v.(map[string]interface{})
+		if !ok {
+			schemas = append(schemas, crdSchema{field: field})
+			continue
+		}
+		schema, _, err := unstructured.NestedMap(version, "schema", "openAPIV3Schema")
+		if err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, crdSchema{schema: schema, field: field})
+	}
+	return schemas, nil
+}
+
+func CrdsHaveStructuralSchema(uri string, _ *viper.Viper) (Result, error) {
+	c, _, err := LoadChartFromURI(uri)
+	if err != nil {
+		return Result{}, err
+	}
+
+	crdFiles := c.CRDObjects()
+	if len(crdFiles) == 0 {
+		return NewResult(true, CRDSchemasSkipped), nil
+	}
+
+	r := NewResult(true, CRDSchemasStructural)
+	for _, f := range crdFiles {
+		resources, err := parseRenderedResources(string(f.File.Data))
+		if err != nil {
+			return Result{}, err
+		}
+
+		for _, crd := range resources {
+			if crd.GetKind() != "CustomResourceDefinition" {
+				continue
+			}
+
+			schemas, err := getCRDSchemas(crd)
+			if err != nil {
+				return Result{}, err
+			}
+
+			for _, s := range schemas {
+				var reason, message string
+				switch {
+				case s.schema == nil:
+					reason = CRDSchemaMissing
+					message = fmt.Sprintf("CRD %s does not declare %s", crd.GetName(), s.field)
+				case s.schema["type"] != "object":
+					reason = CRDSchemaNotStructural
+					message = fmt.Sprintf("CRD %s schema should have type object at its root", crd.GetName())
+				default:
+					continue
+				}
+
+				addFailure(&r, fmt.Sprintf("%s : %s", reason, crd.GetName()))
+				r.AddFinding(Finding{
+					Resource: crd.String(),
+					Field:    s.field,
+					Message:  message,
+					Severity: ErrorSeverity,
+				})
+			}
+		}
+	}
+
+	return r, nil
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrdsHaveStructuralSchema(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		reason      string
+		findings    []Finding
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart with structural CRD schemas", uri: "chart-0.1.0-v3.structural-crd.tgz", reason: CRDSchemasStructural},
+		{description: "chart without CRDs", uri: "chart-0.1.0-v3.valid.tgz", reason: CRDSchemasSkipped},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			r, err := CrdsHaveStructuralSchema(tc.uri, viper.New())
+			require.NoError(t, err)
+			require.True(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart with a CRD without schema",
+			uri:         "chart-0.1.0-v3.with-crd.tgz",
+			reason:      CRDSchemaMissing + " : backservs.service.example.com",
+			findings: []Finding{
+				{
+					Resource: "CustomResourceDefinition/backservs.service.example.com",
+					Field:    "spec.versions[0].schema.openAPIV3Schema",
+					Message:  "CRD backservs.service.example.com does not declare spec.versions[0].schema.openAPIV3Schema",
+					Severity: ErrorSeverity,
+				},
+			},
+		},
+		{
+			description: "chart with a CRD schema without type at its root",
+			uri:         "chart-0.1.0-v3.untyped-crd.tgz",
+			reason:      CRDSchemaNotStructural + " : backends.service.example.com",
+			findings: []Finding{
+				{
+					Resource: "CustomResourceDefinition/backends.service.example.com",
+					Field:    "spec.versions[0].schema.openAPIV3Schema",
+					Message:  "CRD backends.service.example.com schema should have type object at its root",
+					Severity: ErrorSeverity,
+				},
+			},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			r, err := CrdsHaveStructuralSchema(tc.uri, viper.New())
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, tc.findings, r.Findings)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/spf13/viper"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+const (
+	DependenciesAreTrusted        = "Chart dependencies come from trusted repositories"
+	DependenciesTrustSkipped      = "Chart dependencies check skipped: no trusted repositories are configured"
+	UntrustedDependencyFound      = "Dependency comes from an untrusted repository"
+	UnbundledLocalDependencyFound = "Local dependency is not bundled in the chart"
+)
+
+// isTrustedRepository informs whether repository matches any of the given patterns; patterns may contain globs, and
+// a trailing * matches any suffix, including further path segments.
+func isTrustedRepository(repository string, patterns []string) bool {
+	repository = strings.TrimSuffix(repository, "/")
+	for _, p := range patterns {
+		p = strings.TrimSuffix(p, "/")
+		if matched, _ := path.Match(p, repository); matched {
+			return true
+		}
+		if strings.HasSuffix(p, "*") && strings.HasPrefix(repository, strings.TrimSuffix(p, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBundled informs whether a subchart named name is bundled within the chart's charts directory.
+func isBundled(c *chart.Chart, name string) bool {
+	for _, d := range c.Dependencies() {
+		if d.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+func DependenciesFromTrustedRepos(uri string, config *viper.Viper) (Result, error) {
+	c, _, err := LoadChartFromURI(uri)
+	if err != nil {
+		return Result{}, err
+	}
+
+	trusted := getStringSliceConfig(config, AllowlistConfigKey)
+	if len(trusted) == 0 {
+		return NewResult(true, DependenciesTrustSkipped), nil
+	}
+
+	r := NewResult(true, DependenciesAreTrusted)
+	for i, dep := range c.Metadata.Dependencies {
+		field := fmt.Sprintf("dependencies[%d].repository", i)
+
+		switch {
+		case dep.Repository == "" || strings.HasPrefix(dep.Repository, "file://"):
+			// local dependencies have no remote source; they're only acceptable when shipped with the chart itself
+			if isBundled(c, dep.Name) {
+				continue
+			}
+			addFailure(&r, fmt.Sprintf("%s : %s", UnbundledLocalDependencyFound, dep.Name))
+			r.AddFinding(Finding{
+				Resource: "Chart.yaml",
+				Field:    field,
+				Message:  fmt.Sprintf("Dependency %s refers to %q, which is not bundled in the chart", dep.Name, dep.Repository),
+				Severity: ErrorSeverity,
+			})
+
+		case isTrustedRepository(dep.Repository, trusted):
+			// both remote and oci:// repositories are matched against the trusted patterns as they are
+			continue
+
+		default:
+			addFailure(&r, fmt.Sprintf("%s : %s (%s)", UntrustedDependencyFound, dep.Name, dep.Repository))
+			r.AddFinding(Finding{
+				Resource: "Chart.yaml",
+				Field:    field,
+				Message:  fmt.Sprintf("Dependency %s comes from untrusted repository %q", dep.Name, dep.Repository),
+				Severity: ErrorSeverity,
+			})
+		}
+	}
+
+	return r, nil
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDependenciesFromTrustedRepos(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		allowlist   interface{}
+		reason      string
+		findings    []Finding
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart without dependencies", uri: "chart-0.1.0-v3.valid.tgz", allowlist: []string{"https://charts.example.com/*"}, reason: DependenciesAreTrusted},
+		{description: "chart without trusted repositories configured", uri: "chart-0.1.0-v3.untrusted-dependencies.tgz", reason: DependenciesTrustSkipped},
+		{description: "chart with trusted and bundled local dependencies", uri: "chart-0.1.0-v3.trusted-dependencies.tgz", allowlist: []string{"https://charts.example.com/stable/"}, reason: DependenciesAreTrusted},
+		{description: "chart with dependencies matching a prefix", uri: "chart-0.1.0-v3.trusted-dependencies.tgz", allowlist: "https://charts.example.com/*", reason: DependenciesAreTrusted},
+		{description: "chart with dependencies matching a glob", uri: "chart-0.1.0-v3.trusted-dependencies.tgz", allowlist: "https://*.example.com/stable", reason: DependenciesAreTrusted},
+		{description: "chart with an allowed OCI dependency", uri: "chart-0.1.0-v3.oci-dependencies.tgz", allowlist: []string{"oci://registry.example.com/charts"}, reason: DependenciesAreTrusted},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			r, err := DependenciesFromTrustedRepos(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.True(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart with untrusted and unbundled local dependencies",
+			uri:         "chart-0.1.0-v3.untrusted-dependencies.tgz",
+			allowlist:   []string{"https://charts.example.com/*"},
+			reason: UntrustedDependencyFound + " : untrusted (https://charts.untrusted.example.org)" +
+				"\n\t\t" + UnbundledLocalDependencyFound + " : missing",
+			findings: []Finding{
+				{
+					Resource: "Chart.yaml",
+					Field:    "dependencies[1].repository",
+					Message:  `Dependency untrusted comes from untrusted repository "https://charts.untrusted.example.org"`,
+					Severity: ErrorSeverity,
+				},
+				{
+					Resource: "Chart.yaml",
+					Field:    "dependencies[2].repository",
+					Message:  `Dependency missing refers to "file://../missing", which is not bundled in the chart`,
+					Severity: ErrorSeverity,
+				},
+			},
+		},
+		{
+			description: "chart with an OCI dependency from an untrusted registry",
+			uri:         "chart-0.1.0-v3.oci-dependencies.tgz",
+			allowlist:   []string{"https://charts.example.com/*", "oci://registry.example.com/other"},
+			reason:      UntrustedDependencyFound + " : lib (oci://registry.example.com/charts)",
+			findings: []Finding{
+				{
+					Resource: "Chart.yaml",
+					Field:    "dependencies[0].repository",
+					Message:  `Dependency lib comes from untrusted repository "oci://registry.example.com/charts"`,
+					Severity: ErrorSeverity,
+				},
+			},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			r, err := DependenciesFromTrustedRepos(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, tc.findings, r.Findings)
+		})
+	}
+}
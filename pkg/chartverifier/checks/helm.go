@@ -18,21 +18,26 @@ package checks
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
-	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
-
-	"helm.sh/helm/v3/pkg/chartutil"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/repo"
+	"sigs.k8s.io/yaml"
 
 	"helm.sh/helm/v3/pkg/action"
 	kubefake "helm.sh/helm/v3/pkg/kube/fake"
@@ -42,23 +47,130 @@ import (
 	"github.com/redhat-certification/chart-verifier/pkg/helm/actions"
 )
 
+// Credentials informs the basic authentication credentials and the TLS settings used to retrieve charts over HTTP.
+type Credentials struct {
+	Username string
+	Password string
+	// CAFile is the path of a PEM encoded bundle of certificate authorities trusted in addition to the system's.
+	CAFile string
+	// InsecureSkipTLSVerify disables the verification of the server's certificate.
+	InsecureSkipTLSVerify bool
+	// Transport, when set, performs the requests instead of Go's default transport; the TLS settings above don't apply
+	// to it.
+	Transport http.RoundTripper
+}
+
+// isSet informs whether any credential has been informed.
+func (c Credentials) isSet() bool {
+	return c.Username != "" || c.Password != ""
+}
+
+// httpClient returns a client using the transport or, when unset, the TLS settings of the credentials.
+func (c Credentials) httpClient(timeout time.Duration) (*http.Client, error) {
+	tlsConfig, err := NewTLSConfig(c.CAFile, c.InsecureSkipTLSVerify)
+	if err != nil {
+		return nil, err
+	}
+	return newHTTPClient(c.Transport, tlsConfig, timeout), nil
+}
+
+// withRepositoryCredentials completes the given credentials with the ones configured for the chart's repository in
+// Helm's repository configuration: the repository's username and password are used when none are informed, and its CA
+// file when none is informed.
+func withRepositoryCredentials(url *url.URL, creds Credentials) (Credentials, error) {
+	repoCreds, err := repositoryCredentials(url)
+	if err != nil {
+		return Credentials{}, err
+	}
+	if !creds.isSet() {
+		creds.Username, creds.Password = repoCreds.Username, repoCreds.Password
+	}
+	if creds.CAFile == "" {
+		creds.CAFile = repoCreds.CAFile
+	}
+	creds.InsecureSkipTLSVerify = creds.InsecureSkipTLSVerify || repoCreds.InsecureSkipTLSVerify
+	return creds, nil
+}
+
+// repositoryCredentials returns the credentials of the first entry of the Helm repository configuration whose URL
+// contains the given url; a missing repository configuration is not an error.
+func repositoryCredentials(url *url.URL) (Credentials, error) {
+	f, err := repo.LoadFile(cli.New().RepositoryConfig)
+	if err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			return Credentials{}, nil
+		}
+		return Credentials{}, err
+	}
+
+	for _, entry := range f.Repositories {
+		if entry.URL != "" && strings.HasPrefix(url.String(), strings.TrimSuffix(entry.URL, "/")+"/") {
+			return Credentials{
+				Username:              entry.Username,
+				Password:              entry.Password,
+				CAFile:                entry.CAFile,
+				InsecureSkipTLSVerify: entry.InsecureSkipTLSverify,
+			}, nil
+		}
+	}
+	return Credentials{}, nil
+}
+
 // loadChartFromRemote attempts to retrieve a Helm chart from the given remote url. Returns an error if the given url
 // doesn't contain the 'http' or 'https' schema, or any other error related to retrieving the contents of the chart.
-func loadChartFromRemote(url *url.URL) (*chart.Chart, error) {
+// The request is aborted when ctx is cancelled. When no credentials are informed, the ones configured for the chart's
+// repository in Helm's repository configuration are used, if any.
+func loadChartFromRemote(ctx context.Context, url *url.URL, creds Credentials) (*chart.Chart, error) {
 	if url.Scheme != "http" && url.Scheme != "https" {
 		return nil, errors.Errorf("only 'http' and 'https' schemes are supported, but got %q", url.Scheme)
 	}
 
-	resp, err := http.Get(url.String())
+	data, err := fetchRemote(ctx, url, creds)
+	if err != nil {
+		return nil, err
+	}
+	return loader.LoadArchive(bytes.NewReader(data))
+}
+
+// fetchRemote retrieves the contents found at the given remote url, authenticating with the given credentials or,
+// when none are informed, the ones configured for url in Helm's repository configuration, if any.
+func fetchRemote(ctx context.Context, url *url.URL, creds Credentials) ([]byte, error) {
+	creds, err := withRepositoryCredentials(url, creds)
+	if err != nil {
+		return nil, err
+	}
+	client, err := creds.httpClient(0)
 	if err != nil {
 		return nil, err
 	}
 
-	if resp.StatusCode == http.StatusNotFound {
-		return nil, ChartNotFoundErr(url.String())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if creds.isSet() {
+		req.SetBasicAuth(creds.Username, creds.Password)
 	}
 
-	return loader.LoadArchive(resp.Body)
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return ioutil.ReadAll(resp.Body)
+	case http.StatusNotFound:
+		return nil, ChartNotFoundErr(url.Redacted())
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return nil, ChartUnauthorizedErr(url.Redacted())
+	default:
+		return nil, errors.Errorf("failed retrieving chart %s: %s", url.Redacted(), resp.Status)
+	}
 }
 
 // loadChartFromAbsPath attempts to retrieve a local Helm chart by resolving the maybe relative path into an absolute
@@ -92,16 +204,36 @@ type ChartCache interface {
 
 type ChartCacheItem struct {
 	Chart *chart.Chart
-	Path  string
+	// Path is the absolute path local charts have been loaded from; it is empty for remote charts.
+	Path string
 }
 
+// chartCacheEntry is a chart kept in memory by the chart cache.
+type chartCacheEntry struct {
+	// files are the files the chart has been loaded from; every lookup loads a distinct copy of the chart from them,
+	// since rendering mutates charts.
+	files []*loader.BufferedFile
+	path  string
+	// retained counts the verifications in progress retaining the chart; scoped entries, added by the first of them,
+	// are evicted once the last one releases the chart.
+	retained int
+	scoped   bool
+	// authenticated entries have been retrieved with credentials, which the chart's server must accept again before
+	// the chart is handed to another caller informing credentials.
+	authenticated bool
+}
+
+// chartCache keeps the charts loaded from each uri in memory, so remote charts are retrieved once; nothing is
+// written to disk.
 type chartCache struct {
-	chartMap map[string]ChartCacheItem
+	// mutex guards chartMap, since checks might be executed concurrently.
+	mutex    sync.Mutex
+	chartMap map[string]*chartCacheEntry
 }
 
 func newChartCache() *chartCache {
 	return &chartCache{
-		chartMap: make(map[string]ChartCacheItem),
+		chartMap: make(map[string]*chartCacheEntry),
 	}
 }
 
@@ -109,28 +241,143 @@ func (c *chartCache) MakeKey(uri string) string {
 	return regexp.MustCompile("[:/?.-]").ReplaceAllString(uri, "_")
 }
 
+// contains informs whether a chart has been cached for uri.
+func (c *chartCache) contains(uri string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_, ok := c.chartMap[c.MakeKey(uri)]
+	return ok
+}
+
+// authenticated informs whether the chart cached for uri, if any, has been retrieved with credentials.
+func (c *chartCache) authenticated(uri string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, ok := c.chartMap[c.MakeKey(uri)]
+	return ok && entry.authenticated
+}
+
 func (c *chartCache) Get(uri string) (ChartCacheItem, bool, error) {
-	if item, ok := c.chartMap[c.MakeKey(uri)]; !ok {
+	c.mutex.Lock()
+	entry, ok := c.chartMap[c.MakeKey(uri)]
+	var files []*loader.BufferedFile
+	if ok {
+		files = entry.files
+	}
+	c.mutex.Unlock()
+	if !ok {
 		return ChartCacheItem{}, false, nil
-	} else {
-		return item, true, nil
 	}
+
+	chrt, err := loader.LoadFiles(files)
+	if err != nil {
+		return ChartCacheItem{}, false, err
+	}
+	return ChartCacheItem{Chart: chrt, Path: entry.path}, true, nil
 }
 
 func (c *chartCache) Add(uri string, chrt *chart.Chart) (ChartCacheItem, error) {
-	userCacheDir, err := os.UserCacheDir()
-	if err != nil {
-		return ChartCacheItem{}, err
+	return c.add(uri, chrt, false)
+}
+
+// add is like Add, but records whether chrt has been retrieved with credentials.
+func (c *chartCache) add(uri string, chrt *chart.Chart, authenticated bool) (ChartCacheItem, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry := newChartCacheEntry(uri, chrt)
+	entry.authenticated = authenticated
+	c.chartMap[c.MakeKey(uri)] = entry
+	return ChartCacheItem{Chart: chrt, Path: entry.path}, nil
+}
+
+// derive caches the chart cached for uri along with the given files for derivedURI, until the returned function is
+// called, leaving the chart cached for uri as is. Returns false when no chart is cached for uri.
+func (c *chartCache) derive(uri, derivedURI string, files []*loader.BufferedFile) (func(), bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, ok := c.chartMap[c.MakeKey(uri)]
+	if !ok {
+		return nil, false
+	}
+	key := c.MakeKey(derivedURI)
+	derived := &chartCacheEntry{
+		// the files are copied, since lookups in progress might still be loading the original ones
+		files:         append(append([]*loader.BufferedFile{}, entry.files...), files...),
+		path:          entry.path,
+		scoped:        true,
+		authenticated: entry.authenticated,
 	}
+	c.chartMap[key] = derived
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mutex.Lock()
+			defer c.mutex.Unlock()
+			if c.chartMap[key] == derived {
+				delete(c.chartMap, key)
+			}
+		})
+	}, true
+}
+
+// retain keeps the chart cached for uri until the returned function is called; chrt is cached if uri isn't already,
+// and evicted once released by every verification retaining it; authenticated informs whether chrt has been retrieved
+// with credentials.
+func (c *chartCache) retain(uri string, chrt *chart.Chart, authenticated bool) func() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	key := c.MakeKey(uri)
-	cacheDir := path.Join(userCacheDir, "chart-verifier")
-	chartCacheDir := path.Join(cacheDir, key)
-	cacheItem := ChartCacheItem{Chart: chrt, Path: chartCacheDir}
-	if err = chartutil.SaveDir(chrt, chartCacheDir); err != nil {
-		return ChartCacheItem{}, err
+	entry, ok := c.chartMap[key]
+	if !ok {
+		entry = newChartCacheEntry(uri, chrt)
+		entry.scoped = true
+		entry.authenticated = authenticated
+		c.chartMap[key] = entry
+	}
+	entry.retained++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mutex.Lock()
+			defer c.mutex.Unlock()
+			entry.retained--
+			if entry.scoped && entry.retained == 0 && c.chartMap[key] == entry {
+				delete(c.chartMap, key)
+			}
+		})
+	}
+}
+
+// newChartCacheEntry returns an entry holding the files chrt has been loaded from.
+func newChartCacheEntry(uri string, chrt *chart.Chart) *chartCacheEntry {
+	files := make([]*loader.BufferedFile, len(chrt.Raw))
+	for i, f := range chrt.Raw {
+		files[i] = &loader.BufferedFile{Name: f.Name, Data: f.Data}
+	}
+	return &chartCacheEntry{files: files, path: localChartPath(uri)}
+}
+
+// localChartPath returns the absolute path of the local chart found at uri, or an empty string for remote charts.
+func localChartPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return ""
+	}
+	var p string
+	switch u.Scheme {
+	case "file":
+		p = u.Host + u.Path
+	case "":
+		p = uri
+	default:
+		return ""
 	}
-	c.chartMap[key] = cacheItem
-	return cacheItem, nil
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return p
 }
 
 var defaultChartCache *chartCache
@@ -139,43 +386,380 @@ func init() {
 	defaultChartCache = newChartCache()
 }
 
-// LoadChartFromURI attempts to retrieve a chart from the given uri string. It accepts "http", "https", "file" schemes,
-// and defaults to "file" if there isn't one.
-func LoadChartFromURI(uri string) (*chart.Chart, string, error) {
-	var (
-		chrt *chart.Chart
-		err  error
-	)
+// preflightTimeout is the maximum amount of time to wait for the server hosting a remote chart to answer the
+// pre-flight request.
+var preflightTimeout = 10 * time.Second
+
+// ValidateChartURI checks whether uri looks like a chart can be retrieved from it, without downloading the chart: the
+// uri must be well-formed and use one of the supported schemes, and the servers hosting remote charts must be
+// reachable. Returns an InvalidChartURIErr naming the problem, a ChartNotFoundErr when the server doesn't have it, or a
+// ChartUnauthorizedErr when the server rejects the credentials. Cached charts are valid, unless they have been
+// retrieved with credentials, in which case the server must accept the given ones.
+func ValidateChartURI(ctx context.Context, uri string, creds Credentials) error {
+	if defaultChartCache.contains(uri) && !defaultChartCache.authenticated(uri) {
+		return nil
+	}
 
-	if cached, ok, _ := defaultChartCache.Get(uri); ok {
-		return cached.Chart, cached.Path, nil
+	if strings.TrimSpace(uri) == "" {
+		return InvalidChartURIErr{Problem: "the uri is empty"}
 	}
 
 	u, err := url.Parse(uri)
 	if err != nil {
-		return nil, "", err
+		return InvalidChartURIErr{URI: uri, Problem: "the uri is malformed: " + unwrapURLError(err).Error()}
 	}
 
 	switch u.Scheme {
 	case "http", "https":
-		chrt, err = loadChartFromRemote(u)
-	case "file", "":
-		chrt, err = loadChartFromAbsPath(u.Path)
+		if u.Host == "" {
+			return InvalidChartURIErr{URI: u.Redacted(), Problem: "the url does not inform a host"}
+		}
+		return checkRemoteChart(ctx, u, creds)
+	case "file":
+		if u.Host+u.Path == "" {
+			return InvalidChartURIErr{URI: uri, Problem: "the file uri does not inform a path"}
+		}
+	case "":
 	default:
-		return nil, "", errors.Errorf("scheme %q not supported", u.Scheme)
+		return InvalidChartURIErr{URI: u.Redacted(), Problem: fmt.Sprintf("scheme %q is not supported, use one of http, https or file", u.Scheme)}
+	}
+
+	return nil
+}
+
+// checkRemoteChart sends a HEAD request for the chart at url, failing when its server can't be reached, doesn't have
+// the chart or rejects the credentials; other responses, such as servers not accepting HEAD requests, are left to the
+// chart download.
+func checkRemoteChart(ctx context.Context, url *url.URL, creds Credentials) error {
+	ctx, cancel := context.WithTimeout(ctx, preflightTimeout)
+	defer cancel()
+
+	creds, err := withRepositoryCredentials(url, creds)
+	if err != nil {
+		return err
+	}
+	client, err := creds.httpClient(0)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url.String(), nil)
+	if err != nil {
+		return InvalidChartURIErr{URI: url.Redacted(), Problem: err.Error()}
+	}
+	if creds.isSet() {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return InvalidChartURIErr{URI: url.Redacted(), Problem: "the server is unreachable: " + unwrapURLError(err).Error()}
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return ChartNotFoundErr(url.Redacted())
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ChartUnauthorizedErr(url.Redacted())
+	}
+	return nil
+}
+
+// usesCredentials informs whether the chart at uri is retrieved with credentials, either the given ones or the ones
+// configured for its repository in Helm's repository configuration.
+func usesCredentials(uri string, creds Credentials) bool {
+	u, err := url.Parse(uri)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	creds, err = withRepositoryCredentials(u, creds)
+	return err != nil || creds.isSet()
+}
+
+// authorizeCachedChart checks whether the server hosting the chart cached for uri accepts the given credentials, when
+// the chart has been retrieved with credentials, so cache hits don't hand charts to callers which couldn't retrieve
+// them.
+func authorizeCachedChart(ctx context.Context, uri string, creds Credentials) error {
+	if !defaultChartCache.authenticated(uri) {
+		return nil
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return err
+	}
+	return checkRemoteChart(ctx, u, creds)
+}
+
+// unwrapURLError returns the error wrapped by a *url.Error, whose message repeats the url.
+func unwrapURLError(err error) error {
+	if uErr, ok := err.(*url.Error); ok {
+		return uErr.Err
+	}
+	return err
+}
+
+// LoadChartFromURI attempts to retrieve a chart from the given uri string. It accepts "http", "https", "file" schemes,
+// and defaults to "file" if there isn't one; local paths can either be chart archives or chart directories, relative to
+// the current working directory or absolute. Charts are cached in memory for the lifetime of the process, unless
+// retained by RetainChartFromURI; every call returns a distinct copy of the chart, along with the absolute path of
+// local charts, or an empty path for remote ones.
+func LoadChartFromURI(uri string) (*chart.Chart, string, error) {
+	return LoadChartFromURIContext(context.Background(), uri)
+}
+
+// LoadChartFromURIContext is like LoadChartFromURI, but returns ctx.Err() if the context is cancelled before the chart
+// has been retrieved. Cached charts are returned as is, so the checks of a verification load the chart it retrieved.
+func LoadChartFromURIContext(ctx context.Context, uri string) (*chart.Chart, string, error) {
+	return loadChartFromURI(ctx, uri, Credentials{}, false)
+}
+
+// LoadChartFromURIWithCredentials is like LoadChartFromURIContext, but authenticates with the given credentials when
+// retrieving charts over HTTP; cached charts which have been retrieved with credentials are only returned once their
+// server accepts the given ones, otherwise a ChartUnauthorizedErr is returned.
+func LoadChartFromURIWithCredentials(ctx context.Context, uri string, creds Credentials) (*chart.Chart, string, error) {
+	return loadChartFromURI(ctx, uri, creds, true)
+}
+
+// loadChartFromURI retrieves the chart found at uri, unless already cached; authorize informs whether cache hits of
+// charts retrieved with credentials must be authorized with the given ones.
+func loadChartFromURI(ctx context.Context, uri string, creds Credentials, authorize bool) (*chart.Chart, string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
 	}
 
+	if authorize {
+		if err := authorizeCachedChart(ctx, uri, creds); err != nil {
+			return nil, "", err
+		}
+	}
+	if cached, ok, _ := defaultChartCache.Get(uri); ok {
+		return cached.Chart, cached.Path, nil
+	}
+
+	chrt, err := loadChart(ctx, uri, creds)
 	if err != nil {
 		return nil, "", err
 	}
 
-	if cached, err := defaultChartCache.Add(uri, chrt); err != nil {
+	if cached, err := defaultChartCache.add(uri, chrt, usesCredentials(uri, creds)); err != nil {
 		return nil, "", err
 	} else {
 		return cached.Chart, cached.Path, nil
 	}
 }
 
+// RetainChartFromURI is like LoadChartFromURIWithCredentials, but the chart is only cached until the returned function
+// is called, unless it had already been cached, so verifications leave no chart behind; those retaining the same uri
+// concurrently share the cached chart. The returned function must be called once the chart isn't needed anymore.
+func RetainChartFromURI(ctx context.Context, uri string, creds Credentials) (*chart.Chart, func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if err := authorizeCachedChart(ctx, uri, creds); err != nil {
+		return nil, nil, err
+	}
+	cached, ok, _ := defaultChartCache.Get(uri)
+	chrt := cached.Chart
+	if !ok {
+		var err error
+		if chrt, err = loadChart(ctx, uri, creds); err != nil {
+			return nil, nil, err
+		}
+	}
+	return chrt, defaultChartCache.retain(uri, chrt, usesCredentials(uri, creds)), nil
+}
+
+// BuiltDependencies describes the dependencies retrieved by BuildChartDependencies.
+type BuiltDependencies struct {
+	// URI is the uri checks load the chart along with its retrieved dependencies from.
+	URI string
+	// Skipped contains the names of the dependencies which can't be retrieved, since they're hosted in OCI registries.
+	Skipped []string
+	release func()
+}
+
+// Release evicts the chart cached for URI along with the retrieved dependencies.
+func (d BuiltDependencies) Release() {
+	if d.release != nil {
+		d.release()
+	}
+}
+
+// derivedURIs counts the charts cached along with their dependencies, so each of them is cached for a distinct uri.
+var derivedURIs uint64
+
+// BuildChartDependencies retrieves the dependencies declared by the chart cached for uri but not found in its charts
+// directory, as `helm dependency build` does, and caches the chart along with them for the returned URI until
+// released, so the checks loading the chart from it render its complete dependency tree, while the chart cached for
+// uri is left as retrieved. Dependencies are resolved to the versions locked in the chart's Chart.lock, if any,
+// otherwise to the latest versions satisfying their constraints. Repositories are informed either as URLs or by name,
+// as @name or alias:name, in which case they're looked up in Helm's repository configuration; local dependencies
+// can't be retrieved and are left as is, as are the ones hosted in OCI registries, which are reported as skipped.
+// When no dependency is retrieved, the returned URI is uri.
+//
+// Requests to the host the chart has been retrieved from are authenticated with the given credentials, and requests
+// to other hosts with the ones configured in Helm's repository configuration, if any; the TLS settings of the given
+// credentials apply to every request.
+func BuildChartDependencies(ctx context.Context, uri string, creds Credentials) (BuiltDependencies, error) {
+	cached, ok, err := defaultChartCache.Get(uri)
+	if err != nil {
+		return BuiltDependencies{}, err
+	}
+	if !ok {
+		return BuiltDependencies{}, errors.Errorf("chart %s has not been retrieved", uri)
+	}
+	chrt := cached.Chart
+
+	locked := map[string]string{}
+	if chrt.Lock != nil {
+		for _, dep := range chrt.Lock.Dependencies {
+			locked[dep.Name] = dep.Version
+		}
+	}
+
+	chartHost := ""
+	if u, err := url.Parse(uri); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		chartHost = u.Host
+	}
+
+	built := BuiltDependencies{URI: uri}
+	var files []*loader.BufferedFile
+	for _, dep := range chrt.Metadata.Dependencies {
+		if dep.Repository == "" || strings.HasPrefix(dep.Repository, "file://") || isBundled(chrt, dep.Name) {
+			continue
+		}
+		if strings.HasPrefix(dep.Repository, "oci://") {
+			built.Skipped = append(built.Skipped, dep.Name)
+			continue
+		}
+
+		version := dep.Version
+		if v, ok := locked[dep.Name]; ok {
+			version = v
+		}
+
+		data, resolved, err := fetchDependency(ctx, dep.Name, version, dep.Repository, chartHost, creds)
+		if err != nil {
+			return BuiltDependencies{}, errors.Wrapf(err, "failed building dependency %s", dep.Name)
+		}
+		files = append(files, &loader.BufferedFile{Name: fmt.Sprintf("charts/%s-%s.tgz", dep.Name, resolved), Data: data})
+	}
+
+	if len(files) > 0 {
+		derivedURI := fmt.Sprintf("%s#dependencies-%d", uri, atomic.AddUint64(&derivedURIs, 1))
+		release, ok := defaultChartCache.derive(uri, derivedURI, files)
+		if !ok {
+			return BuiltDependencies{}, errors.Errorf("chart %s has not been retrieved", uri)
+		}
+		built.URI, built.release = derivedURI, release
+	}
+	return built, nil
+}
+
+// fetchDependency retrieves the archive of the latest version of the chart named name satisfying the given version
+// constraint from the given repository, along with the version retrieved.
+func fetchDependency(ctx context.Context, name, version, repository, chartHost string, creds Credentials) ([]byte, string, error) {
+	repoURL, err := resolveRepositoryURL(repository)
+	if err != nil {
+		return nil, "", err
+	}
+
+	indexURL, err := url.Parse(strings.TrimSuffix(repoURL, "/") + "/index.yaml")
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := fetchRemote(ctx, indexURL, dependencyCredentials(indexURL, chartHost, creds))
+	if err != nil {
+		return nil, "", err
+	}
+	index := &repo.IndexFile{}
+	if err := yaml.Unmarshal(data, index); err != nil {
+		return nil, "", errors.Wrapf(err, "failed parsing the index of repository %s", repoURL)
+	}
+	index.SortEntries()
+
+	cv, err := index.Get(name, version)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(cv.URLs) == 0 {
+		return nil, "", errors.Errorf("repository %s does not inform where %s-%s can be retrieved from", repoURL, name, cv.Version)
+	}
+	archive, err := repo.ResolveReferenceURL(repoURL, cv.URLs[0])
+	if err != nil {
+		return nil, "", err
+	}
+
+	archiveURL, err := url.Parse(archive)
+	if err != nil {
+		return nil, "", err
+	}
+	data, err = fetchRemote(ctx, archiveURL, dependencyCredentials(archiveURL, chartHost, creds))
+	if err != nil {
+		return nil, "", err
+	}
+	return data, cv.Version, nil
+}
+
+// resolveRepositoryURL returns the URL of the given dependency repository, looking up repositories informed by name
+// in Helm's repository configuration.
+func resolveRepositoryURL(repository string) (string, error) {
+	name := ""
+	switch {
+	case strings.HasPrefix(repository, "@"):
+		name = strings.TrimPrefix(repository, "@")
+	case strings.HasPrefix(repository, "alias:"):
+		name = strings.TrimPrefix(repository, "alias:")
+	case strings.HasPrefix(repository, "http://"), strings.HasPrefix(repository, "https://"):
+		return repository, nil
+	default:
+		return "", errors.Errorf("repository %q is not supported, use an http or https URL or a repository name", repository)
+	}
+
+	f, err := repo.LoadFile(cli.New().RepositoryConfig)
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return "", err
+	}
+	if f != nil {
+		if entry := f.Get(name); entry != nil {
+			return entry.URL, nil
+		}
+	}
+	return "", errors.Errorf("repository %q is not configured", name)
+}
+
+// dependencyCredentials returns the credentials authenticating requests to url: the given credentials when url is
+// served by chartHost, otherwise only their TLS settings and transport.
+func dependencyCredentials(url *url.URL, chartHost string, creds Credentials) Credentials {
+	if chartHost != "" && url.Host == chartHost {
+		return creds
+	}
+	return Credentials{CAFile: creds.CAFile, InsecureSkipTLSVerify: creds.InsecureSkipTLSVerify, Transport: creds.Transport}
+}
+
+// loadChart retrieves the chart found at uri, bypassing the chart cache.
+func loadChart(ctx context.Context, uri string, creds Credentials) (*chart.Chart, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return loadChartFromRemote(ctx, u, creds)
+	case "file":
+		// relative file URIs, such as file://chart, are parsed as if the first path element was the host
+		return loadChartFromAbsPath(u.Host + u.Path)
+	case "":
+		// the uri is used verbatim, since local paths might contain characters with a special meaning in URLs
+		return loadChartFromAbsPath(uri)
+	default:
+		return nil, errors.Errorf("scheme %q not supported", u.Scheme)
+	}
+}
+
 type ChartNotFoundErr string
 
 func (c ChartNotFoundErr) Error() string {
@@ -187,25 +771,73 @@ func IsChartNotFound(err error) bool {
 	return ok
 }
 
-func getImageReferences(chartUri string) ([]string, error) {
+// InvalidChartURIErr is returned when a chart can't be retrieved from the informed uri, before attempting to do so.
+type InvalidChartURIErr struct {
+	URI string
+	// Problem describes what is wrong with the uri.
+	Problem string
+}
+
+func (e InvalidChartURIErr) Error() string {
+	return fmt.Sprintf("invalid chart uri %q: %s", e.URI, e.Problem)
+}
+
+func IsInvalidChartURI(err error) bool {
+	_, ok := err.(InvalidChartURIErr)
+	return ok
+}
+
+// ChartUnauthorizedErr is returned when the server hosting a chart rejects the informed credentials, if any.
+type ChartUnauthorizedErr string
+
+func (c ChartUnauthorizedErr) Error() string {
+	return "chart access unauthorized: " + string(c)
+}
+
+func IsChartUnauthorized(err error) bool {
+	_, ok := err.(ChartUnauthorizedErr)
+	return ok
+}
+
+// renderManifests renders the templates of the chart found at chartUri using the given values for the given Kubernetes
+// version, Helm's default one if empty, without reaching any cluster. The chart is rendered from memory, so archives,
+// directories and remote charts render identically.
+func renderManifests(chartUri string, vals map[string]interface{}, kubeVersion string) (string, error) {
+	caps, err := CapabilitiesForKubeVersion(kubeVersion)
+	if err != nil {
+		return "", err
+	}
+
+	c, _, err := LoadChartFromURI(chartUri)
+	if err != nil {
+		return "", err
+	}
 
 	actionConfig := &action.Configuration{
 		Releases:     nil,
 		KubeClient:   &kubefake.PrintingKubeClient{Out: ioutil.Discard},
-		Capabilities: chartutil.DefaultCapabilities,
+		Capabilities: caps,
 		Log:          func(format string, v ...interface{}) {},
 	}
 	mem := driver.NewMemory()
 	mem.SetNamespace("TestNamespace")
 	actionConfig.Releases = storage.Init(mem)
 
-	var m map[string]interface{}
+	return actions.RenderManifests("testRelease", c, vals, actionConfig)
+}
+
+// GetImageReferences renders the chart found at chartUri with its default values, returning the images it references.
+func GetImageReferences(chartUri string, kubeVersion string) ([]string, error) {
+	return GetImageReferencesWithValues(chartUri, nil, kubeVersion)
+}
+
+// GetImageReferencesWithValues is like GetImageReferences, but renders the chart with the given values.
+func GetImageReferencesWithValues(chartUri string, vals map[string]interface{}, kubeVersion string) ([]string, error) {
+
 	imagesMap := make(map[string]bool)
 
-	txt, err := actions.RenderManifests("testRelease", chartUri, m, actionConfig)
-	if err != nil {
-		fmt.Printf("RenderManifests error : %v\n", err)
-	} else {
+	txt, err := renderManifests(chartUri, vals, kubeVersion)
+	if err == nil {
 		r := strings.NewReader(txt)
 		scanner := bufio.NewScanner(r)
 		for scanner.Scan() {
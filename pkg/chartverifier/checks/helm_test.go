@@ -18,9 +18,19 @@ package checks
 
 import (
 	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
 
 	"github.com/redhat-certification/chart-verifier/pkg/testutil"
 )
@@ -79,6 +89,387 @@ func TestLoadChartFromURI(t *testing.T) {
 	cancel()
 }
 
+func TestLoadChartFromURIContext(t *testing.T) {
+
+	t.Run("Should return cancellation error when context is already cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		c, _, err := LoadChartFromURIContext(ctx, "chart-0.1.0-v3.valid.tgz")
+		require.Error(t, err)
+		require.True(t, errors.Is(err, context.Canceled))
+		require.Nil(t, c)
+	})
+
+	t.Run("Should abort the download when context is cancelled", func(t *testing.T) {
+		release := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-release:
+			}
+		}))
+		defer srv.Close()
+		defer close(release)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		c, _, err := LoadChartFromURIContext(ctx, srv.URL+"/charts/chart-0.1.0-v3.blocked.tgz")
+		require.Error(t, err)
+		require.True(t, errors.Is(err, context.DeadlineExceeded))
+		require.Nil(t, c)
+	})
+}
+
+// serveChartWithBasicAuth serves the valid chart on every path, as long as the given credentials are informed.
+func serveChartWithBasicAuth(t *testing.T, username, password string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != username || p != password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		http.ServeFile(w, r, "chart-0.1.0-v3.valid.tgz")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// useRepositoryConfig points Helm's repository configuration to a file with the given contents for the duration of
+// the test.
+func useRepositoryConfig(t *testing.T, contents string) {
+	dir, err := ioutil.TempDir("", "chart-verifier-repositories")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	repositoryConfig := filepath.Join(dir, "repositories.yaml")
+	require.NoError(t, ioutil.WriteFile(repositoryConfig, []byte(contents), 0644))
+
+	original, found := os.LookupEnv("HELM_REPOSITORY_CONFIG")
+	require.NoError(t, os.Setenv("HELM_REPOSITORY_CONFIG", repositoryConfig))
+	t.Cleanup(func() {
+		if found {
+			os.Setenv("HELM_REPOSITORY_CONFIG", original)
+		} else {
+			os.Unsetenv("HELM_REPOSITORY_CONFIG")
+		}
+	})
+}
+
+func TestRetainChartFromURI(t *testing.T) {
+	t.Run("Should keep the chart cached until released by every verification retaining it", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, chartutil.ExpandFile(dir, "chart-0.1.0-v3.valid.tgz"))
+		uri := filepath.Join(dir, "chart")
+
+		c1, release1, err := RetainChartFromURI(context.Background(), uri, Credentials{})
+		require.NoError(t, err)
+		c2, release2, err := RetainChartFromURI(context.Background(), uri, Credentials{})
+		require.NoError(t, err)
+		require.True(t, defaultChartCache.contains(uri))
+
+		c3, p, err := LoadChartFromURI(uri)
+		require.NoError(t, err)
+		require.Equal(t, uri, p)
+		require.Equal(t, c1.Metadata, c3.Metadata)
+		require.NotSame(t, c2, c3, "every lookup should return a distinct copy of the chart")
+
+		release1()
+		release1()
+		require.True(t, defaultChartCache.contains(uri))
+		release2()
+		require.False(t, defaultChartCache.contains(uri))
+	})
+
+	t.Run("Should keep charts cached before being retained", func(t *testing.T) {
+		uri := "chart-0.1.0-v3.valid.tgz"
+		_, _, err := LoadChartFromURI(uri)
+		require.NoError(t, err)
+
+		_, release, err := RetainChartFromURI(context.Background(), uri, Credentials{})
+		require.NoError(t, err)
+		release()
+		require.True(t, defaultChartCache.contains(uri))
+	})
+}
+
+// newSubchart returns a chart named subchart, whose only template is a config map informing its version.
+func newSubchart(version string) *chart.Chart {
+	return &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "subchart", Version: version},
+		Templates: []*chart.File{{
+			Name: "templates/configmap.yaml",
+			Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .Release.Name }}-subchart\ndata:\n  version: {{ .Chart.Version }}\n"),
+		}},
+	}
+}
+
+func TestBuildChartDependencies(t *testing.T) {
+	// the chart declares a dependency on subchart ^0.1.0, served from this repository
+	addr := "127.0.0.1:9878"
+	uri := "chart-0.1.0-v3.remote-dependency.tgz"
+
+	dir := t.TempDir()
+	require.NoError(t, testutil.WriteChartRepository(dir, newSubchart("0.1.0"), newSubchart("0.1.1"), newSubchart("0.2.0")))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, testutil.ServeCharts(ctx, addr, dir))
+
+	t.Run("Should not render dependencies which are not built", func(t *testing.T) {
+		_, release, err := RetainChartFromURI(context.Background(), uri, Credentials{})
+		require.NoError(t, err)
+		defer release()
+
+		manifests, err := renderManifests(uri, nil, "")
+		require.NoError(t, err)
+		require.NotContains(t, manifests, "testRelease-subchart")
+	})
+
+	t.Run("Should fetch and render the latest dependency version satisfying the constraint", func(t *testing.T) {
+		_, release, err := RetainChartFromURI(context.Background(), uri, Credentials{})
+		require.NoError(t, err)
+		defer release()
+
+		deps, err := BuildChartDependencies(context.Background(), uri, Credentials{})
+		require.NoError(t, err)
+		require.NotEqual(t, uri, deps.URI)
+		require.Empty(t, deps.Skipped)
+
+		c, _, err := LoadChartFromURI(deps.URI)
+		require.NoError(t, err)
+		require.Len(t, c.Dependencies(), 1)
+		require.Equal(t, "0.1.1", c.Dependencies()[0].Metadata.Version)
+
+		manifests, err := renderManifests(deps.URI, nil, "")
+		require.NoError(t, err)
+		require.Contains(t, manifests, "name: testRelease-subchart")
+		require.Contains(t, manifests, "version: 0.1.1")
+
+		// the chart cached for uri is left as retrieved
+		c, _, err = LoadChartFromURI(uri)
+		require.NoError(t, err)
+		require.Empty(t, c.Dependencies())
+
+		// dependencies already built are not retrieved again
+		rebuilt, err := BuildChartDependencies(context.Background(), deps.URI, Credentials{})
+		require.NoError(t, err)
+		require.Equal(t, deps.URI, rebuilt.URI)
+
+		deps.Release()
+		require.False(t, defaultChartCache.contains(deps.URI))
+		require.True(t, defaultChartCache.contains(uri))
+	})
+
+	t.Run("Should skip dependencies hosted in OCI registries", func(t *testing.T) {
+		parent := newSubchart("0.1.0")
+		parent.Metadata.Name = "parent"
+		parent.Metadata.Dependencies = []*chart.Dependency{{Name: "subchart", Version: "^0.1.0", Repository: "oci://registry.example.com/charts"}}
+		parentDir := t.TempDir()
+		require.NoError(t, chartutil.SaveDir(parent, parentDir))
+		parentURI := filepath.Join(parentDir, "parent")
+
+		_, release, err := RetainChartFromURI(context.Background(), parentURI, Credentials{})
+		require.NoError(t, err)
+		defer release()
+
+		deps, err := BuildChartDependencies(context.Background(), parentURI, Credentials{})
+		require.NoError(t, err)
+		require.Equal(t, parentURI, deps.URI)
+		require.Equal(t, []string{"subchart"}, deps.Skipped)
+		deps.Release()
+	})
+
+	t.Run("Should fail when the dependency can't be retrieved", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		defer srv.Close()
+
+		parent := newSubchart("0.1.0")
+		parent.Metadata.Name = "parent"
+		parent.Metadata.Dependencies = []*chart.Dependency{{Name: "subchart", Version: "^0.1.0", Repository: srv.URL}}
+		parentDir := t.TempDir()
+		require.NoError(t, chartutil.SaveDir(parent, parentDir))
+		parentURI := filepath.Join(parentDir, "parent")
+
+		_, release, err := RetainChartFromURI(context.Background(), parentURI, Credentials{})
+		require.NoError(t, err)
+		defer release()
+
+		_, err = BuildChartDependencies(context.Background(), parentURI, Credentials{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed building dependency subchart")
+	})
+
+	t.Run("Should fail when the chart has not been retrieved", func(t *testing.T) {
+		_, err := BuildChartDependencies(context.Background(), "chart-0.1.0-v3.non-existing.tgz", Credentials{})
+		require.Error(t, err)
+	})
+}
+
+func TestResolveRepositoryURL(t *testing.T) {
+	useRepositoryConfig(t, "repositories:\n  - name: stable\n    url: https://charts.example.com/stable\n")
+
+	u, err := resolveRepositoryURL("https://charts.example.com/stable")
+	require.NoError(t, err)
+	require.Equal(t, "https://charts.example.com/stable", u)
+
+	_, err = resolveRepositoryURL("oci://registry.example.com/charts")
+	require.Error(t, err)
+
+	u, err = resolveRepositoryURL("@stable")
+	require.NoError(t, err)
+	require.Equal(t, "https://charts.example.com/stable", u)
+
+	_, err = resolveRepositoryURL("alias:incubator")
+	require.EqualError(t, err, `repository "incubator" is not configured`)
+}
+
+func TestLoadChartFromURIWithCredentials(t *testing.T) {
+	srv := serveChartWithBasicAuth(t, "admin", "s3cr3t")
+
+	t.Run("Should retrieve the chart when the correct credentials are informed", func(t *testing.T) {
+		useRepositoryConfig(t, "repositories: []\n")
+		uri := srv.URL + "/charts/chart-0.1.0-v3.correct-credentials.tgz"
+		c, _, err := LoadChartFromURIWithCredentials(context.Background(), uri, Credentials{Username: "admin", Password: "s3cr3t"})
+		require.NoError(t, err)
+		require.NotNil(t, c)
+	})
+
+	t.Run("Should fail when wrong credentials are informed", func(t *testing.T) {
+		useRepositoryConfig(t, "repositories: []\n")
+		uri := srv.URL + "/charts/chart-0.1.0-v3.wrong-credentials.tgz"
+		c, _, err := LoadChartFromURIWithCredentials(context.Background(), uri, Credentials{Username: "admin", Password: "guess"})
+		require.Error(t, err)
+		require.True(t, IsChartUnauthorized(err))
+		require.Equal(t, "chart access unauthorized: "+uri, err.Error())
+		require.NotContains(t, err.Error(), "guess")
+		require.Nil(t, c)
+	})
+
+	t.Run("Should fail when wrong credentials are informed for a chart cached with the correct ones", func(t *testing.T) {
+		useRepositoryConfig(t, "repositories: []\n")
+		uri := srv.URL + "/charts/chart-0.1.0-v3.cached-credentials.tgz"
+		c, _, err := LoadChartFromURIWithCredentials(context.Background(), uri, Credentials{Username: "admin", Password: "s3cr3t"})
+		require.NoError(t, err)
+		require.NotNil(t, c)
+
+		wrong := Credentials{Username: "admin", Password: "guess"}
+		c, _, err = LoadChartFromURIWithCredentials(context.Background(), uri, wrong)
+		require.Error(t, err)
+		require.True(t, IsChartUnauthorized(err))
+		require.Nil(t, c)
+
+		c, release, err := RetainChartFromURI(context.Background(), uri, wrong)
+		require.Error(t, err)
+		require.True(t, IsChartUnauthorized(err))
+		require.Nil(t, c)
+		require.Nil(t, release)
+
+		err = ValidateChartURI(context.Background(), uri, Credentials{})
+		require.Error(t, err)
+		require.True(t, IsChartUnauthorized(err))
+
+		c, _, err = LoadChartFromURIWithCredentials(context.Background(), uri, Credentials{Username: "admin", Password: "s3cr3t"})
+		require.NoError(t, err)
+		require.NotNil(t, c)
+	})
+
+	t.Run("Should fail when no credentials are informed", func(t *testing.T) {
+		useRepositoryConfig(t, "repositories: []\n")
+		uri := srv.URL + "/charts/chart-0.1.0-v3.anonymous.tgz"
+		c, _, err := LoadChartFromURI(uri)
+		require.Error(t, err)
+		require.True(t, IsChartUnauthorized(err))
+		require.Nil(t, c)
+	})
+
+	t.Run("Should use the credentials configured for the chart's Helm repository", func(t *testing.T) {
+		useRepositoryConfig(t, strings.Join([]string{
+			"repositories:",
+			"- name: other",
+			"  url: " + srv.URL + "/other",
+			"  username: other",
+			"  password: other",
+			"- name: internal",
+			"  url: " + srv.URL + "/charts",
+			"  username: admin",
+			"  password: s3cr3t",
+		}, "\n"))
+		uri := srv.URL + "/charts/chart-0.1.0-v3.repository-credentials.tgz"
+		c, _, err := LoadChartFromURI(uri)
+		require.NoError(t, err)
+		require.NotNil(t, c)
+	})
+
+	t.Run("Should prefer the informed credentials over the chart's Helm repository ones", func(t *testing.T) {
+		useRepositoryConfig(t, strings.Join([]string{
+			"repositories:",
+			"- name: internal",
+			"  url: " + srv.URL + "/charts",
+			"  username: admin",
+			"  password: s3cr3t",
+		}, "\n"))
+		uri := srv.URL + "/charts/chart-0.1.0-v3.overridden-credentials.tgz"
+		c, _, err := LoadChartFromURIWithCredentials(context.Background(), uri, Credentials{Username: "admin", Password: "guess"})
+		require.Error(t, err)
+		require.True(t, IsChartUnauthorized(err))
+		require.Nil(t, c)
+	})
+}
+
+func TestValidateChartURI(t *testing.T) {
+	srv := httptest.NewServer(http.StripPrefix("/charts/", http.FileServer(http.Dir("."))))
+	t.Cleanup(srv.Close)
+
+	// a port nothing listens on, since the server has already been closed
+	closed := httptest.NewServer(http.NotFoundHandler())
+	unreachableURL := closed.URL
+	closed.Close()
+
+	positiveTestCases := map[string]string{
+		"reachable http url": srv.URL + "/charts/chart-0.1.0-v3.valid.tgz",
+		"relative path":      "chart-0.1.0-v3.valid.tgz",
+		"file uri":           "file://chart-0.1.0-v3.valid.tgz",
+	}
+
+	for description, uri := range positiveTestCases {
+		t.Run("Should accept a "+description, func(t *testing.T) {
+			require.NoError(t, ValidateChartURI(context.Background(), uri, Credentials{}))
+		})
+	}
+
+	type testCase struct {
+		description string
+		uri         string
+		problem     string
+	}
+
+	negativeTestCases := []testCase{
+		{description: "an empty uri", uri: "", problem: "the uri is empty"},
+		{description: "an unsupported scheme", uri: "ftp://charts.example.com/chart-0.1.0.tgz", problem: `scheme "ftp" is not supported, use one of http, https or file`},
+		{description: "a malformed uri", uri: "https://charts.example.com/%zz", problem: `the uri is malformed: invalid URL escape "%zz"`},
+		{description: "an url without host", uri: "https:///chart-0.1.0.tgz", problem: "the url does not inform a host"},
+		{description: "a file uri without path", uri: "file://", problem: "the file uri does not inform a path"},
+		{description: "an unreachable url", uri: unreachableURL + "/charts/chart-0.1.0-v3.valid.tgz", problem: "the server is unreachable: "},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run("Should reject "+tc.description, func(t *testing.T) {
+			start := time.Now()
+			err := ValidateChartURI(context.Background(), tc.uri, Credentials{})
+			require.Error(t, err)
+			require.True(t, IsInvalidChartURI(err), "unexpected error %v", err)
+			require.Contains(t, err.(InvalidChartURIErr).Problem, tc.problem)
+			require.Less(t, int64(time.Since(start)), int64(preflightTimeout))
+		})
+	}
+
+	t.Run("Should fail when the server does not have the chart", func(t *testing.T) {
+		uri := srv.URL + "/charts/chart-0.1.0-v3.missing.tgz"
+		err := ValidateChartURI(context.Background(), uri, Credentials{})
+		require.True(t, IsChartNotFound(err), "unexpected error %v", err)
+	})
+}
+
 func TestTemplate(t *testing.T) {
 
 	type testCase struct {
@@ -95,7 +486,7 @@ func TestTemplate(t *testing.T) {
 
 	for _, tc := range TestCases {
 		t.Run(tc.description, func(t *testing.T) {
-			images, err := getImageReferences(tc.uri)
+			images, err := GetImageReferences(tc.uri, "")
 			require.NoError(t, err)
 			require.Equal(t, len(images), len(tc.images))
 			for i := 0; i < len(tc.images); i++ {
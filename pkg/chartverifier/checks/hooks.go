@@ -0,0 +1,126 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+const (
+	HelmHooksWellFormed         = "Helm hooks are well-formed"
+	HelmHookEventUnknown        = "Helm hook names an unknown event"
+	HelmHookWeightInvalid       = "Helm hook weight is not an integer"
+	HelmHookDeletePolicyUnknown = "Helm hook names an unknown delete policy"
+)
+
+// helmHookEvents contains the events Helm runs hooks for; test-success is still accepted for Helm 2 tests.
+var helmHookEvents = map[string]bool{
+	release.HookPreInstall.String():   true,
+	release.HookPostInstall.String():  true,
+	release.HookPreDelete.String():    true,
+	release.HookPostDelete.String():   true,
+	release.HookPreUpgrade.String():   true,
+	release.HookPostUpgrade.String():  true,
+	release.HookPreRollback.String():  true,
+	release.HookPostRollback.String(): true,
+	release.HookTest.String():         true,
+	"test-success":                    true,
+}
+
+// helmHookDeletePolicies contains the policies Helm deletes hook resources by.
+var helmHookDeletePolicies = map[string]bool{
+	release.HookSucceeded.String():          true,
+	release.HookFailed.String():             true,
+	release.HookBeforeHookCreation.String(): true,
+}
+
+// splitHookAnnotation splits a comma separated hook annotation the way Helm does.
+func splitHookAnnotation(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		items = append(items, strings.ToLower(strings.TrimSpace(item)))
+	}
+	return items
+}
+
+// HelmHooksValid checks the hook annotations of the rendered resources, which Helm silently ignores when malformed:
+// hooks naming an unknown event are never created, unknown delete policies are skipped, and weights that aren't
+// integers default to 0. Templates are rendered unsorted, so hooks Helm would drop are still checked.
+func HelmHooksValid(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getUnsortedRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	allowlist := getStringSetConfig(config, AllowlistConfigKey)
+
+	r := NewResult(true, HelmHooksWellFormed)
+	for _, res := range resources {
+		annotations := res.GetAnnotations()
+		hooks, ok := annotations[release.HookAnnotation]
+		if !ok || allowlist[res.GetName()] {
+			continue
+		}
+
+		for _, event := range splitHookAnnotation(hooks) {
+			if helmHookEvents[event] {
+				continue
+			}
+			addFailure(&r, fmt.Sprintf("%s : %s (%q)", HelmHookEventUnknown, res, event))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    "metadata.annotations." + release.HookAnnotation,
+				Message:  fmt.Sprintf("Hook event %q is unknown, the hook won't run for it", event),
+				Severity: ErrorSeverity,
+			})
+		}
+
+		if weight, ok := annotations[release.HookWeightAnnotation]; ok {
+			if _, err := strconv.Atoi(weight); err != nil {
+				addFailure(&r, fmt.Sprintf("%s : %s (%q)", HelmHookWeightInvalid, res, weight))
+				r.AddFinding(Finding{
+					Resource: res.String(),
+					Field:    "metadata.annotations." + release.HookWeightAnnotation,
+					Message:  fmt.Sprintf("Hook weight %q is not an integer, the hook will be ordered with a weight of 0", weight),
+					Severity: ErrorSeverity,
+				})
+			}
+		}
+
+		if policies, ok := annotations[release.HookDeleteAnnotation]; ok {
+			for _, policy := range splitHookAnnotation(policies) {
+				if helmHookDeletePolicies[policy] {
+					continue
+				}
+				addFailure(&r, fmt.Sprintf("%s : %s (%q)", HelmHookDeletePolicyUnknown, res, policy))
+				r.AddFinding(Finding{
+					Resource: res.String(),
+					Field:    "metadata.annotations." + release.HookDeleteAnnotation,
+					Message:  fmt.Sprintf("Hook delete policy %q is unknown, the hook resource won't be deleted by it", policy),
+					Severity: ErrorSeverity,
+				})
+			}
+		}
+	}
+
+	return r, nil
+}
@@ -0,0 +1,120 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+func TestHelmHooksValid(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		values      chartutil.Values
+		reason      string
+		findings    []Finding
+	}
+
+	hookAnnotations := func(annotations map[string]interface{}) chartutil.Values {
+		return chartutil.Values{"hook": map[string]interface{}{"annotations": annotations}}
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart with a test hook", uri: "chart-0.1.0-v3.valid.tgz"},
+		{description: "chart with well-formed hooks", uri: "chart-0.1.0-v3.hooks.tgz"},
+		{description: "chart with hooks in mixed case and spaces", uri: "chart-0.1.0-v3.hooks.tgz", values: hookAnnotations(map[string]interface{}{"helm.sh/hook": "Pre-Install, post-upgrade", "helm.sh/hook-delete-policy": "Hook-Failed"})},
+		{description: "chart without hooks", uri: "chart-0.1.0-v3.hooks.tgz", values: chartutil.Values{"hook": map[string]interface{}{"enabled": false}}},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			if tc.values != nil {
+				config.Set(ValuesConfigKey, tc.values)
+			}
+			r, err := HelmHooksValid(tc.uri, config)
+			require.NoError(t, err)
+			require.True(t, r.Ok)
+			require.Equal(t, HelmHooksWellFormed, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart with an unknown hook event",
+			uri:         "chart-0.1.0-v3.hooks.tgz",
+			values:      hookAnnotations(map[string]interface{}{"helm.sh/hook": "pre-install,pre-upgrad"}),
+			reason:      HelmHookEventUnknown + ` : Job/testRelease-chart-migrate ("pre-upgrad")`,
+			findings: []Finding{
+				{Resource: "Job/testRelease-chart-migrate", Field: "metadata.annotations.helm.sh/hook", Message: `Hook event "pre-upgrad" is unknown, the hook won't run for it`, Severity: ErrorSeverity},
+			},
+		},
+		{
+			description: "chart with a non-numeric hook weight",
+			uri:         "chart-0.1.0-v3.hooks.tgz",
+			values:      hookAnnotations(map[string]interface{}{"helm.sh/hook-weight": "first"}),
+			reason:      HelmHookWeightInvalid + ` : Job/testRelease-chart-migrate ("first")`,
+			findings: []Finding{
+				{Resource: "Job/testRelease-chart-migrate", Field: "metadata.annotations.helm.sh/hook-weight", Message: `Hook weight "first" is not an integer, the hook will be ordered with a weight of 0`, Severity: ErrorSeverity},
+			},
+		},
+		{
+			description: "chart with a hook weight padded with spaces",
+			uri:         "chart-0.1.0-v3.hooks.tgz",
+			values:      hookAnnotations(map[string]interface{}{"helm.sh/hook-weight": " 10"}),
+			reason:      HelmHookWeightInvalid + ` : Job/testRelease-chart-migrate (" 10")`,
+			findings: []Finding{
+				{Resource: "Job/testRelease-chart-migrate", Field: "metadata.annotations.helm.sh/hook-weight", Message: `Hook weight " 10" is not an integer, the hook will be ordered with a weight of 0`, Severity: ErrorSeverity},
+			},
+		},
+		{
+			description: "chart with an unknown hook delete policy",
+			uri:         "chart-0.1.0-v3.hooks.tgz",
+			values:      hookAnnotations(map[string]interface{}{"helm.sh/hook-delete-policy": "hook-succeeded,always"}),
+			reason:      HelmHookDeletePolicyUnknown + ` : Job/testRelease-chart-migrate ("always")`,
+			findings: []Finding{
+				{Resource: "Job/testRelease-chart-migrate", Field: "metadata.annotations.helm.sh/hook-delete-policy", Message: `Hook delete policy "always" is unknown, the hook resource won't be deleted by it`, Severity: ErrorSeverity},
+			},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, tc.values)
+			r, err := HelmHooksValid(tc.uri, config)
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, tc.findings, r.Findings)
+		})
+	}
+
+	t.Run("Should skip allowlisted resources", func(t *testing.T) {
+		config := viper.New()
+		config.Set(ValuesConfigKey, hookAnnotations(map[string]interface{}{"helm.sh/hook": "pre-upgrad"}))
+		config.Set(AllowlistConfigKey, []string{"testRelease-chart-migrate"})
+		r, err := HelmHooksValid("chart-0.1.0-v3.hooks.tgz", config)
+		require.NoError(t, err)
+		require.True(t, r.Ok)
+	})
+}
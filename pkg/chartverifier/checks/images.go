@@ -0,0 +1,466 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"helm.sh/helm/v3/pkg/release"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// MirrorsConfigKey is the check configuration key containing the image mirror map, either as a list of
+	// "source=mirror" strings or as a list of objects declaring both source and mirror.
+	MirrorsConfigKey = "mirrors"
+	// MainImageConfigKey is the check configuration key naming the main component of the chart, either by container
+	// name or by image repository, whose image tag appversion-matches-image-tag compares to the chart's appVersion.
+	MainImageConfigKey = "mainImage"
+)
+
+const (
+	ImagesAreAirgapReady   = "All images are pinned by digest and present in the mirror map"
+	ImagesAirgapSkipped    = "Airgap readiness skipped: no mirror map is configured"
+	ImageNotPinnedByDigest = "Image is not pinned by digest"
+	ImageNotMirrored       = "Image is not present in the mirror map"
+
+	ImageRegistriesApproved  = "All images come from approved registries"
+	ImageRegistriesSkipped   = "Image registries check skipped: no approved registries are configured"
+	ImageRegistryNotApproved = "Image comes from a registry that is not approved"
+	ImageRegistryImplicit    = "Image does not name its registry"
+
+	ImagesDeclareNonRootUser = "All images declare a non-root user or are overridden by a security context"
+	ImagesUserSkipped        = "Image users check skipped: network access is not allowed"
+	ImageRunsAsRoot          = "Image runs as root by default"
+	ImageConfigUnavailable   = "Image configuration could not be retrieved"
+
+	ImageTagMatchesAppVersion = "Main image tag matches the chart's appVersion"
+	ImageTagSkipped           = "Main image tag check skipped: the chart renders no workload"
+	ImageTagMismatch          = "Main image tag does not match the chart's appVersion"
+	MainImageNotFound         = "Main image not found"
+)
+
+// imageUserRequestTimeout is the time given to each registry request retrieving image configurations.
+const imageUserRequestTimeout = 30 * time.Second
+
+// containerImage is an image used by one of the containers of a rendered workload.
+type containerImage struct {
+	image    string
+	resource renderedResource
+}
+
+// getContainerImages renders the chart found at uri with the values informed in config, returning the images used by
+// each workload in the order they've been rendered; each image is returned once per workload.
+func getContainerImages(uri string, config *viper.Viper) ([]containerImage, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return nil, err
+	}
+
+	var images []containerImage
+	for _, res := range resources {
+		podSpec, ok := getPodSpec(res)
+		if !ok {
+			continue
+		}
+		seen := map[string]bool{}
+		for _, container := range getAllContainers(podSpec) {
+			if !seen[container.Image] {
+				seen[container.Image] = true
+				images = append(images, containerImage{image: container.Image, resource: res})
+			}
+		}
+	}
+
+	return images, nil
+}
+
+// getImageRepository returns the repository of the given image reference, without its tag or digest.
+func getImageRepository(image string) string {
+	repository := image
+	if i := strings.Index(repository, "@"); i != -1 {
+		repository = repository[:i]
+	}
+	if i := strings.LastIndex(repository, ":"); i != -1 && !strings.Contains(repository[i:], "/") {
+		repository = repository[:i]
+	}
+	return repository
+}
+
+// normalizeImageRepository returns the fully qualified form of the given repository, as resolved by container
+// runtimes; for example "nginx" is resolved as "docker.io/library/nginx".
+func normalizeImageRepository(repository string) string {
+	parts := strings.SplitN(repository, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return repository
+	}
+	if len(parts) == 1 {
+		return "docker.io/library/" + repository
+	}
+	return "docker.io/" + repository
+}
+
+// getImageRegistry returns the registry the given image is pulled from, informing whether the image names it rather
+// than relying on container runtimes defaulting to docker.io.
+func getImageRegistry(image string) (string, bool) {
+	parts := strings.SplitN(getImageRepository(image), "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return parts[0], true
+	}
+	return "docker.io", false
+}
+
+// getMirrorsConfig returns the image mirror map informed in config, keyed by source repository.
+func getMirrorsConfig(config *viper.Viper) map[string]string {
+	mirrors := map[string]string{}
+	add := func(entry string) {
+		if parts := strings.SplitN(entry, "=", 2); len(parts) == 2 {
+			mirrors[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+
+	switch v := config.Get(MirrorsConfigKey).(type) {
+	case string:
+		for _, entry := range strings.Split(v, ",") {
+			add(entry)
+		}
+	case []string:
+		for _, entry := range v {
+			add(entry)
+		}
+	case []interface{}:
+		for _, entry := range v {
+			switch e := entry.(type) {
+			case string:
+				add(e)
+			case map[string]interface{}:
+				add(fmt.Sprintf("%v=%v", e["source"], e["mirror"]))
+			case map[interface{}]interface{}:
+				add(fmt.Sprintf("%v=%v", e["source"], e["mirror"]))
+			}
+		}
+	}
+
+	return mirrors
+}
+
+// isMirrored reports whether the given repository, or one of its parent namespaces, is a source of the mirror map.
+func isMirrored(repository string, mirrors map[string]string) bool {
+	for _, candidate := range []string{repository, normalizeImageRepository(repository)} {
+		for source := range mirrors {
+			if candidate == source || strings.HasPrefix(candidate, strings.TrimSuffix(source, "/")+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func ImagesAirgapReady(uri string, config *viper.Viper) (Result, error) {
+	mirrors := getMirrorsConfig(config)
+	if len(mirrors) == 0 {
+		return NewResult(true, ImagesAirgapSkipped), nil
+	}
+
+	images, err := getContainerImages(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	r := NewResult(true, ImagesAreAirgapReady)
+	for _, ci := range images {
+		if !strings.Contains(ci.image, "@") {
+			addFailure(&r, fmt.Sprintf("%s : %s used by %s", ImageNotPinnedByDigest, ci.image, ci.resource))
+			r.AddFinding(Finding{
+				Resource: ci.resource.String(),
+				Field:    "image",
+				Message:  fmt.Sprintf("image %q should be referenced by digest", ci.image),
+				Severity: ErrorSeverity,
+			})
+		}
+		if !isMirrored(getImageRepository(ci.image), mirrors) {
+			addFailure(&r, fmt.Sprintf("%s : %s used by %s", ImageNotMirrored, ci.image, ci.resource))
+			r.AddFinding(Finding{
+				Resource: ci.resource.String(),
+				Field:    "image",
+				Message:  fmt.Sprintf("image %q has no mirror", ci.image),
+				Severity: ErrorSeverity,
+			})
+		}
+	}
+
+	return r, nil
+}
+
+// ImagesFromApprovedRegistries checks the images used by the chart's workloads come from the registries, or
+// repositories, matching the patterns configured through AllowlistConfigKey.
+func ImagesFromApprovedRegistries(uri string, config *viper.Viper) (Result, error) {
+	approved := getStringSliceConfig(config, AllowlistConfigKey)
+	if len(approved) == 0 {
+		return NewResult(true, ImageRegistriesSkipped), nil
+	}
+
+	images, err := getContainerImages(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	r := NewResult(true, ImageRegistriesApproved)
+	for _, ci := range images {
+		registry, explicit := getImageRegistry(ci.image)
+		switch {
+		case !explicit:
+			// the registry the image is pulled from depends on the container runtime configuration
+			addFailure(&r, fmt.Sprintf("%s : %s used by %s", ImageRegistryImplicit, ci.image, ci.resource))
+			r.AddFinding(Finding{
+				Resource: ci.resource.String(),
+				Field:    "image",
+				Message:  fmt.Sprintf("image %q should name its registry rather than default to %s", ci.image, registry),
+				Severity: ErrorSeverity,
+			})
+		case !isTrustedRepository(registry, approved) && !isTrustedRepository(getImageRepository(ci.image), approved):
+			addFailure(&r, fmt.Sprintf("%s : %s used by %s", ImageRegistryNotApproved, ci.image, ci.resource))
+			r.AddFinding(Finding{
+				Resource: ci.resource.String(),
+				Field:    "image",
+				Message:  fmt.Sprintf("image %q comes from registry %s, which is not approved", ci.image, registry),
+				Severity: ErrorSeverity,
+			})
+		}
+	}
+
+	return r, nil
+}
+
+// isRootUser informs whether the given image USER, either "user", "uid", "user:group" or "uid:gid", runs as root;
+// images not declaring a user run as root.
+func isRootUser(user string) bool {
+	user = strings.TrimSpace(strings.SplitN(user, ":", 2)[0])
+	return user == "" || user == "root" || user == "0"
+}
+
+// overridesRootUser informs whether the security contexts of the given container, or its pod, run it with a non-root
+// user regardless of the user declared by its image.
+func overridesRootUser(podSpec *corev1.PodSpec, container *corev1.Container) bool {
+	if container.SecurityContext != nil && container.SecurityContext.RunAsUser != nil {
+		return *container.SecurityContext.RunAsUser != 0
+	}
+	return podSpec.SecurityContext != nil && podSpec.SecurityContext.RunAsUser != nil && *podSpec.SecurityContext.RunAsUser != 0
+}
+
+// ImagesDeclareNonRootUsers checks the images used by the chart's containers declare a non-root USER, retrieving
+// their configuration from their registries, unless the containers are run with a non-root user through their
+// security contexts.
+func ImagesDeclareNonRootUsers(uri string, config *viper.Viper) (Result, error) {
+	if !config.GetBool(AllowNetworkConfigKey) {
+		return NewResult(true, ImagesUserSkipped), nil
+	}
+
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	allowlist := getStringSetConfig(config, AllowlistConfigKey)
+	client := newRegistryClient(newConfiguredHTTPClient(config, imageUserRequestTimeout), getRateLimiter(config))
+	// configurations and errors are kept per image, as images are often shared by several workloads
+	configs := map[string]*imageConfig{}
+	configErrs := map[string]error{}
+
+	r := NewResult(true, ImagesDeclareNonRootUser)
+	for _, res := range resources {
+		podSpec, ok := getPodSpec(res)
+		if !ok {
+			continue
+		}
+
+		for _, c := range getIndexedContainers(podSpec, strings.Join(podSpecFields[res.GetKind()], ".")) {
+			image := c.container.Image
+			if allowlist[image] || allowlist[getImageRepository(image)] || overridesRootUser(podSpec, c.container) {
+				continue
+			}
+
+			if _, ok := configs[image]; !ok && configErrs[image] == nil {
+				configs[image], configErrs[image] = client.getImageConfig(parseImageReference(image))
+			}
+
+			if err := configErrs[image]; err != nil {
+				addFailure(&r, fmt.Sprintf("%s : %s used by %s : %v", ImageConfigUnavailable, image, res, err))
+				r.AddFinding(Finding{
+					Resource: res.String(),
+					Field:    c.field + ".image",
+					Message:  fmt.Sprintf("configuration of image %q could not be retrieved: %v", image, err),
+					Severity: ErrorSeverity,
+				})
+			} else if isRootUser(configs[image].Config.User) {
+				addFailure(&r, fmt.Sprintf("%s : %s used by %s", ImageRunsAsRoot, image, res))
+				r.AddFinding(Finding{
+					Resource: res.String(),
+					Field:    c.field + ".image",
+					Message:  fmt.Sprintf("image %q runs as root; declare a non-root USER or set securityContext.runAsUser", image),
+					Severity: ErrorSeverity,
+				})
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// mainContainer is a container of a rendered workload, candidate to run the chart's main component.
+type mainContainer struct {
+	indexedContainer
+	resource renderedResource
+}
+
+// getImageTag returns the tag of the given image reference, or an empty string if the image is only pinned by digest;
+// images without tag nor digest default to the latest tag.
+func getImageTag(image string) string {
+	if i := strings.Index(image, "@"); i != -1 {
+		image = image[:i]
+		if repository := getImageRepository(image); len(image) == len(repository) {
+			return ""
+		}
+	}
+	if repository := getImageRepository(image); len(image) > len(repository) {
+		return image[len(repository)+1:]
+	}
+	return "latest"
+}
+
+// imageTagMatches compares tag to appVersion; unless strict, a leading "v" is ignored and variants of the version,
+// such as "1.16.0-alpine", match.
+func imageTagMatches(tag, appVersion string, strict bool) bool {
+	if strict {
+		return tag == appVersion
+	}
+	tag, appVersion = strings.TrimPrefix(tag, "v"), strings.TrimPrefix(appVersion, "v")
+	return tag == appVersion || strings.HasPrefix(tag, appVersion+"-") || strings.HasPrefix(tag, appVersion+"_")
+}
+
+// matchesComponent informs whether c is named after the given component, either by container name or by image
+// repository, including the last path segment of the repository.
+func (c mainContainer) matchesComponent(component string) bool {
+	repository := getImageRepository(c.container.Image)
+	return c.container.Name == component || repository == component || path.Base(repository) == component
+}
+
+// findMainContainer returns the container running the chart's main component: the one named by component when
+// informed; otherwise the one named after the chart, or the first container of the first workload. Hooks, such as
+// test pods, and init containers are ignored.
+func findMainContainer(resources []renderedResource, chartName, component string) (*mainContainer, error) {
+	var candidates []mainContainer
+	for _, res := range resources {
+		if _, ok := res.GetAnnotations()[release.HookAnnotation]; ok {
+			continue
+		}
+		podSpec, ok := getPodSpec(res)
+		if !ok {
+			continue
+		}
+		for _, c := range getIndexedContainers(podSpec, strings.Join(podSpecFields[res.GetKind()], ".")) {
+			if !strings.Contains(c.field, ".initContainers[") {
+				candidates = append(candidates, mainContainer{indexedContainer: c, resource: res})
+			}
+		}
+	}
+
+	if component != "" {
+		for i := range candidates {
+			if candidates[i].matchesComponent(component) {
+				return &candidates[i], nil
+			}
+		}
+		return nil, nil
+	}
+	for i := range candidates {
+		if candidates[i].matchesComponent(chartName) {
+			return &candidates[i], nil
+		}
+	}
+	if len(candidates) > 0 {
+		return &candidates[0], nil
+	}
+	return nil, nil
+}
+
+func AppVersionMatchesImageTag(uri string, config *viper.Viper) (Result, error) {
+	c, _, err := LoadChartFromURI(uri)
+	if err != nil {
+		return NewResult(false, err.Error()), err
+	}
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	component := config.GetString(MainImageConfigKey)
+	main, err := findMainContainer(resources, c.Name(), component)
+	if err != nil {
+		return Result{}, err
+	}
+	if main == nil {
+		if component != "" {
+			return NewResult(false, fmt.Sprintf("%s : no container nor image repository is named %s", MainImageNotFound, component)), nil
+		}
+		return NewResult(true, ImageTagSkipped), nil
+	}
+
+	image := main.container.Image
+	appVersion := c.AppVersion()
+	tag := getImageTag(image)
+	strict := config.GetBool(StrictConfigKey)
+	if tag != "" && imageTagMatches(tag, appVersion, strict) {
+		return NewResult(true, ImageTagMatchesAppVersion), nil
+	}
+
+	message := fmt.Sprintf("Image %s is tagged %s, while the chart's appVersion is %s", image, tag, appVersion)
+	if tag == "" {
+		message = fmt.Sprintf("Image %s is only pinned by digest, its tag can't be compared to the chart's appVersion %s", image, appVersion)
+	}
+	reason := fmt.Sprintf("%s : %s (%s)", ImageTagMismatch, main.resource, image)
+	finding := Finding{
+		Resource: main.resource.String(),
+		Field:    main.field + ".image",
+		Message:  message,
+		Severity: WarningSeverity,
+	}
+
+	// image tags diverging from the appVersion are only reported as warnings unless strict
+	r := NewResult(true, reason)
+	if strict {
+		r.SetResult(false, reason)
+		finding.Severity = ErrorSeverity
+	}
+	r.AddFinding(finding)
+
+	// images pinned by digest aren't suggested a tag, which would drop the digest
+	if config.GetBool(SuggestFixesConfigKey) && appVersion != "" && !strings.Contains(image, "@") {
+		repository := strings.TrimSuffix(image, ":"+tag)
+		suggestion, err := newSuggestion(main.resource.String(), fmt.Sprintf("Tag image %s with the chart's appVersion %s", repository, appVersion),
+			containerPatch(main.field, main.container.Name, map[string]interface{}{"image": repository + ":" + appVersion}))
+		if err != nil {
+			return Result{}, err
+		}
+		r.AddSuggestion(suggestion)
+	}
+	return r, nil
+}
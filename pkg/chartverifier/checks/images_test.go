@@ -0,0 +1,512 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+func TestImagesAirgapReady(t *testing.T) {
+	allMirrors := []interface{}{
+		map[string]interface{}{"source": "docker.io/library/nginx", "mirror": "mirror.example.com/nginx"},
+		"docker.io/library/busybox=mirror.example.com/busybox",
+	}
+
+	type testCase struct {
+		description string
+		uri         string
+		mirrors     interface{}
+		reason      string
+		reasons     []string
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart with digest pinned and mirrored images", uri: "chart-0.1.0-v3.digest-pinned.tgz", mirrors: allMirrors, reason: ImagesAreAirgapReady},
+		{description: "chart with images mirrored by namespace", uri: "chart-0.1.0-v3.digest-pinned.tgz", mirrors: "docker.io/library=mirror.example.com/library", reason: ImagesAreAirgapReady},
+		{description: "chart without mirror map", uri: "chart-0.1.0-v3.valid.tgz", reason: ImagesAirgapSkipped},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(MirrorsConfigKey, tc.mirrors)
+			r, err := ImagesAirgapReady(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.True(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart with tag only images",
+			uri:         "chart-0.1.0-v3.valid.tgz",
+			mirrors:     allMirrors,
+			reasons: []string{
+				ImageNotPinnedByDigest + " : busybox used by Pod/testRelease-chart-test-connection",
+				ImageNotPinnedByDigest + " : nginx:1.16.0 used by Deployment/testRelease-chart",
+			},
+		},
+		{
+			description: "chart with an unmapped image",
+			uri:         "chart-0.1.0-v3.digest-pinned.tgz",
+			mirrors:     "nginx=mirror.example.com/nginx",
+			reasons: []string{
+				ImageNotMirrored + " : busybox@sha256:ce2360d5189a033012fbad1635e037be86f23b65cfd676b436d0931af390a2ac used by Pod/testRelease-chart-test-connection",
+			},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(MirrorsConfigKey, tc.mirrors)
+			r, err := ImagesAirgapReady(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.False(t, r.Ok)
+			for _, reason := range tc.reasons {
+				require.Contains(t, r.Reason, reason)
+			}
+			require.Len(t, r.Findings, len(tc.reasons))
+		})
+	}
+}
+
+func TestGetImageRepository(t *testing.T) {
+	for image, repository := range map[string]string{
+		"nginx":                              "nginx",
+		"nginx:1.16.0":                       "nginx",
+		"nginx@sha256:abc":                   "nginx",
+		"localhost:5000/nginx":               "localhost:5000/nginx",
+		"localhost:5000/nginx:1.16.0":        "localhost:5000/nginx",
+		"quay.io/org/image:tag@sha256:abc":   "quay.io/org/image",
+		"registry.example.com:443/org/image": "registry.example.com:443/org/image",
+	} {
+		require.Equal(t, repository, getImageRepository(image), image)
+	}
+}
+
+func TestImagesFromApprovedRegistries(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		values      chartutil.Values
+		allowlist   []string
+		reason      string
+		reasons     []string
+	}
+
+	uri := "chart-0.1.0-v3.approved-registries.tgz"
+	mirrored := chartutil.Values{"image": map[string]interface{}{"repository": "mirror.internal.example.com/nginx"}}
+
+	positiveTestCases := []testCase{
+		{description: "chart with images from approved registries", uri: uri, allowlist: []string{"registry.redhat.io"}, reason: ImageRegistriesApproved},
+		{description: "chart with an image matching a wildcard mirror", uri: uri, values: mirrored, allowlist: []string{"registry.redhat.io", "*.internal.example.com"}, reason: ImageRegistriesApproved},
+		{description: "chart with images matching approved repositories", uri: uri, allowlist: []string{"registry.redhat.io/rhel8/*", "registry.redhat.io/ubi8/*"}, reason: ImageRegistriesApproved},
+		{description: "chart without approved registries", uri: "chart-0.1.0-v3.valid.tgz", reason: ImageRegistriesSkipped},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			if tc.values != nil {
+				config.Set(ValuesConfigKey, tc.values)
+			}
+			r, err := ImagesFromApprovedRegistries(tc.uri, config)
+			require.NoError(t, err)
+			require.True(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart with images without registry",
+			uri:         "chart-0.1.0-v3.valid.tgz",
+			allowlist:   []string{"registry.redhat.io", "docker.io"},
+			reasons: []string{
+				ImageRegistryImplicit + " : busybox used by Pod/testRelease-chart-test-connection",
+				ImageRegistryImplicit + " : nginx:1.16.0 used by Deployment/testRelease-chart",
+			},
+		},
+		{
+			description: "chart with a docker.io image",
+			uri:         uri,
+			values:      chartutil.Values{"image": map[string]interface{}{"repository": "docker.io/library/nginx"}},
+			allowlist:   []string{"registry.redhat.io"},
+			reasons: []string{
+				ImageRegistryNotApproved + " : docker.io/library/nginx:1.16.0 used by Deployment/testRelease-chart",
+			},
+		},
+		{
+			description: "chart with a mirror not matching the wildcard",
+			uri:         uri,
+			values:      mirrored,
+			allowlist:   []string{"registry.redhat.io", "*.external.example.com"},
+			reasons: []string{
+				ImageRegistryNotApproved + " : mirror.internal.example.com/nginx:1.16.0 used by Deployment/testRelease-chart",
+			},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			if tc.values != nil {
+				config.Set(ValuesConfigKey, tc.values)
+			}
+			r, err := ImagesFromApprovedRegistries(tc.uri, config)
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			for _, reason := range tc.reasons {
+				require.Contains(t, r.Reason, reason)
+			}
+			require.Len(t, r.Findings, len(tc.reasons))
+		})
+	}
+}
+
+// newFakeRegistry starts a registry serving team/root, an image without USER, team/app, a multi-platform image with
+// USER 1001, and team/private, an image with USER 1001 requiring an anonymous bearer token.
+func newFakeRegistry(t *testing.T) *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v2/team/private/") && r.Header.Get("Authorization") != "Bearer anonymous" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:team/private:pull"`, srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/token":
+			if r.URL.Query().Get("scope") != "repository:team/private:pull" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, `{"token":"anonymous"}`)
+		case "/v2/team/root/manifests/1.0":
+			fmt.Fprint(w, `{"schemaVersion":2,"config":{"digest":"sha256:root"}}`)
+		case "/v2/team/app/manifests/1.0":
+			fmt.Fprint(w, `{"schemaVersion":2,"manifests":[`+
+				`{"digest":"sha256:app-arm64","platform":{"os":"linux","architecture":"arm64"}},`+
+				`{"digest":"sha256:app-amd64","platform":{"os":"linux","architecture":"amd64"}}]}`)
+		case "/v2/team/app/manifests/sha256:app-amd64", "/v2/team/private/manifests/1.0":
+			fmt.Fprint(w, `{"schemaVersion":2,"config":{"digest":"sha256:nonroot"}}`)
+		case "/v2/team/root/blobs/sha256:root":
+			fmt.Fprint(w, `{"config":{"Env":["PATH=/usr/bin"]}}`)
+		case "/v2/team/app/blobs/sha256:nonroot", "/v2/team/private/blobs/sha256:nonroot":
+			fmt.Fprint(w, `{"config":{"User":"1001"}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestImagesDeclareNonRootUsers(t *testing.T) {
+	type testCase struct {
+		description string
+		values      chartutil.Values
+		reason      string
+		reasons     []string
+	}
+
+	srv := newFakeRegistry(t)
+	registry := strings.TrimPrefix(srv.URL, "https://")
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	image := func(repository string) map[string]interface{} {
+		return map[string]interface{}{"repository": registry + "/" + repository, "tag": "1.0"}
+	}
+	runAsUser := func(uid int64) map[string]interface{} {
+		return map[string]interface{}{"runAsUser": uid}
+	}
+
+	newConfig := func(values chartutil.Values) *viper.Viper {
+		config := viper.New()
+		config.Set(AllowNetworkConfigKey, true)
+		config.Set(TLSConfigKey, &tls.Config{RootCAs: roots})
+		// the test pod's image is pulled from docker.io
+		config.Set(AllowlistConfigKey, []string{"busybox"})
+		config.Set(ValuesConfigKey, values)
+		return config
+	}
+
+	positiveTestCases := []testCase{
+		{description: "image declaring a non-root user", values: chartutil.Values{"image": image("team/app")}, reason: ImagesDeclareNonRootUser},
+		{description: "image declaring a non-root user requiring a token", values: chartutil.Values{"image": image("team/private")}, reason: ImagesDeclareNonRootUser},
+		{description: "root image overridden by the container", values: chartutil.Values{"image": image("team/root"), "securityContext": runAsUser(1001)}, reason: ImagesDeclareNonRootUser},
+		{description: "root image overridden by the pod", values: chartutil.Values{"image": image("team/root"), "podSecurityContext": runAsUser(1001)}, reason: ImagesDeclareNonRootUser},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			r, err := ImagesDeclareNonRootUsers("chart-0.1.0-v3.valid.tgz", newConfig(tc.values))
+			require.NoError(t, err)
+			require.True(t, r.Ok, r.Reason)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	t.Run("Should skip when network access is not allowed", func(t *testing.T) {
+		r, err := ImagesDeclareNonRootUsers("chart-0.1.0-v3.valid.tgz", viper.New())
+		require.NoError(t, err)
+		require.True(t, r.Ok)
+		require.Equal(t, ImagesUserSkipped, r.Reason)
+	})
+
+	negativeTestCases := []testCase{
+		{
+			description: "image without user",
+			values:      chartutil.Values{"image": image("team/root")},
+			reasons:     []string{ImageRunsAsRoot + " : " + registry + "/team/root:1.0 used by Deployment/testRelease-chart"},
+		},
+		{
+			description: "root image with the pod's user reset to root by the container",
+			values:      chartutil.Values{"image": image("team/root"), "podSecurityContext": runAsUser(1001), "securityContext": runAsUser(0)},
+			reasons:     []string{ImageRunsAsRoot + " : " + registry + "/team/root:1.0 used by Deployment/testRelease-chart"},
+		},
+		{
+			description: "image missing from the registry",
+			values:      chartutil.Values{"image": image("team/missing")},
+			reasons:     []string{ImageConfigUnavailable + " : " + registry + "/team/missing:1.0 used by Deployment/testRelease-chart"},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			r, err := ImagesDeclareNonRootUsers("chart-0.1.0-v3.valid.tgz", newConfig(tc.values))
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			for _, reason := range tc.reasons {
+				require.Contains(t, r.Reason, reason)
+			}
+			require.Len(t, r.Findings, len(tc.reasons))
+			require.Equal(t, "spec.template.spec.containers[0].image", r.Findings[0].Field)
+		})
+	}
+}
+
+func TestParseImageReference(t *testing.T) {
+	require.Equal(t, imageReference{"registry-1.docker.io", "library/nginx", "latest"}, parseImageReference("nginx"))
+	require.Equal(t, imageReference{"registry-1.docker.io", "bitnami/redis", "6.2"}, parseImageReference("bitnami/redis:6.2"))
+	require.Equal(t, imageReference{"localhost:5000", "team/app", "sha256:abc"}, parseImageReference("localhost:5000/team/app@sha256:abc"))
+	require.Equal(t, imageReference{"quay.io", "team/app", "1.0"}, parseImageReference("quay.io/team/app:1.0"))
+}
+
+func TestIsRootUser(t *testing.T) {
+	for _, user := range []string{"", "root", "0", "0:0", "root:wheel"} {
+		require.True(t, isRootUser(user), user)
+	}
+	for _, user := range []string{"1001", "nobody", "1001:0"} {
+		require.False(t, isRootUser(user), user)
+	}
+}
+
+func TestGetImageTag(t *testing.T) {
+	for image, tag := range map[string]string{
+		"nginx":                           "latest",
+		"nginx:1.16.0":                    "1.16.0",
+		"localhost:5000/team/app:v2":      "v2",
+		"quay.io/team/app@sha256:abc":     "",
+		"quay.io/team/app:1.0@sha256:abc": "1.0",
+	} {
+		require.Equal(t, tag, getImageTag(image), image)
+	}
+}
+
+func TestAppVersionMatchesImageTag(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		tag         string
+		config      map[string]interface{}
+		reason      string
+		finding     *Finding
+	}
+
+	uri := "chart-0.1.0-v3.valid.tgz"
+	sidecarUri := "chart-0.1.0-v3.sidecar-images.tgz"
+	strict := map[string]interface{}{StrictConfigKey: true}
+
+	positiveTestCases := []testCase{
+		{description: "chart whose image tag defaults to the appVersion", uri: uri, reason: ImageTagMatchesAppVersion},
+		{description: "chart whose image tag is a variant of the appVersion", uri: uri, tag: "1.16.0-alpine", reason: ImageTagMatchesAppVersion},
+		{description: "chart whose image tag prefixes the appVersion with v", uri: uri, tag: "v1.16.0", reason: ImageTagMatchesAppVersion},
+		{description: "chart whose image tag strictly matches the appVersion", uri: uri, config: strict, reason: ImageTagMatchesAppVersion},
+		{
+			description: "chart whose main container is named by option",
+			uri:         sidecarUri,
+			config:      map[string]interface{}{MainImageConfigKey: "server", StrictConfigKey: true},
+			reason:      ImageTagMatchesAppVersion,
+		},
+		{
+			description: "chart whose main image repository is named by option",
+			uri:         sidecarUri,
+			config:      map[string]interface{}{MainImageConfigKey: "nginx", StrictConfigKey: true},
+			reason:      ImageTagMatchesAppVersion,
+		},
+		{
+			description: "chart whose image tag diverges from the appVersion, only reported as a warning",
+			uri:         uri,
+			tag:         "1.17.0",
+			reason:      ImageTagMismatch + " : Deployment/testRelease-chart (nginx:1.17.0)",
+			finding: &Finding{
+				Resource: "Deployment/testRelease-chart",
+				Field:    "spec.template.spec.containers[0].image",
+				Message:  "Image nginx:1.17.0 is tagged 1.17.0, while the chart's appVersion is 1.16.0",
+				Severity: WarningSeverity,
+			},
+		},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, chartutil.Values{"image": map[string]interface{}{"tag": tc.tag}})
+			for k, v := range tc.config {
+				config.Set(k, v)
+			}
+			r, err := AppVersionMatchesImageTag(tc.uri, config)
+			require.NoError(t, err)
+			require.True(t, r.Ok, r.Reason)
+			require.Equal(t, tc.reason, r.Reason)
+			if tc.finding == nil {
+				require.Empty(t, r.Findings)
+			} else {
+				require.Equal(t, []Finding{*tc.finding}, r.Findings)
+			}
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart whose image tag diverges from the appVersion",
+			uri:         uri,
+			tag:         "1.17.0",
+			config:      strict,
+			reason:      ImageTagMismatch + " : Deployment/testRelease-chart (nginx:1.17.0)",
+			finding: &Finding{
+				Resource: "Deployment/testRelease-chart",
+				Field:    "spec.template.spec.containers[0].image",
+				Message:  "Image nginx:1.17.0 is tagged 1.17.0, while the chart's appVersion is 1.16.0",
+				Severity: ErrorSeverity,
+			},
+		},
+		{
+			description: "chart whose image tag is only a variant of the appVersion",
+			uri:         uri,
+			tag:         "1.16.0-alpine",
+			config:      strict,
+			reason:      ImageTagMismatch + " : Deployment/testRelease-chart (nginx:1.16.0-alpine)",
+			finding: &Finding{
+				Resource: "Deployment/testRelease-chart",
+				Field:    "spec.template.spec.containers[0].image",
+				Message:  "Image nginx:1.16.0-alpine is tagged 1.16.0-alpine, while the chart's appVersion is 1.16.0",
+				Severity: ErrorSeverity,
+			},
+		},
+		{
+			description: "chart whose sidecar is taken for the main container",
+			uri:         sidecarUri,
+			config:      strict,
+			reason:      ImageTagMismatch + " : Deployment/testRelease-chart (quay.io/team/envoy:v1.20.0)",
+			finding: &Finding{
+				Resource: "Deployment/testRelease-chart",
+				Field:    "spec.template.spec.containers[0].image",
+				Message:  "Image quay.io/team/envoy:v1.20.0 is tagged v1.20.0, while the chart's appVersion is 1.16.0",
+				Severity: ErrorSeverity,
+			},
+		},
+		{
+			description: "chart without the main container named by option",
+			uri:         sidecarUri,
+			config:      map[string]interface{}{MainImageConfigKey: "app"},
+			reason:      MainImageNotFound + " : no container nor image repository is named app",
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, chartutil.Values{"image": map[string]interface{}{"tag": tc.tag}})
+			for k, v := range tc.config {
+				config.Set(k, v)
+			}
+			r, err := AppVersionMatchesImageTag(tc.uri, config)
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			if tc.finding == nil {
+				require.Empty(t, r.Findings)
+			} else {
+				require.Equal(t, []Finding{*tc.finding}, r.Findings)
+			}
+		})
+	}
+}
+
+func TestAppVersionMatchesImageTagSuggestions(t *testing.T) {
+	uri := "chart-0.1.0-v3.valid.tgz"
+
+	t.Run("Should suggest tagging the main image with the appVersion", func(t *testing.T) {
+		config := viper.New()
+		config.Set(ValuesConfigKey, chartutil.Values{"image": map[string]interface{}{"tag": "1.17.0"}})
+		config.Set(StrictConfigKey, true)
+		config.Set(SuggestFixesConfigKey, true)
+		r, err := AppVersionMatchesImageTag(uri, config)
+		require.NoError(t, err)
+		require.False(t, r.Ok)
+		require.Equal(t, []Suggestion{{
+			Resource:    "Deployment/testRelease-chart",
+			Description: "Tag image nginx with the chart's appVersion 1.16.0",
+			Patch:       "spec:\n  template:\n    spec:\n      containers:\n      - image: nginx:1.16.0\n        name: chart\n",
+		}}, r.Suggestions)
+
+		deployment := applySuggestion(t, uri, config, r.Suggestions[0])
+		require.Len(t, deployment.Spec.Template.Spec.Containers, 1)
+		require.Equal(t, "nginx:1.16.0", deployment.Spec.Template.Spec.Containers[0].Image)
+		require.Equal(t, "IfNotPresent", string(deployment.Spec.Template.Spec.Containers[0].ImagePullPolicy))
+	})
+
+	t.Run("Should not suggest dropping the digest of images pinned by digest", func(t *testing.T) {
+		config := viper.New()
+		config.Set(ValuesConfigKey, chartutil.Values{"image": map[string]interface{}{"tag": "1.17.0@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"}})
+		config.Set(SuggestFixesConfigKey, true)
+		r, err := AppVersionMatchesImageTag(uri, config)
+		require.NoError(t, err)
+		require.NotEmpty(t, r.Findings)
+		require.Empty(t, r.Suggestions)
+	})
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"strconv"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+const (
+	// KubeVersionConfigKey is the check configuration key informing the Kubernetes version, e.g. "1.21.0", templates
+	// are rendered for and version dependent checks target; Helm's default version is used when not informed.
+	KubeVersionConfigKey = "kubeVersion"
+)
+
+// CapabilitiesForKubeVersion returns the capabilities templates are rendered with when targeting the given Kubernetes
+// version; Helm's default capabilities are returned for an empty version.
+func CapabilitiesForKubeVersion(version string) (*chartutil.Capabilities, error) {
+	// a copy, since Helm appends to the API versions of the capabilities it renders with
+	caps := *chartutil.DefaultCapabilities
+	caps.APIVersions = append(chartutil.VersionSet{}, chartutil.DefaultCapabilities.APIVersions...)
+	if version == "" {
+		return &caps, nil
+	}
+
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return nil, errors.Errorf("invalid Kubernetes version %q: %v", version, err)
+	}
+	caps.KubeVersion = chartutil.KubeVersion{
+		Version: "v" + v.String(),
+		Major:   strconv.FormatUint(v.Major(), 10),
+		Minor:   strconv.FormatUint(v.Minor(), 10),
+	}
+	return &caps, nil
+}
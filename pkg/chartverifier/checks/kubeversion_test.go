@@ -0,0 +1,45 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+func TestCapabilitiesForKubeVersion(t *testing.T) {
+	t.Run("Should return Helm's default capabilities when no version is informed", func(t *testing.T) {
+		caps, err := CapabilitiesForKubeVersion("")
+		require.NoError(t, err)
+		require.Equal(t, chartutil.DefaultCapabilities.KubeVersion, caps.KubeVersion)
+		require.NotSame(t, chartutil.DefaultCapabilities, caps)
+	})
+
+	t.Run("Should report the informed version", func(t *testing.T) {
+		caps, err := CapabilitiesForKubeVersion("1.22")
+		require.NoError(t, err)
+		require.Equal(t, chartutil.KubeVersion{Version: "v1.22.0", Major: "1", Minor: "22"}, caps.KubeVersion)
+		require.Equal(t, "v1.20.0", chartutil.DefaultCapabilities.KubeVersion.Version)
+	})
+
+	t.Run("Should reject an invalid version", func(t *testing.T) {
+		_, err := CapabilitiesForKubeVersion("latest")
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,218 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// StrictConfigKey is the check configuration key enabling a check's stricter set of rules.
+	StrictConfigKey = "strict"
+)
+
+const (
+	NodePortServicesAbsent   = "Chart does not expose NodePort Services"
+	NodePortServiceFound     = "Service exposes a NodePort"
+	LoadBalancerServiceFound = "Service exposes a LoadBalancer"
+	IngressHostsUnique       = "Ingress and Route hosts are unique"
+	IngressHostDuplicated    = "Host is exposed by more than one resource"
+	IngressHostWithoutTLS    = "Host is exposed without TLS"
+)
+
+func NoNodePortServices(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	strict := config.GetBool(StrictConfigKey)
+	allowlist := getStringSetConfig(config, AllowlistConfigKey)
+
+	r := NewResult(true, NodePortServicesAbsent)
+	for _, res := range resources {
+		if res.GetKind() != "Service" || allowlist[res.GetName()] {
+			continue
+		}
+
+		serviceType, _, err := unstructured.NestedString(res.Object, "spec", "type")
+		if err != nil {
+			return Result{}, err
+		}
+
+		var reason string
+		switch {
+		case serviceType == "NodePort":
+			reason = NodePortServiceFound
+		case serviceType == "LoadBalancer" && strict:
+			reason = LoadBalancerServiceFound
+		default:
+			continue
+		}
+
+		addFailure(&r, fmt.Sprintf("%s : %s", reason, res))
+		r.AddFinding(Finding{
+			Resource: res.String(),
+			Field:    "spec.type",
+			Message:  fmt.Sprintf("Service of type %s should be replaced by a Route or an Ingress", serviceType),
+			Severity: ErrorSeverity,
+		})
+	}
+
+	return r, nil
+}
+
+// exposedHost is a host exposed by an Ingress or a Route.
+type exposedHost struct {
+	resource renderedResource
+	host     string
+	field    string
+	tls      bool
+}
+
+// getIngressHosts returns the hosts exposed by the given Ingress, informing whether each is covered by its TLS
+// configuration.
+func getIngressHosts(res renderedResource) ([]exposedHost, error) {
+	tlsEntries, _, err := unstructured.NestedSlice(res.Object, "spec", "tls")
+	if err != nil {
+		return nil, err
+	}
+	var tlsHosts []string
+	for _, entry := range tlsEntries {
+		if m, ok := entry.(map[string]interface{}); ok {
+			hosts, _, _ := unstructured.NestedStringSlice(m, "hosts")
+			tlsHosts = append(tlsHosts, hosts...)
+		}
+	}
+
+	rules, _, err := unstructured.NestedSlice(res.Object, "spec", "rules")
+	if err != nil {
+		return nil, err
+	}
+	var hosts []exposedHost
+	seen := map[string]bool{}
+	for i, rule := range rules {
+		m, ok := rule.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		// rules sharing a host only route distinct paths, so the host is exposed once by the Ingress
+		host, _, _ := unstructured.NestedString(m, "host")
+		if host == "" || seen[host] {
+			continue
+		}
+		seen[host] = true
+		hosts = append(hosts, exposedHost{
+			resource: res,
+			host:     host,
+			field:    fmt.Sprintf("spec.rules[%d].host", i),
+			tls:      tlsCovers(tlsHosts, host),
+		})
+	}
+	return hosts, nil
+}
+
+// getRouteHost returns the host exposed by the given Route, if any.
+func getRouteHost(res renderedResource) ([]exposedHost, error) {
+	host, _, err := unstructured.NestedString(res.Object, "spec", "host")
+	if err != nil || host == "" {
+		return nil, err
+	}
+	tls, _, err := unstructured.NestedMap(res.Object, "spec", "tls")
+	if err != nil {
+		return nil, err
+	}
+	return []exposedHost{{resource: res, host: host, field: "spec.host", tls: len(tls) > 0}}, nil
+}
+
+// tlsCovers informs whether host is one of tlsHosts, or matches one of its wildcard hosts, e.g. "*.example.com".
+func tlsCovers(tlsHosts []string, host string) bool {
+	for _, h := range tlsHosts {
+		if h == host {
+			return true
+		}
+		if strings.HasPrefix(h, "*.") {
+			if i := strings.Index(host, "."); i > 0 && host[i:] == h[1:] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func IngressHostsValid(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	strict := config.GetBool(StrictConfigKey)
+	allowlist := getStringSetConfig(config, AllowlistConfigKey)
+
+	var hosts []exposedHost
+	for _, res := range resources {
+		if allowlist[res.GetName()] {
+			continue
+		}
+
+		var resourceHosts []exposedHost
+		switch res.GetKind() {
+		case "Ingress":
+			resourceHosts, err = getIngressHosts(res)
+		case "Route":
+			resourceHosts, err = getRouteHost(res)
+		default:
+			continue
+		}
+		if err != nil {
+			return Result{}, err
+		}
+		hosts = append(hosts, resourceHosts...)
+	}
+
+	r := NewResult(true, IngressHostsUnique)
+	exposedBy := map[string]renderedResource{}
+	for _, h := range hosts {
+		if first, ok := exposedBy[h.host]; ok {
+			addFailure(&r, fmt.Sprintf("%s : %s exposed by %s and %s", IngressHostDuplicated, h.host, first, h.resource))
+			r.AddFinding(Finding{
+				Resource: h.resource.String(),
+				Field:    h.field,
+				Message:  fmt.Sprintf("Host %s is already exposed by %s", h.host, first),
+				Severity: ErrorSeverity,
+			})
+		} else {
+			exposedBy[h.host] = h.resource
+		}
+
+		if strict && !h.tls {
+			addFailure(&r, fmt.Sprintf("%s : %s exposed by %s", IngressHostWithoutTLS, h.host, h.resource))
+			r.AddFinding(Finding{
+				Resource: h.resource.String(),
+				Field:    h.field,
+				Message:  fmt.Sprintf("Host %s should be served over TLS", h.host),
+				Severity: ErrorSeverity,
+			})
+		}
+	}
+
+	return r, nil
+}
@@ -0,0 +1,198 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+func TestNoNodePortServices(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		strict      bool
+		allowlist   []string
+		reason      string
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart with a ClusterIP service", uri: "chart-0.1.0-v3.valid.tgz"},
+		{description: "chart with a ClusterIP service in strict mode", uri: "chart-0.1.0-v3.valid.tgz", strict: true},
+		{description: "chart with an allowlisted NodePort service", uri: "chart-0.1.0-v3.nodeport-service.tgz", allowlist: []string{"testRelease-chart"}},
+		{description: "chart with a LoadBalancer service", uri: "chart-0.1.0-v3.loadbalancer-service.tgz"},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(StrictConfigKey, tc.strict)
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			r, err := NoNodePortServices(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.True(t, r.Ok)
+			require.Equal(t, NodePortServicesAbsent, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart with a NodePort service",
+			uri:         "chart-0.1.0-v3.nodeport-service.tgz",
+			reason:      NodePortServiceFound + " : Service/testRelease-chart",
+		},
+		{
+			description: "chart with a NodePort service allowlisting another service",
+			uri:         "chart-0.1.0-v3.nodeport-service.tgz",
+			allowlist:   []string{"other-service"},
+			reason:      NodePortServiceFound + " : Service/testRelease-chart",
+		},
+		{
+			description: "chart with a LoadBalancer service in strict mode",
+			uri:         "chart-0.1.0-v3.loadbalancer-service.tgz",
+			strict:      true,
+			reason:      LoadBalancerServiceFound + " : Service/testRelease-chart",
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(StrictConfigKey, tc.strict)
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			r, err := NoNodePortServices(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Len(t, r.Findings, 1)
+			require.Equal(t, "Service/testRelease-chart", r.Findings[0].Resource)
+		})
+	}
+}
+
+func TestIngressHostsValid(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		values      chartutil.Values
+		strict      bool
+		allowlist   []string
+		reason      string
+		findings    []Finding
+	}
+
+	ingress := func(host string, tlsHosts ...string) map[string]interface{} {
+		tls := []interface{}{}
+		if len(tlsHosts) > 0 {
+			tls = append(tls, map[string]interface{}{"secretName": "chart-tls", "hosts": tlsHosts})
+		}
+		return map[string]interface{}{
+			"enabled": true,
+			"hosts":   []interface{}{map[string]interface{}{"host": host, "paths": []interface{}{}}},
+			"tls":     tls,
+		}
+	}
+	route := func(host string, tls bool) map[string]interface{} {
+		r := map[string]interface{}{"enabled": true, "host": host}
+		if tls {
+			r["tls"] = map[string]interface{}{"termination": "edge"}
+		}
+		return r
+	}
+
+	uri := "chart-0.1.0-v3.ingress-route.tgz"
+
+	positiveTestCases := []testCase{
+		{description: "chart without Ingress nor Route", uri: "chart-0.1.0-v3.valid.tgz", strict: true},
+		{description: "Ingress and Route with unique TLS-enabled hosts", uri: uri, strict: true,
+			values: chartutil.Values{"ingress": ingress("chart.example.com", "chart.example.com"), "route": route("console.example.com", true)}},
+		{description: "Ingress host covered by a wildcard TLS host", uri: uri, strict: true,
+			values: chartutil.Values{"ingress": ingress("chart.example.com", "*.example.com")}},
+		{description: "Ingress host without TLS when not strict", uri: uri,
+			values: chartutil.Values{"ingress": ingress("chart.example.com")}},
+		{description: "duplicate host of an allowlisted resource", uri: uri, allowlist: []string{"testRelease-chart"},
+			values: chartutil.Values{"ingress": ingress("chart.example.com"), "route": route("chart.example.com", false)}},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(StrictConfigKey, tc.strict)
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			if tc.values != nil {
+				config.Set(ValuesConfigKey, tc.values)
+			}
+			r, err := IngressHostsValid(tc.uri, config)
+			require.NoError(t, err)
+			require.True(t, r.Ok)
+			require.Equal(t, IngressHostsUnique, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "Ingress and Route exposing the same host",
+			uri:         uri,
+			values:      chartutil.Values{"ingress": ingress("chart.example.com", "chart.example.com"), "route": route("chart.example.com", true)},
+			reason:      IngressHostDuplicated + " : chart.example.com exposed by Ingress/testRelease-chart and Route/testRelease-chart",
+			findings: []Finding{
+				{Resource: "Route/testRelease-chart", Field: "spec.host", Message: "Host chart.example.com is already exposed by Ingress/testRelease-chart", Severity: ErrorSeverity},
+			},
+		},
+		{
+			description: "Ingress host without TLS in strict mode",
+			uri:         uri,
+			strict:      true,
+			values:      chartutil.Values{"ingress": ingress("chart.example.com", "other.example.com")},
+			reason:      IngressHostWithoutTLS + " : chart.example.com exposed by Ingress/testRelease-chart",
+			findings: []Finding{
+				{Resource: "Ingress/testRelease-chart", Field: "spec.rules[0].host", Message: "Host chart.example.com should be served over TLS", Severity: ErrorSeverity},
+			},
+		},
+		{
+			description: "Route host without TLS in strict mode",
+			uri:         uri,
+			strict:      true,
+			values:      chartutil.Values{"route": route("chart.example.com", false)},
+			reason:      IngressHostWithoutTLS + " : chart.example.com exposed by Route/testRelease-chart",
+			findings: []Finding{
+				{Resource: "Route/testRelease-chart", Field: "spec.host", Message: "Host chart.example.com should be served over TLS", Severity: ErrorSeverity},
+			},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(StrictConfigKey, tc.strict)
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			config.Set(ValuesConfigKey, tc.values)
+			r, err := IngressHostsValid(tc.uri, config)
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, tc.findings, r.Findings)
+		})
+	}
+}
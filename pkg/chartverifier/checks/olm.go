@@ -0,0 +1,133 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	OLMAnnotationsValid    = "OLM annotations are valid"
+	OLMAnnotationsSkipped  = "Chart does not contain OLM resources or annotations"
+	OLMAnnotationMissing   = "OLM annotation is missing"
+	OLMCapabilitiesInvalid = "OLM capabilities level is invalid"
+)
+
+// olmChartAnnotationPrefix is the prefix of the Chart.yaml annotations carrying OLM metadata.
+const olmChartAnnotationPrefix = "operators.openshift.io/"
+
+// olmCapabilityLevels are the operator capability levels recognized by OperatorHub.
+var olmCapabilityLevels = []string{"Basic Install", "Seamless Upgrades", "Full Lifecycle", "Deep Insights", "Auto Pilot"}
+
+// olmAnnotations are the OLM annotations of either a ClusterServiceVersion or the chart itself.
+type olmAnnotations struct {
+	resource    string
+	fieldPrefix string
+	keyPrefix   string
+	annotations map[string]string
+}
+
+// validateOLMAnnotations records a failure in r for each missing or malformed required OLM annotation.
+func validateOLMAnnotations(r *Result, a olmAnnotations) {
+	for _, name := range []string{"capabilities", "categories"} {
+		key := a.keyPrefix + name
+		field := a.fieldPrefix + key
+		value := strings.TrimSpace(a.annotations[key])
+
+		if value == "" {
+			addFailure(r, fmt.Sprintf("%s : %s %s", OLMAnnotationMissing, a.resource, key))
+			r.AddFinding(Finding{
+				Resource: a.resource,
+				Field:    field,
+				Message:  fmt.Sprintf("Annotation %s should be declared", key),
+				Severity: ErrorSeverity,
+			})
+			continue
+		}
+
+		if name == "capabilities" && !containsString(olmCapabilityLevels, value) {
+			addFailure(r, fmt.Sprintf("%s : %s %q", OLMCapabilitiesInvalid, a.resource, value))
+			r.AddFinding(Finding{
+				Resource: a.resource,
+				Field:    field,
+				Message:  fmt.Sprintf("Capabilities level %q should be one of: %s", value, strings.Join(olmCapabilityLevels, ", ")),
+				Severity: ErrorSeverity,
+			})
+		}
+	}
+}
+
+// containsString informs whether s is one of values.
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func OlmAnnotationsValid(uri string, config *viper.Viper) (Result, error) {
+	c, _, err := LoadChartFromURI(uri)
+	if err != nil {
+		return Result{}, err
+	}
+
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	var found []olmAnnotations
+
+	for k := range c.Metadata.Annotations {
+		if strings.HasPrefix(k, olmChartAnnotationPrefix) {
+			found = append(found, olmAnnotations{
+				resource:    "Chart.yaml",
+				fieldPrefix: "annotations.",
+				keyPrefix:   olmChartAnnotationPrefix,
+				annotations: c.Metadata.Annotations,
+			})
+			break
+		}
+	}
+
+	for _, res := range resources {
+		if res.GetKind() != "ClusterServiceVersion" {
+			continue
+		}
+		found = append(found, olmAnnotations{
+			resource:    res.String(),
+			fieldPrefix: "metadata.annotations.",
+			annotations: res.GetAnnotations(),
+		})
+	}
+
+	if len(found) == 0 {
+		return NewResult(true, OLMAnnotationsSkipped), nil
+	}
+
+	r := NewResult(true, OLMAnnotationsValid)
+	for _, a := range found {
+		validateOLMAnnotations(&r, a)
+	}
+
+	return r, nil
+}
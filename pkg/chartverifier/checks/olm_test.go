@@ -0,0 +1,87 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOlmAnnotationsValid(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		reason      string
+		findings    []Finding
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart with valid OLM annotations", uri: "chart-0.1.0-v3.olm-valid.tgz", reason: OLMAnnotationsValid},
+		{description: "chart without OLM constructs", uri: "chart-0.1.0-v3.valid.tgz", reason: OLMAnnotationsSkipped},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			r, err := OlmAnnotationsValid(tc.uri, viper.New())
+			require.NoError(t, err)
+			require.True(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "ClusterServiceVersion missing categories",
+			uri:         "chart-0.1.0-v3.olm-missing-categories.tgz",
+			reason:      OLMAnnotationMissing + " : ClusterServiceVersion/testRelease-chart.v1.16.0 categories",
+			findings: []Finding{
+				{
+					Resource: "ClusterServiceVersion/testRelease-chart.v1.16.0",
+					Field:    "metadata.annotations.categories",
+					Message:  "Annotation categories should be declared",
+					Severity: ErrorSeverity,
+				},
+			},
+		},
+		{
+			description: "Chart.yaml with an invalid capabilities level",
+			uri:         "chart-0.1.0-v3.olm-chart-annotations.tgz",
+			reason:      OLMCapabilitiesInvalid + ` : Chart.yaml "Expert Install"`,
+			findings: []Finding{
+				{
+					Resource: "Chart.yaml",
+					Field:    "annotations.operators.openshift.io/capabilities",
+					Message:  `Capabilities level "Expert Install" should be one of: Basic Install, Seamless Upgrades, Full Lifecycle, Deep Insights, Auto Pilot`,
+					Severity: ErrorSeverity,
+				},
+			},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			r, err := OlmAnnotationsValid(tc.uri, viper.New())
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, tc.findings, r.Findings)
+		})
+	}
+}
@@ -0,0 +1,192 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// OpenShiftVersionConfigKey is the check configuration key informing the OpenShift version the chart is being
+	// verified against; it is set by the certifier for checks requiring an OpenShift version.
+	OpenShiftVersionConfigKey = "openshiftVersion"
+)
+
+// minOpenShiftMinor is the oldest OpenShift 4 minor release whose Kubernetes version can be derived from its own.
+const minOpenShiftMinor = 6
+
+// KubeVersionForOpenShift returns the Kubernetes version shipped with the given OpenShift version, e.g. "1.25.0" for
+// "4.12"; only OpenShift 4.6 or newer is supported.
+func KubeVersionForOpenShift(version string) (string, error) {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return "", errors.Errorf("invalid OpenShift version %q: %v", version, err)
+	}
+	if v.Major() != 4 || v.Minor() < minOpenShiftMinor {
+		return "", errors.Errorf("unsupported OpenShift version %q, expected 4.%d or newer", version, minOpenShiftMinor)
+	}
+	// starting with OpenShift 4.6, which ships Kubernetes 1.19, each OpenShift minor release ships the next Kubernetes
+	// minor release
+	return fmt.Sprintf("1.%d.0", v.Minor()+13), nil
+}
+
+const (
+	SCCReferencesExist  = "Referenced SecurityContextConstraints exist"
+	SCCReferenceUnknown = "Referenced SecurityContextConstraints does not exist"
+)
+
+// defaultSCCs maps the SecurityContextConstraints shipped with OpenShift 4 to the minor release introducing them.
+var defaultSCCs = map[string]uint64{
+	"anyuid":           0,
+	"hostaccess":       0,
+	"hostmount-anyuid": 0,
+	"hostnetwork":      0,
+	"node-exporter":    0,
+	"nonroot":          0,
+	"privileged":       0,
+	"restricted":       0,
+	"hostnetwork-v2":   11,
+	"nonroot-v2":       11,
+	"restricted-v2":    11,
+}
+
+// sccAnnotations are the pod annotations naming the SecurityContextConstraints pods are admitted with.
+var sccAnnotations = []string{"openshift.io/scc", "openshift.io/required-scc"}
+
+// sccReference is a SecurityContextConstraints name found in a rendered resource.
+type sccReference struct {
+	name  string
+	field string
+}
+
+// getSCCReferences returns the SecurityContextConstraints referenced by res, either as resource names of RBAC rules
+// granting their use, or through the annotations of the resource or of its pod template.
+func getSCCReferences(res renderedResource) ([]sccReference, error) {
+	var refs []sccReference
+
+	if kind := res.GetKind(); kind == "Role" || kind == "ClusterRole" {
+		role := &rbacv1.ClusterRole{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(res.Object, role); err != nil {
+			return nil, err
+		}
+		for i, rule := range role.Rules {
+			if !containsString(rule.APIGroups, "security.openshift.io") || !containsString(rule.Resources, "securitycontextconstraints") {
+				continue
+			}
+			for _, name := range rule.ResourceNames {
+				refs = append(refs, sccReference{name: name, field: fmt.Sprintf("rules[%d].resourceNames", i)})
+			}
+		}
+	}
+
+	metadataFields := [][]string{{"metadata"}}
+	if fields, ok := podSpecFields[res.GetKind()]; ok && len(fields) > 1 {
+		metadataFields = append(metadataFields, append(append([]string{}, fields[:len(fields)-1]...), "metadata"))
+	}
+	for _, fields := range metadataFields {
+		annotations, _, err := unstructured.NestedStringMap(res.Object, append(fields, "annotations")...)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range sccAnnotations {
+			if name, ok := annotations[key]; ok {
+				refs = append(refs, sccReference{name: name, field: strings.Join(fields, ".") + ".annotations." + key})
+			}
+		}
+	}
+	return refs, nil
+}
+
+// sccsAvailable returns the default SecurityContextConstraints available on the given OpenShift version, or on any
+// OpenShift 4 release when no version is informed.
+func sccsAvailable(openShiftVersion string) (map[string]bool, error) {
+	minor := uint64(1<<63 - 1)
+	if openShiftVersion != "" {
+		v, err := semver.NewVersion(openShiftVersion)
+		if err != nil {
+			return nil, errors.Errorf("invalid OpenShift version %q: %v", openShiftVersion, err)
+		}
+		minor = v.Minor()
+	}
+
+	available := map[string]bool{}
+	for name, since := range defaultSCCs {
+		if since <= minor {
+			available[name] = true
+		}
+	}
+	return available, nil
+}
+
+func SCCReferencesValid(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	openShiftVersion := config.GetString(OpenShiftVersionConfigKey)
+	available, err := sccsAvailable(openShiftVersion)
+	if err != nil {
+		return Result{}, err
+	}
+	// SecurityContextConstraints created by the chart, or allowlisted as provisioned on the target clusters, exist
+	for name := range getStringSetConfig(config, AllowlistConfigKey) {
+		available[name] = true
+	}
+	for _, res := range resources {
+		if res.GetKind() == "SecurityContextConstraints" {
+			available[res.GetName()] = true
+		}
+	}
+
+	r := NewResult(true, SCCReferencesExist)
+	for _, res := range resources {
+		refs, err := getSCCReferences(res)
+		if err != nil {
+			return Result{}, err
+		}
+		for _, ref := range refs {
+			if available[ref.name] {
+				continue
+			}
+
+			message := fmt.Sprintf("SecurityContextConstraints %s is not shipped with OpenShift", ref.name)
+			if since, ok := defaultSCCs[ref.name]; ok {
+				message = fmt.Sprintf("SecurityContextConstraints %s is only shipped with OpenShift 4.%d or newer", ref.name, since)
+			} else if openShiftVersion != "" {
+				message = fmt.Sprintf("SecurityContextConstraints %s is not shipped with OpenShift %s", ref.name, openShiftVersion)
+			}
+			addFailure(&r, fmt.Sprintf("%s : %s references %s", SCCReferenceUnknown, res, ref.name))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    ref.field,
+				Message:  message,
+				Severity: ErrorSeverity,
+			})
+		}
+	}
+
+	return r, nil
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+func TestKubeVersionForOpenShift(t *testing.T) {
+	for openShiftVersion, kubeVersion := range map[string]string{
+		"4.6":    "1.19.0",
+		"4.7":    "1.20.0",
+		"4.12":   "1.25.0",
+		"4.14.3": "1.27.0",
+	} {
+		t.Run("Should map OpenShift "+openShiftVersion, func(t *testing.T) {
+			v, err := KubeVersionForOpenShift(openShiftVersion)
+			require.NoError(t, err)
+			require.Equal(t, kubeVersion, v)
+		})
+	}
+
+	for _, openShiftVersion := range []string{"3.11", "4.5", "5.0", "latest"} {
+		t.Run("Should reject OpenShift "+openShiftVersion, func(t *testing.T) {
+			_, err := KubeVersionForOpenShift(openShiftVersion)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestSCCReferencesValid(t *testing.T) {
+	type testCase struct {
+		description      string
+		uri              string
+		values           chartutil.Values
+		openShiftVersion string
+		config           map[string]interface{}
+		reason           string
+		finding          Finding
+	}
+
+	uri := "chart-0.1.0-v3.scc-references.tgz"
+	use := func(names ...string) chartutil.Values {
+		return chartutil.Values{"scc": map[string]interface{}{"use": names}}
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart without SecurityContextConstraints references", uri: "chart-0.1.0-v3.valid.tgz"},
+		{description: "chart using a SecurityContextConstraints shipped with the target version", uri: uri, openShiftVersion: "4.12"},
+		{description: "chart using a SecurityContextConstraints shipped with some version", uri: uri},
+		{description: "chart using a long standing SecurityContextConstraints", uri: uri, values: use("restricted"), openShiftVersion: "4.6"},
+		{
+			description: "chart using the SecurityContextConstraints it creates",
+			uri:         uri,
+			values:      chartutil.Values{"scc": map[string]interface{}{"use": []string{"testRelease-chart"}, "create": true}},
+		},
+		{
+			description: "chart using an allowlisted SecurityContextConstraints",
+			uri:         uri,
+			values:      use("team-restricted"),
+			config:      map[string]interface{}{AllowlistConfigKey: []string{"team-restricted"}},
+		},
+		{
+			description: "chart annotating pods with a shipped SecurityContextConstraints",
+			uri:         "chart-0.1.0-v3.valid.tgz",
+			values:      chartutil.Values{"podAnnotations": map[string]interface{}{"openshift.io/required-scc": "nonroot-v2"}},
+		},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, tc.values)
+			config.Set(OpenShiftVersionConfigKey, tc.openShiftVersion)
+			for k, v := range tc.config {
+				config.Set(k, v)
+			}
+			r, err := SCCReferencesValid(tc.uri, config)
+			require.NoError(t, err)
+			require.True(t, r.Ok, r.Reason)
+			require.Equal(t, SCCReferencesExist, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description:      "chart using a SecurityContextConstraints not shipped yet with the target version",
+			uri:              uri,
+			openShiftVersion: "4.10",
+			reason:           SCCReferenceUnknown + " : Role/testRelease-chart-scc references restricted-v2",
+			finding: Finding{
+				Resource: "Role/testRelease-chart-scc",
+				Field:    "rules[0].resourceNames",
+				Message:  "SecurityContextConstraints restricted-v2 is only shipped with OpenShift 4.11 or newer",
+				Severity: ErrorSeverity,
+			},
+		},
+		{
+			description:      "chart using a SecurityContextConstraints no longer shipped",
+			uri:              uri,
+			values:           use("restricted", "privileged-legacy"),
+			openShiftVersion: "4.12",
+			reason:           SCCReferenceUnknown + " : Role/testRelease-chart-scc references privileged-legacy",
+			finding: Finding{
+				Resource: "Role/testRelease-chart-scc",
+				Field:    "rules[0].resourceNames",
+				Message:  "SecurityContextConstraints privileged-legacy is not shipped with OpenShift 4.12",
+				Severity: ErrorSeverity,
+			},
+		},
+		{
+			description: "chart annotating pods with an unknown SecurityContextConstraints",
+			uri:         "chart-0.1.0-v3.valid.tgz",
+			values:      chartutil.Values{"podAnnotations": map[string]interface{}{"openshift.io/scc": "restricted-legacy"}},
+			reason:      SCCReferenceUnknown + " : Deployment/testRelease-chart references restricted-legacy",
+			finding: Finding{
+				Resource: "Deployment/testRelease-chart",
+				Field:    "spec.template.metadata.annotations.openshift.io/scc",
+				Message:  "SecurityContextConstraints restricted-legacy is not shipped with OpenShift",
+				Severity: ErrorSeverity,
+			},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, tc.values)
+			config.Set(OpenShiftVersionConfigKey, tc.openShiftVersion)
+			r, err := SCCReferencesValid(tc.uri, config)
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, []Finding{tc.finding}, r.Findings)
+		})
+	}
+
+	t.Run("Should fail when the OpenShift version is invalid", func(t *testing.T) {
+		config := viper.New()
+		config.Set(OpenShiftVersionConfigKey, "latest")
+		_, err := SCCReferencesValid(uri, config)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+
+	"github.com/spf13/viper"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+const (
+	ChartRepackagesCleanly = "Chart can be packaged and reloaded without changes"
+	ChartPackagingFailed   = "Chart can't be packaged"
+	ChartReloadFailed      = "Packaged chart can't be loaded"
+	ChartRepackageChanged  = "Packaged chart differs from the original chart"
+)
+
+// chartContents returns the contents of the files of c and its dependencies, keyed by their path within c; Chart.yaml
+// and Chart.lock are derived from the chart's metadata and lock, respectively, which are informed as their content.
+func chartContents(c *chart.Chart, prefix string, contents map[string]interface{}) {
+	contents[prefix+chartutil.ChartfileName] = c.Metadata
+	if c.Lock != nil {
+		contents[prefix+"Chart.lock"] = c.Lock
+	}
+	for _, f := range c.Raw {
+		if f.Name == chartutil.ValuesfileName {
+			contents[prefix+f.Name] = f.Data
+		}
+	}
+	if c.Schema != nil {
+		contents[prefix+chartutil.SchemafileName] = c.Schema
+	}
+	for _, f := range c.Templates {
+		contents[prefix+f.Name] = f.Data
+	}
+	for _, f := range c.Files {
+		contents[prefix+f.Name] = f.Data
+	}
+	for _, d := range c.Dependencies() {
+		chartContents(d, prefix+chartutil.ChartsDir+"/"+d.Name()+"/", contents)
+	}
+}
+
+// sameContent informs whether the given contents, as returned by chartContents, are equal.
+func sameContent(a, b interface{}) bool {
+	if aData, ok := a.([]byte); ok {
+		bData, ok := b.([]byte)
+		return ok && bytes.Equal(aData, bData)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// repackagingChanges returns a finding for each path of the original chart lost, added or modified by repackaging.
+func repackagingChanges(original, repackaged *chart.Chart) []Finding {
+	before, after := map[string]interface{}{}, map[string]interface{}{}
+	chartContents(original, "", before)
+	chartContents(repackaged, "", after)
+
+	paths := make([]string, 0, len(before)+len(after))
+	for p := range before {
+		paths = append(paths, p)
+	}
+	for p := range after {
+		if _, ok := before[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	var findings []Finding
+	for _, p := range paths {
+		b, inBefore := before[p]
+		a, inAfter := after[p]
+		var msg string
+		switch {
+		case !inAfter:
+			msg = fmt.Sprintf("%s is missing from the packaged chart", p)
+		case !inBefore:
+			msg = fmt.Sprintf("%s is only found in the packaged chart", p)
+		case !sameContent(b, a):
+			msg = fmt.Sprintf("%s differs in the packaged chart", p)
+		default:
+			continue
+		}
+		findings = append(findings, Finding{Resource: p, Message: msg, Severity: ErrorSeverity})
+	}
+	return findings
+}
+
+func ChartRepackagesWithoutChanges(uri string, _ *viper.Viper) (Result, error) {
+	c, _, err := LoadChartFromURI(uri)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// the chart is packaged to a directory removed once reloaded
+	dir, err := ioutil.TempDir("", "chart-verifier-package-")
+	if err != nil {
+		return Result{}, err
+	}
+	defer os.RemoveAll(dir)
+
+	archive, err := chartutil.Save(c, dir)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", ChartPackagingFailed, err)), nil
+	}
+
+	repackaged, err := loader.LoadFile(archive)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", ChartReloadFailed, err)), nil
+	}
+
+	r := NewResult(true, ChartRepackagesCleanly)
+	for _, f := range repackagingChanges(c, repackaged) {
+		addFailure(&r, fmt.Sprintf("%s : %s", ChartRepackageChanged, f.Message))
+		r.AddFinding(f)
+	}
+
+	return r, nil
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// useTempDir points the directory temporary files are created in to an empty directory for the duration of the test,
+// returning it.
+func useTempDir(t *testing.T) string {
+	dir := t.TempDir()
+	original, found := os.LookupEnv("TMPDIR")
+	require.NoError(t, os.Setenv("TMPDIR", dir))
+	t.Cleanup(func() {
+		if found {
+			os.Setenv("TMPDIR", original)
+		} else {
+			os.Unsetenv("TMPDIR")
+		}
+	})
+	return dir
+}
+
+func TestChartRepackagesWithoutChanges(t *testing.T) {
+	t.Run("Should succeed for a chart archive", func(t *testing.T) {
+		tmp := useTempDir(t)
+		r, err := ChartRepackagesWithoutChanges("chart-0.1.0-v3.valid.tgz", viper.New())
+		require.NoError(t, err)
+		require.True(t, r.Ok, r.Reason)
+		require.Equal(t, ChartRepackagesCleanly, r.Reason)
+		require.Empty(t, r.Findings)
+
+		entries, err := ioutil.ReadDir(tmp)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+
+	t.Run("Should succeed for a chart directory", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, chartutil.ExpandFile(dir, "chart-0.1.0-v3.valid.tgz"))
+		r, err := ChartRepackagesWithoutChanges(filepath.Join(dir, "chart"), viper.New())
+		require.NoError(t, err)
+		require.True(t, r.Ok, r.Reason)
+	})
+
+	t.Run("Should fail when the chart can't be packaged", func(t *testing.T) {
+		tmp := useTempDir(t)
+		r, err := ChartRepackagesWithoutChanges("chart-0.1.0-v3.invalid-values-schema.tgz", viper.New())
+		require.NoError(t, err)
+		require.False(t, r.Ok)
+		require.Equal(t, ChartPackagingFailed+" : Invalid JSON in values.schema.json", r.Reason)
+
+		entries, err := ioutil.ReadDir(tmp)
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+
+	t.Run("Should fail when a file is moved by packaging", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, chartutil.ExpandFile(dir, "chart-0.1.0-v3.valid.tgz"))
+		// archives with backslashes in their paths are read as generated on Windows, so the file ends up in the root
+		name := `templates/configmap\data.yaml`
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "chart", name), []byte("apiVersion: v1\nkind: ConfigMap\n"), 0644))
+
+		r, err := ChartRepackagesWithoutChanges(filepath.Join(dir, "chart"), viper.New())
+		require.NoError(t, err)
+		require.False(t, r.Ok)
+		require.Equal(t, ChartRepackageChanged+" : data.yaml is only found in the packaged chart"+
+			"\n\t\t"+ChartRepackageChanged+" : "+name+" is missing from the packaged chart", r.Reason)
+		require.Equal(t, []Finding{
+			{Resource: "data.yaml", Message: "data.yaml is only found in the packaged chart", Severity: ErrorSeverity},
+			{Resource: name, Message: name + " is missing from the packaged chart", Severity: ErrorSeverity},
+		}, r.Findings)
+	})
+}
+
+func TestRepackagingChanges(t *testing.T) {
+	original, _, err := LoadChartFromURI("chart-0.1.0-v3.valid.tgz")
+	require.NoError(t, err)
+	repackaged, _, err := LoadChartFromURI("chart-0.1.0-v3.valid.tgz")
+	require.NoError(t, err)
+	require.Empty(t, repackagingChanges(original, repackaged))
+
+	repackaged.Metadata.Description = "Changed"
+	repackaged.Templates[0].Data = append(repackaged.Templates[0].Data, '\n')
+	require.Equal(t, []Finding{
+		{Resource: "Chart.yaml", Message: "Chart.yaml differs in the packaged chart", Severity: ErrorSeverity},
+		{Resource: repackaged.Templates[0].Name, Message: repackaged.Templates[0].Name + " differs in the packaged chart", Severity: ErrorSeverity},
+	}, repackagingChanges(original, repackaged))
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	PodSecurityPoliciesAbsent = "Chart does not use PodSecurityPolicies"
+	PodSecurityPolicyFound    = "Chart uses PodSecurityPolicies, removed in Kubernetes 1.25"
+)
+
+// podSecurityAdmissionRemediation is the remediation suggested for the PodSecurityPolicies found.
+const podSecurityAdmissionRemediation = "enforce a Pod Security Standard through Pod Security Admission namespace labels instead"
+
+// podSecurityPolicyAPIGroups are the API groups PodSecurityPolicies have been served from.
+var podSecurityPolicyAPIGroups = map[string]bool{"policy": true, "extensions": true, rbacv1.APIGroupAll: true}
+
+// grantsPodSecurityPolicies informs whether rule grants access to PodSecurityPolicies by name; rules granting access
+// to all resources are reported by rbac-least-privilege instead.
+func grantsPodSecurityPolicies(rule rbacv1.PolicyRule) bool {
+	groupFound := false
+	for _, group := range rule.APIGroups {
+		groupFound = groupFound || podSecurityPolicyAPIGroups[group]
+	}
+	if !groupFound {
+		return false
+	}
+	for _, resource := range rule.Resources {
+		if resource == "podsecuritypolicies" {
+			return true
+		}
+	}
+	return false
+}
+
+// NoPodSecurityPolicy checks the chart neither renders PodSecurityPolicies, which can't be installed on Kubernetes 1.25
+// or newer, nor Roles granting access to them. Resources can be allowlisted by name.
+func NoPodSecurityPolicy(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	allowlist := getStringSetConfig(config, AllowlistConfigKey)
+
+	r := NewResult(true, PodSecurityPoliciesAbsent)
+	for _, res := range resources {
+		if allowlist[res.GetName()] {
+			continue
+		}
+
+		switch res.GetKind() {
+		case "PodSecurityPolicy":
+			addFailure(&r, fmt.Sprintf("%s : %s", PodSecurityPolicyFound, res))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    "kind",
+				Message:  "PodSecurityPolicy is removed in Kubernetes 1.25; " + podSecurityAdmissionRemediation,
+				Severity: ErrorSeverity,
+			})
+
+		case "Role", "ClusterRole":
+			role := &rbacv1.ClusterRole{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(res.Object, role); err != nil {
+				return Result{}, err
+			}
+			for i, rule := range role.Rules {
+				if !grantsPodSecurityPolicies(rule) {
+					continue
+				}
+				field := fmt.Sprintf("rules[%d]", i)
+				addFailure(&r, fmt.Sprintf("%s : %s %s", PodSecurityPolicyFound, res, field))
+				r.AddFinding(Finding{
+					Resource: res.String(),
+					Field:    field,
+					Message:  "Rule grants access to PodSecurityPolicies; " + podSecurityAdmissionRemediation,
+					Severity: ErrorSeverity,
+				})
+			}
+		}
+	}
+
+	return r, nil
+}
@@ -0,0 +1,108 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+func TestNoPodSecurityPolicy(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		values      chartutil.Values
+		allowlist   []string
+		reason      string
+		findings    []Finding
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart without PodSecurityPolicies", uri: "chart-0.1.0-v3.valid.tgz"},
+		{
+			description: "chart with its PodSecurityPolicy disabled",
+			uri:         "chart-0.1.0-v3.psp.tgz",
+			values:      chartutil.Values{"podSecurityPolicy": map[string]interface{}{"enabled": false}},
+		},
+		{
+			description: "chart with an allowlisted PodSecurityPolicy",
+			uri:         "chart-0.1.0-v3.psp.tgz",
+			allowlist:   []string{"testRelease-chart", "testRelease-chart-psp"},
+		},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, tc.values)
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			r, err := NoPodSecurityPolicy(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.True(t, r.Ok)
+			require.Equal(t, PodSecurityPoliciesAbsent, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	pspFinding := Finding{
+		Resource: "PodSecurityPolicy/testRelease-chart",
+		Field:    "kind",
+		Message:  "PodSecurityPolicy is removed in Kubernetes 1.25; " + podSecurityAdmissionRemediation,
+		Severity: ErrorSeverity,
+	}
+	roleFinding := Finding{
+		Resource: "Role/testRelease-chart-psp",
+		Field:    "rules[0]",
+		Message:  "Rule grants access to PodSecurityPolicies; " + podSecurityAdmissionRemediation,
+		Severity: ErrorSeverity,
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart with a PodSecurityPolicy and a Role using it",
+			uri:         "chart-0.1.0-v3.psp.tgz",
+			reason: PodSecurityPolicyFound + " : PodSecurityPolicy/testRelease-chart" +
+				"\n\t\t" + PodSecurityPolicyFound + " : Role/testRelease-chart-psp rules[0]",
+			findings: []Finding{pspFinding, roleFinding},
+		},
+		{
+			description: "chart with a Role using an allowlisted PodSecurityPolicy",
+			uri:         "chart-0.1.0-v3.psp.tgz",
+			allowlist:   []string{"testRelease-chart"},
+			reason:      PodSecurityPolicyFound + " : Role/testRelease-chart-psp rules[0]",
+			findings:    []Finding{roleFinding},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, tc.values)
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			r, err := NoPodSecurityPolicy(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, tc.findings, r.Findings)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// RateLimiterConfigKey is the check configuration key containing the *RateLimiter checks performing network requests
+// wait on before each request.
+const RateLimiterConfigKey = "rateLimiter"
+
+// RateLimiter spaces outbound requests evenly, so that no more than a given number of requests are sent per second;
+// it is safe for concurrent use, so a single limiter can be shared by all checks.
+type RateLimiter struct {
+	interval time.Duration
+	mutex    sync.Mutex
+	next     time.Time
+	// now and sleep are replaced in tests.
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// NewRateLimiter returns a limiter allowing requestsPerSecond requests per second, or nil, which never waits, when
+// requestsPerSecond isn't positive.
+func NewRateLimiter(requestsPerSecond float64) *RateLimiter {
+	if requestsPerSecond <= 0 {
+		return nil
+	}
+	return &RateLimiter{
+		interval: time.Duration(float64(time.Second) / requestsPerSecond),
+		now:      time.Now,
+		sleep:    time.Sleep,
+	}
+}
+
+// Wait blocks until the next request may be sent.
+func (l *RateLimiter) Wait() {
+	if l == nil {
+		return
+	}
+
+	l.mutex.Lock()
+	now := l.now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mutex.Unlock()
+
+	if delay := at.Sub(now); delay > 0 {
+		l.sleep(delay)
+	}
+}
+
+// getRateLimiter returns the limiter informed in config, or nil when requests aren't limited.
+func getRateLimiter(config *viper.Viper) *RateLimiter {
+	if config == nil {
+		return nil
+	}
+	limiter, _ := config.Get(RateLimiterConfigKey).(*RateLimiter)
+	return limiter
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a clock only advancing when slept on.
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newFakeRateLimiter(requestsPerSecond float64) (*RateLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)}
+	limiter := NewRateLimiter(requestsPerSecond)
+	limiter.now = clock.Now
+	limiter.sleep = clock.Sleep
+	return limiter, clock
+}
+
+func TestRateLimiter(t *testing.T) {
+	t.Run("Should space requests according to the configured rate", func(t *testing.T) {
+		limiter, clock := newFakeRateLimiter(4)
+		start := clock.Now()
+
+		var mutex sync.Mutex
+		var received []time.Duration
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mutex.Lock()
+			received = append(received, clock.Now().Sub(start))
+			mutex.Unlock()
+			w.Header().Set("Content-Type", "image/png")
+		}))
+		defer server.Close()
+
+		for i := 0; i < 4; i++ {
+			r := checkIconURL(server.URL, limiter, newHTTPClient(nil, nil, iconRequestTimeout))
+			require.True(t, r.Ok)
+		}
+
+		require.Equal(t, []time.Duration{0, 250 * time.Millisecond, 500 * time.Millisecond, 750 * time.Millisecond}, received)
+	})
+
+	t.Run("Should not wait once requests are less frequent than the configured rate", func(t *testing.T) {
+		limiter, clock := newFakeRateLimiter(2)
+		start := clock.Now()
+
+		limiter.Wait()
+		clock.Sleep(2 * time.Second)
+		limiter.Wait()
+		require.Equal(t, 2*time.Second, clock.Now().Sub(start))
+
+		limiter.Wait()
+		require.Equal(t, 2500*time.Millisecond, clock.Now().Sub(start))
+	})
+
+	t.Run("Should share the rate among concurrent callers", func(t *testing.T) {
+		limiter := NewRateLimiter(1000)
+		start := time.Now()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				limiter.Wait()
+			}()
+		}
+		wg.Wait()
+
+		require.GreaterOrEqual(t, int64(time.Since(start)), int64(19*time.Millisecond))
+	})
+
+	t.Run("Should not limit requests when no rate is configured", func(t *testing.T) {
+		require.Nil(t, NewRateLimiter(0))
+		require.Nil(t, getRateLimiter(viper.New()))
+
+		var limiter *RateLimiter
+		limiter.Wait()
+	})
+
+	t.Run("Should return the limiter informed in the configuration", func(t *testing.T) {
+		limiter := NewRateLimiter(1)
+		config := viper.New()
+		config.Set(RateLimiterConfigKey, limiter)
+		require.Same(t, limiter, getRateLimiter(config))
+	})
+}
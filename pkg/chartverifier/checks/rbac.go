@@ -0,0 +1,220 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	RbacIsLeastPrivilege     = "RBAC rules grant least privilege"
+	WildcardRuleFound        = "RBAC rule uses wildcards"
+	ClusterAdminBindingFound = "RBAC binding grants cluster-admin"
+	TokenAutomountsDisabled  = "Pods not using the Kubernetes API do not mount a ServiceAccount token"
+	TokenAutomountEnabled    = "Pod mounts a ServiceAccount token without using the Kubernetes API"
+)
+
+// getWildcardFields returns the fields of rule granting access through wildcards.
+func getWildcardFields(rule rbacv1.PolicyRule) []string {
+	fields := map[string][]string{
+		"apiGroups":       rule.APIGroups,
+		"resources":       rule.Resources,
+		"verbs":           rule.Verbs,
+		"nonResourceURLs": rule.NonResourceURLs,
+	}
+
+	var wildcards []string
+	for _, field := range []string{"apiGroups", "resources", "verbs", "nonResourceURLs"} {
+		for _, v := range fields[field] {
+			if v == rbacv1.ResourceAll {
+				wildcards = append(wildcards, field)
+				break
+			}
+		}
+	}
+	return wildcards
+}
+
+func RbacLeastPrivilege(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	allowlist := getStringSetConfig(config, AllowlistConfigKey)
+
+	r := NewResult(true, RbacIsLeastPrivilege)
+	for _, res := range resources {
+		if allowlist[res.GetName()] {
+			continue
+		}
+
+		switch res.GetKind() {
+		case "Role", "ClusterRole":
+			role := &rbacv1.ClusterRole{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(res.Object, role); err != nil {
+				return Result{}, err
+			}
+			for i, rule := range role.Rules {
+				wildcards := getWildcardFields(rule)
+				if len(wildcards) == 0 {
+					continue
+				}
+				field := fmt.Sprintf("rules[%d]", i)
+				addFailure(&r, fmt.Sprintf("%s : %s %s", WildcardRuleFound, res, field))
+				r.AddFinding(Finding{
+					Resource: res.String(),
+					Field:    field,
+					Message:  fmt.Sprintf("Rule grants access to all %s", strings.Join(wildcards, ", ")),
+					Severity: ErrorSeverity,
+				})
+			}
+
+		case "RoleBinding", "ClusterRoleBinding":
+			binding := &rbacv1.ClusterRoleBinding{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(res.Object, binding); err != nil {
+				return Result{}, err
+			}
+			if binding.RoleRef.Kind != "ClusterRole" || binding.RoleRef.Name != "cluster-admin" {
+				continue
+			}
+			addFailure(&r, fmt.Sprintf("%s : %s", ClusterAdminBindingFound, res))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    "roleRef",
+				Message:  "Binding grants the cluster-admin ClusterRole",
+				Severity: ErrorSeverity,
+			})
+		}
+	}
+
+	return r, nil
+}
+
+// getBoundServiceAccounts returns the names of the ServiceAccounts bound to a Role or ClusterRole by the RoleBindings
+// and ClusterRoleBindings found in the given resources.
+func getBoundServiceAccounts(resources []renderedResource) (map[string]bool, error) {
+	bound := map[string]bool{}
+	for _, res := range resources {
+		if res.GetKind() != "RoleBinding" && res.GetKind() != "ClusterRoleBinding" {
+			continue
+		}
+		binding := &rbacv1.ClusterRoleBinding{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(res.Object, binding); err != nil {
+			return nil, err
+		}
+		for _, subject := range binding.Subjects {
+			if subject.Kind == rbacv1.ServiceAccountKind {
+				bound[subject.Name] = true
+			}
+		}
+	}
+	return bound, nil
+}
+
+// getServiceAccountAutomounts returns, for the name of each ServiceAccount found in the given resources declaring
+// automountServiceAccountToken, whether it has its token mounted.
+func getServiceAccountAutomounts(resources []renderedResource) (map[string]bool, error) {
+	automounts := map[string]bool{}
+	for _, res := range resources {
+		if res.GetKind() != "ServiceAccount" {
+			continue
+		}
+		automount, found, err := unstructured.NestedBool(res.Object, "automountServiceAccountToken")
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			automounts[res.GetName()] = automount
+		}
+	}
+	return automounts, nil
+}
+
+// TokenAutomountDisabled checks the pods rendered by the chart don't mount a ServiceAccount token, either through
+// their own automountServiceAccountToken or their ServiceAccount's, unless they appear to use the Kubernetes API: their
+// ServiceAccount is bound to a role by the chart, or the workload is named in the allowlist.
+func TokenAutomountDisabled(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	bound, err := getBoundServiceAccounts(resources)
+	if err != nil {
+		return Result{}, err
+	}
+	automounts, err := getServiceAccountAutomounts(resources)
+	if err != nil {
+		return Result{}, err
+	}
+	allowlist := getStringSetConfig(config, AllowlistConfigKey)
+
+	r := NewResult(true, TokenAutomountsDisabled)
+	for _, res := range resources {
+		if allowlist[res.GetName()] {
+			continue
+		}
+
+		podSpec, ok := getPodSpec(res)
+		if !ok {
+			continue
+		}
+
+		name := podSpec.ServiceAccountName
+		if name == "" {
+			name = podSpec.DeprecatedServiceAccount
+		}
+		if name == "" {
+			name = "default"
+		}
+		if bound[name] {
+			continue
+		}
+
+		var message string
+		if automount := podSpec.AutomountServiceAccountToken; automount != nil {
+			if !*automount {
+				continue
+			}
+			message = "automountServiceAccountToken is true"
+		} else if automount, ok := automounts[name]; ok {
+			if !automount {
+				continue
+			}
+			message = fmt.Sprintf("automountServiceAccountToken is true for ServiceAccount %s", name)
+		} else {
+			message = "automountServiceAccountToken is not set to false"
+		}
+
+		addFailure(&r, fmt.Sprintf("%s : %s", TokenAutomountEnabled, res))
+		r.AddFinding(Finding{
+			Resource: res.String(),
+			Field:    strings.Join(podSpecFields[res.GetKind()], ".") + ".automountServiceAccountToken",
+			Message:  fmt.Sprintf("%s, while the chart binds no role to ServiceAccount %s", message, name),
+			Severity: ErrorSeverity,
+		})
+	}
+
+	return r, nil
+}
@@ -0,0 +1,202 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+func TestRbacLeastPrivilege(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		allowlist   []string
+		reason      string
+		finding     Finding
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart without RBAC", uri: "chart-0.1.0-v3.valid.tgz"},
+		{description: "chart with a scoped Role", uri: "chart-0.1.0-v3.rbac-scoped.tgz"},
+		{description: "chart with an allowlisted wildcard ClusterRole", uri: "chart-0.1.0-v3.rbac-wildcard.tgz", allowlist: []string{"testRelease-chart"}},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			r, err := RbacLeastPrivilege(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.True(t, r.Ok)
+			require.Equal(t, RbacIsLeastPrivilege, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart with a wildcard ClusterRole",
+			uri:         "chart-0.1.0-v3.rbac-wildcard.tgz",
+			reason:      WildcardRuleFound + " : ClusterRole/testRelease-chart rules[1]",
+			finding: Finding{
+				Resource: "ClusterRole/testRelease-chart",
+				Field:    "rules[1]",
+				Message:  "Rule grants access to all apiGroups, resources, verbs",
+				Severity: ErrorSeverity,
+			},
+		},
+		{
+			description: "chart with a wildcard ClusterRole allowlisting another resource",
+			uri:         "chart-0.1.0-v3.rbac-wildcard.tgz",
+			allowlist:   []string{"other-role"},
+			reason:      WildcardRuleFound + " : ClusterRole/testRelease-chart rules[1]",
+			finding: Finding{
+				Resource: "ClusterRole/testRelease-chart",
+				Field:    "rules[1]",
+				Message:  "Rule grants access to all apiGroups, resources, verbs",
+				Severity: ErrorSeverity,
+			},
+		},
+		{
+			description: "chart binding cluster-admin",
+			uri:         "chart-0.1.0-v3.rbac-cluster-admin.tgz",
+			reason:      ClusterAdminBindingFound + " : ClusterRoleBinding/testRelease-chart",
+			finding: Finding{
+				Resource: "ClusterRoleBinding/testRelease-chart",
+				Field:    "roleRef",
+				Message:  "Binding grants the cluster-admin ClusterRole",
+				Severity: ErrorSeverity,
+			},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			r, err := RbacLeastPrivilege(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, []Finding{tc.finding}, r.Findings)
+		})
+	}
+}
+
+func TestTokenAutomountDisabled(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		values      chartutil.Values
+		allowlist   []string
+		reason      string
+		finding     Finding
+	}
+
+	positiveTestCases := []testCase{
+		{description: "pod with automount disabled", uri: "chart-0.1.0-v3.token-automount.tgz"},
+		{
+			description: "pod whose ServiceAccount has automount disabled",
+			uri:         "chart-0.1.0-v3.token-automount.tgz",
+			values:      chartutil.Values{"automountServiceAccountToken": nil, "serviceAccount": map[string]interface{}{"automount": false}},
+		},
+		{
+			description: "pod with automount enabled and its ServiceAccount bound to a Role",
+			uri:         "chart-0.1.0-v3.token-automount.tgz",
+			values:      chartutil.Values{"automountServiceAccountToken": true, "rbac": map[string]interface{}{"create": true}},
+		},
+		{
+			description: "pod with automount enabled explicitly using the API",
+			uri:         "chart-0.1.0-v3.token-automount.tgz",
+			values:      chartutil.Values{"automountServiceAccountToken": true},
+			allowlist:   []string{"testRelease-chart"},
+		},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, tc.values)
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			r, err := TokenAutomountDisabled(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.True(t, r.Ok)
+			require.Equal(t, TokenAutomountsDisabled, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "pod with automount enabled",
+			uri:         "chart-0.1.0-v3.token-automount.tgz",
+			values:      chartutil.Values{"automountServiceAccountToken": true},
+			reason:      TokenAutomountEnabled + " : Deployment/testRelease-chart",
+			finding: Finding{
+				Resource: "Deployment/testRelease-chart",
+				Field:    "spec.template.spec.automountServiceAccountToken",
+				Message:  "automountServiceAccountToken is true, while the chart binds no role to ServiceAccount testRelease-chart",
+				Severity: ErrorSeverity,
+			},
+		},
+		{
+			description: "pod whose ServiceAccount has automount enabled",
+			uri:         "chart-0.1.0-v3.token-automount.tgz",
+			values:      chartutil.Values{"automountServiceAccountToken": nil, "serviceAccount": map[string]interface{}{"automount": true}},
+			reason:      TokenAutomountEnabled + " : Deployment/testRelease-chart",
+			finding: Finding{
+				Resource: "Deployment/testRelease-chart",
+				Field:    "spec.template.spec.automountServiceAccountToken",
+				Message:  "automountServiceAccountToken is true for ServiceAccount testRelease-chart, while the chart binds no role to ServiceAccount testRelease-chart",
+				Severity: ErrorSeverity,
+			},
+		},
+		{
+			description: "pod leaving automount unset",
+			uri:         "chart-0.1.0-v3.token-automount.tgz",
+			values:      chartutil.Values{"automountServiceAccountToken": nil},
+			reason:      TokenAutomountEnabled + " : Deployment/testRelease-chart",
+			finding: Finding{
+				Resource: "Deployment/testRelease-chart",
+				Field:    "spec.template.spec.automountServiceAccountToken",
+				Message:  "automountServiceAccountToken is not set to false, while the chart binds no role to ServiceAccount testRelease-chart",
+				Severity: ErrorSeverity,
+			},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, tc.values)
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			r, err := TokenAutomountDisabled(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, []Finding{tc.finding}, r.Findings)
+		})
+	}
+}
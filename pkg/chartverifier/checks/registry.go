@@ -16,7 +16,29 @@
 
 package checks
 
-import "github.com/spf13/viper"
+import (
+	"sort"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	ErrorSeverity   = "error"
+	WarningSeverity = "warning"
+	InfoSeverity    = "info"
+)
+
+// Finding is a machine readable description of an issue found by a check.
+type Finding struct {
+	// Resource identifies the chart resource the finding refers to, e.g. "Deployment/chart" or an image reference.
+	Resource string `json:"resource,omitempty" yaml:"resource,omitempty"`
+	// Field is the path of the offending field within the resource, if any.
+	Field string `json:"field,omitempty" yaml:"field,omitempty"`
+	// Message describes the finding.
+	Message string `json:"message" yaml:"message"`
+	// Severity is one of ErrorSeverity, WarningSeverity or InfoSeverity.
+	Severity string `json:"severity" yaml:"severity"`
+}
 
 type Result struct {
 	// Ok indicates whether the result was successful or not.
@@ -24,6 +46,10 @@ type Result struct {
 	// Reason for the result value.  This is a message indicating
 	// the reason for the value of Ok became true or false.
 	Reason string
+	// Findings optionally details the issues summarized by Reason.
+	Findings []Finding
+	// Suggestions optionally proposes fixes for the findings, when requested through SuggestFixesConfigKey.
+	Suggestions []Suggestion
 }
 
 func NewResult(outcome bool, reason string) Result {
@@ -48,21 +74,68 @@ func (r *Result) AddResult(outcome bool, reason string) Result {
 	return *r
 }
 
+func (r *Result) AddFinding(finding Finding) Result {
+	r.Findings = append(r.Findings, finding)
+	return *r
+}
+
+func (r *Result) AddSuggestion(suggestion Suggestion) Result {
+	r.Suggestions = append(r.Suggestions, suggestion)
+	return *r
+}
+
 type CheckFunc func(uri string, config *viper.Viper) (Result, error)
 
+// CheckType classifies checks according to their impact on the overall certification outcome.
+type CheckType string
+
+const (
+	// MandatoryCheckType is the type of checks required for certification.
+	MandatoryCheckType CheckType = "mandatory"
+	// OptionalCheckType is the type of checks whose failures are only reported, unless requested otherwise.
+	OptionalCheckType CheckType = "optional"
+)
+
+// Check is a check function and its classification.
+type Check struct {
+	Func CheckFunc
+	Type CheckType
+	// RequiresOpenShiftVersion indicates the check's outcome depends on the OpenShift version informed through
+	// OpenShiftVersionConfigKey, so it is executed once per target OpenShift version.
+	RequiresOpenShiftVersion bool
+	// RendersTemplates indicates the check inspects the resources rendered from the chart's templates with the values
+	// informed through ValuesConfigKey, so it is executed once per values profile.
+	RendersTemplates bool
+	// RequiresNetwork indicates the check can't be executed without reaching external services, such as Pyxis.
+	RequiresNetwork bool
+	// RequiresCluster indicates the check can't be executed without reaching the cluster of the current Kubernetes
+	// context.
+	RequiresCluster bool
+}
+
 type Registry interface {
-	Get(name string) (CheckFunc, bool)
+	// Get returns the check registered under the given name, if any.
+	Get(name string) (Check, bool)
+	// Add registers checkFunc as a mandatory check.
 	Add(name string, checkFunc CheckFunc) Registry
+	// AddCheck registers check under the given name.
+	AddCheck(name string, check Check) Registry
+	// AllChecks returns the names of the registered checks in alphabetical order.
 	AllChecks() []string
+	// Remove unregisters the check registered under the given name, if any.
+	Remove(name string) Registry
+	// Clone returns a copy of the registry, which can be modified without affecting the original one.
+	Clone() Registry
 }
 
-type defaultRegistry map[string]CheckFunc
+type defaultRegistry map[string]Check
 
 func (r *defaultRegistry) AllChecks() []string {
 	allChecks := make([]string, 0)
 	for k, _ := range *r {
 		allChecks = append(allChecks, k)
 	}
+	sort.Strings(allChecks)
 	return allChecks
 }
 
@@ -70,12 +143,29 @@ func NewRegistry() Registry {
 	return &defaultRegistry{}
 }
 
-func (r *defaultRegistry) Get(name string) (CheckFunc, bool) {
+func (r *defaultRegistry) Get(name string) (Check, bool) {
 	v, ok := (*r)[name]
 	return v, ok
 }
 
 func (r *defaultRegistry) Add(name string, checkFunc CheckFunc) Registry {
-	(*r)[name] = checkFunc
+	return r.AddCheck(name, Check{Func: checkFunc, Type: MandatoryCheckType})
+}
+
+func (r *defaultRegistry) AddCheck(name string, check Check) Registry {
+	(*r)[name] = check
 	return r
 }
+
+func (r *defaultRegistry) Remove(name string) Registry {
+	delete(*r, name)
+	return r
+}
+
+func (r *defaultRegistry) Clone() Registry {
+	clone := defaultRegistry{}
+	for name, check := range *r {
+		clone[name] = check
+	}
+	return &clone
+}
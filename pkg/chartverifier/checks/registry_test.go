@@ -0,0 +1,62 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func dummyCheck(_ string, _ *viper.Viper) (Result, error) {
+	return NewResult(true, "Dummy passed"), nil
+}
+
+func TestRegistry_Clone(t *testing.T) {
+	original := NewRegistry().
+		Add("mandatory-check", dummyCheck).
+		AddCheck("optional-check", Check{Func: dummyCheck, Type: OptionalCheckType})
+
+	clone := original.Clone().
+		Remove("mandatory-check").
+		AddCheck("optional-check", Check{Func: dummyCheck, Type: MandatoryCheckType}).
+		Add("added-check", dummyCheck)
+
+	require.Equal(t, []string{"added-check", "optional-check"}, clone.AllChecks())
+	check, ok := clone.Get("optional-check")
+	require.True(t, ok)
+	require.Equal(t, MandatoryCheckType, check.Type)
+
+	// the original registry is left untouched
+	require.Equal(t, []string{"mandatory-check", "optional-check"}, original.AllChecks())
+	check, ok = original.Get("optional-check")
+	require.True(t, ok)
+	require.Equal(t, OptionalCheckType, check.Type)
+	_, ok = original.Get("added-check")
+	require.False(t, ok)
+}
+
+func TestRegistry_Remove(t *testing.T) {
+	registry := NewRegistry().Add("dummy-check", dummyCheck)
+
+	registry.Remove("dummy-check").Remove("unknown-check")
+
+	_, ok := registry.Get("dummy-check")
+	require.False(t, ok)
+	require.Empty(t, registry.AllChecks())
+}
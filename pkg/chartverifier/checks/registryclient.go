@@ -0,0 +1,224 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// manifestMediaTypes are the manifest and index media types accepted from registries.
+var manifestMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+}
+
+// imageReference is an image reference broken into the registry it is pulled from, its repository within the
+// registry and its tag or digest.
+type imageReference struct {
+	registry   string
+	repository string
+	reference  string
+}
+
+// parseImageReference breaks the given image into its registry, repository and tag or digest, as resolved by container
+// runtimes; images not naming their registry are pulled from Docker Hub, and images without tag or digest default to
+// the latest tag.
+func parseImageReference(image string) imageReference {
+	repository := getImageRepository(image)
+	reference := "latest"
+	if i := strings.Index(image, "@"); i != -1 {
+		reference = image[i+1:]
+	} else if len(image) > len(repository) {
+		reference = image[len(repository)+1:]
+	}
+
+	parts := strings.SplitN(normalizeImageRepository(repository), "/", 2)
+	registry := parts[0]
+	if registry == "docker.io" {
+		// Docker Hub serves its registry API from a different host
+		registry = "registry-1.docker.io"
+	}
+	return imageReference{registry: registry, repository: parts[1], reference: reference}
+}
+
+// imageManifest is the subset of image manifests and indexes needed to locate an image's configuration.
+type imageManifest struct {
+	Config struct {
+		Digest string `json:"digest"`
+	} `json:"config"`
+	Manifests []struct {
+		Digest   string `json:"digest"`
+		Platform struct {
+			OS           string `json:"os"`
+			Architecture string `json:"architecture"`
+		} `json:"platform"`
+	} `json:"manifests"`
+}
+
+// imageConfig is the subset of an image's configuration relevant to checks.
+type imageConfig struct {
+	Config struct {
+		User string `json:"User"`
+	} `json:"config"`
+}
+
+// registryClient retrieves image configurations through the registry HTTP API v2, with anonymous access.
+type registryClient struct {
+	client  *http.Client
+	limiter *RateLimiter
+	// tokens contains the bearer tokens granted for each repository, keyed by registry and repository.
+	tokens map[string]string
+}
+
+func newRegistryClient(client *http.Client, limiter *RateLimiter) *registryClient {
+	return &registryClient{client: client, limiter: limiter, tokens: map[string]string{}}
+}
+
+// getImageConfig returns the configuration of the given image; for multi-platform images, the configuration of the
+// linux/amd64 image is returned, or of the first image when there is none.
+func (c *registryClient) getImageConfig(ref imageReference) (*imageConfig, error) {
+	manifest := &imageManifest{}
+	if err := c.get(ref, "manifests/"+ref.reference, strings.Join(manifestMediaTypes, ", "), manifest); err != nil {
+		return nil, err
+	}
+
+	if len(manifest.Manifests) > 0 {
+		digest := manifest.Manifests[0].Digest
+		for _, m := range manifest.Manifests {
+			if m.Platform.OS == "linux" && m.Platform.Architecture == "amd64" {
+				digest = m.Digest
+				break
+			}
+		}
+		manifest = &imageManifest{}
+		if err := c.get(ref, "manifests/"+digest, strings.Join(manifestMediaTypes, ", "), manifest); err != nil {
+			return nil, err
+		}
+	}
+
+	if manifest.Config.Digest == "" {
+		return nil, errors.Errorf("manifest of %s/%s:%s does not reference a configuration", ref.registry, ref.repository, ref.reference)
+	}
+
+	config := &imageConfig{}
+	if err := c.get(ref, "blobs/"+manifest.Config.Digest, "", config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// get retrieves the given path of the image's repository, decoding the JSON response into v; a bearer token is
+// requested when the registry requires one.
+func (c *registryClient) get(ref imageReference, path, accept string, v interface{}) error {
+	u := fmt.Sprintf("https://%s/v2/%s/%s", ref.registry, ref.repository, path)
+	resp, err := c.do(u, accept, c.tokens[ref.registry+"/"+ref.repository])
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		token, err := c.requestToken(challenge)
+		if err != nil {
+			return err
+		}
+		c.tokens[ref.registry+"/"+ref.repository] = token
+		if resp, err = c.do(u, accept, token); err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("failed retrieving %s: %s", u, resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(json.Unmarshal(body, v), "failed parsing %s", u)
+}
+
+func (c *registryClient) do(u, accept, token string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	c.limiter.Wait()
+	return c.client.Do(req)
+}
+
+// challengeParamRegexp matches the parameters of a WWW-Authenticate challenge, e.g. realm="https://auth.example.com".
+var challengeParamRegexp = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// requestToken requests an anonymous bearer token from the authorization server of the given Bearer challenge.
+func (c *registryClient) requestToken(challenge string) (string, error) {
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return "", errors.New("registry requires authentication")
+	}
+
+	params := url.Values{}
+	realm := ""
+	for _, m := range challengeParamRegexp.FindAllStringSubmatch(challenge, -1) {
+		if m[1] == "realm" {
+			realm = m[2]
+		} else {
+			params.Set(m[1], m[2])
+		}
+	}
+	if realm == "" {
+		return "", errors.Errorf("registry authentication challenge does not inform a realm: %s", challenge)
+	}
+
+	resp, err := c.do(realm+"?"+params.Encode(), "", "")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("failed requesting registry token from %s: %s", realm, resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", errors.Wrapf(err, "failed parsing registry token from %s", realm)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
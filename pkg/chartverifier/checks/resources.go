@@ -0,0 +1,201 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/releaseutil"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// ValuesConfigKey is the check configuration key containing the chart values, as chartutil.Values, the chart's
+// templates are rendered with; the named type keeps viper from lowercasing the values' keys.
+const ValuesConfigKey = "values"
+
+var sourceRegexp = regexp.MustCompile(`(?m)^# Source: (.+)$`)
+
+// renderedResource is a Kubernetes resource rendered from one of the chart's templates.
+type renderedResource struct {
+	*unstructured.Unstructured
+	// Source is the path of the template the resource has been rendered from.
+	Source string
+}
+
+// String identifies the resource by its kind and name, e.g. "Deployment/chart".
+func (r renderedResource) String() string {
+	return r.GetKind() + "/" + r.GetName()
+}
+
+// getRenderedResources renders the templates of the chart found at chartUri with its default values, merged with the
+// values informed in config, returning the resulting resources in the order they've been rendered.
+func getRenderedResources(chartUri string, config *viper.Viper) ([]renderedResource, error) {
+	vals, _ := config.Get(ValuesConfigKey).(chartutil.Values)
+	if vals == nil {
+		vals = chartutil.Values{}
+	}
+	txt, err := renderManifests(chartUri, vals, config.GetString(KubeVersionConfigKey))
+	if err != nil {
+		return nil, err
+	}
+	return parseRenderedResources(txt)
+}
+
+// getUnsortedRenderedResources renders the templates of the chart found at chartUri as getRenderedResources does, but
+// without Helm sorting them into manifests and hooks, so resources Helm would silently drop, such as hooks for unknown
+// events, are also returned. Resources are returned in the alphabetical order of their templates.
+func getUnsortedRenderedResources(chartUri string, config *viper.Viper) ([]renderedResource, error) {
+	vals, _ := config.Get(ValuesConfigKey).(chartutil.Values)
+	if vals == nil {
+		vals = chartutil.Values{}
+	}
+
+	caps, err := CapabilitiesForKubeVersion(config.GetString(KubeVersionConfigKey))
+	if err != nil {
+		return nil, err
+	}
+
+	c, _, err := LoadChartFromURI(chartUri)
+	if err != nil {
+		return nil, err
+	}
+	if err := chartutil.ProcessDependencies(c, vals); err != nil {
+		return nil, err
+	}
+
+	options := chartutil.ReleaseOptions{Name: "testRelease", Revision: 1, IsInstall: true}
+	renderValues, err := chartutil.ToRenderValues(c, vals, options, caps)
+	if err != nil {
+		return nil, err
+	}
+	files, err := engine.Render(c, renderValues)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if path.Base(name) != "NOTES.txt" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var manifests strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&manifests, "---\n# Source: %s\n%s\n", name, files[name])
+	}
+	return parseRenderedResources(manifests.String())
+}
+
+// parseRenderedResources parses the given multi-document manifest, ignoring empty documents.
+func parseRenderedResources(txt string) ([]renderedResource, error) {
+	manifests := releaseutil.SplitManifests(txt)
+
+	keys := make([]string, 0, len(manifests))
+	for k := range manifests {
+		keys = append(keys, k)
+	}
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	resources := make([]renderedResource, 0, len(keys))
+	for _, k := range keys {
+		manifest := manifests[k]
+
+		source := ""
+		if m := sourceRegexp.FindStringSubmatch(manifest); m != nil {
+			source = strings.TrimSpace(m[1])
+		}
+
+		b, err := yaml.YAMLToJSON([]byte(manifest))
+		if err != nil {
+			return nil, err
+		}
+		if len(b) == 0 || string(b) == "null" {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(b); err != nil {
+			return nil, err
+		}
+		res := renderedResource{Unstructured: obj, Source: source}
+		// pod specs are validated once here, so checks reading them report malformed ones as render failures
+		if _, _, err := decodePodSpec(res); err != nil {
+			return nil, fmt.Errorf("%s has an invalid pod spec: %w", res, err)
+		}
+		resources = append(resources, res)
+	}
+
+	return resources, nil
+}
+
+// podSpecFields maps workload kinds to the fields containing their pod specs.
+var podSpecFields = map[string][]string{
+	"Pod":                   {"spec"},
+	"Deployment":            {"spec", "template", "spec"},
+	"StatefulSet":           {"spec", "template", "spec"},
+	"DaemonSet":             {"spec", "template", "spec"},
+	"ReplicaSet":            {"spec", "template", "spec"},
+	"ReplicationController": {"spec", "template", "spec"},
+	"Job":                   {"spec", "template", "spec"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// getPodSpec returns the pod spec of the given workload resource, or false if the resource isn't a workload; pod specs
+// of rendered resources have been validated when parsed.
+func getPodSpec(r renderedResource) (*corev1.PodSpec, bool) {
+	podSpec, ok, _ := decodePodSpec(r)
+	return podSpec, ok
+}
+
+// decodePodSpec returns the pod spec of the given workload resource, or false if the resource isn't a workload.
+func decodePodSpec(r renderedResource) (*corev1.PodSpec, bool, error) {
+	fields, ok := podSpecFields[r.GetKind()]
+	if !ok {
+		return nil, false, nil
+	}
+
+	m, found, err := unstructured.NestedMap(r.Object, fields...)
+	if err != nil || !found {
+		return nil, false, err
+	}
+
+	podSpec := &corev1.PodSpec{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, podSpec); err != nil {
+		return nil, false, err
+	}
+
+	return podSpec, true, nil
+}
+
+// getAllContainers returns both init and regular containers of the given pod spec.
+func getAllContainers(podSpec *corev1.PodSpec) []corev1.Container {
+	containers := make([]corev1.Container, 0, len(podSpec.InitContainers)+len(podSpec.Containers))
+	containers = append(containers, podSpec.InitContainers...)
+	return append(containers, podSpec.Containers...)
+}
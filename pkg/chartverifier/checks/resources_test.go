@@ -0,0 +1,153 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestGetRenderedResources(t *testing.T) {
+
+	uri := "chart-0.1.0-v3.typed-values.tgz"
+
+	getData := func(t *testing.T, config *viper.Viper) (map[string]string, int64) {
+		resources, err := getRenderedResources(uri, config)
+		require.NoError(t, err)
+
+		var data map[string]string
+		var replicas int64
+		for _, res := range resources {
+			switch res.String() {
+			case "ConfigMap/testRelease-chart-types":
+				data, _, err = unstructured.NestedStringMap(res.Object, "data")
+				require.NoError(t, err)
+			case "Deployment/testRelease-chart":
+				replicas, _, err = unstructured.NestedInt64(res.Object, "spec", "replicas")
+				require.NoError(t, err)
+			}
+		}
+		require.NotNil(t, data)
+		return data, replicas
+	}
+
+	t.Run("Should render the chart with its default values", func(t *testing.T) {
+		data, replicas := getData(t, viper.New())
+		require.Equal(t, "8080", data["zipCode"])
+		require.Equal(t, "float64", data["zipCodeKind"])
+		require.Equal(t, int64(1), replicas)
+	})
+
+	t.Run("Should render the chart with the informed values keeping their types", func(t *testing.T) {
+		config := viper.New()
+		config.Set(ValuesConfigKey, chartutil.Values{"zipCode": "08080", "replicaCount": int64(3)})
+
+		data, replicas := getData(t, config)
+		require.Equal(t, "08080", data["zipCode"])
+		require.Equal(t, "string", data["zipCodeKind"])
+		require.Equal(t, "int64", data["replicaCountKind"])
+		require.Equal(t, int64(3), replicas)
+	})
+}
+
+func TestGetRenderedResourcesForKubeVersion(t *testing.T) {
+
+	uri := "chart-0.1.0-v3.kube-version.tgz"
+
+	getIngressAPIVersion := func(t *testing.T, resources []renderedResource) string {
+		for _, res := range resources {
+			if res.String() == "Ingress/testRelease-chart" {
+				return res.GetAPIVersion()
+			}
+		}
+		require.Fail(t, "Ingress not rendered")
+		return ""
+	}
+
+	for kubeVersion, apiVersion := range map[string]string{
+		"":       "networking.k8s.io/v1",
+		"1.22.1": "networking.k8s.io/v1",
+		"1.18":   "networking.k8s.io/v1beta1",
+		"1.13.0": "extensions/v1beta1",
+	} {
+		t.Run("Should render the chart for Kubernetes "+kubeVersion, func(t *testing.T) {
+			config := viper.New()
+			config.Set(KubeVersionConfigKey, kubeVersion)
+
+			resources, err := getRenderedResources(uri, config)
+			require.NoError(t, err)
+			require.Equal(t, apiVersion, getIngressAPIVersion(t, resources))
+
+			resources, err = getUnsortedRenderedResources(uri, config)
+			require.NoError(t, err)
+			require.Equal(t, apiVersion, getIngressAPIVersion(t, resources))
+		})
+	}
+
+	t.Run("Should fail rendering for an invalid Kubernetes version", func(t *testing.T) {
+		config := viper.New()
+		config.Set(KubeVersionConfigKey, "latest")
+		_, err := getRenderedResources(uri, config)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), `invalid Kubernetes version "latest"`)
+	})
+
+	t.Run("Should fail rendering when the chart excludes the Kubernetes version", func(t *testing.T) {
+		config := viper.New()
+		config.Set(KubeVersionConfigKey, "1.21.0")
+		_, err := getRenderedResources("chart-0.1.0-v3.valid.tgz", config)
+		require.Error(t, err)
+	})
+}
+
+func TestParseRenderedResources_InvalidPodSpec(t *testing.T) {
+	manifest := "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  template:\n    spec:\n      containers: web\n"
+
+	t.Run("Should fail parsing a workload with an invalid pod spec", func(t *testing.T) {
+		_, err := parseRenderedResources(manifest)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "Deployment/web has an invalid pod spec")
+	})
+
+	t.Run("Should report the invalid pod spec as a render failure", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, chartutil.SaveDir(&chart.Chart{
+			Metadata:  &chart.Metadata{APIVersion: chart.APIVersionV2, Name: "invalid", Version: "0.1.0"},
+			Templates: []*chart.File{{Name: "templates/deployment.yaml", Data: []byte(manifest)}},
+		}, dir))
+		uri := filepath.Join(dir, "invalid")
+
+		for name, check := range map[string]CheckFunc{
+			"referenced-configmaps-exist": ReferencedConfigMapsExist,
+			"pods-run-as-nonroot":         PodsRunAsNonroot,
+			"secrets-are-external":        SecretsAreExternal,
+			"token-automount-disabled":    TokenAutomountDisabled,
+			"probe-parameters-sane":       ProbeParametersSane,
+		} {
+			r, err := check(uri, viper.New())
+			require.NoError(t, err, name)
+			require.False(t, r.Ok, name)
+			require.Contains(t, r.Reason, RenderFailed, name)
+		}
+	})
+}
@@ -0,0 +1,174 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+	"helm.sh/helm/v3/pkg/release"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	ResourceScopesCorrect           = "Resources are correctly scoped"
+	ClusterScopedResourceNamespaced = "Cluster-scoped resource sets a namespace"
+	NamespaceHardcoded              = "Namespaced resource is bound to a hardcoded namespace"
+	ResourcesUnique                 = "Rendered resources are unique"
+	ResourceDuplicated              = "Resource is rendered several times"
+)
+
+// clusterScopedKinds contains the kinds of the well known cluster-scoped resources.
+var clusterScopedKinds = map[string]bool{
+	"APIService":                     true,
+	"CSIDriver":                      true,
+	"ClusterRole":                    true,
+	"ClusterRoleBinding":             true,
+	"CustomResourceDefinition":       true,
+	"IngressClass":                   true,
+	"MutatingWebhookConfiguration":   true,
+	"Namespace":                      true,
+	"PersistentVolume":               true,
+	"PodSecurityPolicy":              true,
+	"PriorityClass":                  true,
+	"RuntimeClass":                   true,
+	"SecurityContextConstraints":     true,
+	"StorageClass":                   true,
+	"ValidatingWebhookConfiguration": true,
+	"VolumeSnapshotClass":            true,
+}
+
+// getClusterScopedKinds returns the kinds of the well known cluster-scoped resources, along with the ones of the
+// cluster-scoped custom resources defined by the given resources.
+func getClusterScopedKinds(resources []renderedResource) map[string]bool {
+	kinds := make(map[string]bool, len(clusterScopedKinds))
+	for k := range clusterScopedKinds {
+		kinds[k] = true
+	}
+	for _, res := range resources {
+		if res.GetKind() != "CustomResourceDefinition" {
+			continue
+		}
+		scope, _, _ := unstructured.NestedString(res.Object, "spec", "scope")
+		kind, _, _ := unstructured.NestedString(res.Object, "spec", "names", "kind")
+		if scope == "Cluster" && kind != "" {
+			kinds[kind] = true
+		}
+	}
+	return kinds
+}
+
+// ResourceScopeCorrect checks cluster-scoped resources don't set a namespace, and namespaced resources are deployed
+// to the release namespace. Templates are rendered for an empty release namespace, so namespaced resources setting a
+// namespace haven't been rendered from .Release.Namespace.
+func ResourceScopeCorrect(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	allowlist := getStringSetConfig(config, AllowlistConfigKey)
+	clusterScoped := getClusterScopedKinds(resources)
+
+	r := NewResult(true, ResourceScopesCorrect)
+	for _, res := range resources {
+		namespace := res.GetNamespace()
+		if namespace == "" || allowlist[res.GetName()] {
+			continue
+		}
+
+		if clusterScoped[res.GetKind()] {
+			addFailure(&r, fmt.Sprintf("%s : %s", ClusterScopedResourceNamespaced, res))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    "metadata.namespace",
+				Message:  fmt.Sprintf("%s is cluster-scoped and should not set namespace %s", res.GetKind(), namespace),
+				Severity: ErrorSeverity,
+			})
+			continue
+		}
+
+		addFailure(&r, fmt.Sprintf("%s : %s (%s)", NamespaceHardcoded, res, namespace))
+		r.AddFinding(Finding{
+			Resource: res.String(),
+			Field:    "metadata.namespace",
+			Message:  fmt.Sprintf("Namespace %s should be replaced by the release namespace", namespace),
+			Severity: ErrorSeverity,
+		})
+	}
+
+	return r, nil
+}
+
+// resourceIdentity returns the key identifying the object the given resource is applied to; versions of the same
+// API group address the same objects, and resources without namespace are deployed to the release namespace.
+func resourceIdentity(res renderedResource) string {
+	gv, err := schema.ParseGroupVersion(res.GetAPIVersion())
+	group := gv.Group
+	if err != nil {
+		group = res.GetAPIVersion()
+	}
+	return strings.Join([]string{group, res.GetKind(), res.GetNamespace(), res.GetName()}, "/")
+}
+
+// NoDuplicateResources checks no two rendered resources share their API group, kind, namespace and name, in which case
+// one silently overwrites the other. Hooks are left out, since they're created and deleted outside of the release.
+func NoDuplicateResources(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	var identities []string
+	rendered := map[string][]renderedResource{}
+	for _, res := range resources {
+		if _, ok := res.GetAnnotations()[release.HookAnnotation]; ok {
+			continue
+		}
+		identity := resourceIdentity(res)
+		if _, ok := rendered[identity]; !ok {
+			identities = append(identities, identity)
+		}
+		rendered[identity] = append(rendered[identity], res)
+	}
+
+	r := NewResult(true, ResourcesUnique)
+	for _, identity := range identities {
+		duplicates := rendered[identity]
+		if len(duplicates) < 2 {
+			continue
+		}
+
+		sources := make([]string, len(duplicates))
+		for i, res := range duplicates {
+			sources[i] = res.Source
+		}
+		addFailure(&r, fmt.Sprintf("%s : %s (%s)", ResourceDuplicated, duplicates[0], strings.Join(sources, ", ")))
+		for _, res := range duplicates[1:] {
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    "metadata.name",
+				Message:  fmt.Sprintf("%s rendered by %s is also rendered by %s, so one overwrites the other", res, res.Source, duplicates[0].Source),
+				Severity: ErrorSeverity,
+			})
+		}
+	}
+
+	return r, nil
+}
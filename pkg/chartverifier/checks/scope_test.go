@@ -0,0 +1,173 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+func TestResourceScopeCorrect(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		values      chartutil.Values
+		allowlist   []string
+		reason      string
+		findings    []Finding
+	}
+
+	scope := func(clusterRoleNamespace, configMapNamespace string) chartutil.Values {
+		return chartutil.Values{"scope": map[string]interface{}{
+			"clusterRoleNamespace": clusterRoleNamespace,
+			"configMapNamespace":   configMapNamespace,
+		}}
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart with namespaced resources only", uri: "chart-0.1.0-v3.valid.tgz"},
+		{description: "ClusterRole without namespace and ConfigMap in the release namespace", uri: "chart-0.1.0-v3.scope.tgz"},
+		{description: "allowlisted ClusterRole with a namespace", uri: "chart-0.1.0-v3.scope.tgz", values: scope("default", ""), allowlist: []string{"testRelease-chart"}},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			if tc.values != nil {
+				config.Set(ValuesConfigKey, tc.values)
+			}
+			r, err := ResourceScopeCorrect(tc.uri, config)
+			require.NoError(t, err)
+			require.True(t, r.Ok)
+			require.Equal(t, ResourceScopesCorrect, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "ClusterRole with a namespace",
+			uri:         "chart-0.1.0-v3.scope.tgz",
+			values:      scope("default", ""),
+			reason:      ClusterScopedResourceNamespaced + " : ClusterRole/testRelease-chart",
+			findings: []Finding{
+				{Resource: "ClusterRole/testRelease-chart", Field: "metadata.namespace", Message: "ClusterRole is cluster-scoped and should not set namespace default", Severity: ErrorSeverity},
+			},
+		},
+		{
+			description: "ConfigMap in a hardcoded namespace",
+			uri:         "chart-0.1.0-v3.scope.tgz",
+			values:      scope("", "kube-system"),
+			reason:      NamespaceHardcoded + " : ConfigMap/testRelease-chart (kube-system)",
+			findings: []Finding{
+				{Resource: "ConfigMap/testRelease-chart", Field: "metadata.namespace", Message: "Namespace kube-system should be replaced by the release namespace", Severity: ErrorSeverity},
+			},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			config.Set(ValuesConfigKey, tc.values)
+			r, err := ResourceScopeCorrect(tc.uri, config)
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, tc.findings, r.Findings)
+		})
+	}
+}
+
+func TestNoDuplicateResources(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		values      chartutil.Values
+		reason      string
+		findings    []Finding
+	}
+
+	duplicatesUri := "chart-0.1.0-v3.duplicate-resources.tgz"
+
+	positiveTestCases := []testCase{
+		{description: "chart with resources of the same name but different kinds", uri: "chart-0.1.0-v3.valid.tgz"},
+		{
+			description: "chart with uniquely named resources",
+			uri:         duplicatesUri,
+			values:      chartutil.Values{"configCopy": map[string]interface{}{"enabled": false}},
+		},
+		{
+			description: "chart with resources of the same name in different namespaces",
+			uri:         duplicatesUri,
+			values:      chartutil.Values{"configCopy": map[string]interface{}{"namespace": "other"}},
+		},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, tc.values)
+			r, err := NoDuplicateResources(tc.uri, config)
+			require.NoError(t, err)
+			require.True(t, r.Ok, r.Reason)
+			require.Equal(t, ResourcesUnique, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart with a pair of duplicate resources",
+			uri:         duplicatesUri,
+			reason:      ResourceDuplicated + " : ConfigMap/testRelease-chart (chart/templates/configmap-copy.yaml, chart/templates/configmap.yaml)",
+			findings: []Finding{{
+				Resource: "ConfigMap/testRelease-chart",
+				Field:    "metadata.name",
+				Message:  "ConfigMap/testRelease-chart rendered by chart/templates/configmap.yaml is also rendered by chart/templates/configmap-copy.yaml, so one overwrites the other",
+				Severity: ErrorSeverity,
+			}},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, tc.values)
+			r, err := NoDuplicateResources(tc.uri, config)
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, tc.findings, r.Findings)
+		})
+	}
+}
+
+func TestResourceIdentity(t *testing.T) {
+	resources, err := parseRenderedResources("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n" +
+		"---\napiVersion: apps/v1beta2\nkind: Deployment\nmetadata:\n  name: web\n" +
+		"---\napiVersion: v1\nkind: Service\nmetadata:\n  name: web\n  namespace: other\n")
+	require.NoError(t, err)
+	require.Len(t, resources, 3)
+	require.Equal(t, "apps/Deployment//web", resourceIdentity(resources[0]))
+	require.Equal(t, resourceIdentity(resources[0]), resourceIdentity(resources[1]))
+	require.Equal(t, "/Service/other/web", resourceIdentity(resources[2]))
+}
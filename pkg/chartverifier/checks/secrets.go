@@ -0,0 +1,301 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// MinEntropyConfigKey is the check configuration key informing the minimum Shannon entropy, in bits per
+	// character, of values considered secret literals when their keys resemble secrets.
+	MinEntropyConfigKey = "minEntropy"
+	// KeyPatternsConfigKey is the check configuration key containing the regular expressions matching the keys of
+	// values, or the names of environment variables, expected to hold secrets; they are matched case insensitively.
+	KeyPatternsConfigKey = "keyPatterns"
+)
+
+const (
+	ValuesFreeOfSecrets = "Values do not contain secret literals"
+	SecretLiteralFound  = "Values contain a secret literal"
+	SecretsExternal     = "Secrets are not embedded in the chart"
+	SecretInline        = "Secret embeds its data in the chart"
+	EnvFreeOfSecrets    = "Container environment variables do not embed secrets"
+	EnvSecretPlaintext  = "Container environment variable embeds a secret in plaintext"
+)
+
+const (
+	// defaultMinEntropy is the minimum entropy used when none is configured; placeholders such as "changeme" stay
+	// below it, while generated passwords and tokens are usually well above.
+	defaultMinEntropy = 3.5
+	// minSecretLength is the minimum length of values considered secret literals because of their entropy.
+	minSecretLength = 8
+)
+
+// defaultKeyPatterns are the key patterns used when none is configured.
+var defaultKeyPatterns = []string{"password", "passwd", "secret", "token", "api_?key", "credential", "private_?key"}
+
+// defaultEnvNamePatterns are the environment variable name patterns used when none is configured; unlike keys of
+// values, names ending in KEY are expected to hold secrets, e.g. AWS_SECRET_ACCESS_KEY or SIGNING_KEY.
+var defaultEnvNamePatterns = []string{"password", "passwd", "secret", "token", "credential", "(^|_)key(_|$)", "api_?key"}
+
+// maskedValue replaces secret values in reasons and findings, as reports are usually shared.
+var maskedValue = strings.Repeat("*", 8)
+
+// secretValueRegexps match values recognizable as secrets regardless of their keys.
+var secretValueRegexps = map[string]*regexp.Regexp{
+	"a private key":        regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY( BLOCK)?-----`),
+	"an AWS access key id": regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),
+	"a GitHub token":       regexp.MustCompile(`\bgh[pousr]_[0-9A-Za-z]{36}\b`),
+	"a Slack token":        regexp.MustCompile(`\bxox[abprs]-[0-9A-Za-z-]{10,}\b`),
+}
+
+// shannonEntropy returns the Shannon entropy of s, in bits per character.
+func shannonEntropy(s string) float64 {
+	counts := map[rune]int{}
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	entropy := 0.0
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// stringValue is a string found at path within the chart's values.
+type stringValue struct {
+	path  string
+	key   string
+	value string
+}
+
+// getStringValues returns all strings found in v, in path order.
+func getStringValues(path, key string, v interface{}) []stringValue {
+	switch v := v.(type) {
+	case string:
+		return []stringValue{{path: path, key: key, value: v}}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var values []stringValue
+		for _, k := range keys {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			values = append(values, getStringValues(p, k, v[k])...)
+		}
+		return values
+	case []interface{}:
+		var values []stringValue
+		for i, e := range v {
+			values = append(values, getStringValues(fmt.Sprintf("%s[%d]", path, i), key, e)...)
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// describeSecret returns what v resembles, or an empty string if it doesn't resemble a secret.
+func describeSecret(v stringValue, keyRegexps []*regexp.Regexp, minEntropy float64) string {
+	descriptions := make([]string, 0, len(secretValueRegexps))
+	for description := range secretValueRegexps {
+		descriptions = append(descriptions, description)
+	}
+	sort.Strings(descriptions)
+	for _, description := range descriptions {
+		if secretValueRegexps[description].MatchString(v.value) {
+			return description
+		}
+	}
+
+	if len(v.value) < minSecretLength || shannonEntropy(v.value) < minEntropy {
+		return ""
+	}
+	for _, re := range keyRegexps {
+		if re.MatchString(v.key) {
+			return "a high entropy secret"
+		}
+	}
+	return ""
+}
+
+// getKeyRegexps compiles the key patterns informed in config, or the given default ones when none are, so they match
+// case insensitively.
+func getKeyRegexps(config *viper.Viper, defaults []string) ([]*regexp.Regexp, error) {
+	keyPatterns := defaults
+	if config.IsSet(KeyPatternsConfigKey) {
+		keyPatterns = getStringSliceConfig(config, KeyPatternsConfigKey)
+	}
+
+	keyRegexps := make([]*regexp.Regexp, 0, len(keyPatterns))
+	for _, p := range keyPatterns {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key pattern %q: %v", p, err)
+		}
+		keyRegexps = append(keyRegexps, re)
+	}
+	return keyRegexps, nil
+}
+
+func NoSecretsInValues(uri string, config *viper.Viper) (Result, error) {
+	c, _, err := LoadChartFromURI(uri)
+	if err != nil {
+		return Result{}, err
+	}
+
+	minEntropy := defaultMinEntropy
+	if config.IsSet(MinEntropyConfigKey) {
+		minEntropy = config.GetFloat64(MinEntropyConfigKey)
+	}
+
+	keyRegexps, err := getKeyRegexps(config, defaultKeyPatterns)
+	if err != nil {
+		return Result{}, err
+	}
+
+	r := NewResult(true, ValuesFreeOfSecrets)
+	for _, v := range getStringValues("", "", c.Values) {
+		description := describeSecret(v, keyRegexps, minEntropy)
+		if description == "" {
+			continue
+		}
+		// the value itself is never reported, as reports are usually shared
+		addFailure(&r, fmt.Sprintf("%s : %s", SecretLiteralFound, v.path))
+		r.AddFinding(Finding{
+			Resource: "values.yaml",
+			Field:    v.path,
+			Message:  fmt.Sprintf("Value resembling %s: %s", description, maskedValue),
+			Severity: ErrorSeverity,
+		})
+	}
+
+	return r, nil
+}
+
+// SecretsAreExternal checks the chart doesn't render Secrets holding data, which should rather be provided by existing
+// Secrets, or by resources such as ExternalSecrets or SealedSecrets; Secrets without data, e.g. ServiceAccount tokens
+// populated by the cluster, are accepted. Bootstrap Secrets can be allowlisted by name.
+func SecretsAreExternal(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	allowlist := getStringSetConfig(config, AllowlistConfigKey)
+
+	r := NewResult(true, SecretsExternal)
+	for _, res := range resources {
+		if res.GetKind() != "Secret" || allowlist[res.GetName()] {
+			continue
+		}
+
+		for _, field := range []string{"data", "stringData"} {
+			data, _, err := unstructured.NestedMap(res.Object, field)
+			if err != nil {
+				return Result{}, err
+			}
+			if len(data) == 0 {
+				continue
+			}
+
+			keys := make([]string, 0, len(data))
+			for k := range data {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			addFailure(&r, fmt.Sprintf("%s : %s", SecretInline, res))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    field,
+				Message:  fmt.Sprintf("Secret embeds %s; reference an existing Secret instead", strings.Join(keys, ", ")),
+				Severity: ErrorSeverity,
+			})
+		}
+	}
+
+	return r, nil
+}
+
+// NoPlaintextEnvSecrets checks the containers of the workloads rendered by the chart don't set environment variables
+// whose names suggest secrets to literal values, which should rather be read from Secrets through
+// valueFrom.secretKeyRef; empty values are accepted. Variables can be allowlisted by name.
+func NoPlaintextEnvSecrets(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	nameRegexps, err := getKeyRegexps(config, defaultEnvNamePatterns)
+	if err != nil {
+		return Result{}, err
+	}
+
+	allowlist := getStringSetConfig(config, AllowlistConfigKey)
+
+	r := NewResult(true, EnvFreeOfSecrets)
+	for _, res := range resources {
+		podSpec, ok := getPodSpec(res)
+		if !ok {
+			continue
+		}
+
+		for _, c := range getIndexedContainers(podSpec, strings.Join(podSpecFields[res.GetKind()], ".")) {
+			for i, env := range c.container.Env {
+				if env.ValueFrom != nil || env.Value == "" || allowlist[env.Name] {
+					continue
+				}
+				matched := false
+				for _, re := range nameRegexps {
+					matched = matched || re.MatchString(env.Name)
+				}
+				if !matched {
+					continue
+				}
+
+				field := fmt.Sprintf("%s.env[%d]", c.field, i)
+				addFailure(&r, fmt.Sprintf("%s : %s %s (%s)", EnvSecretPlaintext, res, field, env.Name))
+				r.AddFinding(Finding{
+					Resource: res.String(),
+					Field:    field,
+					Message: fmt.Sprintf("Variable %s is set to %s; read it from a Secret through valueFrom.secretKeyRef instead",
+						env.Name, maskedValue),
+					Severity: ErrorSeverity,
+				})
+			}
+		}
+	}
+
+	return r, nil
+}
@@ -0,0 +1,292 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+func TestNoSecretsInValues(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		options     map[string]interface{}
+		reason      string
+		field       string
+		message     string
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart without secrets in its values", uri: "chart-0.1.0-v3.valid.tgz"},
+		{
+			description: "chart with a password below the configured entropy",
+			uri:         "chart-0.1.0-v3.secret-password-values.tgz",
+			options:     map[string]interface{}{MinEntropyConfigKey: "5"},
+		},
+		{
+			description: "chart with a password not matching the configured key patterns",
+			uri:         "chart-0.1.0-v3.secret-password-values.tgz",
+			options:     map[string]interface{}{KeyPatternsConfigKey: "token,api_?key"},
+		},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			for k, v := range tc.options {
+				config.Set(k, v)
+			}
+			r, err := NoSecretsInValues(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.True(t, r.Ok)
+			require.Equal(t, ValuesFreeOfSecrets, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart with a private key block in its values",
+			uri:         "chart-0.1.0-v3.private-key-values.tgz",
+			reason:      SecretLiteralFound + " : tls.key",
+			field:       "tls.key",
+			message:     "Value resembling a private key: ********",
+		},
+		{
+			description: "chart with a high entropy password in its values",
+			uri:         "chart-0.1.0-v3.secret-password-values.tgz",
+			reason:      SecretLiteralFound + " : auth.password",
+			field:       "auth.password",
+			message:     "Value resembling a high entropy secret: ********",
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			r, err := NoSecretsInValues(tc.uri, viper.New())
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Len(t, r.Findings, 1)
+			require.Equal(t, "values.yaml", r.Findings[0].Resource)
+			require.Equal(t, tc.field, r.Findings[0].Field)
+			require.Equal(t, tc.message, r.Findings[0].Message)
+			require.Equal(t, ErrorSeverity, r.Findings[0].Severity)
+			require.NotContains(t, r.Reason, "x8#Kp2!vQz9LmR4tWb7N")
+		})
+	}
+
+	t.Run("Should fail when a key pattern is invalid", func(t *testing.T) {
+		config := viper.New()
+		config.Set(KeyPatternsConfigKey, "pass(")
+		_, err := NoSecretsInValues("chart-0.1.0-v3.valid.tgz", config)
+		require.Error(t, err)
+	})
+}
+
+func TestSecretsAreExternal(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		values      chartutil.Values
+		allowlist   []string
+		reason      string
+		findings    []Finding
+	}
+
+	externalSecret := chartutil.Values{
+		"secret":         map[string]interface{}{"create": false},
+		"externalSecret": map[string]interface{}{"enabled": true},
+	}
+	bootstrapSecret := chartutil.Values{
+		"secret":    map[string]interface{}{"create": false},
+		"bootstrap": map[string]interface{}{"enabled": true},
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart without Secrets", uri: "chart-0.1.0-v3.valid.tgz"},
+		{description: "chart referencing an external secret", uri: "chart-0.1.0-v3.secrets-inline.tgz", values: externalSecret},
+		{description: "chart with an allowlisted bootstrap Secret", uri: "chart-0.1.0-v3.secrets-inline.tgz", values: bootstrapSecret, allowlist: []string{"testRelease-chart-bootstrap"}},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			if tc.values != nil {
+				config.Set(ValuesConfigKey, tc.values)
+			}
+			r, err := SecretsAreExternal(tc.uri, config)
+			require.NoError(t, err)
+			require.True(t, r.Ok)
+			require.Equal(t, SecretsExternal, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart with an inline Secret",
+			uri:         "chart-0.1.0-v3.secrets-inline.tgz",
+			reason:      SecretInline + " : Secret/testRelease-chart",
+			findings: []Finding{
+				{Resource: "Secret/testRelease-chart", Field: "stringData", Message: "Secret embeds password, username; reference an existing Secret instead", Severity: ErrorSeverity},
+			},
+		},
+		{
+			description: "chart with inline Secrets when only one is allowlisted",
+			uri:         "chart-0.1.0-v3.secrets-inline.tgz",
+			values:      chartutil.Values{"bootstrap": map[string]interface{}{"enabled": true}},
+			allowlist:   []string{"testRelease-chart"},
+			reason:      SecretInline + " : Secret/testRelease-chart-bootstrap",
+			findings: []Finding{
+				{Resource: "Secret/testRelease-chart-bootstrap", Field: "data", Message: "Secret embeds token; reference an existing Secret instead", Severity: ErrorSeverity},
+			},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			if tc.values != nil {
+				config.Set(ValuesConfigKey, tc.values)
+			}
+			r, err := SecretsAreExternal(tc.uri, config)
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, tc.findings, r.Findings)
+		})
+	}
+}
+
+func TestNoPlaintextEnvSecrets(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		values      chartutil.Values
+		keyPatterns []string
+		allowlist   []string
+		reason      string
+		findings    []Finding
+	}
+
+	apiToken := chartutil.Values{"apiToken": "c2VjcmV0LXRva2Vu"}
+
+	positiveTestCases := []testCase{
+		{description: "chart without environment variables", uri: "chart-0.1.0-v3.valid.tgz"},
+		{description: "chart reading its secret from a Secret along with a benign variable", uri: "chart-0.1.0-v3.env-secrets.tgz"},
+		{description: "chart with an allowlisted plaintext secret", uri: "chart-0.1.0-v3.env-secrets.tgz", values: apiToken, allowlist: []string{"API_TOKEN"}},
+		{description: "chart with a plaintext secret not matching the key patterns", uri: "chart-0.1.0-v3.env-secrets.tgz", values: apiToken, keyPatterns: []string{"password"}},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			if tc.values != nil {
+				config.Set(ValuesConfigKey, tc.values)
+			}
+			if tc.keyPatterns != nil {
+				config.Set(KeyPatternsConfigKey, tc.keyPatterns)
+			}
+			r, err := NoPlaintextEnvSecrets(tc.uri, config)
+			require.NoError(t, err)
+			require.True(t, r.Ok)
+			require.Equal(t, EnvFreeOfSecrets, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart with a plaintext secret",
+			uri:         "chart-0.1.0-v3.env-secrets.tgz",
+			values:      apiToken,
+			reason:      EnvSecretPlaintext + " : Deployment/testRelease-chart spec.template.spec.containers[0].env[2] (API_TOKEN)",
+			findings: []Finding{
+				{
+					Resource: "Deployment/testRelease-chart",
+					Field:    "spec.template.spec.containers[0].env[2]",
+					Message:  "Variable API_TOKEN is set to ********; read it from a Secret through valueFrom.secretKeyRef instead",
+					Severity: ErrorSeverity,
+				},
+			},
+		},
+		{
+			description: "chart with a benign variable matching the key patterns",
+			uri:         "chart-0.1.0-v3.env-secrets.tgz",
+			keyPatterns: []string{"level"},
+			reason:      EnvSecretPlaintext + " : Deployment/testRelease-chart spec.template.spec.containers[0].env[0] (LOG_LEVEL)",
+			findings: []Finding{
+				{
+					Resource: "Deployment/testRelease-chart",
+					Field:    "spec.template.spec.containers[0].env[0]",
+					Message:  "Variable LOG_LEVEL is set to ********; read it from a Secret through valueFrom.secretKeyRef instead",
+					Severity: ErrorSeverity,
+				},
+			},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			if tc.values != nil {
+				config.Set(ValuesConfigKey, tc.values)
+			}
+			if tc.keyPatterns != nil {
+				config.Set(KeyPatternsConfigKey, tc.keyPatterns)
+			}
+			r, err := NoPlaintextEnvSecrets(tc.uri, config)
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, tc.findings, r.Findings)
+			require.NotContains(t, r.Reason, "c2VjcmV0LXRva2Vu")
+		})
+	}
+}
+
+func TestDefaultEnvNamePatterns(t *testing.T) {
+	nameRegexps, err := getKeyRegexps(viper.New(), defaultEnvNamePatterns)
+	require.NoError(t, err)
+
+	matches := func(name string) bool {
+		for _, re := range nameRegexps {
+			if re.MatchString(name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, name := range []string{"DB_PASSWORD", "AWS_SECRET_ACCESS_KEY", "SIGNING_KEY", "KEY", "ApiKey", "GITHUB_TOKEN", "SMTP_CREDENTIALS"} {
+		require.True(t, matches(name), name)
+	}
+	for _, name := range []string{"LOG_LEVEL", "KEYCLOAK_URL", "MONKEY_MODE", "PORT"} {
+		require.False(t, matches(name), name)
+	}
+}
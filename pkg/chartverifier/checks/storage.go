@@ -0,0 +1,289 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// ZonalStorageClassesConfigKey is the check configuration key containing the names of the cluster's storage
+	// classes provisioning volumes bound to a single zone; defaults to the ones OpenShift creates on the major clouds.
+	ZonalStorageClassesConfigKey = "zonalStorageClasses"
+)
+
+const (
+	PvcStorageIsDeclared       = "Persistent volume claims declare their storage"
+	PvcStorageSizeMissing      = "Persistent volume claim does not request a storage size"
+	PvcStorageClassMissing     = "Persistent volume claim does not declare a storage class"
+	StatefulSetTopologyValid   = "StatefulSets claiming zonal storage constrain their pods to zones"
+	StatefulSetTopologyMissing = "StatefulSet claims zonal storage without zone topology constraints"
+)
+
+// defaultZonalStorageClasses are the storage classes OpenShift creates on AWS, GCP, Azure and OpenStack, all backed by
+// block volumes only attachable to nodes of their zone.
+var defaultZonalStorageClasses = []string{
+	"gp2", "gp2-csi", "gp3", "gp3-csi",
+	"standard", "standard-csi",
+	"managed-premium", "managed-csi",
+}
+
+// zonalProvisioners are the provisioners of block volumes only attachable to nodes of their zone.
+var zonalProvisioners = map[string]bool{
+	"kubernetes.io/aws-ebs":    true,
+	"ebs.csi.aws.com":          true,
+	"kubernetes.io/gce-pd":     true,
+	"pd.csi.storage.gke.io":    true,
+	"kubernetes.io/azure-disk": true,
+	"disk.csi.azure.com":       true,
+	"kubernetes.io/cinder":     true,
+	"cinder.csi.openstack.org": true,
+}
+
+// persistentVolumeClaim is a claim rendered either as a PersistentVolumeClaim or as a StatefulSet volumeClaimTemplate.
+type persistentVolumeClaim struct {
+	// resource is the resource the claim has been rendered in.
+	resource renderedResource
+	// field is the path of the claim within resource; empty for PersistentVolumeClaims.
+	field string
+	claim *corev1.PersistentVolumeClaim
+}
+
+// String identifies the claim, e.g. "PersistentVolumeClaim/data" or "StatefulSet/db volumeClaimTemplates/data".
+func (c persistentVolumeClaim) String() string {
+	if c.field == "" {
+		return c.resource.String()
+	}
+	return c.resource.String() + " volumeClaimTemplates/" + c.claim.Name
+}
+
+// fieldPath returns the path of the given claim field within the claim's resource.
+func (c persistentVolumeClaim) fieldPath(field string) string {
+	if c.field == "" {
+		return field
+	}
+	return c.field + "." + field
+}
+
+// getPersistentVolumeClaims returns the claims found in the given resources, in the order they've been rendered.
+func getPersistentVolumeClaims(resources []renderedResource) ([]persistentVolumeClaim, error) {
+	var claims []persistentVolumeClaim
+	for _, res := range resources {
+		switch res.GetKind() {
+		case "PersistentVolumeClaim":
+			claim := &corev1.PersistentVolumeClaim{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(res.Object, claim); err != nil {
+				return nil, err
+			}
+			claims = append(claims, persistentVolumeClaim{resource: res, claim: claim})
+
+		case "StatefulSet":
+			templates, _, err := unstructured.NestedSlice(res.Object, "spec", "volumeClaimTemplates")
+			if err != nil {
+				return nil, err
+			}
+			for i, t := range templates {
+				m, ok := t.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("%s: unexpected volume claim template %v", res, t)
+				}
+				claim := &corev1.PersistentVolumeClaim{}
+				if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, claim); err != nil {
+					return nil, err
+				}
+				claims = append(claims, persistentVolumeClaim{
+					resource: res,
+					field:    fmt.Sprintf("spec.volumeClaimTemplates[%d]", i),
+					claim:    claim,
+				})
+			}
+		}
+	}
+	return claims, nil
+}
+
+func PvcStorageDeclared(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	claims, err := getPersistentVolumeClaims(resources)
+	if err != nil {
+		return Result{}, err
+	}
+
+	strict := config.GetBool(StrictConfigKey)
+
+	r := NewResult(true, PvcStorageIsDeclared)
+	for _, c := range claims {
+		if _, ok := c.claim.Spec.Resources.Requests[corev1.ResourceStorage]; !ok {
+			addFailure(&r, fmt.Sprintf("%s : %s", PvcStorageSizeMissing, c))
+			r.AddFinding(Finding{
+				Resource: c.resource.String(),
+				Field:    c.fieldPath("spec.resources.requests.storage"),
+				Message:  fmt.Sprintf("Claim %s does not request a storage size", c.claim.Name),
+				Severity: ErrorSeverity,
+			})
+		}
+
+		if strict && c.claim.Spec.StorageClassName == nil {
+			addFailure(&r, fmt.Sprintf("%s : %s", PvcStorageClassMissing, c))
+			r.AddFinding(Finding{
+				Resource: c.resource.String(),
+				Field:    c.fieldPath("spec.storageClassName"),
+				Message:  fmt.Sprintf("Claim %s relies on the cluster's default storage class", c.claim.Name),
+				Severity: ErrorSeverity,
+			})
+		}
+	}
+
+	return r, nil
+}
+
+// isZoneTopologyKey informs whether key identifies the zone of a node, either the well known
+// topology.kubernetes.io/zone label, its deprecated beta predecessor, or a CSI driver's zone label.
+func isZoneTopologyKey(key string) bool {
+	return key == corev1.LabelTopologyZone || key == corev1.LabelFailureDomainBetaZone || strings.HasSuffix(key, "/zone")
+}
+
+// getZonalStorageClasses returns the configured zonal storage classes, along with the ones rendered by the chart
+// whose provisioner is zonal.
+func getZonalStorageClasses(resources []renderedResource, config *viper.Viper) (map[string]bool, error) {
+	zonal := map[string]bool{}
+	if config.IsSet(ZonalStorageClassesConfigKey) {
+		zonal = getStringSetConfig(config, ZonalStorageClassesConfigKey)
+	} else {
+		for _, name := range defaultZonalStorageClasses {
+			zonal[name] = true
+		}
+	}
+
+	for _, res := range resources {
+		if res.GetKind() != "StorageClass" {
+			continue
+		}
+		provisioner, _, err := unstructured.NestedString(res.Object, "provisioner")
+		if err != nil {
+			return nil, err
+		}
+		if zonalProvisioners[provisioner] {
+			zonal[res.GetName()] = true
+		}
+	}
+	return zonal, nil
+}
+
+// constrainsZones informs whether podSpec either spreads its pods across zones or pins them to zones through node
+// affinity, so pods are scheduled where their zonal volumes can be attached.
+func constrainsZones(podSpec *corev1.PodSpec) bool {
+	for _, c := range podSpec.TopologySpreadConstraints {
+		if isZoneTopologyKey(c.TopologyKey) {
+			return true
+		}
+	}
+
+	if podSpec.Affinity == nil || podSpec.Affinity.NodeAffinity == nil {
+		return false
+	}
+	nodeAffinity := podSpec.Affinity.NodeAffinity
+
+	var terms []corev1.NodeSelectorTerm
+	if nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution != nil {
+		terms = append(terms, nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms...)
+	}
+	for _, p := range nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+		terms = append(terms, p.Preference)
+	}
+	for _, term := range terms {
+		for _, e := range term.MatchExpressions {
+			if isZoneTopologyKey(e.Key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func StatefulSetTopologyIsValid(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	zonal, err := getZonalStorageClasses(resources, config)
+	if err != nil {
+		return Result{}, err
+	}
+
+	claims, err := getPersistentVolumeClaims(resources)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// the zonal storage classes claimed by each StatefulSet, keyed by StatefulSet
+	claimed := map[string]map[string]bool{}
+	for _, c := range claims {
+		if c.field == "" || c.claim.Spec.StorageClassName == nil || !zonal[*c.claim.Spec.StorageClassName] {
+			continue
+		}
+		if claimed[c.resource.String()] == nil {
+			claimed[c.resource.String()] = map[string]bool{}
+		}
+		claimed[c.resource.String()][*c.claim.Spec.StorageClassName] = true
+	}
+
+	allowlist := getStringSetConfig(config, AllowlistConfigKey)
+
+	r := NewResult(true, StatefulSetTopologyValid)
+	for _, res := range resources {
+		classes, ok := claimed[res.String()]
+		if !ok || allowlist[res.GetName()] {
+			continue
+		}
+
+		podSpec, found := getPodSpec(res)
+		if found && constrainsZones(podSpec) {
+			continue
+		}
+
+		var names []string
+		for name := range classes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		addFailure(&r, fmt.Sprintf("%s : %s (%s)", StatefulSetTopologyMissing, res, strings.Join(names, ", ")))
+		r.AddFinding(Finding{
+			Resource: res.String(),
+			Field:    "spec.template.spec.topologySpreadConstraints",
+			Message: fmt.Sprintf("StatefulSet %s claims zonal storage class %s but neither spreads its pods across "+
+				"zones through topologySpreadConstraints nor constrains them to zones through node affinity on %s",
+				res.GetName(), strings.Join(names, ", "), corev1.LabelTopologyZone),
+			Severity: ErrorSeverity,
+		})
+	}
+
+	return r, nil
+}
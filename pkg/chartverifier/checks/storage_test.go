@@ -0,0 +1,274 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+func TestPvcStorageDeclared(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		strict      bool
+		reason      string
+		findings    []Finding
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart without claims in strict mode", uri: "chart-0.1.0-v3.valid.tgz", strict: true},
+		{description: "chart with a fully specified claim in strict mode", uri: "chart-0.1.0-v3.pvc-declared.tgz", strict: true},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(StrictConfigKey, tc.strict)
+			r, err := PvcStorageDeclared(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.True(t, r.Ok)
+			require.Equal(t, PvcStorageIsDeclared, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart with a claim missing its storage size",
+			uri:         "chart-0.1.0-v3.pvc-missing-size.tgz",
+			reason:      PvcStorageSizeMissing + " : PersistentVolumeClaim/testRelease-chart-data",
+			findings: []Finding{
+				{
+					Resource: "PersistentVolumeClaim/testRelease-chart-data",
+					Field:    "spec.resources.requests.storage",
+					Message:  "Claim testRelease-chart-data does not request a storage size",
+					Severity: ErrorSeverity,
+				},
+			},
+		},
+		{
+			description: "chart with a claim missing its storage size and class in strict mode",
+			uri:         "chart-0.1.0-v3.pvc-missing-size.tgz",
+			strict:      true,
+			reason: PvcStorageSizeMissing + " : PersistentVolumeClaim/testRelease-chart-data" +
+				"\n\t\t" + PvcStorageClassMissing + " : PersistentVolumeClaim/testRelease-chart-data",
+			findings: []Finding{
+				{
+					Resource: "PersistentVolumeClaim/testRelease-chart-data",
+					Field:    "spec.resources.requests.storage",
+					Message:  "Claim testRelease-chart-data does not request a storage size",
+					Severity: ErrorSeverity,
+				},
+				{
+					Resource: "PersistentVolumeClaim/testRelease-chart-data",
+					Field:    "spec.storageClassName",
+					Message:  "Claim testRelease-chart-data relies on the cluster's default storage class",
+					Severity: ErrorSeverity,
+				},
+			},
+		},
+		{
+			description: "chart with a StatefulSet volume claim template missing its storage size",
+			uri:         "chart-0.1.0-v3.pvc-statefulset.tgz",
+			reason:      PvcStorageSizeMissing + " : StatefulSet/testRelease-chart-db volumeClaimTemplates/logs",
+			findings: []Finding{
+				{
+					Resource: "StatefulSet/testRelease-chart-db",
+					Field:    "spec.volumeClaimTemplates[1].spec.resources.requests.storage",
+					Message:  "Claim logs does not request a storage size",
+					Severity: ErrorSeverity,
+				},
+			},
+		},
+		{
+			description: "chart with StatefulSet volume claim templates in strict mode",
+			uri:         "chart-0.1.0-v3.pvc-statefulset.tgz",
+			strict:      true,
+			reason: PvcStorageClassMissing + " : StatefulSet/testRelease-chart-db volumeClaimTemplates/data" +
+				"\n\t\t" + PvcStorageSizeMissing + " : StatefulSet/testRelease-chart-db volumeClaimTemplates/logs",
+			findings: []Finding{
+				{
+					Resource: "StatefulSet/testRelease-chart-db",
+					Field:    "spec.volumeClaimTemplates[0].spec.storageClassName",
+					Message:  "Claim data relies on the cluster's default storage class",
+					Severity: ErrorSeverity,
+				},
+				{
+					Resource: "StatefulSet/testRelease-chart-db",
+					Field:    "spec.volumeClaimTemplates[1].spec.resources.requests.storage",
+					Message:  "Claim logs does not request a storage size",
+					Severity: ErrorSeverity,
+				},
+			},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(StrictConfigKey, tc.strict)
+			r, err := PvcStorageDeclared(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, tc.findings, r.Findings)
+		})
+	}
+}
+
+func TestStatefulSetTopologyIsValid(t *testing.T) {
+	const uri = "chart-0.1.0-v3.statefulset-topology.tgz"
+
+	withoutSpread := func(statefulSet map[string]interface{}) chartutil.Values {
+		statefulSet["topologySpreadConstraints"] = []interface{}{}
+		return chartutil.Values{"statefulSet": statefulSet}
+	}
+
+	type testCase struct {
+		description string
+		uri         string
+		values      chartutil.Values
+		zonal       []string
+		allowlist   []string
+		reason      string
+		findings    []Finding
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart without StatefulSets", uri: "chart-0.1.0-v3.valid.tgz"},
+		{description: "StatefulSet spreading its pods across zones", uri: uri},
+		{
+			description: "StatefulSet constraining its pods to zones through node affinity",
+			uri:         uri,
+			values: withoutSpread(map[string]interface{}{
+				"affinity": map[string]interface{}{
+					"nodeAffinity": map[string]interface{}{
+						"requiredDuringSchedulingIgnoredDuringExecution": map[string]interface{}{
+							"nodeSelectorTerms": []interface{}{
+								map[string]interface{}{
+									"matchExpressions": []interface{}{
+										map[string]interface{}{"key": "topology.kubernetes.io/zone", "operator": "In", "values": []interface{}{"us-east-1a"}},
+									},
+								},
+							},
+						},
+					},
+				},
+			}),
+		},
+		{
+			description: "StatefulSet claiming a storage class not bound to zones",
+			uri:         uri,
+			values:      withoutSpread(map[string]interface{}{"storageClass": "ocs-storagecluster-cephfs"}),
+		},
+		{
+			description: "StatefulSet claiming the cluster's default storage class",
+			uri:         uri,
+			values:      withoutSpread(map[string]interface{}{"storageClass": ""}),
+		},
+		{
+			description: "allowlisted StatefulSet",
+			uri:         uri,
+			values:      withoutSpread(map[string]interface{}{}),
+			allowlist:   []string{"testRelease-chart-db"},
+		},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, tc.values)
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			r, err := StatefulSetTopologyIsValid(tc.uri, config)
+			require.NoError(t, err)
+			require.True(t, r.Ok, r.Reason)
+			require.Equal(t, StatefulSetTopologyValid, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	finding := func(class string) []Finding {
+		return []Finding{{
+			Resource: "StatefulSet/testRelease-chart-db",
+			Field:    "spec.template.spec.topologySpreadConstraints",
+			Message: "StatefulSet testRelease-chart-db claims zonal storage class " + class + " but neither spreads its " +
+				"pods across zones through topologySpreadConstraints nor constrains them to zones through node affinity " +
+				"on topology.kubernetes.io/zone",
+			Severity: ErrorSeverity,
+		}}
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "StatefulSet claiming a zonal storage class without topology constraints",
+			uri:         uri,
+			values:      withoutSpread(map[string]interface{}{}),
+			reason:      StatefulSetTopologyMissing + " : StatefulSet/testRelease-chart-db (gp3-csi)",
+			findings:    finding("gp3-csi"),
+		},
+		{
+			description: "StatefulSet spreading its pods across nodes only",
+			uri:         uri,
+			values: chartutil.Values{"statefulSet": map[string]interface{}{
+				"topologySpreadConstraints": []interface{}{
+					map[string]interface{}{"maxSkew": 1, "topologyKey": "kubernetes.io/hostname", "whenUnsatisfiable": "ScheduleAnyway"},
+				},
+			}},
+			reason:   StatefulSetTopologyMissing + " : StatefulSet/testRelease-chart-db (gp3-csi)",
+			findings: finding("gp3-csi"),
+		},
+		{
+			description: "StatefulSet claiming a zonal storage class rendered by the chart",
+			uri:         uri,
+			values: chartutil.Values{
+				"statefulSet":  map[string]interface{}{"storageClass": "testRelease-chart-zonal", "topologySpreadConstraints": []interface{}{}},
+				"storageClass": map[string]interface{}{"create": true},
+			},
+			reason:   StatefulSetTopologyMissing + " : StatefulSet/testRelease-chart-db (testRelease-chart-zonal)",
+			findings: finding("testRelease-chart-zonal"),
+		},
+		{
+			description: "StatefulSet claiming a storage class configured as zonal",
+			uri:         uri,
+			values:      withoutSpread(map[string]interface{}{"storageClass": "thin-csi"}),
+			zonal:       []string{"thin-csi"},
+			reason:      StatefulSetTopologyMissing + " : StatefulSet/testRelease-chart-db (thin-csi)",
+			findings:    finding("thin-csi"),
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, tc.values)
+			if tc.zonal != nil {
+				config.Set(ZonalStorageClassesConfigKey, tc.zonal)
+			}
+			r, err := StatefulSetTopologyIsValid(tc.uri, config)
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, tc.findings, r.Findings)
+		})
+	}
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// SuggestFixesConfigKey is the check configuration key enabling checks able to compute a remediation to attach it to
+// their results as a Suggestion.
+const SuggestFixesConfigKey = "suggestFixes"
+
+// Suggestion is a fix proposed by a check for one of its findings.
+type Suggestion struct {
+	// Resource identifies what the patch applies to, as Finding.Resource does, e.g. "Deployment/chart".
+	Resource string `json:"resource" yaml:"resource"`
+	// Description summarizes the fix.
+	Description string `json:"description" yaml:"description"`
+	// Patch is a YAML strategic merge patch of the resource, to be reflected in the template rendering it.
+	Patch string `json:"patch" yaml:"patch"`
+}
+
+// newSuggestion returns a suggestion patching the given resource with patch, marshalled as YAML.
+func newSuggestion(resource, description string, patch map[string]interface{}) (Suggestion, error) {
+	b, err := yaml.Marshal(patch)
+	if err != nil {
+		return Suggestion{}, err
+	}
+	return Suggestion{Resource: resource, Description: description, Patch: string(b)}, nil
+}
+
+// containerPatch returns a strategic merge patch setting the given fields on the container found at field within its
+// workload, e.g. "spec.template.spec.containers[0]"; containers are merged by name.
+func containerPatch(field, name string, fields map[string]interface{}) map[string]interface{} {
+	container := map[string]interface{}{"name": name}
+	for k, v := range fields {
+		container[k] = v
+	}
+
+	segments := strings.Split(field, ".")
+	list := segments[len(segments)-1]
+	if i := strings.Index(list, "["); i >= 0 {
+		list = list[:i]
+	}
+	patch := map[string]interface{}{list: []interface{}{container}}
+	for i := len(segments) - 2; i >= 0; i-- {
+		patch = map[string]interface{}{segments[i]: patch}
+	}
+	return patch
+}
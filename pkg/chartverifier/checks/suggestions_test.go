@@ -0,0 +1,71 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"sigs.k8s.io/yaml"
+)
+
+// applySuggestion applies the patch of the given suggestion to the Deployment it refers to, as rendered by the chart
+// with the given configuration, and returns the patched Deployment.
+func applySuggestion(t *testing.T, uri string, config *viper.Viper, suggestion Suggestion) appsv1.Deployment {
+	patch, err := yaml.YAMLToJSON([]byte(suggestion.Patch))
+	require.NoError(t, err, "patch is not valid YAML")
+
+	resources, err := getRenderedResources(uri, config)
+	require.NoError(t, err)
+	for _, res := range resources {
+		if res.String() != suggestion.Resource {
+			continue
+		}
+		original, err := res.MarshalJSON()
+		require.NoError(t, err)
+		patched, err := strategicpatch.StrategicMergePatch(original, patch, appsv1.Deployment{})
+		require.NoError(t, err)
+
+		var deployment appsv1.Deployment
+		require.NoError(t, json.Unmarshal(patched, &deployment))
+		return deployment
+	}
+	require.FailNow(t, "suggestion refers to a resource not rendered by the chart", suggestion.Resource)
+	return appsv1.Deployment{}
+}
+
+func TestContainerPatch(t *testing.T) {
+	require.Equal(t, map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"initContainers": []interface{}{map[string]interface{}{"name": "init", "image": "busybox:1.35"}},
+				},
+			},
+		},
+	}, containerPatch("spec.template.spec.initContainers[1]", "init", map[string]interface{}{"image": "busybox:1.35"}))
+
+	require.Equal(t, map[string]interface{}{
+		"spec": map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"name": "app"}},
+		},
+	}, containerPatch("spec.containers[0]", "app", nil))
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// TLSConfigKey is the check configuration key containing the *tls.Config checks performing HTTPS requests, such as
+// has-valid-icon and images-are-certified, use; the system's certificate authorities are trusted when absent.
+const TLSConfigKey = "tlsConfig"
+
+// HTTPTransportConfigKey is the check configuration key containing the http.RoundTripper checks performing HTTP
+// requests use instead of Go's default transport; the TLS settings informed under TLSConfigKey don't apply to it.
+const HTTPTransportConfigKey = "httpTransport"
+
+// NewTLSConfig returns TLS settings trusting the PEM encoded certificate authorities found in caFile, in addition to
+// the system's, and skipping the verification of server certificates when insecureSkipVerify is set; nil, standing for
+// Go's defaults, is returned when neither is informed.
+func NewTLSConfig(caFile string, insecureSkipVerify bool) (*tls.Config, error) {
+	if caFile == "" && !insecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed reading CA file %q", caFile)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("CA file %q does not contain PEM encoded certificates", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// getTLSConfig returns the TLS settings informed in config, or nil when Go's defaults apply.
+func getTLSConfig(config *viper.Viper) *tls.Config {
+	tlsConfig, _ := config.Get(TLSConfigKey).(*tls.Config)
+	return tlsConfig
+}
+
+// getHTTPTransport returns the transport informed in config, or nil when Go's default transport applies.
+func getHTTPTransport(config *viper.Viper) http.RoundTripper {
+	transport, _ := config.Get(HTTPTransportConfigKey).(http.RoundTripper)
+	return transport
+}
+
+// newConfiguredHTTPClient returns a client using the transport and TLS settings informed in config, and giving up on
+// requests after timeout, if any, or once the verification's context is cancelled.
+func newConfiguredHTTPClient(config *viper.Viper, timeout time.Duration) *http.Client {
+	client := newHTTPClient(getHTTPTransport(config), getTLSConfig(config), timeout)
+	client.Transport = withContext(getContext(config), client.Transport)
+	return client
+}
+
+// newHTTPClient returns a client performing requests with the given transport, as is, or when nil with Go's default
+// transport using the given TLS settings, or Go's defaults when nil, and giving up on requests after timeout, if any.
+func newHTTPClient(transport http.RoundTripper, tlsConfig *tls.Config, timeout time.Duration) *http.Client {
+	if transport != nil {
+		return &http.Client{Transport: transport, Timeout: timeout}
+	}
+	if tlsConfig == nil {
+		return &http.Client{Timeout: timeout}
+	}
+	defaultTransport := http.DefaultTransport.(*http.Transport).Clone()
+	defaultTransport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: defaultTransport, Timeout: timeout}
+}
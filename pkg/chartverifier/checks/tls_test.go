@@ -0,0 +1,129 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeServerCA writes the self-signed certificate of the given TLS server to a PEM file, returning its path.
+func writeServerCA(t *testing.T, srv *httptest.Server) string {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	require.NoError(t, ioutil.WriteFile(caFile, ca, 0644))
+	return caFile
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	t.Run("Should return the defaults when neither a CA file nor insecure verification are informed", func(t *testing.T) {
+		tlsConfig, err := NewTLSConfig("", false)
+		require.NoError(t, err)
+		require.Nil(t, tlsConfig)
+	})
+
+	t.Run("Should skip verification when requested", func(t *testing.T) {
+		tlsConfig, err := NewTLSConfig("", true)
+		require.NoError(t, err)
+		require.True(t, tlsConfig.InsecureSkipVerify)
+		require.Nil(t, tlsConfig.RootCAs)
+	})
+
+	t.Run("Should fail when the CA file does not exist", func(t *testing.T) {
+		_, err := NewTLSConfig(filepath.Join(t.TempDir(), "missing.pem"), false)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed reading CA file")
+	})
+
+	t.Run("Should fail when the CA file does not contain certificates", func(t *testing.T) {
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		require.NoError(t, ioutil.WriteFile(caFile, []byte("not a certificate"), 0644))
+		_, err := NewTLSConfig(caFile, false)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "does not contain PEM encoded certificates")
+	})
+}
+
+func TestLoadChartFromURIWithTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "chart-0.1.0-v3.valid.tgz")
+	}))
+	t.Cleanup(srv.Close)
+	caFile := writeServerCA(t, srv)
+
+	t.Run("Should retrieve the chart when the server's CA is informed", func(t *testing.T) {
+		useRepositoryConfig(t, "repositories: []\n")
+		uri := srv.URL + "/charts/chart-0.1.0-v3.trusted-ca.tgz"
+		require.NoError(t, ValidateChartURI(context.Background(), uri, Credentials{CAFile: caFile}))
+		c, _, err := LoadChartFromURIWithCredentials(context.Background(), uri, Credentials{CAFile: caFile})
+		require.NoError(t, err)
+		require.NotNil(t, c)
+	})
+
+	t.Run("Should fail when the server's CA is not informed", func(t *testing.T) {
+		useRepositoryConfig(t, "repositories: []\n")
+		uri := srv.URL + "/charts/chart-0.1.0-v3.untrusted-ca.tgz"
+		err := ValidateChartURI(context.Background(), uri, Credentials{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "certificate")
+		c, _, err := LoadChartFromURIWithCredentials(context.Background(), uri, Credentials{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "certificate")
+		require.Nil(t, c)
+	})
+
+	t.Run("Should retrieve the chart when server certificates are not verified", func(t *testing.T) {
+		useRepositoryConfig(t, "repositories: []\n")
+		uri := srv.URL + "/charts/chart-0.1.0-v3.insecure.tgz"
+		c, _, err := LoadChartFromURIWithCredentials(context.Background(), uri, Credentials{InsecureSkipTLSVerify: true})
+		require.NoError(t, err)
+		require.NotNil(t, c)
+	})
+
+	t.Run("Should use the CA configured for the chart's Helm repository", func(t *testing.T) {
+		useRepositoryConfig(t, "repositories:\n- name: internal\n  url: "+srv.URL+"/charts\n  caFile: "+caFile+"\n")
+		uri := srv.URL + "/charts/chart-0.1.0-v3.repository-ca.tgz"
+		c, _, err := LoadChartFromURI(uri)
+		require.NoError(t, err)
+		require.NotNil(t, c)
+	})
+}
+
+func TestCheckIconURLWithTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+	}))
+	t.Cleanup(srv.Close)
+
+	tlsConfig, err := NewTLSConfig(writeServerCA(t, srv), false)
+	require.NoError(t, err)
+
+	r := checkIconURL(srv.URL+"/icon.png", nil, newHTTPClient(nil, tlsConfig, iconRequestTimeout))
+	require.True(t, r.Ok, r.Reason)
+
+	r = checkIconURL(srv.URL+"/icon.png", nil, newHTTPClient(nil, nil, iconRequestTimeout))
+	require.False(t, r.Ok)
+	require.Contains(t, r.Reason, IconNotReachable)
+	require.Contains(t, r.Reason, "certificate")
+}
@@ -0,0 +1,432 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+const (
+	ValuesTypesAreConsistent   = "Default values have consistent types"
+	ValuesTypeInconsistent     = "Default value type is inconsistent"
+	ValuesTypeMismatchesSchema = "Default value type does not match the values schema"
+	ValuesSchemaInvalid        = "Values schema is not valid JSON"
+	ValuesCasingConsistent     = "Default values keys use a consistent casing"
+	ValuesKeyCasingDeviates    = "Default values key deviates from the casing convention"
+)
+
+const (
+	// CasingConfigKey is the check configuration key informing the casing convention values keys are expected to
+	// follow, instead of the one most keys follow: camelCase, PascalCase, snake_case or kebab-case.
+	CasingConfigKey = "casing"
+
+	CamelCase  = "camelCase"
+	PascalCase = "PascalCase"
+	SnakeCase  = "snake_case"
+	KebabCase  = "kebab-case"
+)
+
+// casingConventions lists the casing conventions in order of preference, when several are followed by as many keys.
+var casingConventions = []string{CamelCase, SnakeCase, KebabCase, PascalCase}
+
+// valuesFile is the resource default values type mismatches are reported against.
+const valuesFile = "values.yaml"
+
+// valueTypeMismatch is a default value whose type is inconsistent with another default or with the values schema.
+type valueTypeMismatch struct {
+	kind    string
+	path    string
+	message string
+}
+
+// valueType returns the JSON schema type of a value parsed from the chart's values, or an empty string for null
+// values, which Helm drops when merging values; whole numbers are integers, as Helm parses all numbers as floats.
+func valueType(v interface{}) string {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int32, int64:
+		return "integer"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	default:
+		return ""
+	}
+}
+
+// valueKind returns the type of v, integers and other numbers being of the same kind.
+func valueKind(v interface{}) string {
+	if t := valueType(v); t != "integer" {
+		return t
+	}
+	return "number"
+}
+
+// joinValuesPath returns the path of key within the value found at path.
+func joinValuesPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// sortedKeys returns the keys of m in alphabetical order, so mismatches are reported in a predictable order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// findInconsistentElements returns the keys declared by several objects of the same list with values of different
+// types, e.g. a port given as a number in one element and as a string in another.
+func findInconsistentElements(path string, v interface{}) []valueTypeMismatch {
+	var mismatches []valueTypeMismatch
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(v) {
+			mismatches = append(mismatches, findInconsistentElements(joinValuesPath(path, k), v[k])...)
+		}
+
+	case []interface{}:
+		type firstElement struct {
+			index int
+			kind  string
+			typ   string
+		}
+		seen := map[string]firstElement{}
+		for i, e := range v {
+			elementPath := fmt.Sprintf("%s[%d]", path, i)
+			if m, ok := e.(map[string]interface{}); ok {
+				for _, k := range sortedKeys(m) {
+					kind := valueKind(m[k])
+					if kind == "" {
+						continue
+					}
+					first, ok := seen[k]
+					if !ok {
+						seen[k] = firstElement{index: i, kind: kind, typ: valueType(m[k])}
+						continue
+					}
+					if first.kind != kind {
+						mismatches = append(mismatches, valueTypeMismatch{
+							kind: ValuesTypeInconsistent,
+							path: joinValuesPath(elementPath, k),
+							message: fmt.Sprintf("%s is %s while %s[%d].%s is %s",
+								joinValuesPath(elementPath, k), valueType(m[k]), path, first.index, k, first.typ),
+						})
+					}
+				}
+			}
+			mismatches = append(mismatches, findInconsistentElements(elementPath, e)...)
+		}
+	}
+	return mismatches
+}
+
+// findInconsistentOverrides returns the values the chart sets for a dependency whose types differ from the defaults
+// of the dependency itself, e.g. a map of settings given as a single string.
+func findInconsistentOverrides(path, dependency string, overrides, defaults map[string]interface{}) []valueTypeMismatch {
+	var mismatches []valueTypeMismatch
+	for _, k := range sortedKeys(overrides) {
+		override, def := overrides[k], defaults[k]
+		overrideKind, defaultKind := valueKind(override), valueKind(def)
+		if overrideKind == "" || defaultKind == "" {
+			continue
+		}
+		if overrideKind != defaultKind {
+			mismatches = append(mismatches, valueTypeMismatch{
+				kind: ValuesTypeInconsistent,
+				path: joinValuesPath(path, k),
+				message: fmt.Sprintf("%s is %s while dependency %s defaults it to %s",
+					joinValuesPath(path, k), valueType(override), dependency, valueType(def)),
+			})
+			continue
+		}
+		if overrideMap, ok := override.(map[string]interface{}); ok {
+			mismatches = append(mismatches, findInconsistentOverrides(joinValuesPath(path, k), dependency, overrideMap, def.(map[string]interface{}))...)
+		}
+	}
+	return mismatches
+}
+
+// getDependencyValueKeys returns the dependencies of c, keyed by the key of their values within the values of c.
+func getDependencyValueKeys(c *chart.Chart) map[string]*chart.Chart {
+	aliases := map[string]string{}
+	for _, d := range c.Metadata.Dependencies {
+		if d.Alias != "" {
+			aliases[d.Name] = d.Alias
+		}
+	}
+
+	dependencies := map[string]*chart.Chart{}
+	for _, d := range c.Dependencies() {
+		key := d.Name()
+		if alias, ok := aliases[key]; ok {
+			key = alias
+		}
+		dependencies[key] = d
+	}
+	return dependencies
+}
+
+// getSchemaTypes returns the types the given schema declares, if any.
+func getSchemaTypes(schema map[string]interface{}) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var types []string
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	default:
+		return nil
+	}
+}
+
+// findSchemaMismatches returns the values whose types aren't among the ones declared by their schema; only the
+// properties, additionalProperties and items keywords are followed.
+func findSchemaMismatches(path string, v interface{}, schema map[string]interface{}) []valueTypeMismatch {
+	t := valueType(v)
+	if t == "" {
+		return nil
+	}
+
+	if types := getSchemaTypes(schema); len(types) > 0 {
+		allowed := false
+		for _, declared := range types {
+			if declared == t || (declared == "number" && t == "integer") {
+				allowed = true
+			}
+		}
+		if !allowed {
+			name := path
+			if name == "" {
+				name = "values"
+			}
+			return []valueTypeMismatch{{
+				kind:    ValuesTypeMismatchesSchema,
+				path:    path,
+				message: fmt.Sprintf("%s is %s while the values schema declares %s", name, t, strings.Join(types, " or ")),
+			}}
+		}
+	}
+
+	var mismatches []valueTypeMismatch
+	switch v := v.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		additionalProperties, _ := schema["additionalProperties"].(map[string]interface{})
+		for _, k := range sortedKeys(v) {
+			if s, ok := properties[k].(map[string]interface{}); ok {
+				mismatches = append(mismatches, findSchemaMismatches(joinValuesPath(path, k), v[k], s)...)
+			} else if additionalProperties != nil {
+				mismatches = append(mismatches, findSchemaMismatches(joinValuesPath(path, k), v[k], additionalProperties)...)
+			}
+		}
+
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, e := range v {
+				mismatches = append(mismatches, findSchemaMismatches(fmt.Sprintf("%s[%d]", path, i), e, items)...)
+			}
+		}
+	}
+	return mismatches
+}
+
+func ValuesTypesConsistent(uri string, config *viper.Viper) (Result, error) {
+	c, _, err := LoadChartFromURI(uri)
+	if err != nil {
+		return Result{}, err
+	}
+
+	mismatches := findInconsistentElements("", c.Values)
+
+	dependencies := getDependencyValueKeys(c)
+	for _, key := range sortedKeys(c.Values) {
+		overrides, ok := c.Values[key].(map[string]interface{})
+		if d, found := dependencies[key]; ok && found {
+			mismatches = append(mismatches, findInconsistentOverrides(key, d.Name(), overrides, d.Values)...)
+		}
+	}
+
+	if len(c.Schema) > 0 {
+		schema := map[string]interface{}{}
+		if err := json.Unmarshal(c.Schema, &schema); err != nil {
+			return NewResult(false, fmt.Sprintf("%s : %v", ValuesSchemaInvalid, err)), nil
+		}
+		mismatches = append(mismatches, findSchemaMismatches("", c.Values, schema)...)
+	}
+
+	allowlist := getStringSetConfig(config, AllowlistConfigKey)
+
+	r := NewResult(true, ValuesTypesAreConsistent)
+	for _, m := range mismatches {
+		if allowlist[m.path] {
+			continue
+		}
+		addFailure(&r, fmt.Sprintf("%s : %s", m.kind, m.message))
+		r.AddFinding(Finding{
+			Resource: valuesFile,
+			Field:    m.path,
+			Message:  m.message,
+			Severity: ErrorSeverity,
+		})
+	}
+
+	return r, nil
+}
+
+// keyCasings returns the casing conventions the given values key follows; single lowercase words follow every
+// convention but PascalCase, while keys mixing conventions follow none.
+func keyCasings(key string) []string {
+	if key == "" {
+		return nil
+	}
+	hasUpper := strings.ToLower(key) != key
+	hasUnderscore := strings.Contains(key, "_")
+	hasDash := strings.Contains(key, "-")
+	firstUpper := strings.ToUpper(key[:1]) == key[:1] && strings.ToLower(key[:1]) != key[:1]
+
+	switch {
+	case !hasUpper && !hasUnderscore && !hasDash:
+		return []string{CamelCase, SnakeCase, KebabCase}
+	case hasUnderscore && !hasDash && !hasUpper:
+		return []string{SnakeCase}
+	case hasDash && !hasUnderscore && !hasUpper:
+		return []string{KebabCase}
+	case !hasUnderscore && !hasDash && firstUpper:
+		return []string{PascalCase}
+	case !hasUnderscore && !hasDash:
+		return []string{CamelCase}
+	default:
+		return nil
+	}
+}
+
+// valuesKey is a key of the chart's default values, along with its path.
+type valuesKey struct {
+	key  string
+	path string
+}
+
+// collectValuesKeys returns the keys found at path within v, including the keys of objects nested in lists; keys
+// containing dots or slashes, such as annotation and label names, are data rather than values keys and are ignored,
+// along with their values.
+func collectValuesKeys(path string, v interface{}, skipped map[string]bool) []valuesKey {
+	var keys []valuesKey
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(v) {
+			p := joinValuesPath(path, k)
+			if skipped[p] || strings.ContainsAny(k, "./") {
+				continue
+			}
+			keys = append(keys, valuesKey{key: k, path: p})
+			keys = append(keys, collectValuesKeys(p, v[k], skipped)...)
+		}
+	case []interface{}:
+		for i, e := range v {
+			keys = append(keys, collectValuesKeys(fmt.Sprintf("%s[%d]", path, i), e, skipped)...)
+		}
+	}
+	return keys
+}
+
+// dominantCasing returns the casing convention followed by most of the given keys, or an empty string if no key
+// follows a convention.
+func dominantCasing(keys []valuesKey) string {
+	counts := map[string]int{}
+	for _, k := range keys {
+		// keys following several conventions don't tell which one is preferred
+		if casings := keyCasings(k.key); len(casings) == 1 {
+			counts[casings[0]]++
+		}
+	}
+	dominant := ""
+	for _, casing := range casingConventions {
+		if counts[casing] > counts[dominant] {
+			dominant = casing
+		}
+	}
+	return dominant
+}
+
+func ValuesConsistentCasing(uri string, config *viper.Viper) (Result, error) {
+	c, _, err := LoadChartFromURI(uri)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// the values overriding the ones of dependencies follow the casing of the dependencies, and allowlisted paths
+	// follow whatever casing they need to
+	skipped := getStringSetConfig(config, AllowlistConfigKey)
+	for key := range getDependencyValueKeys(c) {
+		skipped[key] = true
+	}
+	keys := collectValuesKeys("", c.Values, skipped)
+
+	casing := config.GetString(CasingConfigKey)
+	if casing == "" {
+		casing = dominantCasing(keys)
+	} else if !containsString(casingConventions, casing) {
+		return Result{}, errors.Errorf("unknown casing convention %q, expected one of %s", casing, strings.Join(casingConventions, ", "))
+	}
+	if casing == "" {
+		return NewResult(true, ValuesCasingConsistent), nil
+	}
+
+	r := NewResult(true, ValuesCasingConsistent)
+	for _, k := range keys {
+		if containsString(keyCasings(k.key), casing) {
+			continue
+		}
+		addFailure(&r, fmt.Sprintf("%s : %s", ValuesKeyCasingDeviates, k.path))
+		r.AddFinding(Finding{
+			Resource: valuesFile,
+			Field:    k.path,
+			Message:  fmt.Sprintf("Key %s does not follow the %s convention", k.key, casing),
+			Severity: ErrorSeverity,
+		})
+	}
+
+	return r, nil
+}
@@ -0,0 +1,214 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValuesTypesConsistent(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		allowlist   []string
+		reason      string
+		findings    []Finding
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart whose defaults match the values schema", uri: "chart-0.1.0-v3.valid.tgz"},
+		{description: "chart without values schema", uri: "chart-0.1.0-v3.no-values-schema.tgz"},
+		{description: "chart with consistent list elements and dependency overrides", uri: "chart-0.1.0-v3.values-types-consistent.tgz"},
+		{
+			description: "chart with allowlisted mismatches",
+			uri:         "chart-0.1.0-v3.values-types-mismatch.tgz",
+			allowlist:   []string{"extraPorts[1].containerPort", "db.auth", "replicaCount", "service.port"},
+		},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			r, err := ValuesTypesConsistent(tc.uri, config)
+			require.NoError(t, err)
+			require.True(t, r.Ok, r.Reason)
+			require.Equal(t, ValuesTypesAreConsistent, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart with mismatched defaults",
+			uri:         "chart-0.1.0-v3.values-types-mismatch.tgz",
+			reason: ValuesTypeInconsistent + " : extraPorts[1].containerPort is string while extraPorts[0].containerPort is integer" +
+				"\n\t\t" + ValuesTypeInconsistent + " : db.auth is string while dependency db defaults it to object" +
+				"\n\t\t" + ValuesTypeMismatchesSchema + " : replicaCount is number while the values schema declares integer" +
+				"\n\t\t" + ValuesTypeMismatchesSchema + " : service.port is string while the values schema declares integer",
+			findings: []Finding{
+				{
+					Resource: "values.yaml",
+					Field:    "extraPorts[1].containerPort",
+					Message:  "extraPorts[1].containerPort is string while extraPorts[0].containerPort is integer",
+					Severity: ErrorSeverity,
+				},
+				{
+					Resource: "values.yaml",
+					Field:    "db.auth",
+					Message:  "db.auth is string while dependency db defaults it to object",
+					Severity: ErrorSeverity,
+				},
+				{
+					Resource: "values.yaml",
+					Field:    "replicaCount",
+					Message:  "replicaCount is number while the values schema declares integer",
+					Severity: ErrorSeverity,
+				},
+				{
+					Resource: "values.yaml",
+					Field:    "service.port",
+					Message:  "service.port is string while the values schema declares integer",
+					Severity: ErrorSeverity,
+				},
+			},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			r, err := ValuesTypesConsistent(tc.uri, config)
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, tc.findings, r.Findings)
+		})
+	}
+}
+
+func TestFindSchemaMismatches(t *testing.T) {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"ratio":  map[string]interface{}{"type": "number"},
+			"labels": map[string]interface{}{"type": "object", "additionalProperties": map[string]interface{}{"type": "string"}},
+			"hosts":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			"name":   map[string]interface{}{"type": []interface{}{"string", "null"}},
+		},
+	}
+
+	values := map[string]interface{}{
+		"ratio":  float64(1),
+		"labels": map[string]interface{}{"app": "chart", "tier": true},
+		"hosts":  []interface{}{"chart.local", float64(8080)},
+		"name":   nil,
+	}
+
+	require.Equal(t, []valueTypeMismatch{
+		{kind: ValuesTypeMismatchesSchema, path: "hosts[1]", message: "hosts[1] is integer while the values schema declares string"},
+		{kind: ValuesTypeMismatchesSchema, path: "labels.tier", message: "labels.tier is boolean while the values schema declares string"},
+	}, findSchemaMismatches("", values, schema))
+}
+
+func TestValuesConsistentCasing(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		casing      string
+		allowlist   []string
+		deviating   []string
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart with camelCased values keys", uri: "chart-0.1.0-v3.valid.tgz"},
+		{description: "chart with camelCased values keys and configured convention", uri: "chart-0.1.0-v3.valid.tgz", casing: CamelCase},
+		{
+			description: "chart with allowlisted deviating keys",
+			uri:         "chart-0.1.0-v3.values-casing.tgz",
+			allowlist:   []string{"worker_count", "tls.key_file"},
+		},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(CasingConfigKey, tc.casing)
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			r, err := ValuesConsistentCasing(tc.uri, config)
+			require.NoError(t, err)
+			require.True(t, r.Ok, r.Reason)
+			require.Equal(t, ValuesCasingConsistent, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart with mixed casing values keys",
+			uri:         "chart-0.1.0-v3.values-casing.tgz",
+			deviating:   []string{"tls.key_file", "worker_count"},
+		},
+		{
+			description: "chart with values keys deviating from the configured convention",
+			uri:         "chart-0.1.0-v3.values-casing.tgz",
+			casing:      SnakeCase,
+			allowlist:   []string{"autoscaling", "image", "imagePullSecrets", "nameOverride", "fullnameOverride", "nodeSelector", "podSecurityContext", "replicaCount", "securityContext", "serviceAccount"},
+			deviating:   []string{"logLevel", "maxConnections", "podAnnotations", "sidecars[0].imageName", "tls.certFile"},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(CasingConfigKey, tc.casing)
+			config.Set(AllowlistConfigKey, tc.allowlist)
+			r, err := ValuesConsistentCasing(tc.uri, config)
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			var fields []string
+			for _, f := range r.Findings {
+				require.Equal(t, "values.yaml", f.Resource)
+				require.Equal(t, ErrorSeverity, f.Severity)
+				fields = append(fields, f.Field)
+				require.Contains(t, r.Reason, ValuesKeyCasingDeviates+" : "+f.Field)
+			}
+			require.Equal(t, tc.deviating, fields)
+		})
+	}
+
+	t.Run("Should fail with an unknown configured convention", func(t *testing.T) {
+		config := viper.New()
+		config.Set(CasingConfigKey, "Title Case")
+		_, err := ValuesConsistentCasing("chart-0.1.0-v3.valid.tgz", config)
+		require.Error(t, err)
+	})
+}
+
+func TestKeyCasings(t *testing.T) {
+	require.Equal(t, []string{CamelCase, SnakeCase, KebabCase}, keyCasings("enabled"))
+	require.Equal(t, []string{CamelCase}, keyCasings("replicaCount"))
+	require.Equal(t, []string{PascalCase}, keyCasings("ReplicaCount"))
+	require.Equal(t, []string{SnakeCase}, keyCasings("replica_count"))
+	require.Equal(t, []string{KebabCase}, keyCasings("replica-count"))
+	require.Empty(t, keyCasings("replica_Count"))
+	require.Empty(t, keyCasings("replica-count_total"))
+}
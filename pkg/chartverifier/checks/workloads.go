@@ -0,0 +1,1029 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// MinReplicasConfigKey is the check configuration key informing the replica count from which workloads are
+	// expected to be highly available.
+	MinReplicasConfigKey = "minReplicas"
+	// MaxReplicasConfigKey is the check configuration key informing the highest replica count replica-count-sane
+	// accepts.
+	MaxReplicasConfigKey = "maxReplicas"
+	// RequiredFieldsConfigKey is the check configuration key containing the security context fields pods-run-as-nonroot
+	// requires: runAsNonRoot, fsGroup or both; defaults to runAsNonRoot.
+	RequiredFieldsConfigKey = "requiredFields"
+	// RequireDefinedConfigKey is the check configuration key requiring the ServiceAccounts used by workloads to be
+	// created by the chart.
+	RequireDefinedConfigKey = "requireDefined"
+	// MinInitialDelayConfigKey is the check configuration key informing the shortest initialDelaySeconds
+	// probe-parameters-sane accepts for liveness probes of containers without a startup probe; defaults to 1.
+	MinInitialDelayConfigKey = "minInitialDelaySeconds"
+	// MaxInitialDelayConfigKey is the check configuration key informing the longest initialDelaySeconds
+	// probe-parameters-sane accepts; defaults to 300.
+	MaxInitialDelayConfigKey = "maxInitialDelaySeconds"
+	// MinFailureThresholdConfigKey is the check configuration key informing the lowest failureThreshold
+	// probe-parameters-sane accepts; defaults to 1.
+	MinFailureThresholdConfigKey = "minFailureThreshold"
+	// MaxRevisionHistoryConfigKey is the check configuration key informing the highest revisionHistoryLimit
+	// revision-history-bounded accepts; defaults to 10.
+	MaxRevisionHistoryConfigKey = "maxRevisionHistoryLimit"
+
+	RunAsNonRootField = "runAsNonRoot"
+	FsGroupField      = "fsGroup"
+)
+
+const (
+	PodDisruptionBudgetsConfigured = "Replicated workloads are covered by a PodDisruptionBudget"
+	PodDisruptionBudgetMissing     = "Replicated workload is not covered by a PodDisruptionBudget"
+	PodDisruptionBudgetTooStrict   = "PodDisruptionBudget blocks all voluntary evictions of replicated workload"
+	ReplicasSpread                 = "Replicated workloads are spread across nodes or zones"
+	ReplicasNotSpread              = "Replicated workload configures neither pod anti-affinity nor topology spread constraints"
+	PodsRunAsNonRoot               = "Pods run as non-root"
+	ContainerRunsAsRoot            = "Container runs as root"
+	ContainerRunAsNonRootMissing   = "Container does not set runAsNonRoot"
+	PodFsGroupMissing              = "Pod mounting volumes does not set fsGroup"
+	ServiceAccountsExplicit        = "Workloads use explicit ServiceAccounts"
+	ServiceAccountNotSet           = "Workload uses the default ServiceAccount"
+	ServiceAccountUndefined        = "Workload uses a ServiceAccount not created by the chart"
+	UpdateStrategiesDeclared       = "Workloads declare an update strategy"
+	UpdateStrategyMissing          = "Workload does not declare an update strategy"
+	UpdateStrategyUnsafe           = "Workload declares a rolling update that cannot progress safely"
+	ReplicaCountsSane              = "Deployments declare sensible replica counts"
+	ReplicaCountZero               = "Deployment declares no replicas"
+	ReplicaCountNotHighlyAvailable = "Deployment exposed by a Service declares too few replicas to be highly available"
+	ReplicaCountExcessive          = "Deployment declares more replicas than the ceiling"
+	JobsBounded                    = "Jobs and CronJobs bound their retries and runs"
+	JobBackoffLimitMissing         = "Job does not declare a backoff limit"
+	JobRestartPolicyInvalid        = "Job declares a restart policy other than Never or OnFailure"
+	CronJobConcurrencyMissing      = "CronJob does not declare a concurrency policy"
+	CronJobDeadlineMissing         = "CronJob does not declare a starting deadline"
+	ProbesSane                     = "Container probes declare sensible parameters"
+	ProbeTimeoutExceedsPeriod      = "Probe times out after its period"
+	ProbeFailureThresholdTooLow    = "Probe declares a failure threshold below the minimum"
+	ProbeInitialDelayTooShort      = "Liveness probe starts before the container is expected to be up"
+	ProbeInitialDelayTooLong       = "Probe declares an initial delay above the maximum"
+	RevisionHistoriesBounded       = "Deployments bound their revision history"
+	RevisionHistoryLimitMissing    = "Deployment does not declare a revision history limit"
+	RevisionHistoryLimitExcessive  = "Deployment declares a revision history limit above the maximum"
+	StatefulSetServicesValid       = "StatefulSets reference headless Services defined by the chart"
+	StatefulSetServiceMissing      = "StatefulSet references a Service not defined by the chart"
+	StatefulSetServiceNotHeadless  = "StatefulSet references a Service that is not headless"
+)
+
+// defaultMaxReplicas is the highest replica count replica-count-sane accepts unless configured otherwise.
+const defaultMaxReplicas = 50
+
+// defaultMaxRevisionHistory is the highest revisionHistoryLimit revision-history-bounded accepts unless configured
+// otherwise, the Kubernetes default.
+const defaultMaxRevisionHistory = 10
+
+// defaultMaxInitialDelay is the longest initialDelaySeconds probe-parameters-sane accepts unless configured otherwise.
+const defaultMaxInitialDelay = 300
+
+// replicatedWorkload is a Deployment or StatefulSet declaring multiple replicas.
+type replicatedWorkload struct {
+	renderedResource
+	replicas int64
+}
+
+// getReplicatedWorkloads returns the Deployments and StatefulSets found in the given resources declaring at least
+// minReplicas replicas.
+func getReplicatedWorkloads(resources []renderedResource, minReplicas int64) ([]replicatedWorkload, error) {
+	var workloads []replicatedWorkload
+	for _, res := range resources {
+		if res.GetKind() != "Deployment" && res.GetKind() != "StatefulSet" {
+			continue
+		}
+
+		replicas, found, err := unstructured.NestedInt64(res.Object, "spec", "replicas")
+		if err != nil {
+			return nil, err
+		}
+		if found && replicas >= minReplicas {
+			workloads = append(workloads, replicatedWorkload{renderedResource: res, replicas: replicas})
+		}
+	}
+	return workloads, nil
+}
+
+// podDisruptionBudget is the subset of a rendered PodDisruptionBudget relevant to pdb-configured.
+type podDisruptionBudget struct {
+	name           string
+	selector       labels.Selector
+	minAvailable   *intstr.IntOrString
+	maxUnavailable *intstr.IntOrString
+}
+
+// getPodDisruptionBudgets returns the PodDisruptionBudgets found in the given resources.
+func getPodDisruptionBudgets(resources []renderedResource) ([]podDisruptionBudget, error) {
+	var pdbs []podDisruptionBudget
+	for _, res := range resources {
+		if res.GetKind() != "PodDisruptionBudget" {
+			continue
+		}
+
+		pdb := podDisruptionBudget{name: res.String(), selector: labels.Nothing()}
+
+		if m, found, err := unstructured.NestedMap(res.Object, "spec", "selector"); err != nil {
+			return nil, err
+		} else if found {
+			labelSelector := &metav1.LabelSelector{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(m, labelSelector); err != nil {
+				return nil, err
+			}
+			if pdb.selector, err = metav1.LabelSelectorAsSelector(labelSelector); err != nil {
+				return nil, err
+			}
+		}
+
+		var err error
+		if pdb.minAvailable, err = getIntOrString(res, "spec", "minAvailable"); err != nil {
+			return nil, err
+		}
+		if pdb.maxUnavailable, err = getIntOrString(res, "spec", "maxUnavailable"); err != nil {
+			return nil, err
+		}
+
+		pdbs = append(pdbs, pdb)
+	}
+	return pdbs, nil
+}
+
+// getIntOrString returns the integer or percentage found at the given fields of res, or nil if absent.
+func getIntOrString(res renderedResource, fields ...string) (*intstr.IntOrString, error) {
+	v, found, err := unstructured.NestedFieldNoCopy(res.Object, fields...)
+	if err != nil || !found {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case int64:
+		i := intstr.FromInt(int(v))
+		return &i, nil
+	case float64:
+		i := intstr.FromInt(int(v))
+		return &i, nil
+	case string:
+		s := intstr.FromString(v)
+		return &s, nil
+	default:
+		return nil, fmt.Errorf("%s: unexpected value %v", res, v)
+	}
+}
+
+// blocksEvictions informs whether pdb allows no pod out of the given number of replicas to be evicted.
+func (pdb podDisruptionBudget) blocksEvictions(replicas int) (bool, error) {
+	if pdb.maxUnavailable != nil {
+		maxUnavailable, err := intstr.GetScaledValueFromIntOrPercent(pdb.maxUnavailable, replicas, true)
+		return maxUnavailable <= 0, err
+	}
+	if pdb.minAvailable != nil {
+		minAvailable, err := intstr.GetScaledValueFromIntOrPercent(pdb.minAvailable, replicas, true)
+		return minAvailable >= replicas, err
+	}
+	// Neither minAvailable nor maxUnavailable given; the API server defaults minAvailable to 1.
+	return replicas <= 1, nil
+}
+
+func PdbConfigured(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	pdbs, err := getPodDisruptionBudgets(resources)
+	if err != nil {
+		return Result{}, err
+	}
+
+	workloads, err := getReplicatedWorkloads(resources, 2)
+	if err != nil {
+		return Result{}, err
+	}
+
+	r := NewResult(true, PodDisruptionBudgetsConfigured)
+	for _, w := range workloads {
+		res, replicas := w.renderedResource, w.replicas
+
+		podLabels, _, err := unstructured.NestedStringMap(res.Object, "spec", "template", "metadata", "labels")
+		if err != nil {
+			return Result{}, err
+		}
+
+		var matching []podDisruptionBudget
+		for _, pdb := range pdbs {
+			if pdb.selector.Matches(labels.Set(podLabels)) {
+				matching = append(matching, pdb)
+			}
+		}
+
+		if len(matching) == 0 {
+			addFailure(&r, fmt.Sprintf("%s : %s", PodDisruptionBudgetMissing, res))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    "spec.replicas",
+				Message:  fmt.Sprintf("%d replicas are declared but no PodDisruptionBudget selects the workload's pods", replicas),
+				Severity: ErrorSeverity,
+			})
+			continue
+		}
+
+		for _, pdb := range matching {
+			blocks, err := pdb.blocksEvictions(int(replicas))
+			if err != nil {
+				return Result{}, err
+			}
+			if !blocks {
+				continue
+			}
+			addFailure(&r, fmt.Sprintf("%s : %s", PodDisruptionBudgetTooStrict, res))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    "spec.replicas",
+				Message:  fmt.Sprintf("%s does not allow any of the %d replicas to be evicted", pdb.name, replicas),
+				Severity: ErrorSeverity,
+			})
+		}
+	}
+
+	return r, nil
+}
+
+func HaAntiAffinity(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	minReplicas := int64(2)
+	if config.IsSet(MinReplicasConfigKey) {
+		minReplicas = config.GetInt64(MinReplicasConfigKey)
+	}
+
+	workloads, err := getReplicatedWorkloads(resources, minReplicas)
+	if err != nil {
+		return Result{}, err
+	}
+
+	r := NewResult(true, ReplicasSpread)
+	for _, w := range workloads {
+		podSpec, _ := getPodSpec(w.renderedResource)
+
+		if len(podSpec.TopologySpreadConstraints) > 0 {
+			continue
+		}
+		if a := podSpec.Affinity; a != nil && a.PodAntiAffinity != nil &&
+			(len(a.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution) > 0 ||
+				len(a.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution) > 0) {
+			continue
+		}
+
+		addFailure(&r, fmt.Sprintf("%s : %s", ReplicasNotSpread, w))
+		r.AddFinding(Finding{
+			Resource: w.String(),
+			Field:    "spec.template.spec",
+			Message:  fmt.Sprintf("%d replicas may all be scheduled on the same node", w.replicas),
+			Severity: ErrorSeverity,
+		})
+	}
+
+	return r, nil
+}
+
+// indexedContainer is a container along with the path of its definition within its workload.
+type indexedContainer struct {
+	container *corev1.Container
+	field     string
+}
+
+// getIndexedContainers returns both init and regular containers of podSpec, found at podSpecPath within its workload.
+func getIndexedContainers(podSpec *corev1.PodSpec, podSpecPath string) []indexedContainer {
+	containers := make([]indexedContainer, 0, len(podSpec.InitContainers)+len(podSpec.Containers))
+	for i := range podSpec.InitContainers {
+		containers = append(containers, indexedContainer{&podSpec.InitContainers[i], fmt.Sprintf("%s.initContainers[%d]", podSpecPath, i)})
+	}
+	for i := range podSpec.Containers {
+		containers = append(containers, indexedContainer{&podSpec.Containers[i], fmt.Sprintf("%s.containers[%d]", podSpecPath, i)})
+	}
+	return containers
+}
+
+func PodsRunAsNonroot(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	required := map[string]bool{RunAsNonRootField: true}
+	if config.IsSet(RequiredFieldsConfigKey) {
+		required = getStringSetConfig(config, RequiredFieldsConfigKey)
+	}
+
+	r := NewResult(true, PodsRunAsNonRoot)
+	for _, res := range resources {
+		podSpec, ok := getPodSpec(res)
+		if !ok {
+			continue
+		}
+
+		podSpecPath := strings.Join(podSpecFields[res.GetKind()], ".")
+		podContext := podSpec.SecurityContext
+		if podContext == nil {
+			podContext = &corev1.PodSecurityContext{}
+		}
+
+		mountsVolumes := false
+		for _, c := range getIndexedContainers(podSpec, podSpecPath) {
+			mountsVolumes = mountsVolumes || len(c.container.VolumeMounts) > 0
+
+			// container settings take precedence over the pod ones
+			runAsNonRoot, runAsUser := podContext.RunAsNonRoot, podContext.RunAsUser
+			if sc := c.container.SecurityContext; sc != nil {
+				if sc.RunAsNonRoot != nil {
+					runAsNonRoot = sc.RunAsNonRoot
+				}
+				if sc.RunAsUser != nil {
+					runAsUser = sc.RunAsUser
+				}
+			}
+
+			if runAsUser != nil && *runAsUser == 0 {
+				addFailure(&r, fmt.Sprintf("%s : %s container %s", ContainerRunsAsRoot, res, c.container.Name))
+				r.AddFinding(Finding{
+					Resource: res.String(),
+					Field:    c.field + ".securityContext.runAsUser",
+					Message:  fmt.Sprintf("Container %s runs as UID 0", c.container.Name),
+					Severity: ErrorSeverity,
+				})
+			} else if required[RunAsNonRootField] && (runAsNonRoot == nil || !*runAsNonRoot) {
+				addFailure(&r, fmt.Sprintf("%s : %s container %s", ContainerRunAsNonRootMissing, res, c.container.Name))
+				r.AddFinding(Finding{
+					Resource: res.String(),
+					Field:    c.field + ".securityContext.runAsNonRoot",
+					Message:  fmt.Sprintf("Container %s does not set runAsNonRoot to true", c.container.Name),
+					Severity: ErrorSeverity,
+				})
+			}
+		}
+
+		if required[FsGroupField] && mountsVolumes && podContext.FSGroup == nil {
+			addFailure(&r, fmt.Sprintf("%s : %s", PodFsGroupMissing, res))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    podSpecPath + ".securityContext.fsGroup",
+				Message:  "Pod mounts volumes without setting fsGroup",
+				Severity: ErrorSeverity,
+			})
+		}
+	}
+
+	return r, nil
+}
+
+func WorkloadsUseServiceaccount(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	requireDefined := config.GetBool(RequireDefinedConfigKey)
+	allowlist := getStringSetConfig(config, AllowlistConfigKey)
+
+	defined := map[string]bool{}
+	for _, res := range resources {
+		if res.GetKind() == "ServiceAccount" {
+			defined[res.GetName()] = true
+		}
+	}
+
+	r := NewResult(true, ServiceAccountsExplicit)
+	for _, res := range resources {
+		if allowlist[res.GetName()] {
+			continue
+		}
+
+		podSpec, ok := getPodSpec(res)
+		if !ok {
+			continue
+		}
+
+		field := strings.Join(podSpecFields[res.GetKind()], ".") + ".serviceAccountName"
+		name := podSpec.ServiceAccountName
+		if name == "" {
+			name = podSpec.DeprecatedServiceAccount
+		}
+
+		switch {
+		case name == "" || name == "default":
+			addFailure(&r, fmt.Sprintf("%s : %s", ServiceAccountNotSet, res))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    field,
+				Message:  "Pod runs as the default ServiceAccount",
+				Severity: ErrorSeverity,
+			})
+		case requireDefined && !defined[name]:
+			addFailure(&r, fmt.Sprintf("%s : %s uses %s", ServiceAccountUndefined, res, name))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    field,
+				Message:  fmt.Sprintf("ServiceAccount %s is not created by the chart", name),
+				Severity: ErrorSeverity,
+			})
+		}
+	}
+
+	return r, nil
+}
+
+// updateStrategyFields are the fields holding the update strategy of each kind of workload.
+var updateStrategyFields = map[string][]string{
+	"Deployment":  {"spec", "strategy"},
+	"StatefulSet": {"spec", "updateStrategy"},
+	"DaemonSet":   {"spec", "updateStrategy"},
+}
+
+// isZero informs whether v is given and amounts to no pod at all.
+func isZero(v *intstr.IntOrString) bool {
+	if v == nil {
+		return false
+	}
+	scaled, err := intstr.GetScaledValueFromIntOrPercent(v, 100, false)
+	return err == nil && scaled == 0
+}
+
+// checkRollingUpdate returns the field and description of the problem found in the rolling update parameters of res,
+// or an empty field if they are sane.
+func checkRollingUpdate(res renderedResource, fields []string) (string, string, error) {
+	rollingUpdate := append(append([]string{}, fields...), "rollingUpdate")
+	maxSurge, err := getIntOrString(res, append(rollingUpdate, "maxSurge")...)
+	if err != nil {
+		return "", "", err
+	}
+	maxUnavailable, err := getIntOrString(res, append(rollingUpdate, "maxUnavailable")...)
+	if err != nil || maxUnavailable == nil {
+		return "", "", err
+	}
+
+	field := strings.Join(rollingUpdate, ".") + ".maxUnavailable"
+	// Only Deployments surge by default; StatefulSets and DaemonSets replace pods in place unless told otherwise.
+	noSurge := isZero(maxSurge) || (maxSurge == nil && res.GetKind() != "Deployment")
+	if isZero(maxUnavailable) && noSurge {
+		return field, "Rolling update can make no progress with both maxSurge and maxUnavailable set to 0", nil
+	}
+	if maxUnavailable.Type == intstr.String {
+		scaled, err := intstr.GetScaledValueFromIntOrPercent(maxUnavailable, 100, false)
+		if err != nil {
+			return "", "", fmt.Errorf("%s: %v", res, err)
+		}
+		if scaled >= 100 {
+			return field, fmt.Sprintf("Rolling update can take all pods down at once with maxUnavailable set to %s", maxUnavailable), nil
+		}
+	}
+	return "", "", nil
+}
+
+func UpdateStrategyDeclared(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	strict := config.GetBool(StrictConfigKey)
+	allowlist := getStringSetConfig(config, AllowlistConfigKey)
+
+	r := NewResult(true, UpdateStrategiesDeclared)
+	for _, res := range resources {
+		fields, ok := updateStrategyFields[res.GetKind()]
+		if !ok || allowlist[res.GetName()] {
+			continue
+		}
+
+		strategy, found, err := unstructured.NestedMap(res.Object, fields...)
+		if err != nil {
+			return Result{}, err
+		}
+		if !found || len(strategy) == 0 {
+			addFailure(&r, fmt.Sprintf("%s : %s", UpdateStrategyMissing, res))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    strings.Join(fields, "."),
+				Message:  fmt.Sprintf("%s does not declare how pods are replaced on upgrade", res.GetKind()),
+				Severity: ErrorSeverity,
+			})
+			continue
+		}
+
+		if !strict {
+			continue
+		}
+		field, message, err := checkRollingUpdate(res, fields)
+		if err != nil {
+			return Result{}, err
+		}
+		if field != "" {
+			addFailure(&r, fmt.Sprintf("%s : %s", UpdateStrategyUnsafe, res))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    field,
+				Message:  message,
+				Severity: ErrorSeverity,
+			})
+		}
+	}
+
+	return r, nil
+}
+
+// getServiceSelectors returns the pod selectors of the Services found in the given resources; Services without
+// selector, whose endpoints are managed externally, are ignored.
+func getServiceSelectors(resources []renderedResource) ([]labels.Selector, error) {
+	var selectors []labels.Selector
+	for _, res := range resources {
+		if res.GetKind() != "Service" {
+			continue
+		}
+		selector, found, err := unstructured.NestedStringMap(res.Object, "spec", "selector")
+		if err != nil {
+			return nil, err
+		}
+		if found && len(selector) > 0 {
+			selectors = append(selectors, labels.SelectorFromSet(selector))
+		}
+	}
+	return selectors, nil
+}
+
+// getAutoscaledWorkloads returns the names of the Deployments scaled by HorizontalPodAutoscalers found in the given
+// resources.
+func getAutoscaledWorkloads(resources []renderedResource) (map[string]bool, error) {
+	autoscaled := map[string]bool{}
+	for _, res := range resources {
+		if res.GetKind() != "HorizontalPodAutoscaler" {
+			continue
+		}
+		target, _, err := unstructured.NestedStringMap(res.Object, "spec", "scaleTargetRef")
+		if err != nil {
+			return nil, err
+		}
+		if target["kind"] == "Deployment" {
+			autoscaled[target["name"]] = true
+		}
+	}
+	return autoscaled, nil
+}
+
+// ReplicaCountSane checks the Deployments rendered by the chart declare at least one replica, at least
+// MinReplicasConfigKey replicas when exposed by a Service, and at most MaxReplicasConfigKey replicas; Deployments
+// scaled by a HorizontalPodAutoscaler are ignored.
+func ReplicaCountSane(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	minReplicas := int64(2)
+	if config.IsSet(MinReplicasConfigKey) {
+		minReplicas = config.GetInt64(MinReplicasConfigKey)
+	}
+	maxReplicas := int64(defaultMaxReplicas)
+	if config.IsSet(MaxReplicasConfigKey) {
+		maxReplicas = config.GetInt64(MaxReplicasConfigKey)
+	}
+	allowlist := getStringSetConfig(config, AllowlistConfigKey)
+
+	selectors, err := getServiceSelectors(resources)
+	if err != nil {
+		return Result{}, err
+	}
+	autoscaled, err := getAutoscaledWorkloads(resources)
+	if err != nil {
+		return Result{}, err
+	}
+
+	r := NewResult(true, ReplicaCountsSane)
+	for _, res := range resources {
+		if res.GetKind() != "Deployment" || allowlist[res.GetName()] || autoscaled[res.GetName()] {
+			continue
+		}
+
+		replicas, found, err := unstructured.NestedInt64(res.Object, "spec", "replicas")
+		if err != nil {
+			return Result{}, err
+		}
+		declared := fmt.Sprintf("spec.replicas is %d", replicas)
+		if !found {
+			// the API server defaults replicas to 1
+			replicas, declared = 1, "spec.replicas is not declared, defaulting to 1"
+		}
+
+		podLabels, _, err := unstructured.NestedStringMap(res.Object, "spec", "template", "metadata", "labels")
+		if err != nil {
+			return Result{}, err
+		}
+		exposed := false
+		for _, selector := range selectors {
+			exposed = exposed || selector.Matches(labels.Set(podLabels))
+		}
+
+		switch {
+		case replicas == 0:
+			addFailure(&r, fmt.Sprintf("%s : %s", ReplicaCountZero, res))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    "spec.replicas",
+				Message:  "no pods are run until the Deployment is scaled",
+				Severity: ErrorSeverity,
+			})
+		case replicas > maxReplicas:
+			addFailure(&r, fmt.Sprintf("%s : %s (%d > %d)", ReplicaCountExcessive, res, replicas, maxReplicas))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    "spec.replicas",
+				Message:  fmt.Sprintf("%s, more than the %d accepted", declared, maxReplicas),
+				Severity: ErrorSeverity,
+			})
+		case replicas < minReplicas && exposed:
+			addFailure(&r, fmt.Sprintf("%s : %s (%d < %d)", ReplicaCountNotHighlyAvailable, res, replicas, minReplicas))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    "spec.replicas",
+				Message:  fmt.Sprintf("%s; the Service is unavailable whenever its pod is rescheduled", declared),
+				Severity: ErrorSeverity,
+			})
+		}
+	}
+
+	return r, nil
+}
+
+// jobSpecFields maps the kinds of resources running Jobs to the fields containing their job specs.
+var jobSpecFields = map[string][]string{
+	"Job":     {"spec"},
+	"CronJob": {"spec", "jobTemplate", "spec"},
+}
+
+func JobsConfigured(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	allowlist := getStringSetConfig(config, AllowlistConfigKey)
+
+	r := NewResult(true, JobsBounded)
+	for _, res := range resources {
+		fields, ok := jobSpecFields[res.GetKind()]
+		if !ok || allowlist[res.GetName()] {
+			continue
+		}
+		jobSpec := strings.Join(fields, ".")
+
+		if _, found, err := unstructured.NestedFieldNoCopy(res.Object, append(fields, "backoffLimit")...); err != nil {
+			return Result{}, err
+		} else if !found {
+			addFailure(&r, fmt.Sprintf("%s : %s", JobBackoffLimitMissing, res))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    jobSpec + ".backoffLimit",
+				Message:  "backoffLimit is not declared, failed pods are retried up to the default of 6 times",
+				Severity: ErrorSeverity,
+			})
+		}
+
+		restartPolicy, _, err := unstructured.NestedString(res.Object, append(fields, "template", "spec", "restartPolicy")...)
+		if err != nil {
+			return Result{}, err
+		}
+		if restartPolicy != string(corev1.RestartPolicyNever) && restartPolicy != string(corev1.RestartPolicyOnFailure) {
+			message := fmt.Sprintf("restartPolicy is %s, Jobs only accept Never or OnFailure", restartPolicy)
+			if restartPolicy == "" {
+				message = "restartPolicy is not declared, defaulting to Always which Jobs don't accept"
+			}
+			addFailure(&r, fmt.Sprintf("%s : %s", JobRestartPolicyInvalid, res))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    jobSpec + ".template.spec.restartPolicy",
+				Message:  message,
+				Severity: ErrorSeverity,
+			})
+		}
+
+		if res.GetKind() != "CronJob" {
+			continue
+		}
+
+		if _, found, err := unstructured.NestedString(res.Object, "spec", "concurrencyPolicy"); err != nil {
+			return Result{}, err
+		} else if !found {
+			addFailure(&r, fmt.Sprintf("%s : %s", CronJobConcurrencyMissing, res))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    "spec.concurrencyPolicy",
+				Message:  "concurrencyPolicy is not declared, defaulting to Allow so overdue runs pile up",
+				Severity: ErrorSeverity,
+			})
+		}
+
+		if _, found, err := unstructured.NestedInt64(res.Object, "spec", "startingDeadlineSeconds"); err != nil {
+			return Result{}, err
+		} else if !found {
+			addFailure(&r, fmt.Sprintf("%s : %s", CronJobDeadlineMissing, res))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    "spec.startingDeadlineSeconds",
+				Message:  "startingDeadlineSeconds is not declared, missed runs are started however late they are",
+				Severity: ErrorSeverity,
+			})
+		}
+	}
+
+	return r, nil
+}
+
+// probeFields are the fields of a container declaring its probes.
+var probeFields = []string{"livenessProbe", "readinessProbe", "startupProbe"}
+
+// probeParameters are the parameters of a container probe; parameters left undeclared hold the API server defaults.
+type probeParameters struct {
+	initialDelaySeconds int64
+	timeoutSeconds      int64
+	periodSeconds       int64
+	failureThreshold    int64
+	// declared informs which parameters are declared.
+	declared map[string]bool
+}
+
+// getProbeParameters returns the parameters of the given probe.
+func getProbeParameters(probe map[string]interface{}) (probeParameters, error) {
+	p := probeParameters{timeoutSeconds: 1, periodSeconds: 10, failureThreshold: 3, declared: map[string]bool{}}
+	for field, value := range map[string]*int64{
+		"initialDelaySeconds": &p.initialDelaySeconds,
+		"timeoutSeconds":      &p.timeoutSeconds,
+		"periodSeconds":       &p.periodSeconds,
+		"failureThreshold":    &p.failureThreshold,
+	} {
+		v, found, err := unstructured.NestedInt64(probe, field)
+		if err != nil {
+			return probeParameters{}, err
+		}
+		if found {
+			*value = v
+			p.declared[field] = true
+		}
+	}
+	return p, nil
+}
+
+func ProbeParametersSane(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	minInitialDelay := int64(1)
+	if config.IsSet(MinInitialDelayConfigKey) {
+		minInitialDelay = config.GetInt64(MinInitialDelayConfigKey)
+	}
+	maxInitialDelay := int64(defaultMaxInitialDelay)
+	if config.IsSet(MaxInitialDelayConfigKey) {
+		maxInitialDelay = config.GetInt64(MaxInitialDelayConfigKey)
+	}
+	minFailureThreshold := int64(1)
+	if config.IsSet(MinFailureThresholdConfigKey) {
+		minFailureThreshold = config.GetInt64(MinFailureThresholdConfigKey)
+	}
+	allowlist := getStringSetConfig(config, AllowlistConfigKey)
+
+	r := NewResult(true, ProbesSane)
+	for _, res := range resources {
+		fields, ok := podSpecFields[res.GetKind()]
+		if !ok || allowlist[res.GetName()] {
+			continue
+		}
+
+		// probes are read as rendered, since parameters left undeclared can't be told apart from zeros once converted
+		containers, _, err := unstructured.NestedSlice(res.Object, append(fields, "containers")...)
+		if err != nil {
+			return Result{}, err
+		}
+		for i, c := range containers {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			name, _, _ := unstructured.NestedString(container, "name")
+			containerPath := fmt.Sprintf("%s.containers[%d]", strings.Join(fields, "."), i)
+			_, hasStartupProbe := container["startupProbe"]
+
+			for _, probeField := range probeFields {
+				probe, found, err := unstructured.NestedMap(container, probeField)
+				if err != nil {
+					return Result{}, err
+				}
+				if !found {
+					continue
+				}
+				p, err := getProbeParameters(probe)
+				if err != nil {
+					return Result{}, err
+				}
+				probePath := containerPath + "." + probeField
+
+				if p.timeoutSeconds > p.periodSeconds {
+					addFailure(&r, fmt.Sprintf("%s : %s container %s %s", ProbeTimeoutExceedsPeriod, res, name, probeField))
+					r.AddFinding(Finding{
+						Resource: res.String(),
+						Field:    probePath + ".timeoutSeconds",
+						Message:  fmt.Sprintf("timeoutSeconds is %d, longer than the periodSeconds of %d, so probes overlap", p.timeoutSeconds, p.periodSeconds),
+						Severity: ErrorSeverity,
+					})
+				}
+				if p.declared["failureThreshold"] && p.failureThreshold < minFailureThreshold {
+					addFailure(&r, fmt.Sprintf("%s : %s container %s %s (%d < %d)", ProbeFailureThresholdTooLow, res, name, probeField, p.failureThreshold, minFailureThreshold))
+					r.AddFinding(Finding{
+						Resource: res.String(),
+						Field:    probePath + ".failureThreshold",
+						Message:  fmt.Sprintf("failureThreshold is %d, less than the %d accepted", p.failureThreshold, minFailureThreshold),
+						Severity: ErrorSeverity,
+					})
+				}
+				// a startup probe holds the liveness probe off until the container is up; undeclared, the delay defaults to 0
+				if probeField == "livenessProbe" && !hasStartupProbe && p.initialDelaySeconds < minInitialDelay {
+					addFailure(&r, fmt.Sprintf("%s : %s container %s (%d < %d)", ProbeInitialDelayTooShort, res, name, p.initialDelaySeconds, minInitialDelay))
+					r.AddFinding(Finding{
+						Resource: res.String(),
+						Field:    probePath + ".initialDelaySeconds",
+						Message:  fmt.Sprintf("initialDelaySeconds is %d and no startupProbe is declared, so slow starts get the container restarted", p.initialDelaySeconds),
+						Severity: ErrorSeverity,
+					})
+				}
+				if p.initialDelaySeconds > maxInitialDelay {
+					addFailure(&r, fmt.Sprintf("%s : %s container %s %s (%d > %d)", ProbeInitialDelayTooLong, res, name, probeField, p.initialDelaySeconds, maxInitialDelay))
+					r.AddFinding(Finding{
+						Resource: res.String(),
+						Field:    probePath + ".initialDelaySeconds",
+						Message:  fmt.Sprintf("initialDelaySeconds is %d, more than the %d accepted; declare a startupProbe for slow starts instead", p.initialDelaySeconds, maxInitialDelay),
+						Severity: ErrorSeverity,
+					})
+				}
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// RevisionHistoryBounded checks the Deployments rendered by the chart declare a revisionHistoryLimit of at most
+// MaxRevisionHistoryConfigKey, so old ReplicaSets don't accumulate in the cluster.
+func RevisionHistoryBounded(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	maxRevisionHistory := int64(defaultMaxRevisionHistory)
+	if config.IsSet(MaxRevisionHistoryConfigKey) {
+		maxRevisionHistory = config.GetInt64(MaxRevisionHistoryConfigKey)
+	}
+	allowlist := getStringSetConfig(config, AllowlistConfigKey)
+	suggestFixes := config.GetBool(SuggestFixesConfigKey)
+
+	r := NewResult(true, RevisionHistoriesBounded)
+	for _, res := range resources {
+		if res.GetKind() != "Deployment" || allowlist[res.GetName()] {
+			continue
+		}
+
+		limit, found, err := unstructured.NestedInt64(res.Object, "spec", "revisionHistoryLimit")
+		if err != nil {
+			return Result{}, err
+		}
+		switch {
+		case !found:
+			addFailure(&r, fmt.Sprintf("%s : %s", RevisionHistoryLimitMissing, res))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    "spec.revisionHistoryLimit",
+				Message:  "revisionHistoryLimit is not declared, so old ReplicaSets are kept up to the cluster default",
+				Severity: ErrorSeverity,
+			})
+		case limit > maxRevisionHistory:
+			addFailure(&r, fmt.Sprintf("%s : %s (%d > %d)", RevisionHistoryLimitExcessive, res, limit, maxRevisionHistory))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    "spec.revisionHistoryLimit",
+				Message:  fmt.Sprintf("revisionHistoryLimit is %d, more than the %d accepted", limit, maxRevisionHistory),
+				Severity: ErrorSeverity,
+			})
+		default:
+			continue
+		}
+
+		if suggestFixes {
+			suggestion, err := newSuggestion(res.String(), fmt.Sprintf("Keep at most %d old ReplicaSets", maxRevisionHistory),
+				map[string]interface{}{"spec": map[string]interface{}{"revisionHistoryLimit": maxRevisionHistory}})
+			if err != nil {
+				return Result{}, err
+			}
+			r.AddSuggestion(suggestion)
+		}
+	}
+
+	return r, nil
+}
+
+// getHeadlessServices informs, for the namespace and name of each Service found in the given resources, whether it's
+// headless.
+func getHeadlessServices(resources []renderedResource) (map[string]bool, error) {
+	headless := map[string]bool{}
+	for _, res := range resources {
+		if res.GetKind() != "Service" {
+			continue
+		}
+		clusterIP, _, err := unstructured.NestedString(res.Object, "spec", "clusterIP")
+		if err != nil {
+			return nil, err
+		}
+		headless[res.GetNamespace()+"/"+res.GetName()] = clusterIP == "None"
+	}
+	return headless, nil
+}
+
+// StatefulsetServicenameValid checks the StatefulSets rendered by the chart reference, in their serviceName, a
+// headless Service also rendered by the chart, which gives their pods stable DNS names; Services named in the
+// allowlist, created outside of the chart, are accepted.
+func StatefulsetServicenameValid(uri string, config *viper.Viper) (Result, error) {
+	resources, err := getRenderedResources(uri, config)
+	if err != nil {
+		return NewResult(false, fmt.Sprintf("%s : %v", RenderFailed, err)), nil
+	}
+
+	headless, err := getHeadlessServices(resources)
+	if err != nil {
+		return Result{}, err
+	}
+	allowlist := getStringSetConfig(config, AllowlistConfigKey)
+
+	r := NewResult(true, StatefulSetServicesValid)
+	for _, res := range resources {
+		if res.GetKind() != "StatefulSet" {
+			continue
+		}
+		serviceName, _, err := unstructured.NestedString(res.Object, "spec", "serviceName")
+		if err != nil {
+			return Result{}, err
+		}
+		if serviceName == "" || allowlist[serviceName] {
+			continue
+		}
+
+		isHeadless, found := headless[res.GetNamespace()+"/"+serviceName]
+		switch {
+		case !found:
+			addFailure(&r, fmt.Sprintf("%s : %s referenced by %s", StatefulSetServiceMissing, serviceName, res))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    "spec.serviceName",
+				Message:  fmt.Sprintf("Service %q is not created by the chart", serviceName),
+				Severity: ErrorSeverity,
+			})
+		case !isHeadless:
+			addFailure(&r, fmt.Sprintf("%s : %s referenced by %s", StatefulSetServiceNotHeadless, serviceName, res))
+			r.AddFinding(Finding{
+				Resource: res.String(),
+				Field:    "spec.serviceName",
+				Message:  fmt.Sprintf("Service %q does not declare clusterIP: None, so the pods get no stable DNS names", serviceName),
+				Severity: ErrorSeverity,
+			})
+		}
+	}
+
+	return r, nil
+}
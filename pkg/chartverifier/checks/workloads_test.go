@@ -0,0 +1,1011 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package checks
+
+import (
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+func TestPdbConfigured(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		reason      string
+		message     string
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart with a single replica", uri: "chart-0.1.0-v3.valid.tgz"},
+		{description: "chart with multiple replicas and a PodDisruptionBudget", uri: "chart-0.1.0-v3.pdb-configured.tgz"},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			r, err := PdbConfigured(tc.uri, viper.New())
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.True(t, r.Ok)
+			require.Equal(t, PodDisruptionBudgetsConfigured, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart with multiple replicas and no PodDisruptionBudget",
+			uri:         "chart-0.1.0-v3.pdb-missing.tgz",
+			reason:      PodDisruptionBudgetMissing + " : Deployment/testRelease-chart",
+			message:     "3 replicas are declared but no PodDisruptionBudget selects the workload's pods",
+		},
+		{
+			description: "chart with multiple replicas and a PodDisruptionBudget requiring all of them",
+			uri:         "chart-0.1.0-v3.pdb-too-strict.tgz",
+			reason:      PodDisruptionBudgetTooStrict + " : Deployment/testRelease-chart",
+			message:     "PodDisruptionBudget/testRelease-chart does not allow any of the 3 replicas to be evicted",
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			r, err := PdbConfigured(tc.uri, viper.New())
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Len(t, r.Findings, 1)
+			require.Equal(t, "Deployment/testRelease-chart", r.Findings[0].Resource)
+			require.Equal(t, tc.message, r.Findings[0].Message)
+			require.Equal(t, ErrorSeverity, r.Findings[0].Severity)
+		})
+	}
+}
+
+func TestHaAntiAffinity(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		minReplicas interface{}
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart with a single replica", uri: "chart-0.1.0-v3.valid.tgz"},
+		{description: "chart with multiple replicas and topology spread constraints", uri: "chart-0.1.0-v3.ha-topology-spread.tgz"},
+		{description: "chart with multiple replicas and pod anti-affinity", uri: "chart-0.1.0-v3.ha-antiaffinity.tgz"},
+		{description: "chart with fewer replicas than required for high availability", uri: "chart-0.1.0-v3.ha-unspread.tgz", minReplicas: "4"},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			if tc.minReplicas != nil {
+				config.Set(MinReplicasConfigKey, tc.minReplicas)
+			}
+			r, err := HaAntiAffinity(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.True(t, r.Ok)
+			require.Equal(t, ReplicasSpread, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{description: "chart with multiple replicas and neither pod anti-affinity nor topology spread constraints", uri: "chart-0.1.0-v3.ha-unspread.tgz"},
+		{description: "chart with as many replicas as required for high availability", uri: "chart-0.1.0-v3.ha-unspread.tgz", minReplicas: 3},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			if tc.minReplicas != nil {
+				config.Set(MinReplicasConfigKey, tc.minReplicas)
+			}
+			r, err := HaAntiAffinity(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.False(t, r.Ok)
+			require.Equal(t, ReplicasNotSpread+" : Deployment/testRelease-chart", r.Reason)
+			require.Len(t, r.Findings, 1)
+			require.Equal(t, "Deployment/testRelease-chart", r.Findings[0].Resource)
+			require.Equal(t, "3 replicas may all be scheduled on the same node", r.Findings[0].Message)
+		})
+	}
+}
+
+func TestPodsRunAsNonroot(t *testing.T) {
+	type testCase struct {
+		description    string
+		uri            string
+		requiredFields interface{}
+		reason         string
+		findings       []Finding
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart with non-root pods", uri: "chart-0.1.0-v3.nonroot-pods.tgz"},
+		{description: "chart with non-root pods requiring fsGroup", uri: "chart-0.1.0-v3.nonroot-pods.tgz", requiredFields: "runAsNonRoot,fsGroup"},
+		{description: "chart with non-root pods mounting volumes without fsGroup", uri: "chart-0.1.0-v3.nonroot-volumes.tgz"},
+		{description: "chart missing runAsNonRoot when not required", uri: "chart-0.1.0-v3.valid.tgz", requiredFields: []string{}},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			if tc.requiredFields != nil {
+				config.Set(RequiredFieldsConfigKey, tc.requiredFields)
+			}
+			r, err := PodsRunAsNonroot(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.True(t, r.Ok)
+			require.Equal(t, PodsRunAsNonRoot, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart with pods missing runAsNonRoot",
+			uri:         "chart-0.1.0-v3.valid.tgz",
+			reason: ContainerRunAsNonRootMissing + " : Deployment/testRelease-chart container chart" +
+				"\n\t\t" + ContainerRunAsNonRootMissing + " : Pod/testRelease-chart-test-connection container wget",
+			findings: []Finding{
+				{
+					Resource: "Deployment/testRelease-chart",
+					Field:    "spec.template.spec.containers[0].securityContext.runAsNonRoot",
+					Message:  "Container chart does not set runAsNonRoot to true",
+					Severity: ErrorSeverity,
+				},
+				{
+					Resource: "Pod/testRelease-chart-test-connection",
+					Field:    "spec.containers[0].securityContext.runAsNonRoot",
+					Message:  "Container wget does not set runAsNonRoot to true",
+					Severity: ErrorSeverity,
+				},
+			},
+		},
+		{
+			description:    "chart with a container running as root",
+			uri:            "chart-0.1.0-v3.root-pods.tgz",
+			requiredFields: []string{},
+			reason:         ContainerRunsAsRoot + " : Deployment/testRelease-chart container chart",
+			findings: []Finding{
+				{
+					Resource: "Deployment/testRelease-chart",
+					Field:    "spec.template.spec.containers[0].securityContext.runAsUser",
+					Message:  "Container chart runs as UID 0",
+					Severity: ErrorSeverity,
+				},
+			},
+		},
+		{
+			description:    "chart with pods mounting volumes without fsGroup",
+			uri:            "chart-0.1.0-v3.nonroot-volumes.tgz",
+			requiredFields: "fsGroup",
+			reason:         PodFsGroupMissing + " : Deployment/testRelease-chart",
+			findings: []Finding{
+				{
+					Resource: "Deployment/testRelease-chart",
+					Field:    "spec.template.spec.securityContext.fsGroup",
+					Message:  "Pod mounts volumes without setting fsGroup",
+					Severity: ErrorSeverity,
+				},
+			},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			if tc.requiredFields != nil {
+				config.Set(RequiredFieldsConfigKey, tc.requiredFields)
+			}
+			r, err := PodsRunAsNonroot(tc.uri, config)
+			require.NoError(t, err)
+			require.NotNil(t, r)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, tc.findings, r.Findings)
+		})
+	}
+}
+
+func TestWorkloadsUseServiceaccount(t *testing.T) {
+	type testCase struct {
+		description    string
+		uri            string
+		values         chartutil.Values
+		requireDefined bool
+		reason         string
+		findings       []Finding
+	}
+
+	defaultServiceAccount := chartutil.Values{"serviceAccount": map[string]interface{}{"create": false}}
+	undefinedServiceAccount := chartutil.Values{"serviceAccount": map[string]interface{}{"create": false, "name": "builder"}}
+
+	positiveTestCases := []testCase{
+		{description: "workloads using a chart-defined ServiceAccount", uri: "chart-0.1.0-v3.sa-explicit.tgz", requireDefined: true},
+		{description: "workloads using an undefined ServiceAccount when not required to be defined", uri: "chart-0.1.0-v3.sa-explicit.tgz", values: undefinedServiceAccount},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(RequireDefinedConfigKey, tc.requireDefined)
+			if tc.values != nil {
+				config.Set(ValuesConfigKey, tc.values)
+			}
+			r, err := WorkloadsUseServiceaccount(tc.uri, config)
+			require.NoError(t, err)
+			require.True(t, r.Ok)
+			require.Equal(t, ServiceAccountsExplicit, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "workload without serviceAccountName",
+			uri:         "chart-0.1.0-v3.valid.tgz",
+			reason:      ServiceAccountNotSet + " : Pod/testRelease-chart-test-connection",
+			findings: []Finding{
+				{Resource: "Pod/testRelease-chart-test-connection", Field: "spec.serviceAccountName", Message: "Pod runs as the default ServiceAccount", Severity: ErrorSeverity},
+			},
+		},
+		{
+			description: "workloads using the default ServiceAccount",
+			uri:         "chart-0.1.0-v3.sa-explicit.tgz",
+			values:      defaultServiceAccount,
+			reason: ServiceAccountNotSet + " : Deployment/testRelease-chart" +
+				"\n\t\t" + ServiceAccountNotSet + " : Pod/testRelease-chart-test-connection",
+			findings: []Finding{
+				{Resource: "Deployment/testRelease-chart", Field: "spec.template.spec.serviceAccountName", Message: "Pod runs as the default ServiceAccount", Severity: ErrorSeverity},
+				{Resource: "Pod/testRelease-chart-test-connection", Field: "spec.serviceAccountName", Message: "Pod runs as the default ServiceAccount", Severity: ErrorSeverity},
+			},
+		},
+		{
+			description:    "workloads using an undefined ServiceAccount",
+			uri:            "chart-0.1.0-v3.sa-explicit.tgz",
+			values:         undefinedServiceAccount,
+			requireDefined: true,
+			reason: ServiceAccountUndefined + " : Deployment/testRelease-chart uses builder" +
+				"\n\t\t" + ServiceAccountUndefined + " : Pod/testRelease-chart-test-connection uses builder",
+			findings: []Finding{
+				{Resource: "Deployment/testRelease-chart", Field: "spec.template.spec.serviceAccountName", Message: "ServiceAccount builder is not created by the chart", Severity: ErrorSeverity},
+				{Resource: "Pod/testRelease-chart-test-connection", Field: "spec.serviceAccountName", Message: "ServiceAccount builder is not created by the chart", Severity: ErrorSeverity},
+			},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(RequireDefinedConfigKey, tc.requireDefined)
+			if tc.values != nil {
+				config.Set(ValuesConfigKey, tc.values)
+			}
+			r, err := WorkloadsUseServiceaccount(tc.uri, config)
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, tc.findings, r.Findings)
+		})
+	}
+}
+
+func TestUpdateStrategyDeclared(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		values      chartutil.Values
+		strict      bool
+		reason      string
+		findings    []Finding
+	}
+
+	daemonSet := func(updateStrategy map[string]interface{}) chartutil.Values {
+		return chartutil.Values{"daemonset": map[string]interface{}{"enabled": true, "updateStrategy": updateStrategy}}
+	}
+	rollingUpdate := func(maxSurge, maxUnavailable interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"type":          "RollingUpdate",
+			"rollingUpdate": map[string]interface{}{"maxSurge": maxSurge, "maxUnavailable": maxUnavailable},
+		}
+	}
+
+	positiveTestCases := []testCase{
+		{description: "deployment with an explicit strategy", uri: "chart-0.1.0-v3.update-strategy.tgz", strict: true},
+		{description: "deployment recreating pods", uri: "chart-0.1.0-v3.update-strategy.tgz", values: chartutil.Values{"strategy": map[string]interface{}{"type": "Recreate"}}, strict: true},
+		{description: "daemonset with an explicit update strategy", uri: "chart-0.1.0-v3.update-strategy.tgz", values: daemonSet(map[string]interface{}{"type": "OnDelete"}), strict: true},
+		{description: "deployment with an unsafe rolling update when not strict", uri: "chart-0.1.0-v3.update-strategy.tgz", values: chartutil.Values{"strategy": rollingUpdate(0, 0)}},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(StrictConfigKey, tc.strict)
+			if tc.values != nil {
+				config.Set(ValuesConfigKey, tc.values)
+			}
+			r, err := UpdateStrategyDeclared(tc.uri, config)
+			require.NoError(t, err)
+			require.True(t, r.Ok)
+			require.Equal(t, UpdateStrategiesDeclared, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "deployment without a strategy",
+			uri:         "chart-0.1.0-v3.valid.tgz",
+			reason:      UpdateStrategyMissing + " : Deployment/testRelease-chart",
+			findings: []Finding{
+				{Resource: "Deployment/testRelease-chart", Field: "spec.strategy", Message: "Deployment does not declare how pods are replaced on upgrade", Severity: ErrorSeverity},
+			},
+		},
+		{
+			description: "daemonset without an update strategy",
+			uri:         "chart-0.1.0-v3.update-strategy.tgz",
+			values:      daemonSet(nil),
+			reason:      UpdateStrategyMissing + " : DaemonSet/testRelease-chart-agent",
+			findings: []Finding{
+				{Resource: "DaemonSet/testRelease-chart-agent", Field: "spec.updateStrategy", Message: "DaemonSet does not declare how pods are replaced on upgrade", Severity: ErrorSeverity},
+			},
+		},
+		{
+			description: "deployment with a rolling update that cannot progress",
+			uri:         "chart-0.1.0-v3.update-strategy.tgz",
+			values:      chartutil.Values{"strategy": rollingUpdate(0, "0%")},
+			strict:      true,
+			reason:      UpdateStrategyUnsafe + " : Deployment/testRelease-chart",
+			findings: []Finding{
+				{Resource: "Deployment/testRelease-chart", Field: "spec.strategy.rollingUpdate.maxUnavailable", Message: "Rolling update can make no progress with both maxSurge and maxUnavailable set to 0", Severity: ErrorSeverity},
+			},
+		},
+		{
+			description: "daemonset with a rolling update taking all pods down",
+			uri:         "chart-0.1.0-v3.update-strategy.tgz",
+			values:      daemonSet(rollingUpdate(0, "100%")),
+			strict:      true,
+			reason:      UpdateStrategyUnsafe + " : DaemonSet/testRelease-chart-agent",
+			findings: []Finding{
+				{Resource: "DaemonSet/testRelease-chart-agent", Field: "spec.updateStrategy.rollingUpdate.maxUnavailable", Message: "Rolling update can take all pods down at once with maxUnavailable set to 100%", Severity: ErrorSeverity},
+			},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(StrictConfigKey, tc.strict)
+			if tc.values != nil {
+				config.Set(ValuesConfigKey, tc.values)
+			}
+			r, err := UpdateStrategyDeclared(tc.uri, config)
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, tc.findings, r.Findings)
+		})
+	}
+
+	t.Run("Should skip allowlisted workloads", func(t *testing.T) {
+		config := viper.New()
+		config.Set(AllowlistConfigKey, []string{"testRelease-chart"})
+		r, err := UpdateStrategyDeclared("chart-0.1.0-v3.valid.tgz", config)
+		require.NoError(t, err)
+		require.True(t, r.Ok)
+		require.Empty(t, r.Findings)
+	})
+}
+
+func TestReplicaCountSane(t *testing.T) {
+	type testCase struct {
+		description string
+		values      chartutil.Values
+		config      map[string]interface{}
+		reason      string
+		message     string
+	}
+
+	uri := "chart-0.1.0-v3.valid.tgz"
+	replicas := func(count int) chartutil.Values {
+		return chartutil.Values{"replicaCount": count}
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart with a sensible replica count", values: replicas(3)},
+		{description: "chart with a replica count under a raised ceiling", values: replicas(80), config: map[string]interface{}{MaxReplicasConfigKey: 100}},
+		{description: "chart with a single replica not required to be highly available", values: replicas(1), config: map[string]interface{}{MinReplicasConfigKey: 1}},
+		{description: "chart with an allowlisted single replica", values: replicas(1), config: map[string]interface{}{AllowlistConfigKey: []string{"testRelease-chart"}}},
+		{description: "chart with an autoscaled deployment", values: chartutil.Values{"autoscaling": map[string]interface{}{"enabled": true}}},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, tc.values)
+			for k, v := range tc.config {
+				config.Set(k, v)
+			}
+			r, err := ReplicaCountSane(uri, config)
+			require.NoError(t, err)
+			require.True(t, r.Ok, r.Reason)
+			require.Equal(t, ReplicaCountsSane, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart with a single replica",
+			values:      replicas(1),
+			reason:      ReplicaCountNotHighlyAvailable + " : Deployment/testRelease-chart (1 < 2)",
+			message:     "spec.replicas is 1; the Service is unavailable whenever its pod is rescheduled",
+		},
+		{
+			description: "chart with fewer replicas than required for high availability",
+			values:      replicas(2),
+			config:      map[string]interface{}{MinReplicasConfigKey: 3},
+			reason:      ReplicaCountNotHighlyAvailable + " : Deployment/testRelease-chart (2 < 3)",
+			message:     "spec.replicas is 2; the Service is unavailable whenever its pod is rescheduled",
+		},
+		{
+			description: "chart with no replicas",
+			values:      replicas(0),
+			reason:      ReplicaCountZero + " : Deployment/testRelease-chart",
+			message:     "no pods are run until the Deployment is scaled",
+		},
+		{
+			description: "chart with an excessive replica count",
+			values:      replicas(500),
+			reason:      ReplicaCountExcessive + " : Deployment/testRelease-chart (500 > 50)",
+			message:     "spec.replicas is 500, more than the 50 accepted",
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, tc.values)
+			for k, v := range tc.config {
+				config.Set(k, v)
+			}
+			r, err := ReplicaCountSane(uri, config)
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, []Finding{{
+				Resource: "Deployment/testRelease-chart",
+				Field:    "spec.replicas",
+				Message:  tc.message,
+				Severity: ErrorSeverity,
+			}}, r.Findings)
+		})
+	}
+}
+
+func TestJobsConfigured(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		values      chartutil.Values
+		config      map[string]interface{}
+		reason      string
+		findings    []Finding
+	}
+
+	jobsUri := "chart-0.1.0-v3.jobs.tgz"
+	job := func(values map[string]interface{}) chartutil.Values {
+		return chartutil.Values{"job": values}
+	}
+	cronJob := func(values map[string]interface{}) chartutil.Values {
+		return chartutil.Values{"cronJob": values}
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart without Jobs", uri: "chart-0.1.0-v3.valid.tgz"},
+		{description: "chart with well configured Jobs and CronJobs", uri: jobsUri},
+		{
+			description: "chart with an allowlisted Job",
+			uri:         jobsUri,
+			values:      job(map[string]interface{}{"restartPolicy": "Always"}),
+			config:      map[string]interface{}{AllowlistConfigKey: []string{"testRelease-chart-migrate"}},
+		},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, tc.values)
+			for k, v := range tc.config {
+				config.Set(k, v)
+			}
+			r, err := JobsConfigured(tc.uri, config)
+			require.NoError(t, err)
+			require.True(t, r.Ok, r.Reason)
+			require.Equal(t, JobsBounded, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart with a Job declaring an invalid restart policy",
+			uri:         jobsUri,
+			values:      job(map[string]interface{}{"restartPolicy": "Always"}),
+			reason:      JobRestartPolicyInvalid + " : Job/testRelease-chart-migrate",
+			findings: []Finding{{
+				Resource: "Job/testRelease-chart-migrate",
+				Field:    "spec.template.spec.restartPolicy",
+				Message:  "restartPolicy is Always, Jobs only accept Never or OnFailure",
+				Severity: ErrorSeverity,
+			}},
+		},
+		{
+			description: "chart with a Job declaring neither backoff limit nor restart policy",
+			uri:         jobsUri,
+			values:      job(map[string]interface{}{"backoffLimit": nil, "restartPolicy": nil}),
+			reason: JobBackoffLimitMissing + " : Job/testRelease-chart-migrate" +
+				"\n\t\t" + JobRestartPolicyInvalid + " : Job/testRelease-chart-migrate",
+			findings: []Finding{
+				{
+					Resource: "Job/testRelease-chart-migrate",
+					Field:    "spec.backoffLimit",
+					Message:  "backoffLimit is not declared, failed pods are retried up to the default of 6 times",
+					Severity: ErrorSeverity,
+				},
+				{
+					Resource: "Job/testRelease-chart-migrate",
+					Field:    "spec.template.spec.restartPolicy",
+					Message:  "restartPolicy is not declared, defaulting to Always which Jobs don't accept",
+					Severity: ErrorSeverity,
+				},
+			},
+		},
+		{
+			description: "chart with a CronJob missing its concurrency policy",
+			uri:         jobsUri,
+			values:      cronJob(map[string]interface{}{"concurrencyPolicy": nil}),
+			reason:      CronJobConcurrencyMissing + " : CronJob/testRelease-chart-cleanup",
+			findings: []Finding{{
+				Resource: "CronJob/testRelease-chart-cleanup",
+				Field:    "spec.concurrencyPolicy",
+				Message:  "concurrencyPolicy is not declared, defaulting to Allow so overdue runs pile up",
+				Severity: ErrorSeverity,
+			}},
+		},
+		{
+			description: "chart with a CronJob missing its starting deadline and backoff limit",
+			uri:         jobsUri,
+			values:      cronJob(map[string]interface{}{"startingDeadlineSeconds": nil, "backoffLimit": nil}),
+			reason: JobBackoffLimitMissing + " : CronJob/testRelease-chart-cleanup" +
+				"\n\t\t" + CronJobDeadlineMissing + " : CronJob/testRelease-chart-cleanup",
+			findings: []Finding{
+				{
+					Resource: "CronJob/testRelease-chart-cleanup",
+					Field:    "spec.jobTemplate.spec.backoffLimit",
+					Message:  "backoffLimit is not declared, failed pods are retried up to the default of 6 times",
+					Severity: ErrorSeverity,
+				},
+				{
+					Resource: "CronJob/testRelease-chart-cleanup",
+					Field:    "spec.startingDeadlineSeconds",
+					Message:  "startingDeadlineSeconds is not declared, missed runs are started however late they are",
+					Severity: ErrorSeverity,
+				},
+			},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, tc.values)
+			r, err := JobsConfigured(tc.uri, config)
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, tc.findings, r.Findings)
+		})
+	}
+}
+
+func TestProbeParametersSane(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		values      chartutil.Values
+		config      map[string]interface{}
+		reason      string
+		findings    []Finding
+	}
+
+	probesUri := "chart-0.1.0-v3.probes.tgz"
+	probe := func(name string, values map[string]interface{}) chartutil.Values {
+		return chartutil.Values{name: values}
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart with sane probes", uri: probesUri},
+		{
+			description: "chart with probes declaring only defaults and held off by a startup probe",
+			uri:         probesUri,
+			values: chartutil.Values{
+				"livenessProbe": map[string]interface{}{"initialDelaySeconds": nil, "timeoutSeconds": nil, "failureThreshold": nil},
+				"startupProbe":  map[string]interface{}{"httpGet": map[string]interface{}{"path": "/", "port": "http"}},
+			},
+		},
+		{
+			description: "chart with a liveness probe without initial delay held off by a startup probe",
+			uri:         probesUri,
+			values: chartutil.Values{
+				"livenessProbe": map[string]interface{}{"initialDelaySeconds": 0},
+				"startupProbe":  map[string]interface{}{"httpGet": map[string]interface{}{"path": "/", "port": "http"}, "failureThreshold": 30},
+			},
+		},
+		{
+			description: "chart with an initial delay within configured bounds",
+			uri:         probesUri,
+			values:      probe("livenessProbe", map[string]interface{}{"initialDelaySeconds": 600}),
+			config:      map[string]interface{}{MaxInitialDelayConfigKey: 900},
+		},
+		{
+			description: "chart with an allowlisted workload",
+			uri:         probesUri,
+			values:      probe("readinessProbe", map[string]interface{}{"timeoutSeconds": 15}),
+			config:      map[string]interface{}{AllowlistConfigKey: []string{"testRelease-chart"}},
+		},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, tc.values)
+			for k, v := range tc.config {
+				config.Set(k, v)
+			}
+			r, err := ProbeParametersSane(tc.uri, config)
+			require.NoError(t, err)
+			require.True(t, r.Ok, r.Reason)
+			require.Equal(t, ProbesSane, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart with a probe timing out after its period",
+			uri:         probesUri,
+			values:      probe("readinessProbe", map[string]interface{}{"timeoutSeconds": 15}),
+			reason:      ProbeTimeoutExceedsPeriod + " : Deployment/testRelease-chart container chart readinessProbe",
+			findings: []Finding{{
+				Resource: "Deployment/testRelease-chart",
+				Field:    "spec.template.spec.containers[0].readinessProbe.timeoutSeconds",
+				Message:  "timeoutSeconds is 15, longer than the periodSeconds of 5, so probes overlap",
+				Severity: ErrorSeverity,
+			}},
+		},
+		{
+			description: "chart with a probe declaring a zero failure threshold",
+			uri:         probesUri,
+			values:      probe("livenessProbe", map[string]interface{}{"failureThreshold": 0}),
+			reason:      ProbeFailureThresholdTooLow + " : Deployment/testRelease-chart container chart livenessProbe (0 < 1)",
+			findings: []Finding{{
+				Resource: "Deployment/testRelease-chart",
+				Field:    "spec.template.spec.containers[0].livenessProbe.failureThreshold",
+				Message:  "failureThreshold is 0, less than the 1 accepted",
+				Severity: ErrorSeverity,
+			}},
+		},
+		{
+			description: "chart with a liveness probe without initial delay",
+			uri:         probesUri,
+			values:      probe("livenessProbe", map[string]interface{}{"initialDelaySeconds": 0}),
+			reason:      ProbeInitialDelayTooShort + " : Deployment/testRelease-chart container chart (0 < 1)",
+			findings: []Finding{{
+				Resource: "Deployment/testRelease-chart",
+				Field:    "spec.template.spec.containers[0].livenessProbe.initialDelaySeconds",
+				Message:  "initialDelaySeconds is 0 and no startupProbe is declared, so slow starts get the container restarted",
+				Severity: ErrorSeverity,
+			}},
+		},
+		{
+			description: "chart with a liveness probe without declared initial delay nor startup probe",
+			uri:         "chart-0.1.0-v3.valid.tgz",
+			reason:      ProbeInitialDelayTooShort + " : Deployment/testRelease-chart container chart (0 < 1)",
+			findings: []Finding{{
+				Resource: "Deployment/testRelease-chart",
+				Field:    "spec.template.spec.containers[0].livenessProbe.initialDelaySeconds",
+				Message:  "initialDelaySeconds is 0 and no startupProbe is declared, so slow starts get the container restarted",
+				Severity: ErrorSeverity,
+			}},
+		},
+		{
+			description: "chart with probes outside configured bounds",
+			uri:         probesUri,
+			config: map[string]interface{}{
+				MinInitialDelayConfigKey:     30,
+				MaxInitialDelayConfigKey:     5,
+				MinFailureThresholdConfigKey: 5,
+			},
+			reason: ProbeFailureThresholdTooLow + " : Deployment/testRelease-chart container chart livenessProbe (3 < 5)" +
+				"\n\t\t" + ProbeInitialDelayTooShort + " : Deployment/testRelease-chart container chart (10 < 30)" +
+				"\n\t\t" + ProbeInitialDelayTooLong + " : Deployment/testRelease-chart container chart livenessProbe (10 > 5)",
+			findings: []Finding{
+				{
+					Resource: "Deployment/testRelease-chart",
+					Field:    "spec.template.spec.containers[0].livenessProbe.failureThreshold",
+					Message:  "failureThreshold is 3, less than the 5 accepted",
+					Severity: ErrorSeverity,
+				},
+				{
+					Resource: "Deployment/testRelease-chart",
+					Field:    "spec.template.spec.containers[0].livenessProbe.initialDelaySeconds",
+					Message:  "initialDelaySeconds is 10 and no startupProbe is declared, so slow starts get the container restarted",
+					Severity: ErrorSeverity,
+				},
+				{
+					Resource: "Deployment/testRelease-chart",
+					Field:    "spec.template.spec.containers[0].livenessProbe.initialDelaySeconds",
+					Message:  "initialDelaySeconds is 10, more than the 5 accepted; declare a startupProbe for slow starts instead",
+					Severity: ErrorSeverity,
+				},
+			},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, tc.values)
+			for k, v := range tc.config {
+				config.Set(k, v)
+			}
+			r, err := ProbeParametersSane(tc.uri, config)
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, tc.findings, r.Findings)
+		})
+	}
+}
+
+func TestRevisionHistoryBounded(t *testing.T) {
+	type testCase struct {
+		description string
+		uri         string
+		values      chartutil.Values
+		config      map[string]interface{}
+		reason      string
+		findings    []Finding
+	}
+
+	revisionHistoryUri := "chart-0.1.0-v3.revision-history.tgz"
+
+	positiveTestCases := []testCase{
+		{description: "chart with a bounded revision history", uri: revisionHistoryUri},
+		{
+			description: "chart with a revision history within the configured maximum",
+			uri:         revisionHistoryUri,
+			values:      chartutil.Values{"revisionHistoryLimit": 20},
+			config:      map[string]interface{}{MaxRevisionHistoryConfigKey: 30},
+		},
+		{
+			description: "chart with an allowlisted Deployment",
+			uri:         "chart-0.1.0-v3.valid.tgz",
+			config:      map[string]interface{}{AllowlistConfigKey: []string{"testRelease-chart"}},
+		},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, tc.values)
+			for k, v := range tc.config {
+				config.Set(k, v)
+			}
+			r, err := RevisionHistoryBounded(tc.uri, config)
+			require.NoError(t, err)
+			require.True(t, r.Ok, r.Reason)
+			require.Equal(t, RevisionHistoriesBounded, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart not declaring a revision history limit",
+			uri:         "chart-0.1.0-v3.valid.tgz",
+			reason:      RevisionHistoryLimitMissing + " : Deployment/testRelease-chart",
+			findings: []Finding{{
+				Resource: "Deployment/testRelease-chart",
+				Field:    "spec.revisionHistoryLimit",
+				Message:  "revisionHistoryLimit is not declared, so old ReplicaSets are kept up to the cluster default",
+				Severity: ErrorSeverity,
+			}},
+		},
+		{
+			description: "chart with a revision history limit above the default maximum",
+			uri:         revisionHistoryUri,
+			values:      chartutil.Values{"revisionHistoryLimit": 50},
+			reason:      RevisionHistoryLimitExcessive + " : Deployment/testRelease-chart (50 > 10)",
+			findings: []Finding{{
+				Resource: "Deployment/testRelease-chart",
+				Field:    "spec.revisionHistoryLimit",
+				Message:  "revisionHistoryLimit is 50, more than the 10 accepted",
+				Severity: ErrorSeverity,
+			}},
+		},
+		{
+			description: "chart with a revision history limit above the configured maximum",
+			uri:         revisionHistoryUri,
+			config:      map[string]interface{}{MaxRevisionHistoryConfigKey: 3},
+			reason:      RevisionHistoryLimitExcessive + " : Deployment/testRelease-chart (5 > 3)",
+			findings: []Finding{{
+				Resource: "Deployment/testRelease-chart",
+				Field:    "spec.revisionHistoryLimit",
+				Message:  "revisionHistoryLimit is 5, more than the 3 accepted",
+				Severity: ErrorSeverity,
+			}},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, tc.values)
+			for k, v := range tc.config {
+				config.Set(k, v)
+			}
+			r, err := RevisionHistoryBounded(tc.uri, config)
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, tc.findings, r.Findings)
+		})
+	}
+}
+
+func TestStatefulsetServicenameValid(t *testing.T) {
+	type testCase struct {
+		description string
+		values      chartutil.Values
+		config      map[string]interface{}
+		reason      string
+		findings    []Finding
+	}
+
+	uri := "chart-0.1.0-v3.statefulset-service.tgz"
+	service := func(values map[string]interface{}) chartutil.Values {
+		return chartutil.Values{"statefulSet": map[string]interface{}{"service": values}}
+	}
+
+	positiveTestCases := []testCase{
+		{description: "chart with a StatefulSet referencing a headless Service"},
+		{
+			description: "chart with a StatefulSet referencing an allowlisted external Service",
+			values:      chartutil.Values{"statefulSet": map[string]interface{}{"serviceName": "external-db"}},
+			config:      map[string]interface{}{AllowlistConfigKey: []string{"external-db"}},
+		},
+	}
+
+	for _, tc := range positiveTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, tc.values)
+			for k, v := range tc.config {
+				config.Set(k, v)
+			}
+			r, err := StatefulsetServicenameValid(uri, config)
+			require.NoError(t, err)
+			require.True(t, r.Ok, r.Reason)
+			require.Equal(t, StatefulSetServicesValid, r.Reason)
+			require.Empty(t, r.Findings)
+		})
+	}
+
+	negativeTestCases := []testCase{
+		{
+			description: "chart with a StatefulSet referencing a missing Service",
+			values:      service(map[string]interface{}{"enabled": false}),
+			reason:      StatefulSetServiceMissing + " : testRelease-chart-db referenced by StatefulSet/testRelease-chart-db",
+			findings: []Finding{{
+				Resource: "StatefulSet/testRelease-chart-db",
+				Field:    "spec.serviceName",
+				Message:  `Service "testRelease-chart-db" is not created by the chart`,
+				Severity: ErrorSeverity,
+			}},
+		},
+		{
+			description: "chart with a StatefulSet referencing a Service that is not headless",
+			values:      service(map[string]interface{}{"headless": false}),
+			reason:      StatefulSetServiceNotHeadless + " : testRelease-chart-db referenced by StatefulSet/testRelease-chart-db",
+			findings: []Finding{{
+				Resource: "StatefulSet/testRelease-chart-db",
+				Field:    "spec.serviceName",
+				Message:  `Service "testRelease-chart-db" does not declare clusterIP: None, so the pods get no stable DNS names`,
+				Severity: ErrorSeverity,
+			}},
+		},
+		{
+			description: "chart with a StatefulSet referencing an external Service not allowlisted",
+			values:      chartutil.Values{"statefulSet": map[string]interface{}{"serviceName": "external-db"}},
+			reason:      StatefulSetServiceMissing + " : external-db referenced by StatefulSet/testRelease-chart-db",
+			findings: []Finding{{
+				Resource: "StatefulSet/testRelease-chart-db",
+				Field:    "spec.serviceName",
+				Message:  `Service "external-db" is not created by the chart`,
+				Severity: ErrorSeverity,
+			}},
+		},
+	}
+
+	for _, tc := range negativeTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, tc.values)
+			for k, v := range tc.config {
+				config.Set(k, v)
+			}
+			r, err := StatefulsetServicenameValid(uri, config)
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			require.Equal(t, tc.reason, r.Reason)
+			require.Equal(t, tc.findings, r.Findings)
+		})
+	}
+}
+
+func TestRevisionHistoryBoundedSuggestions(t *testing.T) {
+	uri := "chart-0.1.0-v3.revision-history.tgz"
+
+	for description, values := range map[string]chartutil.Values{
+		"Should suggest bounding a missing revision history limit":    {"revisionHistoryLimit": nil},
+		"Should suggest lowering an excessive revision history limit": {"revisionHistoryLimit": 50},
+	} {
+		t.Run(description, func(t *testing.T) {
+			config := viper.New()
+			config.Set(ValuesConfigKey, values)
+			config.Set(SuggestFixesConfigKey, true)
+			r, err := RevisionHistoryBounded(uri, config)
+			require.NoError(t, err)
+			require.False(t, r.Ok)
+			require.Len(t, r.Suggestions, 1)
+			require.Equal(t, "Deployment/testRelease-chart", r.Suggestions[0].Resource)
+			require.Equal(t, "spec:\n  revisionHistoryLimit: 10\n", r.Suggestions[0].Patch)
+
+			deployment := applySuggestion(t, uri, config, r.Suggestions[0])
+			require.NotNil(t, deployment.Spec.RevisionHistoryLimit)
+			require.Equal(t, int32(10), *deployment.Spec.RevisionHistoryLimit)
+		})
+	}
+
+	t.Run("Should not suggest fixes unless requested", func(t *testing.T) {
+		config := viper.New()
+		config.Set(ValuesConfigKey, chartutil.Values{"revisionHistoryLimit": 50})
+		r, err := RevisionHistoryBounded(uri, config)
+		require.NoError(t, err)
+		require.False(t, r.Ok)
+		require.Empty(t, r.Suggestions)
+	})
+}
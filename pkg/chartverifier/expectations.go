@@ -0,0 +1,96 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Expectation is the outcome a check is expected to have.
+type Expectation string
+
+const (
+	ExpectPass Expectation = "pass"
+	ExpectFail Expectation = "fail"
+	ExpectSkip Expectation = "skip"
+
+	// outcomeNotRun is the outcome of checks absent from the certificate.
+	outcomeNotRun = "not run"
+)
+
+// ParseExpectations parses the informed expectations, given as check=outcome where the outcome is pass, fail or skip.
+func ParseExpectations(specs []string) (map[string]Expectation, error) {
+	expectations := map[string]Expectation{}
+	for _, spec := range specs {
+		parts := strings.SplitN(spec, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("invalid expectation %q, expected check=outcome", spec)
+		}
+		switch e := Expectation(parts[1]); e {
+		case ExpectPass, ExpectFail, ExpectSkip:
+			expectations[parts[0]] = e
+		default:
+			return nil, errors.Errorf("invalid expectation %q, expected an outcome of %s, %s or %s", spec, ExpectPass, ExpectFail, ExpectSkip)
+		}
+	}
+	return expectations, nil
+}
+
+// ExpectationMismatch describes a check whose outcome differs from the expected one.
+type ExpectationMismatch struct {
+	Check    string
+	Expected Expectation
+	// Actual is the outcome of the check, either pass, fail or skip, or "not run" if the check hasn't been required.
+	Actual string
+}
+
+func (m ExpectationMismatch) String() string {
+	return fmt.Sprintf("%s: expected %s, got %s", m.Check, m.Expected, m.Actual)
+}
+
+// CompareExpectations returns the checks of the given certificate whose outcome differs from the expected one, sorted
+// by check name.
+func CompareExpectations(c Certificate, expectations map[string]Expectation) []ExpectationMismatch {
+	var results checkResultMap
+	if cert, ok := c.(*certificate); ok {
+		results = cert.CheckResultMap
+	}
+
+	var mismatches []ExpectationMismatch
+	for name, expected := range expectations {
+		actual := outcomeNotRun
+		if r, ok := results[name]; ok {
+			switch {
+			case r.Skipped:
+				actual = string(ExpectSkip)
+			case r.Ok:
+				actual = string(ExpectPass)
+			default:
+				actual = string(ExpectFail)
+			}
+		}
+		if actual != string(expected) {
+			mismatches = append(mismatches, ExpectationMismatch{Check: name, Expected: expected, Actual: actual})
+		}
+	}
+	sort.Slice(mismatches, func(i, j int) bool { return mismatches[i].Check < mismatches[j].Check })
+	return mismatches
+}
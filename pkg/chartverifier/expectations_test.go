@@ -0,0 +1,78 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
+)
+
+func TestParseExpectations(t *testing.T) {
+	t.Run("Should parse expectations", func(t *testing.T) {
+		expectations, err := ParseExpectations([]string{"has-readme=pass", "is-helm-v3=fail", "images-are-certified=skip"})
+		require.NoError(t, err)
+		require.Equal(t, map[string]Expectation{
+			"has-readme":           ExpectPass,
+			"is-helm-v3":           ExpectFail,
+			"images-are-certified": ExpectSkip,
+		}, expectations)
+	})
+
+	for _, spec := range []string{"has-readme", "=pass", "has-readme=passed"} {
+		t.Run("Should reject expectation "+spec, func(t *testing.T) {
+			_, err := ParseExpectations([]string{spec})
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestCompareExpectations(t *testing.T) {
+	cert, err := NewCertificateBuilder().
+		SetChartName("chart").
+		SetChartVersion("0.1.0").
+		AddCheckResult("passed-check", checks.MandatoryCheckType, checks.NewResult(true, "passed")).
+		AddCheckResult("failed-check", checks.OptionalCheckType, checks.NewResult(false, "failed")).
+		AddSkippedCheck("skipped-check", checks.MandatoryCheckType, CheckSkippedNoNetwork).
+		Build()
+	require.NoError(t, err)
+
+	t.Run("Should match the actual outcomes", func(t *testing.T) {
+		require.Empty(t, CompareExpectations(cert, map[string]Expectation{
+			"passed-check":  ExpectPass,
+			"failed-check":  ExpectFail,
+			"skipped-check": ExpectSkip,
+		}))
+	})
+
+	t.Run("Should report the checks whose outcome differs", func(t *testing.T) {
+		mismatches := CompareExpectations(cert, map[string]Expectation{
+			"passed-check":  ExpectFail,
+			"failed-check":  ExpectFail,
+			"skipped-check": ExpectPass,
+			"missing-check": ExpectPass,
+		})
+		require.Equal(t, []ExpectationMismatch{
+			{Check: "missing-check", Expected: ExpectPass, Actual: "not run"},
+			{Check: "passed-check", Expected: ExpectFail, Actual: "pass"},
+			{Check: "skipped-check", Expected: ExpectPass, Actual: "skip"},
+		}, mismatches)
+		require.Equal(t, "missing-check: expected pass, got not run", mismatches[0].String())
+	})
+}
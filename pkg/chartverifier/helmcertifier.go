@@ -17,21 +17,157 @@
 package chartverifier
 
 import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
 	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
 	"github.com/spf13/viper"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// FailOn selects which check failures turn the overall certification outcome negative.
+type FailOn string
+
+const (
+	// FailOnOptional turns the outcome negative when any check fails, either mandatory or optional.
+	FailOnOptional FailOn = "optional"
+	// FailOnMandatory turns the outcome negative only when a mandatory check fails; this is the default.
+	FailOnMandatory FailOn = "mandatory"
+	// FailOnNone never turns the outcome negative, so failures are only reported.
+	FailOnNone FailOn = "none"
 )
 
+// ParseFailOn returns the FailOn mode named by s, or an error if s isn't a known mode.
+func ParseFailOn(s string) (FailOn, error) {
+	switch f := FailOn(s); f {
+	case FailOnOptional, FailOnMandatory, FailOnNone:
+		return f, nil
+	default:
+		return "", errors.Errorf("unknown fail-on mode %q, expected one of: optional, mandatory, none", s)
+	}
+}
+
+// blocks reports whether a failure of a check of the given type turns the outcome negative; checks without a type are
+// considered mandatory.
+func (f FailOn) blocks(checkType checks.CheckType) bool {
+	switch f {
+	case FailOnNone:
+		return false
+	case FailOnOptional:
+		return true
+	default:
+		return checkType != checks.OptionalCheckType
+	}
+}
+
+// CheckCompleteFunc is invoked with the name and result of each check as soon as it finishes.
+type CheckCompleteFunc func(name string, result checks.Result)
+
 type CertifierBuilder interface {
 	SetRegistry(registry checks.Registry) CertifierBuilder
 	SetChecks(checks []string) CertifierBuilder
 	SetConfig(config *viper.Viper) CertifierBuilder
 	SetOverrides([]string) CertifierBuilder
+	// SetValueOverrides informs chart values, following the semantics of Helm's --set, the templates are rendered
+	// with by checks inspecting the rendered resources.
+	SetValueOverrides([]string) CertifierBuilder
+	// SetStringValueOverrides is like SetValueOverrides, but values are always kept as strings, following the
+	// semantics of Helm's --set-string; they're applied after the ones informed through SetValueOverrides.
+	SetStringValueOverrides([]string) CertifierBuilder
 	SetToolVersion(string) CertifierBuilder
+	SetOnCheckComplete(CheckCompleteFunc) CertifierBuilder
+	// SetFailOn selects which check failures turn the certificate negative; defaults to FailOnMandatory.
+	SetFailOn(FailOn) CertifierBuilder
+	// SetOpenShiftVersions informs the OpenShift versions the chart is verified against; checks requiring an OpenShift
+	// version are executed once per version, while all other checks are executed once.
+	SetOpenShiftVersions([]string) CertifierBuilder
+	// SetKubeVersion informs the Kubernetes version, e.g. "1.21.0", templates are rendered for and version dependent
+	// checks target, independently of the OpenShift versions; Helm's default version is used when unset.
+	SetKubeVersion(string) CertifierBuilder
+	// SetAnnotations informs arbitrary metadata, such as tracking information, to be included in the certificate under
+	// its own annotations map.
+	SetAnnotations(map[string]string) CertifierBuilder
+	// SetContinueOnCheckError records check errors as failed results, with the error as reason, so the remaining
+	// checks are still executed; by default, the certification is aborted on the first check error.
+	SetContinueOnCheckError(bool) CertifierBuilder
+	// SetCredentials informs the basic authentication credentials and TLS settings used to retrieve charts over HTTP;
+	// when unset, the ones configured for the chart's repository in Helm's repository configuration are used, if any.
+	// The CA file and the TLS verification setting also apply to the HTTPS requests performed by checks.
+	SetCredentials(checks.Credentials) CertifierBuilder
+	// SetHTTPTransport informs the transport performing every HTTP request, both the chart retrieval and the ones
+	// performed by checks, such as a proxying or recording one; Go's default transport, honoring the proxy environment
+	// variables, is used when unset. The informed transport is used as is, so the TLS settings of the credentials don't
+	// apply to it.
+	SetHTTPTransport(http.RoundTripper) CertifierBuilder
+	// SetValuesProfiles informs named sets of chart values; checks rendering the chart's templates are executed once
+	// per profile, with the value overrides applied on top of the profile's values, and fail if any profile fails.
+	SetValuesProfiles(map[string]chartutil.Values) CertifierBuilder
+	// SetNoNetwork informs whether checks requiring network access should be skipped, and checks reaching the
+	// network only when allowed to kept from doing so.
+	SetNoNetwork(bool) CertifierBuilder
+	// SetNoCluster informs whether checks requiring cluster access should be skipped.
+	SetNoCluster(bool) CertifierBuilder
+	// SetMetadataOnly informs whether only the checks rendering no templates and requiring neither network nor
+	// cluster access should be executed, the others being skipped.
+	SetMetadataOnly(bool) CertifierBuilder
+	// SetMaxRequestsPerSecond limits the outbound requests checks perform, such as the ones to image registries and
+	// icon hosts, to the given rate; requests aren't limited when the rate isn't positive, the default.
+	SetMaxRequestsPerSecond(float64) CertifierBuilder
+	// SetFailFast informs whether the verification should stop at the first failed mandatory check; the checks not
+	// executed are reported as skipped.
+	SetFailFast(bool) CertifierBuilder
+	// SetBuildDependencies informs whether the dependencies the chart declares but doesn't bundle should be retrieved
+	// from their repositories before executing checks, as `helm dependency build` does, so checks rendering the chart
+	// see its complete dependency tree. Dependency repositories are reached with the informed credentials' TLS settings.
+	// Dependencies hosted in OCI registries are not retrieved, and are reported in the certificate's metadata.
+	SetBuildDependencies(bool) CertifierBuilder
+	// SetSuggestFixes informs whether checks able to compute a remediation for their findings, such as a patch adding
+	// a missing field, should attach it to their results; checks without such ability report no suggestion.
+	SetSuggestFixes(bool) CertifierBuilder
+	// SetMaxConcurrentVerifications informs the maximum number of verifications VerifyBatch runs at once; defaults to
+	// GOMAXPROCS.
+	SetMaxConcurrentVerifications(int) CertifierBuilder
+	// SetCheckpoint informs the checkpoint the outcome of each completed check is persisted to; checks whose outcome
+	// is already persisted for the same chart, options and tool version aren't executed again.
+	SetCheckpoint(*Checkpoint) CertifierBuilder
+	// SetTotalTimeout informs the maximum duration of each verification, including the chart retrieval; once expired,
+	// in-flight checks are cancelled and the remaining checks reported as timed out, unless no check has completed,
+	// in which case the verification fails. Verifications aren't bounded when not informed.
+	SetTotalTimeout(time.Duration) CertifierBuilder
+	// SetMetricsRegisterer informs the Prometheus registerer the metrics of the verifications performed by the
+	// certifier are registered with; verifications aren't instrumented when no registerer is informed.
+	SetMetricsRegisterer(prometheus.Registerer) CertifierBuilder
+	// SetClock informs the clock the certificate's generation time is read from, so certificates can be reproduced;
+	// defaults to time.Now.
+	SetClock(func() time.Time) CertifierBuilder
 	Build() (Certifier, error)
 }
 
 type Certifier interface {
 	Certify(uri string) (Certificate, error)
+	// CertifyContext is like Certify, but stops as soon as ctx is cancelled, returning ctx.Err(). Checks receive ctx,
+	// so their requests to the network and the cluster are aborted, and keep running in the background until they
+	// return.
+	CertifyContext(ctx context.Context, uri string) (Certificate, error)
+	// Verify is like CertifyContext, but returns the report ReportBuilder would serialize, and has no side effect: it
+	// neither writes to stdout nor to disk, and leaves no chart cached, so it can be called concurrently, each call
+	// returning an independent report; when cancelled, the chart is only released once the checks still running in
+	// the background have returned. Only the checks' own requests, e.g. to image registries or to the cluster
+	// when allowed, reach the outside; Helm may still log warnings about invalid values through the standard logger.
+	Verify(ctx context.Context, uri string) (*Report, error)
+	// VerifyBatch verifies the charts found at the given uris concurrently, as Verify does, emitting the outcome of
+	// each verification as soon as it completes; at most the number of verifications informed to
+	// SetMaxConcurrentVerifications run at once. The channel is closed once every chart has been verified or, when ctx
+	// is cancelled, once the verifications in progress have returned; no further verification is started then, and
+	// the outcomes of the cancelled ones may not be emitted. The channel must be drained unless ctx is cancelled.
+	VerifyBatch(ctx context.Context, uris []string) <-chan BatchResult
+	// ImageReferences returns the images referenced by the chart found at uri, sorted, rendering it as checks do: for
+	// the informed Kubernetes version, with the value overrides and, when informed, once per values profile.
+	ImageReferences(uri string) ([]string, error)
 }
 
 type Certificate interface {
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+// LedgerEntry is the summary of a single verification appended to a ledger, an append-only log of verified charts.
+type LedgerEntry struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Digest identifies the chart's contents regardless of where the chart has been retrieved from.
+	Digest string `json:"digest"`
+	Ok     bool   `json:"ok"`
+	// Summary accounts for all checks executed within the verification.
+	Summary summary `json:"summary"`
+	// Timestamp is the time the certificate has been generated, formatted as RFC 3339 in UTC.
+	Timestamp string `json:"timestamp"`
+}
+
+// NewLedgerEntry summarizes the verification of chrt, whose outcome is informed by c.
+func NewLedgerEntry(c Certificate, chrt *chart.Chart) (LedgerEntry, error) {
+	cert, ok := c.(*certificate)
+	if !ok {
+		return LedgerEntry{}, fmt.Errorf("unsupported certificate type %T", c)
+	}
+
+	return LedgerEntry{
+		Name:      chrt.Name(),
+		Version:   chrt.Metadata.Version,
+		Digest:    chartDigest(chrt),
+		Ok:        cert.IsOk(),
+		Summary:   cert.Summary,
+		Timestamp: cert.Metadata.RunMetadata.GeneratedAt,
+	}, nil
+}
+
+// chartDigest returns the SHA-256 digest of the files the chart has been loaded from, sorted by name, so archives and
+// directories with the same contents share a digest.
+func chartDigest(chrt *chart.Chart) string {
+	files := make([]*chart.File, len(chrt.Raw))
+	copy(files, chrt.Raw)
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	h := sha256.New()
+	for _, f := range files {
+		h.Write([]byte(f.Name))
+		h.Write([]byte{0})
+		h.Write(f.Data)
+		h.Write([]byte{0})
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// AppendToLedger appends entry as a single JSON line to the ledger at path, creating it if needed. The ledger is
+// locked while writing, so verifications running concurrently can share it.
+func AppendToLedger(path string, entry LedgerEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := lockFile(f); err != nil {
+		return fmt.Errorf("failed locking ledger %s: %v", path, err)
+	}
+	defer unlockFile(f)
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
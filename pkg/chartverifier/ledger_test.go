@@ -0,0 +1,159 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"helm.sh/helm/v3/pkg/chartutil"
+
+	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
+)
+
+// readLedger returns the entries of the ledger at path, failing unless every line is a valid entry.
+func readLedger(t *testing.T, path string) []LedgerEntry {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var entries []LedgerEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry LedgerEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry), "corrupted ledger line %q", scanner.Text())
+		entries = append(entries, entry)
+	}
+	require.NoError(t, scanner.Err())
+	return entries
+}
+
+func TestNewLedgerEntry(t *testing.T) {
+	uri := "./checks/chart-0.1.0-v3.valid.tgz"
+
+	c, err := NewCertifierBuilder().
+		SetChecks([]string{"is-helm-v3", "has-readme"}).
+		SetClock(func() time.Time { return time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC) }).
+		Build()
+	require.NoError(t, err)
+	cert, err := c.Certify(uri)
+	require.NoError(t, err)
+
+	chrt, _, err := checks.LoadChartFromURI(uri)
+	require.NoError(t, err)
+
+	entry, err := NewLedgerEntry(cert, chrt)
+	require.NoError(t, err)
+	require.Equal(t, "chart", entry.Name)
+	require.Equal(t, "0.1.0-v3.valid", entry.Version)
+	require.Regexp(t, "^sha256:[0-9a-f]{64}$", entry.Digest)
+	require.True(t, entry.Ok)
+	require.Equal(t, summary{Passed: 2}, entry.Summary)
+	require.Equal(t, "2021-03-04T05:06:07Z", entry.Timestamp)
+
+	t.Run("Chart directory should share the digest of its archive", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, chartutil.ExpandFile(dir, uri))
+		expanded, _, err := checks.LoadChartFromURI(filepath.Join(dir, "chart"))
+		require.NoError(t, err)
+		require.Equal(t, entry.Digest, chartDigest(expanded))
+	})
+
+	t.Run("Charts with different contents should have different digests", func(t *testing.T) {
+		other, _, err := checks.LoadChartFromURI("./checks/chart-0.1.0-v3.valid.notest.tgz")
+		require.NoError(t, err)
+		require.NotEqual(t, entry.Digest, chartDigest(other))
+	})
+}
+
+func TestAppendToLedger(t *testing.T) {
+	newEntry := func(version string) LedgerEntry {
+		return LedgerEntry{
+			Name:      "chart",
+			Version:   version,
+			Digest:    "sha256:0123456789abcdef",
+			Ok:        true,
+			Summary:   summary{Passed: 3, Failed: 1},
+			Timestamp: "2021-03-04T05:06:07Z",
+		}
+	}
+
+	t.Run("Should create the ledger and append one line per entry", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ledger.jsonl")
+
+		require.NoError(t, AppendToLedger(path, newEntry("0.1.0")))
+		require.NoError(t, AppendToLedger(path, newEntry("0.2.0")))
+
+		b, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t,
+			`{"name":"chart","version":"0.1.0","digest":"sha256:0123456789abcdef","ok":true,"summary":{"passed":3,"failed":1},"timestamp":"2021-03-04T05:06:07Z"}`+"\n"+
+				`{"name":"chart","version":"0.2.0","digest":"sha256:0123456789abcdef","ok":true,"summary":{"passed":3,"failed":1},"timestamp":"2021-03-04T05:06:07Z"}`+"\n",
+			string(b))
+	})
+
+	t.Run("Should keep previous contents of an existing ledger", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ledger.jsonl")
+		first := newEntry("0.1.0")
+		b, err := json.Marshal(first)
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(path, append(b, '\n'), 0644))
+
+		require.NoError(t, AppendToLedger(path, newEntry("0.2.0")))
+		require.Equal(t, []LedgerEntry{first, newEntry("0.2.0")}, readLedger(t, path))
+	})
+
+	t.Run("Should not corrupt the ledger when appending concurrently", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ledger.jsonl")
+
+		const writers = 50
+		errs := make(chan error, writers)
+		var wg sync.WaitGroup
+		for i := 0; i < writers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				errs <- AppendToLedger(path, newEntry("0.1."+strconv.Itoa(i)))
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			require.NoError(t, err)
+		}
+
+		entries := readLedger(t, path)
+		require.Len(t, entries, writers)
+		seen := map[string]bool{}
+		for _, entry := range entries {
+			seen[entry.Version] = true
+		}
+		require.Len(t, seen, writers)
+	})
+
+	t.Run("Should fail when the ledger can't be opened", func(t *testing.T) {
+		require.Error(t, AppendToLedger(filepath.Join(t.TempDir(), "missing", "ledger.jsonl"), newEntry("0.1.0")))
+	})
+}
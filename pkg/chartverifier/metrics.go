@@ -0,0 +1,158 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
+)
+
+const metricsNamespace = "chart_verifier"
+
+// Outcomes informed in the outcome label of the verification and check metrics.
+const (
+	passedOutcome  = "passed"
+	failedOutcome  = "failed"
+	skippedOutcome = "skipped"
+	errorOutcome   = "error"
+)
+
+// durationBuckets spans from fast metadata checks to slow networked and cluster checks.
+var durationBuckets = prometheus.ExponentialBuckets(0.01, 4, 8)
+
+// verifierMetrics instruments the verifications performed by a certifier; a nil verifierMetrics records nothing, so
+// certifiers built without a registerer aren't instrumented.
+type verifierMetrics struct {
+	verifications    *prometheus.CounterVec
+	checkResults     *prometheus.CounterVec
+	checkDurations   *prometheus.HistogramVec
+	downloadDuration prometheus.Histogram
+}
+
+// newVerifierMetrics registers the verifier metrics with registerer; metrics already registered, e.g. by another
+// certifier sharing the registerer, are reused.
+func newVerifierMetrics(registerer prometheus.Registerer) (*verifierMetrics, error) {
+	m := &verifierMetrics{
+		verifications: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "verifications_total",
+			Help:      "Number of chart verifications performed, by outcome: passed, failed or error.",
+		}, []string{"outcome"}),
+		checkResults: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "check_results_total",
+			Help:      "Number of check results, by check and outcome: passed, failed, skipped or error.",
+		}, []string{"check", "outcome"}),
+		checkDurations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "check_duration_seconds",
+			Help:      "Time taken executing checks, by check.",
+			Buckets:   durationBuckets,
+		}, []string{"check"}),
+		downloadDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "chart_download_duration_seconds",
+			Help:      "Time taken retrieving and loading the verified charts.",
+			Buckets:   durationBuckets,
+		}),
+	}
+
+	verifications, err := registerCollector(registerer, m.verifications)
+	if err != nil {
+		return nil, err
+	}
+	checkResults, err := registerCollector(registerer, m.checkResults)
+	if err != nil {
+		return nil, err
+	}
+	checkDurations, err := registerCollector(registerer, m.checkDurations)
+	if err != nil {
+		return nil, err
+	}
+	downloadDuration, err := registerCollector(registerer, m.downloadDuration)
+	if err != nil {
+		return nil, err
+	}
+	m.verifications = verifications.(*prometheus.CounterVec)
+	m.checkResults = checkResults.(*prometheus.CounterVec)
+	m.checkDurations = checkDurations.(*prometheus.HistogramVec)
+	m.downloadDuration = downloadDuration.(prometheus.Histogram)
+	return m, nil
+}
+
+// registerCollector registers c with registerer, returning the collector already registered in its stead if any.
+func registerCollector(registerer prometheus.Registerer, c prometheus.Collector) (prometheus.Collector, error) {
+	if err := registerer.Register(c); err != nil {
+		var already prometheus.AlreadyRegisteredError
+		if errors.As(err, &already) && reflect.TypeOf(already.ExistingCollector) == reflect.TypeOf(c) {
+			return already.ExistingCollector, nil
+		}
+		return nil, err
+	}
+	return c, nil
+}
+
+// observeDownload records the time taken retrieving a chart since start.
+func (m *verifierMetrics) observeDownload(start time.Time) {
+	if m == nil {
+		return
+	}
+	m.downloadDuration.Observe(time.Since(start).Seconds())
+}
+
+// observeVerification records the outcome of a verification.
+func (m *verifierMetrics) observeVerification(cert Certificate, err error) {
+	if m == nil {
+		return
+	}
+	outcome := failedOutcome
+	if err != nil {
+		outcome = errorOutcome
+	} else if cert.IsOk() {
+		outcome = passedOutcome
+	}
+	m.verifications.WithLabelValues(outcome).Inc()
+}
+
+// observeSkippedCheck records the given check as skipped.
+func (m *verifierMetrics) observeSkippedCheck(name string) {
+	if m == nil {
+		return
+	}
+	m.checkResults.WithLabelValues(name, skippedOutcome).Inc()
+}
+
+// observeCheck records the result of the given check, executed since start; checks which have returned an error
+// are recorded as such, whatever the result.
+func (m *verifierMetrics) observeCheck(name string, r checks.Result, errored bool, start time.Time) {
+	if m == nil {
+		return
+	}
+	outcome := failedOutcome
+	if errored {
+		outcome = errorOutcome
+	} else if r.Ok {
+		outcome = passedOutcome
+	}
+	m.checkResults.WithLabelValues(name, outcome).Inc()
+	m.checkDurations.WithLabelValues(name).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
+)
+
+func TestCertifier_Metrics(t *testing.T) {
+	uri := "./checks/chart-0.1.0-v3.valid.tgz"
+
+	registry := checks.NewRegistry().
+		Add("passing-check", func(uri string, _ *viper.Viper) (checks.Result, error) {
+			return checks.NewResult(true, "passed"), nil
+		}).
+		Add("failing-check", func(uri string, _ *viper.Viper) (checks.Result, error) {
+			return checks.NewResult(false, "failed"), nil
+		}).
+		Add("erroring-check", func(uri string, _ *viper.Viper) (checks.Result, error) {
+			return checks.Result{}, errors.New("unexpected")
+		}).
+		AddCheck("network-check", checks.Check{
+			Func: func(uri string, _ *viper.Viper) (checks.Result, error) {
+				return checks.NewResult(true, "reached"), nil
+			},
+			Type:            checks.MandatoryCheckType,
+			RequiresNetwork: true,
+		})
+
+	newCertifier := func(t *testing.T, registerer prometheus.Registerer, requiredChecks ...string) Certifier {
+		c, err := NewCertifierBuilder().
+			SetRegistry(registry).
+			SetChecks(requiredChecks).
+			SetNoNetwork(true).
+			SetContinueOnCheckError(true).
+			SetMetricsRegisterer(registerer).
+			Build()
+		require.NoError(t, err)
+		return c
+	}
+
+	t.Run("Should register and increment the metrics of each verification", func(t *testing.T) {
+		reg := prometheus.NewPedanticRegistry()
+		c := newCertifier(t, reg, "passing-check", "failing-check", "erroring-check", "network-check")
+
+		_, err := c.Certify(uri)
+		require.NoError(t, err)
+		_, err = c.Certify(uri)
+		require.NoError(t, err)
+
+		m := c.(*certifier).metrics
+		require.Equal(t, 2.0, promtestutil.ToFloat64(m.verifications.WithLabelValues(failedOutcome)))
+		require.Equal(t, 2.0, promtestutil.ToFloat64(m.checkResults.WithLabelValues("passing-check", passedOutcome)))
+		require.Equal(t, 2.0, promtestutil.ToFloat64(m.checkResults.WithLabelValues("failing-check", failedOutcome)))
+		require.Equal(t, 2.0, promtestutil.ToFloat64(m.checkResults.WithLabelValues("erroring-check", errorOutcome)))
+		require.Equal(t, 2.0, promtestutil.ToFloat64(m.checkResults.WithLabelValues("network-check", skippedOutcome)))
+
+		families, err := reg.Gather()
+		require.NoError(t, err)
+		samples := map[string]uint64{}
+		for _, family := range families {
+			for _, metric := range family.GetMetric() {
+				if h := metric.GetHistogram(); h != nil {
+					name := family.GetName()
+					for _, label := range metric.GetLabel() {
+						name += "/" + label.GetValue()
+					}
+					samples[name] = h.GetSampleCount()
+				}
+			}
+		}
+		require.Equal(t, map[string]uint64{
+			"chart_verifier_chart_download_duration_seconds":       2,
+			"chart_verifier_check_duration_seconds/erroring-check": 2,
+			"chart_verifier_check_duration_seconds/failing-check":  2,
+			"chart_verifier_check_duration_seconds/passing-check":  2,
+		}, samples)
+	})
+
+	t.Run("Should record verifications which have failed with an error", func(t *testing.T) {
+		reg := prometheus.NewPedanticRegistry()
+		c := newCertifier(t, reg, "passing-check")
+
+		_, err := c.Certify("./checks/chart-0.0.0-missing.tgz")
+		require.Error(t, err)
+		_, err = c.Certify(uri)
+		require.NoError(t, err)
+
+		m := c.(*certifier).metrics
+		require.Equal(t, 1.0, promtestutil.ToFloat64(m.verifications.WithLabelValues(errorOutcome)))
+		require.Equal(t, 1.0, promtestutil.ToFloat64(m.verifications.WithLabelValues(passedOutcome)))
+	})
+
+	t.Run("Should share the metrics among certifiers using the same registerer", func(t *testing.T) {
+		reg := prometheus.NewPedanticRegistry()
+		first := newCertifier(t, reg, "passing-check")
+		second := newCertifier(t, reg, "passing-check")
+
+		_, err := first.Certify(uri)
+		require.NoError(t, err)
+		_, err = second.Certify(uri)
+		require.NoError(t, err)
+
+		require.Equal(t, 2.0, promtestutil.ToFloat64(second.(*certifier).metrics.verifications.WithLabelValues(passedOutcome)))
+	})
+
+	t.Run("Should not instrument verifications without a registerer", func(t *testing.T) {
+		c := newCertifier(t, nil, "passing-check")
+		_, err := c.Certify(uri)
+		require.NoError(t, err)
+		require.Nil(t, c.(*certifier).metrics)
+	})
+}
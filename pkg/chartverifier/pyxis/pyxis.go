@@ -58,6 +58,11 @@ type RepositoryTag struct {
 }
 
 func GetImageRegistries(repository string) ([]string, error) {
+	return GetImageRegistriesWithClient(&http.Client{}, repository)
+}
+
+// GetImageRegistriesWithClient is like GetImageRegistries, but queries Pyxis with the given client.
+func GetImageRegistriesWithClient(client *http.Client, repository string) ([]string, error) {
 	var err error
 	var registries []string
 
@@ -66,7 +71,6 @@ func GetImageRegistries(repository string) ([]string, error) {
 	queryString.Add("filter", fmt.Sprintf("repository==%s", repository))
 	req.URL.RawQuery = queryString.Encode()
 	req.Header.Set("X-API-KEY", "RedHatChartVerifier")
-	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
 		err = errors.New(fmt.Sprintf("Error getting repository %s : %v\n", repository, err))
@@ -93,6 +97,11 @@ func GetImageRegistries(repository string) ([]string, error) {
 }
 
 func IsImageInRegistry(repository string, version string, registry string) (bool, error) {
+	return IsImageInRegistryWithClient(&http.Client{}, repository, version, registry)
+}
+
+// IsImageInRegistryWithClient is like IsImageInRegistry, but queries Pyxis with the given client.
+func IsImageInRegistryWithClient(client *http.Client, repository string, version string, registry string) (bool, error) {
 
 	var err error
 	found := false
@@ -103,7 +112,6 @@ func IsImageInRegistry(repository string, version string, registry string) (bool
 	queryString.Add("filter", fmt.Sprintf("repositories=em=(repository==%s;registry==%s)", repository, registry))
 	req.URL.RawQuery = queryString.Encode()
 	req.Header.Set("X-API-KEY", "RedHatChartVerifier")
-	client := &http.Client{}
 	resp, err := client.Do(req)
 
 	if err == nil {
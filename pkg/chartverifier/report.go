@@ -0,0 +1,106 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+// Report is a verification report as serialized by ReportBuilder: the certificate, followed by the metadata of the
+// verified chart.
+type Report struct {
+	certificate   `yaml:",inline"`
+	ChartMetadata *chart.Metadata `json:"chart-metadata,omitempty" yaml:"chart-metadata,omitempty"`
+}
+
+// InvalidReportErr is returned when a serialized report can't be loaded.
+type InvalidReportErr struct {
+	Reason string
+}
+
+func (e InvalidReportErr) Error() string {
+	return "invalid report: " + e.Reason
+}
+
+// LoadReport deserializes a report written either as JSON or YAML, detected from its content, and validates its
+// required metadata fields and schema version.
+func LoadReport(r io.Reader) (*Report, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return nil, InvalidReportErr{Reason: "report is empty"}
+	}
+
+	report := &Report{}
+	if b[0] == '{' {
+		if err := json.Unmarshal(b, report); err != nil {
+			return nil, InvalidReportErr{Reason: fmt.Sprintf("failed parsing report as JSON: %v", err)}
+		}
+	} else {
+		if err := yaml.Unmarshal(b, report); err != nil {
+			return nil, InvalidReportErr{Reason: fmt.Sprintf("failed parsing report as YAML: %v", err)}
+		}
+	}
+
+	if err := report.validate(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// validate checks the report contains the metadata identifying the verification.
+func (r *Report) validate() error {
+	if r.Metadata == nil {
+		return InvalidReportErr{Reason: `required field "metadata" is missing`}
+	}
+
+	if err := checkSchemaVersion(r.Metadata.SchemaVersion); err != nil {
+		return err
+	}
+
+	required := []struct {
+		field string
+		value string
+	}{
+		{"metadata.tool.verifier-version", r.Metadata.RunMetadata.Version},
+		{"metadata.tool.chart-uri", r.Metadata.RunMetadata.ChartUri},
+		{"metadata.chart.name", r.Metadata.ChartMetadata.Name},
+	}
+	for _, f := range required {
+		if f.value == "" {
+			return InvalidReportErr{Reason: fmt.Sprintf("required field %q is missing", f.field)}
+		}
+	}
+
+	if r.CheckResultMap == nil {
+		return InvalidReportErr{Reason: `required field "results" is missing`}
+	}
+
+	return nil
+}
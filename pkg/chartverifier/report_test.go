@@ -0,0 +1,102 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
+)
+
+func TestLoadReport(t *testing.T) {
+
+	chartUri := "./checks/chart-0.1.0-v3.valid.tgz"
+
+	cert, err := NewCertificateBuilder().
+		SetChartName("chart").
+		SetChartVersion("1.16.0").
+		SetChartUri(chartUri).
+		SetToolVersion("1.0.0").
+		SetAnnotations(map[string]string{"ticket": "CERT-123"}).
+		AddCheckResult("passed-check", checks.MandatoryCheckType, checks.NewResult(true, "passed")).
+		AddCheckResult("failed-check", checks.OptionalCheckType, checks.NewResult(false, "failed")).
+		Build()
+	require.NoError(t, err)
+
+	builder := NewReportBuilder().SetCertificate(&cert).SetChartUri(chartUri)
+
+	t.Run("Should round trip a YAML report", func(t *testing.T) {
+		require.NoError(t, builder.Generate())
+		defer os.RemoveAll("reports")
+
+		f, err := os.Open(filepath.Join("reports", filepath.Base(chartUri), "verifier.report.yaml"))
+		require.NoError(t, err)
+		defer f.Close()
+
+		report, err := LoadReport(f)
+		require.NoError(t, err)
+		require.Equal(t, *cert.(*certificate), report.certificate)
+		require.Equal(t, "chart", report.ChartMetadata.Name)
+		require.Equal(t, "0.1.0-v3.valid", report.ChartMetadata.Version)
+		require.Equal(t, cert.IsOk(), report.IsOk())
+	})
+
+	t.Run("Should round trip a JSON report", func(t *testing.T) {
+		b, err := builder.(*reportBuilder).jsonReport()
+		require.NoError(t, err)
+
+		report, err := LoadReport(bytes.NewReader(b))
+		require.NoError(t, err)
+		require.Equal(t, *cert.(*certificate), report.certificate)
+		require.Equal(t, "chart", report.ChartMetadata.Name)
+		require.Equal(t, "v2", report.ChartMetadata.APIVersion)
+	})
+
+	validYaml := "metadata:\n  tool:\n    verifier-version: 1.0.0\n    chart-uri: chart.tgz\n  chart:\n    name: chart\nresults: {}\n"
+
+	t.Run("Should load a minimal report", func(t *testing.T) {
+		report, err := LoadReport(strings.NewReader(validYaml))
+		require.NoError(t, err)
+		require.Empty(t, report.CheckResultMap)
+	})
+
+	for description, tc := range map[string]struct {
+		input  string
+		reason string
+	}{
+		"empty input":              {input: " \n", reason: "invalid report: report is empty"},
+		"corrupt JSON":             {input: `{"ok": tru`, reason: "invalid report: failed parsing report as JSON"},
+		"corrupt YAML":             {input: "ok: [true\n", reason: "invalid report: failed parsing report as YAML"},
+		"missing metadata":         {input: "ok: true\nresults: {}\n", reason: `invalid report: required field "metadata" is missing`},
+		"missing verifier version": {input: strings.Replace(validYaml, "verifier-version: 1.0.0", "verifier-version: \"\"", 1), reason: `invalid report: required field "metadata.tool.verifier-version" is missing`},
+		"missing chart name":       {input: strings.Replace(validYaml, "name: chart", "name: \"\"", 1), reason: `invalid report: required field "metadata.chart.name" is missing`},
+		"missing results":          {input: strings.Replace(validYaml, "results: {}\n", "", 1), reason: `invalid report: required field "results" is missing`},
+		"newer schema version":     {input: strings.Replace(validYaml, "metadata:\n", "metadata:\n  schema-version: \"99.0\"\n", 1), reason: `report schema version "99.0" is not supported`},
+	} {
+		t.Run("Should fail loading a report with "+description, func(t *testing.T) {
+			_, err := LoadReport(strings.NewReader(tc.input))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.reason)
+		})
+	}
+}
@@ -1,24 +1,57 @@
 package chartverifier
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
 	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
 	"gopkg.in/yaml.v3"
 	"helm.sh/helm/v3/pkg/chart"
-	"os"
-	"path/filepath"
+)
+
+const (
+	// NotifyOutcomeHeader is the webhook request header informing whether the chart passed all checks.
+	NotifyOutcomeHeader = "X-Chart-Verifier-Outcome"
+	// NotifyPassedHeader is the webhook request header informing the number of passed checks.
+	NotifyPassedHeader = "X-Chart-Verifier-Passed"
+	// NotifyFailedHeader is the webhook request header informing the number of failed checks.
+	NotifyFailedHeader = "X-Chart-Verifier-Failed"
+
+	NotifyOutcomePassed = "passed"
+	NotifyOutcomeFailed = "failed"
+)
+
+var (
+	// notifyAttempts is the maximum number of times the report is sent to the webhook.
+	notifyAttempts = 3
+	// notifyRetryInterval is the amount of time to wait before each new attempt, doubled after every attempt.
+	notifyRetryInterval = time.Second
+	// notifyTimeout is the maximum amount of time to wait for each webhook request.
+	notifyTimeout = 10 * time.Second
 )
 
 type ReportBuilder interface {
 	SetCertificate(*Certificate) ReportBuilder
 	SetChartUri(string) ReportBuilder
 	AddChartYaml(*chart.File) ReportBuilder
+	// SetRedactor masks hostnames in both the certificate and the chart metadata included in the report.
+	SetRedactor(*HostRedactor) ReportBuilder
 	Generate() error
+	// Notify posts the report as JSON to the given webhook url, retrying on transient failures.
+	Notify(url string) error
 }
 
 type reportBuilder struct {
 	Certificate *Certificate
 	ChartUri    string
 	ChartYaml   *chart.File
+	Redactor    *HostRedactor
 }
 
 type helmChartMetadata struct {
@@ -50,6 +83,27 @@ func (r *reportBuilder) AddChartYaml(file *chart.File) ReportBuilder {
 	return r
 }
 
+func (r *reportBuilder) SetRedactor(redactor *HostRedactor) ReportBuilder {
+	r.Redactor = redactor
+	return r
+}
+
+// certificate returns the certificate to be reported, redacted when a redactor has been set.
+func (r *reportBuilder) certificate() Certificate {
+	if r.Redactor == nil {
+		return *r.Certificate
+	}
+	return r.Redactor.Redact(*r.Certificate)
+}
+
+// chartMetadata returns the metadata of the given chart to be reported, redacted when a redactor has been set.
+func (r *reportBuilder) chartMetadata(c *chart.Chart) *chart.Metadata {
+	if r.Redactor == nil {
+		return c.Metadata
+	}
+	return r.Redactor.RedactChartMetadata(c.Metadata)
+}
+
 func (r *reportBuilder) Generate() error {
 
 	var err error
@@ -75,7 +129,7 @@ func (r *reportBuilder) Generate() error {
 		return err
 	}
 
-	b, err := yaml.Marshal(r.Certificate)
+	b, err := yaml.Marshal(r.certificate())
 	if err != nil {
 		return err
 	}
@@ -84,7 +138,7 @@ func (r *reportBuilder) Generate() error {
 
 	c, _, err := checks.LoadChartFromURI(r.ChartUri)
 
-	b, err = yaml.Marshal(newHelmChartMetadata(c.Metadata))
+	b, err = yaml.Marshal(newHelmChartMetadata(r.chartMetadata(c)))
 	if err != nil {
 		return err
 	}
@@ -94,3 +148,100 @@ func (r *reportBuilder) Generate() error {
 
 	return err
 }
+
+// notifyErr is returned when the report couldn't be delivered to the webhook; transient errors can be retried.
+type notifyErr struct {
+	err       error
+	transient bool
+}
+
+func (e notifyErr) Error() string {
+	return "notification error: " + e.err.Error()
+}
+
+func (r *reportBuilder) Notify(url string) error {
+	cert, ok := (*r.Certificate).(*certificate)
+	if !ok {
+		return fmt.Errorf("unsupported certificate type %T", *r.Certificate)
+	}
+
+	payload, err := r.jsonReport()
+	if err != nil {
+		return err
+	}
+
+	outcome := NotifyOutcomeFailed
+	if cert.IsOk() {
+		outcome = NotifyOutcomePassed
+	}
+
+	headers := http.Header{}
+	headers.Set("Content-Type", "application/json")
+	headers.Set(NotifyOutcomeHeader, outcome)
+	headers.Set(NotifyPassedHeader, strconv.Itoa(cert.Summary.Passed))
+	headers.Set(NotifyFailedHeader, strconv.Itoa(cert.Summary.Failed))
+
+	return deliverReport(url, headers, payload)
+}
+
+// deliverReport posts payload to url, retrying on transient failures.
+func deliverReport(url string, headers http.Header, payload []byte) error {
+	interval := notifyRetryInterval
+	for attempt := 1; ; attempt++ {
+		err := postReport(url, headers, payload)
+		if err == nil {
+			return nil
+		}
+		if nErr, ok := err.(notifyErr); !ok || !nErr.transient || attempt == notifyAttempts {
+			return err
+		}
+		time.Sleep(interval)
+		interval *= 2
+	}
+}
+
+// jsonReport serializes the report, which contains the certificate and the chart metadata, as JSON.
+func (r *reportBuilder) jsonReport() ([]byte, error) {
+	b, err := json.Marshal(r.certificate())
+	if err != nil {
+		return nil, err
+	}
+
+	report := map[string]interface{}{}
+	if err = json.Unmarshal(b, &report); err != nil {
+		return nil, err
+	}
+
+	c, _, err := checks.LoadChartFromURI(r.ChartUri)
+	if err != nil {
+		return nil, err
+	}
+	report["chart-metadata"] = r.chartMetadata(c)
+
+	return json.Marshal(report)
+}
+
+// postReport sends payload to url; network errors, server errors and throttling responses are considered transient.
+func postReport(url string, headers http.Header, payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header = headers
+
+	client := http.Client{Timeout: notifyTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return notifyErr{err: err, transient: true}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	return notifyErr{
+		err:       fmt.Errorf("webhook responded with status %q", resp.Status),
+		transient: resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests,
+	}
+}
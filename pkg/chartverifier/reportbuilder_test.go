@@ -0,0 +1,137 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
+)
+
+// webhookReceiver records the requests it receives, answering with the informed status codes in order; the last
+// status code is used for all remaining requests.
+type webhookReceiver struct {
+	mutex    sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (w *webhookReceiver) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	body, _ := ioutil.ReadAll(req.Body)
+	w.requests = append(w.requests, req)
+	w.bodies = append(w.bodies, body)
+	status := w.statuses[len(w.statuses)-1]
+	if len(w.requests) <= len(w.statuses) {
+		status = w.statuses[len(w.requests)-1]
+	}
+	rw.WriteHeader(status)
+}
+
+func TestReportBuilder_Notify(t *testing.T) {
+
+	validChartUri := "./checks/chart-0.1.0-v3.valid.tgz"
+
+	originalInterval := notifyRetryInterval
+	notifyRetryInterval = time.Millisecond
+	t.Cleanup(func() { notifyRetryInterval = originalInterval })
+
+	c, err := NewCertifierBuilder().
+		SetRegistry(checks.NewRegistry().
+			Add("positive-check", func(uri string, _ *viper.Viper) (checks.Result, error) {
+				return checks.NewResult(true, "positive"), nil
+			}).
+			Add("negative-check", func(uri string, _ *viper.Viper) (checks.Result, error) {
+				return checks.NewResult(false, "negative"), nil
+			})).
+		Build()
+	require.NoError(t, err)
+
+	cert, err := c.Certify(validChartUri)
+	require.NoError(t, err)
+
+	notify := func(t *testing.T, statuses ...int) (*webhookReceiver, error) {
+		receiver := &webhookReceiver{statuses: statuses}
+		server := httptest.NewServer(receiver)
+		t.Cleanup(server.Close)
+		err := NewReportBuilder().SetCertificate(&cert).SetChartUri(validChartUri).Notify(server.URL)
+		return receiver, err
+	}
+
+	t.Run("Should post the report with its summary and outcome", func(t *testing.T) {
+		receiver, err := notify(t, http.StatusOK)
+		require.NoError(t, err)
+		require.Len(t, receiver.requests, 1)
+
+		req := receiver.requests[0]
+		require.Equal(t, http.MethodPost, req.Method)
+		require.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		require.Equal(t, NotifyOutcomeFailed, req.Header.Get(NotifyOutcomeHeader))
+		require.Equal(t, "1", req.Header.Get(NotifyPassedHeader))
+		require.Equal(t, "1", req.Header.Get(NotifyFailedHeader))
+
+		payload := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(receiver.bodies[0], &payload))
+		require.Equal(t, false, payload["ok"])
+		require.Equal(t, map[string]interface{}{"passed": float64(1), "failed": float64(1)}, payload["summary"])
+		require.Equal(t, map[string]interface{}{
+			"positive-check": map[string]interface{}{"ok": true, "reason": "positive", "type": "mandatory"},
+			"negative-check": map[string]interface{}{"ok": false, "reason": "negative", "type": "mandatory"},
+		}, payload["results"])
+		metadata, ok := payload["chart-metadata"].(map[string]interface{})
+		require.True(t, ok)
+		require.Equal(t, "chart", metadata["name"])
+	})
+
+	t.Run("Should retry on transient failures", func(t *testing.T) {
+		receiver, err := notify(t, http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK)
+		require.NoError(t, err)
+		require.Len(t, receiver.requests, 3)
+		require.Equal(t, receiver.bodies[0], receiver.bodies[2])
+	})
+
+	t.Run("Should give up after the maximum number of attempts", func(t *testing.T) {
+		receiver, err := notify(t, http.StatusInternalServerError)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "500 Internal Server Error")
+		require.Len(t, receiver.requests, notifyAttempts)
+	})
+
+	t.Run("Should not retry on client errors", func(t *testing.T) {
+		receiver, err := notify(t, http.StatusBadRequest)
+		require.Error(t, err)
+		require.Len(t, receiver.requests, 1)
+	})
+
+	t.Run("Should fail when the webhook is unreachable", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+		err := NewReportBuilder().SetCertificate(&cert).SetChartUri(validChartUri).Notify(server.URL)
+		require.Error(t, err)
+	})
+}
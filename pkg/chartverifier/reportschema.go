@@ -0,0 +1,182 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// ReportSchema is the canonical JSON schema of the reports produced by this package, for the schema version
+// ReportSchemaVersion; consumers depend on it, so it must be updated along with the report whenever the report changes.
+const ReportSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "chart-verifier report",
+  "type": "object",
+  "required": ["ok", "metadata", "summary", "results"],
+  "additionalProperties": false,
+  "properties": {
+    "ok": {"type": "boolean"},
+    "metadata": {
+      "type": "object",
+      "required": ["schema-version", "tool", "chart"],
+      "additionalProperties": false,
+      "properties": {
+        "schema-version": {"type": "string", "pattern": "^[0-9]+\\.[0-9]+(\\.[0-9]+)?$"},
+        "tool": {
+          "type": "object",
+          "required": ["verifier-version", "chart-uri"],
+          "additionalProperties": false,
+          "properties": {
+            "verifier-version": {"type": "string"},
+            "chart-uri": {"type": "string"},
+            "generated-at": {"type": "string", "format": "date-time"},
+            "certified-openshift-versions": {"type": "array", "items": {"type": "string"}},
+            "kube-version": {"type": "string"},
+            "skipped-dependencies": {"type": "array", "items": {"type": "string"}}
+          }
+        },
+        "chart": {
+          "type": "object",
+          "required": ["name", "version"],
+          "additionalProperties": false,
+          "properties": {
+            "name": {"type": "string"},
+            "version": {"type": "string"}
+          }
+        },
+        "annotations": {"type": "object", "additionalProperties": {"type": "string"}}
+      }
+    },
+    "summary": {
+      "type": "object",
+      "required": ["passed", "failed"],
+      "additionalProperties": false,
+      "properties": {
+        "passed": {"type": "integer", "minimum": 0},
+        "failed": {"type": "integer", "minimum": 0},
+        "skipped": {"type": "integer", "minimum": 0}
+      }
+    },
+    "results": {"type": "object", "additionalProperties": {"$ref": "#/definitions/checkResult"}},
+    "chart-metadata": {"type": "object"}
+  },
+  "definitions": {
+    "finding": {
+      "type": "object",
+      "required": ["message", "severity"],
+      "additionalProperties": false,
+      "properties": {
+        "resource": {"type": "string"},
+        "field": {"type": "string"},
+        "message": {"type": "string"},
+        "severity": {"enum": ["error", "warning", "info"]}
+      }
+    },
+    "findings": {"type": "array", "items": {"$ref": "#/definitions/finding"}},
+    "suggestion": {
+      "type": "object",
+      "required": ["resource", "description", "patch"],
+      "additionalProperties": false,
+      "properties": {
+        "resource": {"type": "string"},
+        "description": {"type": "string"},
+        "patch": {"type": "string"}
+      }
+    },
+    "checkResult": {
+      "type": "object",
+      "required": ["ok", "reason", "type"],
+      "additionalProperties": false,
+      "properties": {
+        "ok": {"type": "boolean"},
+        "reason": {"type": "string"},
+        "type": {"enum": ["mandatory", "optional"]},
+        "findings": {"$ref": "#/definitions/findings"},
+        "suggestions": {"type": "array", "items": {"$ref": "#/definitions/suggestion"}},
+        "skipped": {"type": "boolean"},
+        "known": {"type": "boolean"},
+        "openshift-versions": {
+          "type": "object",
+          "additionalProperties": {
+            "type": "object",
+            "required": ["ok", "reason"],
+            "additionalProperties": false,
+            "properties": {
+              "ok": {"type": "boolean"},
+              "reason": {"type": "string"}
+            }
+          }
+        },
+        "values-profiles": {
+          "type": "object",
+          "additionalProperties": {
+            "type": "object",
+            "required": ["ok", "reason"],
+            "additionalProperties": false,
+            "properties": {
+              "ok": {"type": "boolean"},
+              "reason": {"type": "string"},
+              "findings": {"$ref": "#/definitions/findings"}
+            }
+          }
+        }
+      }
+    }
+  }
+}
+`
+
+// ReportSchemaViolationErr is returned when a report doesn't conform to ReportSchema.
+type ReportSchemaViolationErr struct {
+	// Violations describes each violation, prefixed by the path of the offending field, e.g.
+	// "summary.passed: Invalid type. Expected: integer, given: string".
+	Violations []string
+}
+
+func (e ReportSchemaViolationErr) Error() string {
+	return "report does not conform to the report schema:\n\t" + strings.Join(e.Violations, "\n\t")
+}
+
+// ValidateReportSchema checks the JSON serialization of r conforms to ReportSchema, returning a
+// ReportSchemaViolationErr naming the offending fields otherwise.
+func ValidateReportSchema(r *Report) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return validateReportDocument(b)
+}
+
+// validateReportDocument checks the given JSON document conforms to ReportSchema.
+func validateReportDocument(b []byte) error {
+	result, err := gojsonschema.Validate(gojsonschema.NewStringLoader(ReportSchema), gojsonschema.NewBytesLoader(b))
+	if err != nil {
+		return err
+	}
+	if result.Valid() {
+		return nil
+	}
+
+	violations := make([]string, len(result.Errors()))
+	for i, e := range result.Errors() {
+		violations[i] = e.Field() + ": " + e.Description()
+	}
+	return ReportSchemaViolationErr{Violations: violations}
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
+)
+
+func TestValidateReportSchema(t *testing.T) {
+	verify := func(t *testing.T) *Report {
+		c, err := NewCertifierBuilder().
+			SetChecks([]string{"is-helm-v3", "has-readme", "has-minkubeversion", "values-consistent-casing"}).
+			SetOpenShiftVersions([]string{"4.7", "4.8"}).
+			SetAnnotations(map[string]string{"ticket": "CERT-123"}).
+			Build()
+		require.NoError(t, err)
+		r, err := c.Verify(context.Background(), "./checks/chart-0.1.0-v3.values-casing.tgz")
+		require.NoError(t, err)
+		return r
+	}
+
+	t.Run("Should accept a report produced by the verifier", func(t *testing.T) {
+		require.NoError(t, ValidateReportSchema(verify(t)))
+	})
+
+	t.Run("Should name the fields of a corrupted report", func(t *testing.T) {
+		r := verify(t)
+		result := r.CheckResultMap["has-readme"]
+		result.Type = "required"
+		result.Findings = []checks.Finding{{Message: "README.md is missing", Severity: "fatal"}}
+		r.CheckResultMap["has-readme"] = result
+
+		err := ValidateReportSchema(r)
+		require.Error(t, err)
+		violationErr, ok := err.(ReportSchemaViolationErr)
+		require.True(t, ok)
+		require.ElementsMatch(t, []string{
+			`results.has-readme.type: results.has-readme.type must be one of the following: "mandatory", "optional"`,
+			`results.has-readme.findings.0.severity: results.has-readme.findings.0.severity must be one of the following: "error", "warning", "info"`,
+		}, violationErr.Violations)
+	})
+
+	t.Run("Should name the fields of a corrupted report document", func(t *testing.T) {
+		b, err := json.Marshal(verify(t))
+		require.NoError(t, err)
+
+		var document map[string]interface{}
+		require.NoError(t, json.Unmarshal(b, &document))
+		document["summary"].(map[string]interface{})["passed"] = "two"
+		delete(document["metadata"].(map[string]interface{})["tool"].(map[string]interface{}), "chart-uri")
+		document["outcome"] = "passed"
+		b, err = json.Marshal(document)
+		require.NoError(t, err)
+
+		err = validateReportDocument(b)
+		require.Error(t, err)
+		violationErr, ok := err.(ReportSchemaViolationErr)
+		require.True(t, ok)
+		require.ElementsMatch(t, []string{
+			"(root): Additional property outcome is not allowed",
+			"metadata.tool: chart-uri is required",
+			"summary.passed: Invalid type. Expected: integer, given: string",
+		}, violationErr.Violations)
+	})
+}
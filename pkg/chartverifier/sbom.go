@@ -0,0 +1,256 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+)
+
+const (
+	CycloneDXFormat      = "CycloneDX"
+	CycloneDXSpecVersion = "1.4"
+
+	// cycloneDXPropertyPrefix namespaces the properties carrying verification results.
+	cycloneDXPropertyPrefix = "chart-verifier:"
+)
+
+// CycloneDXDocument is a CycloneDX BOM describing the verified chart and its images, each image being linked to the
+// SBOMs describing its components, and carrying the verification outcome as properties of the chart component.
+type CycloneDXDocument struct {
+	BOMFormat    string                `json:"bomFormat"`
+	SpecVersion  string                `json:"specVersion"`
+	Version      int                   `json:"version"`
+	Metadata     CycloneDXMetadata     `json:"metadata"`
+	Components   []CycloneDXComponent  `json:"components,omitempty"`
+	Dependencies []CycloneDXDependency `json:"dependencies,omitempty"`
+}
+
+type CycloneDXMetadata struct {
+	Timestamp string              `json:"timestamp,omitempty"`
+	Tools     []CycloneDXTool     `json:"tools,omitempty"`
+	Component *CycloneDXComponent `json:"component,omitempty"`
+}
+
+type CycloneDXTool struct {
+	Vendor  string `json:"vendor,omitempty"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type CycloneDXComponent struct {
+	BOMRef             string                       `json:"bom-ref,omitempty"`
+	Type               string                       `json:"type"`
+	Name               string                       `json:"name"`
+	Version            string                       `json:"version,omitempty"`
+	Hashes             []CycloneDXHash              `json:"hashes,omitempty"`
+	ExternalReferences []CycloneDXExternalReference `json:"externalReferences,omitempty"`
+	Properties         []CycloneDXProperty          `json:"properties,omitempty"`
+}
+
+type CycloneDXHash struct {
+	Algorithm string `json:"alg"`
+	Content   string `json:"content"`
+}
+
+// CycloneDXExternalReference references a document related to a component; SBOMs are referenced with type "bom".
+type CycloneDXExternalReference struct {
+	Type    string          `json:"type"`
+	URL     string          `json:"url"`
+	Comment string          `json:"comment,omitempty"`
+	Hashes  []CycloneDXHash `json:"hashes,omitempty"`
+}
+
+type CycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type CycloneDXDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// SBOM is a CycloneDX SBOM the verification report is linked to.
+type SBOM struct {
+	// Path is the file the SBOM has been loaded from.
+	Path string
+	// Image is the image the SBOM describes, either informed when loading it or found in its metadata; SBOMs not
+	// describing one of the chart's images are linked to the chart itself.
+	Image string
+
+	serialNumber string
+	version      int
+	componentRef string
+	digest       string
+}
+
+// sbomDocument is the subset of CycloneDX SBOMs needed to link them.
+type sbomDocument struct {
+	BOMFormat    string `json:"bomFormat"`
+	SerialNumber string `json:"serialNumber"`
+	Version      int    `json:"version"`
+	Metadata     struct {
+		Component *struct {
+			BOMRef  string `json:"bom-ref"`
+			Type    string `json:"type"`
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"component"`
+	} `json:"metadata"`
+}
+
+// LoadSBOM loads the CycloneDX SBOM, in the JSON format, found at path; image informs the image the SBOM describes,
+// and can be left empty when the SBOM's metadata describes a container.
+func LoadSBOM(path, image string) (SBOM, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return SBOM{}, err
+	}
+
+	var doc sbomDocument
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return SBOM{}, fmt.Errorf("failed parsing SBOM %s: %v", path, err)
+	}
+	if doc.BOMFormat != CycloneDXFormat {
+		return SBOM{}, fmt.Errorf("SBOM %s is not a CycloneDX document", path)
+	}
+
+	digest := sha256.Sum256(b)
+	s := SBOM{Path: path, Image: image, serialNumber: doc.SerialNumber, version: doc.Version, digest: hex.EncodeToString(digest[:])}
+	if c := doc.Metadata.Component; c != nil {
+		s.componentRef = c.BOMRef
+		if s.Image == "" && c.Type == "container" {
+			s.Image = c.Name
+			if c.Version != "" && !strings.Contains(c.Name, "@") && !strings.Contains(c.Name[strings.LastIndex(c.Name, "/")+1:], ":") {
+				// digests are the version of containers pulled by digest, e.g. "sha256:..."
+				if strings.Contains(c.Version, ":") {
+					s.Image += "@" + c.Version
+				} else {
+					s.Image += ":" + c.Version
+				}
+			}
+		}
+	}
+	return s, nil
+}
+
+// reference returns the external reference linking to the SBOM: a BOM-Link to the component the SBOM describes when
+// the SBOM has a serial number, or the SBOM's path otherwise.
+func (s SBOM) reference() CycloneDXExternalReference {
+	url := s.Path
+	if s.serialNumber != "" {
+		url = fmt.Sprintf("urn:cdx:%s/%d", strings.TrimPrefix(s.serialNumber, "urn:uuid:"), s.version)
+		if s.componentRef != "" {
+			url += "#" + s.componentRef
+		}
+	}
+	return CycloneDXExternalReference{
+		Type:    "bom",
+		URL:     url,
+		Comment: s.Path,
+		Hashes:  []CycloneDXHash{{Algorithm: "SHA-256", Content: s.digest}},
+	}
+}
+
+// describes informs whether the SBOM describes the given image; images from Docker Hub may omit their registry.
+func (s SBOM) describes(image string) bool {
+	return s.Image == image || strings.TrimPrefix(s.Image, "docker.io/") == image ||
+		strings.TrimPrefix(s.Image, "docker.io/library/") == image
+}
+
+// NewCycloneDXDocument describes the verification of chrt, whose outcome is informed by c, along with the images it
+// references, linking each image to the SBOMs describing it.
+func NewCycloneDXDocument(c Certificate, chrt *chart.Chart, images []string, sboms []SBOM) (CycloneDXDocument, error) {
+	cert, ok := c.(*certificate)
+	if !ok {
+		return CycloneDXDocument{}, fmt.Errorf("unsupported certificate type %T", c)
+	}
+
+	chartComponent := &CycloneDXComponent{
+		BOMRef:  fmt.Sprintf("chart:%s@%s", chrt.Name(), chrt.Metadata.Version),
+		Type:    "application",
+		Name:    chrt.Name(),
+		Version: chrt.Metadata.Version,
+		Hashes:  []CycloneDXHash{{Algorithm: "SHA-256", Content: strings.TrimPrefix(chartDigest(chrt), "sha256:")}},
+		Properties: []CycloneDXProperty{
+			{Name: cycloneDXPropertyPrefix + "ok", Value: fmt.Sprint(cert.Ok)},
+			{Name: cycloneDXPropertyPrefix + "passed", Value: fmt.Sprint(cert.Summary.Passed)},
+			{Name: cycloneDXPropertyPrefix + "failed", Value: fmt.Sprint(cert.Summary.Failed)},
+			{Name: cycloneDXPropertyPrefix + "skipped", Value: fmt.Sprint(cert.Summary.Skipped)},
+		},
+	}
+
+	names := make([]string, 0, len(cert.CheckResultMap))
+	for name := range cert.CheckResultMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		outcome := "passed"
+		if result := cert.CheckResultMap[name]; result.Skipped {
+			outcome = "skipped"
+		} else if !result.Ok {
+			outcome = "failed"
+		}
+		chartComponent.Properties = append(chartComponent.Properties, CycloneDXProperty{Name: cycloneDXPropertyPrefix + "check:" + name, Value: outcome})
+	}
+
+	sortedImages := append([]string(nil), images...)
+	sort.Strings(sortedImages)
+
+	linked := make([]bool, len(sboms))
+	dependency := CycloneDXDependency{Ref: chartComponent.BOMRef}
+	var components []CycloneDXComponent
+	for _, image := range sortedImages {
+		component := CycloneDXComponent{BOMRef: "image:" + image, Type: "container", Name: image}
+		for i, s := range sboms {
+			if s.describes(image) {
+				component.ExternalReferences = append(component.ExternalReferences, s.reference())
+				linked[i] = true
+			}
+		}
+		components = append(components, component)
+		dependency.DependsOn = append(dependency.DependsOn, component.BOMRef)
+	}
+
+	for i, s := range sboms {
+		if !linked[i] {
+			chartComponent.ExternalReferences = append(chartComponent.ExternalReferences, s.reference())
+		}
+	}
+
+	return CycloneDXDocument{
+		BOMFormat:   CycloneDXFormat,
+		SpecVersion: CycloneDXSpecVersion,
+		Version:     1,
+		Metadata: CycloneDXMetadata{
+			Timestamp: cert.Metadata.RunMetadata.GeneratedAt,
+			Tools:     []CycloneDXTool{{Vendor: "Red Hat", Name: "chart-verifier", Version: cert.Metadata.RunMetadata.Version}},
+			Component: chartComponent,
+		},
+		Components:   components,
+		Dependencies: []CycloneDXDependency{dependency},
+	}, nil
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
+)
+
+// writeSBOM writes a CycloneDX SBOM describing the given component to a temporary file, returning its path.
+func writeSBOM(t *testing.T, serialNumber string, component map[string]interface{}) string {
+	b, err := json.Marshal(map[string]interface{}{
+		"bomFormat":    "CycloneDX",
+		"specVersion":  "1.4",
+		"serialNumber": serialNumber,
+		"version":      1,
+		"metadata":     map[string]interface{}{"component": component},
+		"components":   []interface{}{map[string]interface{}{"bom-ref": "pkg:deb/debian/openssl@1.1.1", "type": "library", "name": "openssl"}},
+	})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "sbom.cdx.json")
+	require.NoError(t, ioutil.WriteFile(path, b, 0644))
+	return path
+}
+
+func TestLoadSBOM(t *testing.T) {
+	t.Run("Should find the image described by the SBOM's metadata", func(t *testing.T) {
+		path := writeSBOM(t, "", map[string]interface{}{"type": "container", "name": "quay.io/team/app", "version": "1.0"})
+		s, err := LoadSBOM(path, "")
+		require.NoError(t, err)
+		require.Equal(t, "quay.io/team/app:1.0", s.Image)
+
+		path = writeSBOM(t, "", map[string]interface{}{"type": "container", "name": "quay.io/team/app", "version": "sha256:abc"})
+		s, err = LoadSBOM(path, "")
+		require.NoError(t, err)
+		require.Equal(t, "quay.io/team/app@sha256:abc", s.Image)
+	})
+
+	t.Run("Should prefer the informed image", func(t *testing.T) {
+		path := writeSBOM(t, "", map[string]interface{}{"type": "container", "name": "quay.io/team/app", "version": "1.0"})
+		s, err := LoadSBOM(path, "nginx:1.16.0")
+		require.NoError(t, err)
+		require.Equal(t, "nginx:1.16.0", s.Image)
+	})
+
+	t.Run("Should fail when the SBOM is not a CycloneDX document", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "sbom.spdx.json")
+		require.NoError(t, ioutil.WriteFile(path, []byte(`{"spdxVersion":"SPDX-2.2"}`), 0644))
+		_, err := LoadSBOM(path, "")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "is not a CycloneDX document")
+	})
+}
+
+func TestNewCycloneDXDocument(t *testing.T) {
+	uri := "./checks/chart-0.1.0-v3.valid.tgz"
+
+	c, err := NewCertifierBuilder().
+		SetChecks([]string{"is-helm-v3", "has-readme", "has-minkubeversion"}).
+		SetOpenShiftVersions([]string{"4.7", "4.8"}).
+		SetClock(func() time.Time { return time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC) }).
+		SetToolVersion("1.2.3").
+		Build()
+	require.NoError(t, err)
+	cert, err := c.Certify(uri)
+	require.NoError(t, err)
+
+	chrt, _, err := checks.LoadChartFromURI(uri)
+	require.NoError(t, err)
+	images, err := checks.GetImageReferences(uri, "")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"busybox", "nginx:1.16.0"}, images)
+
+	imageSBOM, err := LoadSBOM(writeSBOM(t, "urn:uuid:3e671687-395b-41f5-a30f-a58921a69b79",
+		map[string]interface{}{"bom-ref": "nginx-ref", "type": "container", "name": "docker.io/library/nginx", "version": "1.16.0"}), "")
+	require.NoError(t, err)
+	chartSBOM, err := LoadSBOM(writeSBOM(t, "", map[string]interface{}{"type": "application", "name": "chart"}), "")
+	require.NoError(t, err)
+
+	doc, err := NewCycloneDXDocument(cert, chrt, images, []SBOM{imageSBOM, chartSBOM})
+	require.NoError(t, err)
+
+	// round trip through JSON, as consumers see the document
+	b, err := json.Marshal(doc)
+	require.NoError(t, err)
+	var parsed map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &parsed))
+	require.Equal(t, "CycloneDX", parsed["bomFormat"])
+	require.Equal(t, "1.4", parsed["specVersion"])
+	require.Equal(t, float64(1), parsed["version"])
+
+	require.Equal(t, "2021-03-04T05:06:07Z", doc.Metadata.Timestamp)
+	require.Equal(t, []CycloneDXTool{{Vendor: "Red Hat", Name: "chart-verifier", Version: "1.2.3"}}, doc.Metadata.Tools)
+
+	component := doc.Metadata.Component
+	require.Equal(t, "chart:chart@0.1.0-v3.valid", component.BOMRef)
+	require.Equal(t, "application", component.Type)
+	require.Regexp(t, "^[0-9a-f]{64}$", component.Hashes[0].Content)
+	require.Contains(t, component.Properties, CycloneDXProperty{Name: "chart-verifier:ok", Value: "false"})
+	require.Contains(t, component.Properties, CycloneDXProperty{Name: "chart-verifier:check:is-helm-v3", Value: "passed"})
+	require.Contains(t, component.Properties, CycloneDXProperty{Name: "chart-verifier:check:has-minkubeversion", Value: "failed"})
+	require.Equal(t, []CycloneDXExternalReference{chartSBOM.reference()}, component.ExternalReferences)
+	require.Equal(t, chartSBOM.Path, component.ExternalReferences[0].URL)
+
+	require.Len(t, doc.Components, 2)
+	require.Equal(t, CycloneDXComponent{BOMRef: "image:busybox", Type: "container", Name: "busybox"}, doc.Components[0])
+	nginx := doc.Components[1]
+	require.Equal(t, "image:nginx:1.16.0", nginx.BOMRef)
+	require.Equal(t, "container", nginx.Type)
+	require.Len(t, nginx.ExternalReferences, 1)
+	require.Equal(t, "bom", nginx.ExternalReferences[0].Type)
+	require.Equal(t, "urn:cdx:3e671687-395b-41f5-a30f-a58921a69b79/1#nginx-ref", nginx.ExternalReferences[0].URL)
+	require.Regexp(t, "^[0-9a-f]{64}$", nginx.ExternalReferences[0].Hashes[0].Content)
+
+	require.Equal(t, []CycloneDXDependency{{Ref: component.BOMRef, DependsOn: []string{"image:busybox", "image:nginx:1.16.0"}}}, doc.Dependencies)
+}
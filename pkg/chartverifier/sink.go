@@ -0,0 +1,132 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ReportSink is a destination receiving the serialized report.
+type ReportSink struct {
+	// Name identifies the sink in error messages, e.g: stdout or webhook=https://ci.example.com/hooks.
+	Name string
+	// Writer receives the report; it is closed once the report has been written when it is also an io.Closer.
+	Writer io.Writer
+	// Required makes the emission fail when the report can't be delivered to the sink.
+	Required bool
+}
+
+// SinkErr is returned when the report couldn't be delivered to a sink.
+type SinkErr struct {
+	Sink string
+	Err  error
+}
+
+func (e SinkErr) Error() string {
+	return fmt.Sprintf("sink %s: %v", e.Sink, e.Err)
+}
+
+// EmitReport writes report to every sink, regardless of failures of the others. The failures of optional sinks are
+// returned to be reported, while an error is returned once at least one required sink fails.
+func EmitReport(report []byte, sinks []ReportSink) ([]error, error) {
+	var failures []error
+	var requiredFailures []string
+	for _, sink := range sinks {
+		err := writeToSink(report, sink.Writer)
+		if err == nil {
+			continue
+		}
+		err = SinkErr{Sink: sink.Name, Err: err}
+		if sink.Required {
+			requiredFailures = append(requiredFailures, err.Error())
+		} else {
+			failures = append(failures, err)
+		}
+	}
+	if len(requiredFailures) > 0 {
+		return failures, fmt.Errorf("failed emitting the report: %s", strings.Join(requiredFailures, "; "))
+	}
+	return failures, nil
+}
+
+func writeToSink(report []byte, w io.Writer) error {
+	_, err := w.Write(report)
+	if c, ok := w.(io.Closer); ok {
+		if closeErr := c.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// fileSink creates the file it writes to on the first write, so an optional file sink that can't be created doesn't
+// keep the report from reaching the other sinks.
+type fileSink struct {
+	path string
+	f    *os.File
+}
+
+// NewFileSink returns a sink writing the report to the file at path, replacing any existing content.
+func NewFileSink(path string) io.WriteCloser {
+	return &fileSink{path: path}
+}
+
+func (s *fileSink) Write(p []byte) (int, error) {
+	if s.f == nil {
+		f, err := os.Create(s.path)
+		if err != nil {
+			return 0, err
+		}
+		s.f = f
+	}
+	return s.f.Write(p)
+}
+
+func (s *fileSink) Close() error {
+	if s.f == nil {
+		return nil
+	}
+	return s.f.Close()
+}
+
+// webhookSink buffers the report and posts it to the webhook once closed.
+type webhookSink struct {
+	url         string
+	contentType string
+	buf         bytes.Buffer
+}
+
+// NewWebhookSink returns a sink posting the report to the given webhook url with the given content type, retrying on
+// transient failures as Notify does.
+func NewWebhookSink(url string, contentType string) io.WriteCloser {
+	return &webhookSink{url: url, contentType: contentType}
+}
+
+func (s *webhookSink) Write(p []byte) (int, error) {
+	return s.buf.Write(p)
+}
+
+func (s *webhookSink) Close() error {
+	headers := http.Header{}
+	headers.Set("Content-Type", s.contentType)
+	return deliverReport(s.url, headers, s.buf.Bytes())
+}
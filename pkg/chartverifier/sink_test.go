@@ -0,0 +1,97 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// failingWriter rejects everything written to it.
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestEmitReport(t *testing.T) {
+	report := []byte(`{"ok":true}`)
+
+	t.Run("Should write the report to all sinks", func(t *testing.T) {
+		var received []byte
+		var contentType string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			contentType = r.Header.Get("Content-Type")
+			received, _ = ioutil.ReadAll(r.Body)
+		}))
+		defer server.Close()
+
+		var stdout bytes.Buffer
+		path := filepath.Join(t.TempDir(), "report.json")
+		failures, err := EmitReport(report, []ReportSink{
+			{Name: "stdout", Writer: &stdout},
+			{Name: "file", Writer: NewFileSink(path)},
+			{Name: "webhook", Writer: NewWebhookSink(server.URL, "application/json")},
+		})
+		require.NoError(t, err)
+		require.Empty(t, failures)
+
+		require.Equal(t, report, stdout.Bytes())
+		written, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, report, written)
+		require.Equal(t, report, received)
+		require.Equal(t, "application/json", contentType)
+	})
+
+	t.Run("Should keep writing to the other sinks when an optional sink fails", func(t *testing.T) {
+		var stdout bytes.Buffer
+		failures, err := EmitReport(report, []ReportSink{
+			{Name: "broken", Writer: failingWriter{}},
+			{Name: "missing", Writer: NewFileSink(filepath.Join(t.TempDir(), "missing", "report.json"))},
+			{Name: "stdout", Writer: &stdout},
+		})
+		require.NoError(t, err)
+		require.Len(t, failures, 2)
+		require.Equal(t, "sink broken: disk full", failures[0].Error())
+		require.Equal(t, report, stdout.Bytes())
+	})
+
+	t.Run("Should fail once all sinks have been written to when a required sink fails", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		var stdout bytes.Buffer
+		failures, err := EmitReport(report, []ReportSink{
+			{Name: "webhook", Writer: NewWebhookSink(server.URL, "application/json"), Required: true},
+			{Name: "stdout", Writer: &stdout},
+		})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "sink webhook")
+		require.Empty(t, failures)
+		require.Equal(t, report, stdout.Bytes())
+	})
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
+)
+
+const (
+	// StreamEventResult identifies the lines of a result stream carrying the result of a single check.
+	StreamEventResult = "result"
+	// StreamEventSummary identifies the line closing a result stream, carrying the outcome of the verification.
+	StreamEventSummary = "summary"
+)
+
+// StreamedResult is the line written to a result stream as soon as a check completes.
+type StreamedResult struct {
+	Event    string           `json:"event"`
+	Check    string           `json:"check"`
+	Ok       bool             `json:"ok"`
+	Reason   string           `json:"reason"`
+	Findings []checks.Finding `json:"findings,omitempty"`
+}
+
+// StreamedSummary is the line closing a result stream once the verification has finished.
+type StreamedSummary struct {
+	Event   string  `json:"event"`
+	Ok      bool    `json:"ok"`
+	Summary summary `json:"summary"`
+}
+
+// ResultStream writes check results as newline delimited JSON as they complete, followed by a summary line once the
+// verification has finished.
+type ResultStream struct {
+	mutex   sync.Mutex
+	encoder *json.Encoder
+	// err is the first error writing to the stream; subsequent lines are discarded.
+	err error
+}
+
+// NewResultStream creates a ResultStream writing to w.
+func NewResultStream(w io.Writer) *ResultStream {
+	return &ResultStream{encoder: json.NewEncoder(w)}
+}
+
+func (s *ResultStream) encode(v interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.err == nil {
+		s.err = s.encoder.Encode(v)
+	}
+}
+
+// OnCheckComplete writes the result of the given check to the stream; it is meant to be informed to
+// CertifierBuilder.SetOnCheckComplete.
+func (s *ResultStream) OnCheckComplete(name string, r checks.Result) {
+	s.encode(StreamedResult{
+		Event:    StreamEventResult,
+		Check:    name,
+		Ok:       r.Ok,
+		Reason:   r.Reason,
+		Findings: r.Findings,
+	})
+}
+
+// Close writes the summary of the given certificate to the stream, returning the first error writing to it.
+func (s *ResultStream) Close(c Certificate) error {
+	line := StreamedSummary{Event: StreamEventSummary, Ok: c.IsOk()}
+	if cert, ok := c.(*certificate); ok {
+		line.Summary = cert.Summary
+	}
+	s.encode(line)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.err
+}
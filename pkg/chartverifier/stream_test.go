@@ -0,0 +1,93 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
+)
+
+func TestResultStream(t *testing.T) {
+
+	t.Run("Should write each completed check as a line followed by a summary line", func(t *testing.T) {
+		var out bytes.Buffer
+		stream := NewResultStream(&out)
+
+		certifier, err := NewCertifierBuilder().
+			SetChecks([]string{"is-helm-v3", "has-readme"}).
+			SetOnCheckComplete(stream.OnCheckComplete).
+			Build()
+		require.NoError(t, err)
+
+		cert, err := certifier.Certify("checks/chart-0.1.0-v3.valid.tgz")
+		require.NoError(t, err)
+		require.NoError(t, stream.Close(cert))
+
+		var lines []map[string]interface{}
+		scanner := bufio.NewScanner(&out)
+		for scanner.Scan() {
+			var line map[string]interface{}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &line), scanner.Text())
+			lines = append(lines, line)
+		}
+		require.Len(t, lines, 3)
+
+		streamed := map[string]bool{}
+		for _, line := range lines[:2] {
+			require.Equal(t, StreamEventResult, line["event"])
+			streamed[line["check"].(string)] = line["ok"].(bool)
+		}
+		require.Equal(t, map[string]bool{"is-helm-v3": true, "has-readme": true}, streamed)
+
+		require.Equal(t, StreamEventSummary, lines[2]["event"])
+		require.Equal(t, true, lines[2]["ok"])
+		require.Equal(t, map[string]interface{}{"passed": float64(2), "failed": float64(0)}, lines[2]["summary"])
+	})
+
+	t.Run("Should include findings in the streamed result", func(t *testing.T) {
+		var out bytes.Buffer
+		stream := NewResultStream(&out)
+		stream.OnCheckComplete("dummy", checks.Result{
+			Ok:       false,
+			Reason:   "Dummy failed",
+			Findings: []checks.Finding{{Resource: "Deployment/dummy", Message: "dummy", Severity: checks.ErrorSeverity}},
+		})
+
+		var line StreamedResult
+		require.NoError(t, json.Unmarshal(out.Bytes(), &line))
+		require.Equal(t, StreamedResult{
+			Event:    StreamEventResult,
+			Check:    "dummy",
+			Reason:   "Dummy failed",
+			Findings: []checks.Finding{{Resource: "Deployment/dummy", Message: "dummy", Severity: checks.ErrorSeverity}},
+		}, line)
+	})
+
+	t.Run("Should return the error writing to the stream", func(t *testing.T) {
+		stream := NewResultStream(failingWriter{})
+		stream.OnCheckComplete("dummy", checks.NewResult(true, "Dummy passed"))
+		err := stream.Close(&certificate{Ok: true})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "disk full")
+	})
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FormatSuggestions returns the fixes suggested by the checks of the given certificate as a stream of YAML
+// documents, one patch per document, each preceded by comments naming the check, the resource to patch and the fix;
+// checks are listed by name. An empty string is returned when no fix has been suggested.
+func FormatSuggestions(c Certificate) string {
+	cert, ok := c.(*certificate)
+	if !ok {
+		return ""
+	}
+
+	names := make([]string, 0, len(cert.CheckResultMap))
+	for name := range cert.CheckResultMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var documents []string
+	for _, name := range names {
+		for _, s := range cert.CheckResultMap[name].Suggestions {
+			documents = append(documents, fmt.Sprintf("# %s : %s\n# %s\n%s", name, s.Resource, s.Description, s.Patch))
+		}
+	}
+	return strings.Join(documents, "---\n")
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package chartverifier
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/redhat-certification/chart-verifier/pkg/chartverifier/checks"
+)
+
+func TestFormatSuggestions(t *testing.T) {
+	t.Run("Should format one YAML document per suggestion", func(t *testing.T) {
+		cert := &certificate{CheckResultMap: checkResultMap{
+			"revision-history-bounded": {Suggestions: []checks.Suggestion{
+				{Resource: "Deployment/b", Description: "Keep at most 10 old ReplicaSets", Patch: "spec:\n  revisionHistoryLimit: 10\n"},
+			}},
+			"appversion-matches-image-tag": {Suggestions: []checks.Suggestion{
+				{Resource: "Deployment/a", Description: "Tag image nginx with the chart's appVersion 1.16.0", Patch: "spec:\n  template: {}\n"},
+			}},
+			"has-readme": {Ok: true},
+		}}
+
+		out := FormatSuggestions(cert)
+		require.Equal(t, "# appversion-matches-image-tag : Deployment/a\n"+
+			"# Tag image nginx with the chart's appVersion 1.16.0\n"+
+			"spec:\n  template: {}\n"+
+			"---\n"+
+			"# revision-history-bounded : Deployment/b\n"+
+			"# Keep at most 10 old ReplicaSets\n"+
+			"spec:\n  revisionHistoryLimit: 10\n", out)
+
+		decoder := yaml.NewDecoder(strings.NewReader(out))
+		documents := 0
+		for {
+			var doc map[string]interface{}
+			err := decoder.Decode(&doc)
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			require.Contains(t, doc, "spec")
+			documents++
+		}
+		require.Equal(t, 2, documents)
+	})
+
+	t.Run("Should format nothing when no fix has been suggested", func(t *testing.T) {
+		require.Empty(t, FormatSuggestions(&certificate{CheckResultMap: checkResultMap{"has-readme": {Ok: true}}}))
+	})
+}
@@ -8,40 +8,24 @@ import (
 	"strings"
 
 	"helm.sh/helm/v3/pkg/action"
-	"helm.sh/helm/v3/pkg/chart/loader"
-	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/releaseutil"
 )
 
-func RenderManifests(name string, url string, vals map[string]interface{}, conf *action.Configuration) (string, error) {
+func RenderManifests(name string, ch *chart.Chart, vals map[string]interface{}, conf *action.Configuration) (string, error) {
 
 	var showFiles []string
 	response := make(map[string]string)
-	validate := false
 	client := action.NewInstall(conf)
-	client.DryRun = false
+	// A dry run against the client in conf, rather than a client only install, so the release is rendered with the
+	// capabilities in conf; client only installs always render with Helm's default capabilities.
+	client.DryRun = true
 	includeCrds := true
-	client.ReleaseName = "RELEASE-NAME"
+	client.ReleaseName = name
 	client.Replace = true // Skip the releaseName check
-	client.ClientOnly = !validate
+	client.ClientOnly = false
 	emptyResponse := ""
 
-	name, chart, err := client.NameAndChart([]string{name, url})
-	if err != nil {
-		return emptyResponse, err
-	}
-	client.ReleaseName = name
-
-	cp, err := client.ChartPathOptions.LocateChart(chart, cli.New())
-	if err != nil {
-		return emptyResponse, err
-	}
-
-	ch, err := loader.Load(cp)
-	if err != nil {
-		return emptyResponse, err
-	}
-
 	rel, err := client.Run(ch, vals)
 	if err != nil {
 		return emptyResponse, err
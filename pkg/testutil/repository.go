@@ -0,0 +1,45 @@
+/*
+ * Copyright 2021 Red Hat
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package testutil
+
+import (
+	"path/filepath"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/provenance"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+// WriteChartRepository packages the given charts into dir, along with the index.yaml of a chart repository listing
+// them, so dir can be served as a chart repository by ServeCharts.
+func WriteChartRepository(dir string, charts ...*chart.Chart) error {
+	index := repo.NewIndexFile()
+	for _, c := range charts {
+		archive, err := chartutil.Save(c, dir)
+		if err != nil {
+			return err
+		}
+		digest, err := provenance.DigestFile(archive)
+		if err != nil {
+			return err
+		}
+		index.Add(c.Metadata, filepath.Base(archive), "", digest)
+	}
+	index.SortEntries()
+	return index.WriteFile(filepath.Join(dir, "index.yaml"), 0644)
+}